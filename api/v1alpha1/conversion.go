@@ -13,6 +13,260 @@ import (
 // exist in v1alpha1 and are NOT carried across conversion: the controller stopped
 // writing them in v0.4.0 and failures surface through the conditions instead.
 
+func convertInterfaceConfigsTo(src []InterfaceConfig) []infrav1.InterfaceConfig {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]infrav1.InterfaceConfig, 0, len(src))
+
+	for _, iface := range src {
+		converted := infrav1.InterfaceConfig{
+			Network:    iface.Network,
+			Addressing: infrav1.InterfaceAddressing(iface.Addressing),
+			Address:    iface.Address,
+			SubnetMask: iface.SubnetMask,
+			Gateway:    iface.Gateway,
+			IPPoolRefs: iface.IPPoolRefs,
+			MTU:        iface.MTU,
+		}
+
+		if iface.Routes != nil {
+			converted.Routes = make([]infrav1.Route, 0, len(iface.Routes))
+		}
+
+		for _, route := range iface.Routes {
+			converted.Routes = append(converted.Routes, infrav1.Route(route))
+		}
+
+		dst = append(dst, converted)
+	}
+
+	return dst
+}
+
+func convertInterfaceConfigsFrom(src []infrav1.InterfaceConfig) []InterfaceConfig {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]InterfaceConfig, 0, len(src))
+
+	for _, iface := range src {
+		converted := InterfaceConfig{
+			Network:    iface.Network,
+			Addressing: InterfaceAddressing(iface.Addressing),
+			Address:    iface.Address,
+			SubnetMask: iface.SubnetMask,
+			Gateway:    iface.Gateway,
+			IPPoolRefs: iface.IPPoolRefs,
+			MTU:        iface.MTU,
+		}
+
+		if iface.Routes != nil {
+			converted.Routes = make([]Route, 0, len(iface.Routes))
+		}
+
+		for _, route := range iface.Routes {
+			converted.Routes = append(converted.Routes, Route(route))
+		}
+
+		dst = append(dst, converted)
+	}
+
+	return dst
+}
+
+func convertInterfaceAllocationsTo(src []InterfaceAllocation) []infrav1.InterfaceAllocation {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]infrav1.InterfaceAllocation, 0, len(src))
+	for _, allocation := range src {
+		dst = append(dst, infrav1.InterfaceAllocation(allocation))
+	}
+
+	return dst
+}
+
+func convertInterfaceAllocationsFrom(src []infrav1.InterfaceAllocation) []InterfaceAllocation {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]InterfaceAllocation, 0, len(src))
+	for _, allocation := range src {
+		dst = append(dst, InterfaceAllocation(allocation))
+	}
+
+	return dst
+}
+
+func convertVolumeStatusesTo(src []VolumeStatus) []infrav1.VolumeStatus {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]infrav1.VolumeStatus, 0, len(src))
+	for _, volume := range src {
+		dst = append(dst, infrav1.VolumeStatus(volume))
+	}
+
+	return dst
+}
+
+func convertVolumeStatusesFrom(src []infrav1.VolumeStatus) []VolumeStatus {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]VolumeStatus, 0, len(src))
+	for _, volume := range src {
+		dst = append(dst, VolumeStatus(volume))
+	}
+
+	return dst
+}
+
+func convertImageSelectorTo(src *ImageSelector) *infrav1.ImageSelector {
+	if src == nil {
+		return nil
+	}
+
+	return &infrav1.ImageSelector{Namespace: src.Namespace, LabelSelector: *src.LabelSelector.DeepCopy()}
+}
+
+func convertImageSelectorFrom(src *infrav1.ImageSelector) *ImageSelector {
+	if src == nil {
+		return nil
+	}
+
+	return &ImageSelector{Namespace: src.Namespace, LabelSelector: *src.LabelSelector.DeepCopy()}
+}
+
+func convertImageSourceTo(src *ImageSource) *infrav1.ImageSource {
+	if src == nil {
+		return nil
+	}
+
+	dst := infrav1.ImageSource(*src)
+
+	return &dst
+}
+
+func convertImageSourceFrom(src *infrav1.ImageSource) *ImageSource {
+	if src == nil {
+		return nil
+	}
+
+	dst := ImageSource(*src)
+
+	return &dst
+}
+
+func convertVolumeCloneSourceTo(src *VolumeCloneSource) *infrav1.VolumeCloneSource {
+	if src == nil {
+		return nil
+	}
+
+	return &infrav1.VolumeCloneSource{Kind: infrav1.VolumeCloneSourceKind(src.Kind), Name: src.Name}
+}
+
+func convertVolumeCloneSourceFrom(src *infrav1.VolumeCloneSource) *VolumeCloneSource {
+	if src == nil {
+		return nil
+	}
+
+	return &VolumeCloneSource{Kind: VolumeCloneSourceKind(src.Kind), Name: src.Name}
+}
+
+func convertLeakedIPAllocationsTo(src []LeakedIPAllocation) []infrav1.LeakedIPAllocation {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]infrav1.LeakedIPAllocation, 0, len(src))
+	for _, allocation := range src {
+		dst = append(dst, infrav1.LeakedIPAllocation(allocation))
+	}
+
+	return dst
+}
+
+func convertLeakedIPAllocationsFrom(src []infrav1.LeakedIPAllocation) []LeakedIPAllocation {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]LeakedIPAllocation, 0, len(src))
+	for _, allocation := range src {
+		dst = append(dst, LeakedIPAllocation(allocation))
+	}
+
+	return dst
+}
+
+func convertMachineBackupsTo(src []MachineBackupStatus) []infrav1.MachineBackupStatus {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]infrav1.MachineBackupStatus, 0, len(src))
+	for _, backup := range src {
+		dst = append(dst, infrav1.MachineBackupStatus(backup))
+	}
+
+	return dst
+}
+
+func convertMachineBackupsFrom(src []infrav1.MachineBackupStatus) []MachineBackupStatus {
+	if src == nil {
+		return nil
+	}
+
+	dst := make([]MachineBackupStatus, 0, len(src))
+	for _, backup := range src {
+		dst = append(dst, MachineBackupStatus(backup))
+	}
+
+	return dst
+}
+
+func convertNetworkConfigTo(src *NetworkConfig) *infrav1.NetworkConfig {
+	if src == nil {
+		return nil
+	}
+
+	return &infrav1.NetworkConfig{
+		Address:          src.Address,
+		Gateway:          src.Gateway,
+		IPv6Address:      src.IPv6Address,
+		IPv6PrefixLength: src.IPv6PrefixLength,
+		IPv6Gateway:      src.IPv6Gateway,
+		DNSServers:       src.DNSServers,
+		DNSSearch:        src.DNSSearch,
+		Interfaces:       convertInterfaceConfigsTo(src.Interfaces),
+	}
+}
+
+func convertNetworkConfigFrom(src *infrav1.NetworkConfig) *NetworkConfig {
+	if src == nil {
+		return nil
+	}
+
+	return &NetworkConfig{
+		Address:          src.Address,
+		Gateway:          src.Gateway,
+		IPv6Address:      src.IPv6Address,
+		IPv6PrefixLength: src.IPv6PrefixLength,
+		IPv6Gateway:      src.IPv6Gateway,
+		DNSServers:       src.DNSServers,
+		DNSSearch:        src.DNSSearch,
+		Interfaces:       convertInterfaceConfigsFrom(src.Interfaces),
+	}
+}
+
 func convertVMNetworkConfigTo(src *VMNetworkConfig) *infrav1.VMNetworkConfig {
 	if src == nil {
 		return nil
@@ -25,6 +279,7 @@ func convertVMNetworkConfigTo(src *VMNetworkConfig) *infrav1.VMNetworkConfig {
 		SubnetMask: src.SubnetMask,
 		DNSServers: src.DNSServers,
 		DNSSearch:  src.DNSSearch,
+		Interfaces: convertInterfaceConfigsTo(src.Interfaces),
 	}
 
 	if src.IPPool != nil {
@@ -32,6 +287,11 @@ func convertVMNetworkConfigTo(src *VMNetworkConfig) *infrav1.VMNetworkConfig {
 		dst.IPPool = &pool
 	}
 
+	if src.IPv6 != nil {
+		ipv6 := infrav1.IPv6NetworkConfig(*src.IPv6)
+		dst.IPv6 = &ipv6
+	}
+
 	return &dst
 }
 
@@ -47,6 +307,7 @@ func convertVMNetworkConfigFrom(src *infrav1.VMNetworkConfig) *VMNetworkConfig {
 		SubnetMask: src.SubnetMask,
 		DNSServers: src.DNSServers,
 		DNSSearch:  src.DNSSearch,
+		Interfaces: convertInterfaceConfigsFrom(src.Interfaces),
 	}
 
 	if src.IPPool != nil {
@@ -54,9 +315,70 @@ func convertVMNetworkConfigFrom(src *infrav1.VMNetworkConfig) *VMNetworkConfig {
 		dst.IPPool = &pool
 	}
 
+	if src.IPv6 != nil {
+		ipv6 := IPv6NetworkConfig(*src.IPv6)
+		dst.IPv6 = &ipv6
+	}
+
 	return &dst
 }
 
+func convertProvisioningTimeoutTo(src *ProvisioningTimeout) *infrav1.ProvisioningTimeout {
+	if src == nil {
+		return nil
+	}
+
+	dst := infrav1.ProvisioningTimeout(*src)
+
+	return &dst
+}
+
+func convertProvisioningTimeoutFrom(src *infrav1.ProvisioningTimeout) *ProvisioningTimeout {
+	if src == nil {
+		return nil
+	}
+
+	dst := ProvisioningTimeout(*src)
+
+	return &dst
+}
+
+func convertBackupPolicyTo(src *BackupPolicy) *infrav1.BackupPolicy {
+	if src == nil {
+		return nil
+	}
+
+	dst := &infrav1.BackupPolicy{
+		Target:    infrav1.BackupTarget(src.Target),
+		Schedule:  src.Schedule,
+		Retention: src.Retention,
+	}
+
+	for _, role := range src.Roles {
+		dst.Roles = append(dst.Roles, infrav1.MachineRole(role))
+	}
+
+	return dst
+}
+
+func convertBackupPolicyFrom(src *infrav1.BackupPolicy) *BackupPolicy {
+	if src == nil {
+		return nil
+	}
+
+	dst := &BackupPolicy{
+		Target:    BackupTarget(src.Target),
+		Schedule:  src.Schedule,
+		Retention: src.Retention,
+	}
+
+	for _, role := range src.Roles {
+		dst.Roles = append(dst.Roles, MachineRole(role))
+	}
+
+	return dst
+}
+
 func convertClusterSpecTo(src *HarvesterClusterSpec) infrav1.HarvesterClusterSpec {
 	dst := infrav1.HarvesterClusterSpec{
 		Server:               src.Server,
@@ -65,11 +387,13 @@ func convertClusterSpecTo(src *HarvesterClusterSpec) infrav1.HarvesterClusterSpe
 		TargetNamespace:      src.TargetNamespace,
 		UpdateCloudProviderConfig: infrav1.UpdateCloudProviderConfig(
 			src.UpdateCloudProviderConfig),
+		IPPoolFreeThreshold: src.IPPoolFreeThreshold,
 		LoadBalancerConfig: infrav1.LoadBalancerConfig{
 			IPAMType:    infrav1.IPAMType(src.LoadBalancerConfig.IPAMType),
 			IpPoolRef:   src.LoadBalancerConfig.IpPoolRef,
 			IpPool:      infrav1.IpPool(src.LoadBalancerConfig.IpPool),
 			Description: src.LoadBalancerConfig.Description,
+			IPFamilies:  src.LoadBalancerConfig.IPFamilies,
 		},
 	}
 
@@ -81,6 +405,10 @@ func convertClusterSpecTo(src *HarvesterClusterSpec) infrav1.HarvesterClusterSpe
 	}
 
 	dst.VMNetworkConfig = convertVMNetworkConfigTo(src.VMNetworkConfig)
+	dst.ProvisioningTimeout = convertProvisioningTimeoutTo(src.ProvisioningTimeout)
+	dst.ShutdownGracePeriod = src.ShutdownGracePeriod
+	dst.DeletionPolicy = infrav1.DeletionPolicy(src.DeletionPolicy)
+	dst.BackupPolicy = convertBackupPolicyTo(src.BackupPolicy)
 
 	return dst
 }
@@ -92,11 +420,13 @@ func convertClusterSpecFrom(src *infrav1.HarvesterClusterSpec) HarvesterClusterS
 		ControlPlaneEndpoint:      src.ControlPlaneEndpoint,
 		TargetNamespace:           src.TargetNamespace,
 		UpdateCloudProviderConfig: UpdateCloudProviderConfig(src.UpdateCloudProviderConfig),
+		IPPoolFreeThreshold:       src.IPPoolFreeThreshold,
 		LoadBalancerConfig: LoadBalancerConfig{
 			IPAMType:    IPAMType(src.LoadBalancerConfig.IPAMType),
 			IpPoolRef:   src.LoadBalancerConfig.IpPoolRef,
 			IpPool:      IpPool(src.LoadBalancerConfig.IpPool),
 			Description: src.LoadBalancerConfig.Description,
+			IPFamilies:  src.LoadBalancerConfig.IPFamilies,
 		},
 	}
 
@@ -108,6 +438,10 @@ func convertClusterSpecFrom(src *infrav1.HarvesterClusterSpec) HarvesterClusterS
 	}
 
 	dst.VMNetworkConfig = convertVMNetworkConfigFrom(src.VMNetworkConfig)
+	dst.ProvisioningTimeout = convertProvisioningTimeoutFrom(src.ProvisioningTimeout)
+	dst.ShutdownGracePeriod = src.ShutdownGracePeriod
+	dst.DeletionPolicy = DeletionPolicy(src.DeletionPolicy)
+	dst.BackupPolicy = convertBackupPolicyFrom(src.BackupPolicy)
 
 	return dst
 }
@@ -131,20 +465,26 @@ func convertMachineSpecTo(src *HarvesterMachineSpec) infrav1.HarvesterMachineSpe
 
 	for _, v := range src.Volumes {
 		dst.Volumes = append(dst.Volumes, infrav1.Volume{
-			VolumeType:   infrav1.VolumeType(v.VolumeType),
-			ImageName:    v.ImageName,
-			StorageClass: v.StorageClass,
-			VolumeSize:   v.VolumeSize,
-			BootOrder:    v.BootOrder,
+			VolumeType:    infrav1.VolumeType(v.VolumeType),
+			ImageName:     v.ImageName,
+			ImageSource:   convertImageSourceTo(v.ImageSource),
+			ImageSelector: convertImageSelectorTo(v.ImageSelector),
+			StorageClass:  v.StorageClass,
+			CloneSource:   convertVolumeCloneSourceTo(v.CloneSource),
+			VolumeSize:    v.VolumeSize,
+			BootOrder:     v.BootOrder,
+			DeviceType:    infrav1.VolumeDeviceType(v.DeviceType),
+			Bus:           infrav1.DiskBus(v.Bus),
+			AccessMode:    v.AccessMode,
+			VolumeMode:    v.VolumeMode,
+			Cache:         infrav1.DiskCache(v.Cache),
+			IO:            infrav1.DiskIO(v.IO),
 		})
 	}
 
-	if src.NetworkConfig != nil {
-		network := infrav1.NetworkConfig(*src.NetworkConfig)
-		dst.NetworkConfig = &network
-	}
-
+	dst.NetworkConfig = convertNetworkConfigTo(src.NetworkConfig)
 	dst.VMNetworkConfig = convertVMNetworkConfigTo(src.VMNetworkConfig)
+	dst.AddressesFromPools = src.AddressesFromPools
 
 	if src.Firmware != nil {
 		firmware := infrav1.Firmware(*src.Firmware)
@@ -156,6 +496,43 @@ func convertMachineSpecTo(src *HarvesterMachineSpec) infrav1.HarvesterMachineSpe
 		dst.TPM = &tpm
 	}
 
+	if src.Devices != nil {
+		dst.Devices = &infrav1.GuestDevices{
+			RNG:                      src.Devices.RNG,
+			AutoattachSerialConsole:  src.Devices.AutoattachSerialConsole,
+			AutoattachGraphicsDevice: src.Devices.AutoattachGraphicsDevice,
+			Tablet:                   src.Devices.Tablet,
+		}
+
+		if src.Devices.Watchdog != nil {
+			watchdog := infrav1.WatchdogConfig(*src.Devices.Watchdog)
+			dst.Devices.Watchdog = &watchdog
+		}
+	}
+
+	if src.Hotplug != nil {
+		hotplug := infrav1.HotplugConfig(*src.Hotplug)
+		dst.Hotplug = &hotplug
+	}
+
+	if src.CPUOptions != nil {
+		cpuOptions := infrav1.CPUOptions(*src.CPUOptions)
+		dst.CPUOptions = &cpuOptions
+	}
+
+	if src.MemoryOptions != nil {
+		memoryOptions := infrav1.MemoryOptions(*src.MemoryOptions)
+		dst.MemoryOptions = &memoryOptions
+	}
+
+	dst.InPlaceVolumeUpdates = src.InPlaceVolumeUpdates
+	dst.Instancetype = src.Instancetype
+	dst.Preference = src.Preference
+	dst.VMTemplateVersion = src.VMTemplateVersion
+	dst.ProvisioningTimeout = convertProvisioningTimeoutTo(src.ProvisioningTimeout)
+	dst.ShutdownGracePeriod = src.ShutdownGracePeriod
+	dst.DeletionPolicy = infrav1.DeletionPolicy(src.DeletionPolicy)
+
 	return dst
 }
 
@@ -178,20 +555,26 @@ func convertMachineSpecFrom(src *infrav1.HarvesterMachineSpec) HarvesterMachineS
 
 	for _, v := range src.Volumes {
 		dst.Volumes = append(dst.Volumes, Volume{
-			VolumeType:   VolumeType(v.VolumeType),
-			ImageName:    v.ImageName,
-			StorageClass: v.StorageClass,
-			VolumeSize:   v.VolumeSize,
-			BootOrder:    v.BootOrder,
+			VolumeType:    VolumeType(v.VolumeType),
+			ImageName:     v.ImageName,
+			ImageSource:   convertImageSourceFrom(v.ImageSource),
+			ImageSelector: convertImageSelectorFrom(v.ImageSelector),
+			StorageClass:  v.StorageClass,
+			CloneSource:   convertVolumeCloneSourceFrom(v.CloneSource),
+			VolumeSize:    v.VolumeSize,
+			BootOrder:     v.BootOrder,
+			DeviceType:    VolumeDeviceType(v.DeviceType),
+			Bus:           DiskBus(v.Bus),
+			AccessMode:    v.AccessMode,
+			VolumeMode:    v.VolumeMode,
+			Cache:         DiskCache(v.Cache),
+			IO:            DiskIO(v.IO),
 		})
 	}
 
-	if src.NetworkConfig != nil {
-		network := NetworkConfig(*src.NetworkConfig)
-		dst.NetworkConfig = &network
-	}
-
+	dst.NetworkConfig = convertNetworkConfigFrom(src.NetworkConfig)
 	dst.VMNetworkConfig = convertVMNetworkConfigFrom(src.VMNetworkConfig)
+	dst.AddressesFromPools = src.AddressesFromPools
 
 	if src.Firmware != nil {
 		firmware := Firmware(*src.Firmware)
@@ -203,6 +586,43 @@ func convertMachineSpecFrom(src *infrav1.HarvesterMachineSpec) HarvesterMachineS
 		dst.TPM = &tpm
 	}
 
+	if src.Devices != nil {
+		dst.Devices = &GuestDevices{
+			RNG:                      src.Devices.RNG,
+			AutoattachSerialConsole:  src.Devices.AutoattachSerialConsole,
+			AutoattachGraphicsDevice: src.Devices.AutoattachGraphicsDevice,
+			Tablet:                   src.Devices.Tablet,
+		}
+
+		if src.Devices.Watchdog != nil {
+			watchdog := WatchdogConfig(*src.Devices.Watchdog)
+			dst.Devices.Watchdog = &watchdog
+		}
+	}
+
+	if src.Hotplug != nil {
+		hotplug := HotplugConfig(*src.Hotplug)
+		dst.Hotplug = &hotplug
+	}
+
+	if src.CPUOptions != nil {
+		cpuOptions := CPUOptions(*src.CPUOptions)
+		dst.CPUOptions = &cpuOptions
+	}
+
+	if src.MemoryOptions != nil {
+		memoryOptions := MemoryOptions(*src.MemoryOptions)
+		dst.MemoryOptions = &memoryOptions
+	}
+
+	dst.InPlaceVolumeUpdates = src.InPlaceVolumeUpdates
+	dst.Instancetype = src.Instancetype
+	dst.Preference = src.Preference
+	dst.VMTemplateVersion = src.VMTemplateVersion
+	dst.ProvisioningTimeout = convertProvisioningTimeoutFrom(src.ProvisioningTimeout)
+	dst.ShutdownGracePeriod = src.ShutdownGracePeriod
+	dst.DeletionPolicy = DeletionPolicy(src.DeletionPolicy)
+
 	return dst
 }
 
@@ -216,10 +636,13 @@ func (src *HarvesterCluster) ConvertTo(dstRaw conversion.Hub) error {
 	dst.ObjectMeta = src.ObjectMeta
 	dst.Spec = convertClusterSpecTo(&src.Spec)
 	dst.Status = infrav1.HarvesterClusterStatus{
-		Ready:          src.Status.Ready,
-		Conditions:     src.Status.Conditions,
-		Initialization: infrav1.Initialization(src.Status.Initialization),
-		FailureDomains: src.Status.FailureDomains,
+		Ready:               src.Status.Ready,
+		Conditions:          src.Status.Conditions,
+		Initialization:      infrav1.Initialization(src.Status.Initialization),
+		FailureDomains:      src.Status.FailureDomains,
+		LastIPPoolAuditTime: src.Status.LastIPPoolAuditTime,
+		LeakedIPAllocations: convertLeakedIPAllocationsTo(src.Status.LeakedIPAllocations),
+		MachineBackups:      convertMachineBackupsTo(src.Status.MachineBackups),
 	}
 
 	return nil
@@ -237,10 +660,13 @@ func (dst *HarvesterCluster) ConvertFrom(srcRaw conversion.Hub) error {
 	dst.ObjectMeta = src.ObjectMeta
 	dst.Spec = convertClusterSpecFrom(&src.Spec)
 	dst.Status = HarvesterClusterStatus{
-		Ready:          src.Status.Ready,
-		Conditions:     src.Status.Conditions,
-		Initialization: Initialization(src.Status.Initialization),
-		FailureDomains: src.Status.FailureDomains,
+		Ready:               src.Status.Ready,
+		Conditions:          src.Status.Conditions,
+		Initialization:      Initialization(src.Status.Initialization),
+		FailureDomains:      src.Status.FailureDomains,
+		LastIPPoolAuditTime: src.Status.LastIPPoolAuditTime,
+		LeakedIPAllocations: convertLeakedIPAllocationsFrom(src.Status.LeakedIPAllocations),
+		MachineBackups:      convertMachineBackupsFrom(src.Status.MachineBackups),
 	}
 
 	return nil
@@ -256,13 +682,19 @@ func (src *HarvesterMachine) ConvertTo(dstRaw conversion.Hub) error {
 	dst.ObjectMeta = src.ObjectMeta
 	dst.Spec = convertMachineSpecTo(&src.Spec)
 	dst.Status = infrav1.HarvesterMachineStatus{
-		Ready:              src.Status.Ready,
-		Conditions:         src.Status.Conditions,
-		Addresses:          src.Status.Addresses,
-		Initialization:     infrav1.Initialization(src.Status.Initialization),
-		AllocatedIPAddress: src.Status.AllocatedIPAddress,
-		AllocatedPoolRef:   src.Status.AllocatedPoolRef,
-		FailureDomain:      src.Status.FailureDomain,
+		Ready:                src.Status.Ready,
+		Conditions:           src.Status.Conditions,
+		Addresses:            src.Status.Addresses,
+		Initialization:       infrav1.Initialization(src.Status.Initialization),
+		AllocatedIPAddress:   src.Status.AllocatedIPAddress,
+		AllocatedPoolRef:     src.Status.AllocatedPoolRef,
+		AllocatedIPv6Address: src.Status.AllocatedIPv6Address,
+		AllocatedIPv6PoolRef: src.Status.AllocatedIPv6PoolRef,
+		FailureDomain:        src.Status.FailureDomain,
+		InterfaceAllocations: convertInterfaceAllocationsTo(src.Status.InterfaceAllocations),
+		Volumes:              convertVolumeStatusesTo(src.Status.Volumes),
+		ConsoleLogSecretRef:  src.Status.ConsoleLogSecretRef,
+		VMRecreations:        src.Status.VMRecreations,
 	}
 
 	return nil
@@ -280,13 +712,19 @@ func (dst *HarvesterMachine) ConvertFrom(srcRaw conversion.Hub) error {
 	dst.ObjectMeta = src.ObjectMeta
 	dst.Spec = convertMachineSpecFrom(&src.Spec)
 	dst.Status = HarvesterMachineStatus{
-		Ready:              src.Status.Ready,
-		Conditions:         src.Status.Conditions,
-		Addresses:          src.Status.Addresses,
-		Initialization:     Initialization(src.Status.Initialization),
-		AllocatedIPAddress: src.Status.AllocatedIPAddress,
-		AllocatedPoolRef:   src.Status.AllocatedPoolRef,
-		FailureDomain:      src.Status.FailureDomain,
+		Ready:                src.Status.Ready,
+		Conditions:           src.Status.Conditions,
+		Addresses:            src.Status.Addresses,
+		Initialization:       Initialization(src.Status.Initialization),
+		AllocatedIPAddress:   src.Status.AllocatedIPAddress,
+		AllocatedPoolRef:     src.Status.AllocatedPoolRef,
+		AllocatedIPv6Address: src.Status.AllocatedIPv6Address,
+		AllocatedIPv6PoolRef: src.Status.AllocatedIPv6PoolRef,
+		FailureDomain:        src.Status.FailureDomain,
+		InterfaceAllocations: convertInterfaceAllocationsFrom(src.Status.InterfaceAllocations),
+		Volumes:              convertVolumeStatusesFrom(src.Status.Volumes),
+		ConsoleLogSecretRef:  src.Status.ConsoleLogSecretRef,
+		VMRecreations:        src.Status.VMRecreations,
 	}
 
 	return nil
@@ -332,6 +770,7 @@ func (src *HarvesterMachineTemplate) ConvertTo(dstRaw conversion.Hub) error {
 	dst.ObjectMeta = src.ObjectMeta
 	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
 	dst.Spec.Template.Spec = convertMachineSpecTo(&src.Spec.Template.Spec)
+	dst.Status.Capacity = src.Status.Capacity.DeepCopy()
 
 	return nil
 }
@@ -348,6 +787,125 @@ func (dst *HarvesterMachineTemplate) ConvertFrom(srcRaw conversion.Hub) error {
 	dst.ObjectMeta = src.ObjectMeta
 	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
 	dst.Spec.Template.Spec = convertMachineSpecFrom(&src.Spec.Template.Spec)
+	dst.Status.Capacity = src.Status.Capacity.DeepCopy()
+
+	return nil
+}
+
+// ConvertTo converts this HarvesterMachinePool to the hub version.
+func (src *HarvesterMachinePool) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*infrav1.HarvesterMachinePool)
+	if !ok {
+		return errUnexpectedHub(dstRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.ProviderIDList = src.Spec.ProviderIDList
+	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
+	dst.Spec.Template.Spec = convertMachineSpecTo(&src.Spec.Template.Spec)
+	dst.Status = infrav1.HarvesterMachinePoolStatus{
+		Ready:          src.Status.Ready,
+		Replicas:       src.Status.Replicas,
+		Conditions:     src.Status.Conditions,
+		Initialization: infrav1.Initialization(src.Status.Initialization),
+	}
+
+	if src.Status.Instances != nil {
+		dst.Status.Instances = make([]infrav1.HarvesterMachinePoolInstanceStatus, len(src.Status.Instances))
+		for i, instance := range src.Status.Instances {
+			dst.Status.Instances[i] = infrav1.HarvesterMachinePoolInstanceStatus{
+				InstanceName:         instance.InstanceName,
+				ProviderID:           instance.ProviderID,
+				State:                infrav1.InstanceState(instance.State),
+				Addresses:            instance.Addresses,
+				AllocatedIPAddress:   instance.AllocatedIPAddress,
+				AllocatedPoolRef:     instance.AllocatedPoolRef,
+				AllocatedIPv6Address: instance.AllocatedIPv6Address,
+				AllocatedIPv6PoolRef: instance.AllocatedIPv6PoolRef,
+				InterfaceAllocations: convertInterfaceAllocationsTo(instance.InterfaceAllocations),
+				Conditions:           instance.Conditions,
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts the hub version to this HarvesterMachinePool.
+//
+//nolint:revive // src/dst receiver names follow the CAPI conversion convention
+func (dst *HarvesterMachinePool) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*infrav1.HarvesterMachinePool)
+	if !ok {
+		return errUnexpectedHub(srcRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.ProviderIDList = src.Spec.ProviderIDList
+	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
+	dst.Spec.Template.Spec = convertMachineSpecFrom(&src.Spec.Template.Spec)
+	dst.Status = HarvesterMachinePoolStatus{
+		Ready:          src.Status.Ready,
+		Replicas:       src.Status.Replicas,
+		Conditions:     src.Status.Conditions,
+		Initialization: Initialization(src.Status.Initialization),
+	}
+
+	if src.Status.Instances != nil {
+		dst.Status.Instances = make([]HarvesterMachinePoolInstanceStatus, len(src.Status.Instances))
+		for i, instance := range src.Status.Instances {
+			dst.Status.Instances[i] = HarvesterMachinePoolInstanceStatus{
+				InstanceName:         instance.InstanceName,
+				ProviderID:           instance.ProviderID,
+				State:                InstanceState(instance.State),
+				Addresses:            instance.Addresses,
+				AllocatedIPAddress:   instance.AllocatedIPAddress,
+				AllocatedPoolRef:     instance.AllocatedPoolRef,
+				AllocatedIPv6Address: instance.AllocatedIPv6Address,
+				AllocatedIPv6PoolRef: instance.AllocatedIPv6PoolRef,
+				InterfaceAllocations: convertInterfaceAllocationsFrom(instance.InterfaceAllocations),
+				Conditions:           instance.Conditions,
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConvertTo converts this HarvesterIPPool to the hub version.
+func (src *HarvesterIPPool) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*infrav1.HarvesterIPPool)
+	if !ok {
+		return errUnexpectedHub(dstRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = infrav1.HarvesterIPPoolSpec{
+		IdentitySecret: infrav1.SecretKey(src.Spec.IdentitySecret),
+		IPPoolRefs:     src.Spec.IPPoolRefs,
+		Prefix:         src.Spec.Prefix,
+		Gateway:        src.Spec.Gateway,
+	}
+
+	return nil
+}
+
+// ConvertFrom converts the hub version to this HarvesterIPPool.
+//
+//nolint:revive // src/dst receiver names follow the CAPI conversion convention
+func (dst *HarvesterIPPool) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*infrav1.HarvesterIPPool)
+	if !ok {
+		return errUnexpectedHub(srcRaw)
+	}
+
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = HarvesterIPPoolSpec{
+		IdentitySecret: SecretKey(src.Spec.IdentitySecret),
+		IPPoolRefs:     src.Spec.IPPoolRefs,
+		Prefix:         src.Spec.Prefix,
+		Gateway:        src.Spec.Gateway,
+	}
 
 	return nil
 }
@@ -52,4 +52,12 @@ func TestFuzzyConversion(t *testing.T) {
 		Hub:   &infrav1.HarvesterMachineTemplate{},
 		Spoke: &HarvesterMachineTemplate{},
 	}))
+	t.Run("for HarvesterMachinePool", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Hub:   &infrav1.HarvesterMachinePool{},
+		Spoke: &HarvesterMachinePool{},
+	}))
+	t.Run("for HarvesterIPPool", utilconversion.FuzzTestFunc(utilconversion.FuzzTestFuncInput{
+		Hub:   &infrav1.HarvesterIPPool{},
+		Spoke: &HarvesterIPPool{},
+	}))
 }
@@ -0,0 +1,80 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// IPAddressClaimFinalizer allows the IPAddressClaim reconciler to release the address of a claim
+	// back to its Harvester IPPool before the claim is removed from the apiserver.
+	IPAddressClaimFinalizer = "harvesterippool.infrastructure.cluster.x-k8s.io/release-address"
+)
+
+// HarvesterIPPoolSpec defines the desired state of HarvesterIPPool.
+type HarvesterIPPoolSpec struct {
+	// IdentitySecret is the secret holding the kubeconfig of the Harvester cluster
+	// hosting the IPPools referenced by ipPoolRefs.
+	IdentitySecret SecretKey `json:"identitySecret"`
+
+	// IPPoolRefs lists the Harvester IPPools (loadbalancer.harvesterhci.io) the
+	// addresses of the IPAddressClaims referencing this pool are allocated from.
+	// Pools are tried in order: when one is exhausted, the next one is used.
+	// +kubebuilder:validation:MinItems=1
+	IPPoolRefs []string `json:"ipPoolRefs"`
+
+	// Prefix overrides the prefix length published on the IPAddresses. When
+	// unset, the prefix length of the subnet of the range the address was
+	// allocated from is used.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=128
+	// +optional
+	Prefix *int32 `json:"prefix,omitempty"`
+
+	// Gateway overrides the gateway published on the IPAddresses. When unset,
+	// the gateway of the range the address was allocated from is used.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Pools",type="string",JSONPath=".spec.ipPoolRefs",description="Harvester IPPools serving the addresses"
+
+// HarvesterIPPool is the Schema for the harvesterippools API. It is the pool
+// kind of the CAPI IPAM contract: IPAddressClaims referencing it get an
+// IPAddress allocated from the Harvester IPPools it lists.
+type HarvesterIPPool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec HarvesterIPPoolSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HarvesterIPPoolList contains a list of HarvesterIPPool.
+type HarvesterIPPoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []HarvesterIPPool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HarvesterIPPool{}, &HarvesterIPPoolList{})
+}
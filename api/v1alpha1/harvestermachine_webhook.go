@@ -20,14 +20,23 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/url"
+	"regexp"
 	"strings"
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
+// sha512Checksum matches a hexadecimal SHA-512 checksum.
+var sha512Checksum = regexp.MustCompile(`^[0-9a-fA-F]{128}$`)
+
 // HarvesterMachineValidator implements admission.Validator for HarvesterMachine.
 type HarvesterMachineValidator struct{}
 
@@ -49,8 +58,19 @@ func (v *HarvesterMachineValidator) ValidateCreate(_ context.Context, obj *Harve
 }
 
 // ValidateUpdate implements admission.Validator.
-func (v *HarvesterMachineValidator) ValidateUpdate(_ context.Context, _, newObj *HarvesterMachine) (admission.Warnings, error) {
-	return validateHarvesterMachine(newObj)
+func (v *HarvesterMachineValidator) ValidateUpdate(_ context.Context, oldObj, newObj *HarvesterMachine) (admission.Warnings, error) {
+	warnings, err := validateHarvesterMachine(newObj)
+	if err != nil {
+		return warnings, err
+	}
+
+	errs := validateVolumeUpdate(oldObj, newObj)
+	if len(errs) > 0 {
+		return warnings, fmt.Errorf("validation failed for HarvesterMachine %s/%s: %s",
+			newObj.Namespace, newObj.Name, strings.Join(errs, "; "))
+	}
+
+	return warnings, nil
 }
 
 // ValidateDelete implements admission.Validator.
@@ -61,17 +81,10 @@ func (v *HarvesterMachineValidator) ValidateDelete(_ context.Context, _ *Harvest
 func validateHarvesterMachine(r *HarvesterMachine) (admission.Warnings, error) {
 	var errs []string
 
-	if r.Spec.CPU == 0 {
-		errs = append(errs, "spec.cpu must be greater than 0")
-	}
-
-	if r.Spec.Memory == "" {
-		errs = append(errs, "spec.memory is required")
+	if r.Spec.VMTemplateVersion != "" {
+		errs = append(errs, validateVMTemplateVersion(r)...)
 	} else {
-		_, err := resource.ParseQuantity(r.Spec.Memory)
-		if err != nil {
-			errs = append(errs, fmt.Sprintf("spec.memory %q is not a valid resource quantity: %v", r.Spec.Memory, err))
-		}
+		errs = append(errs, validateVMShape(r)...)
 	}
 
 	if r.Spec.SSHUser == "" {
@@ -82,22 +95,23 @@ func validateHarvesterMachine(r *HarvesterMachine) (admission.Warnings, error) {
 		errs = append(errs, "spec.sshKeyPair is required")
 	}
 
-	if len(r.Spec.Volumes) == 0 {
-		errs = append(errs, "spec.volumes must contain at least one volume")
-	}
-
 	for i, vol := range r.Spec.Volumes {
-		if vol.VolumeType != "image" && vol.VolumeType != "storageClass" {
-			errs = append(errs, fmt.Sprintf("spec.volumes[%d].volumeType must be 'image' or 'storageClass'", i))
+		if vol.VolumeType != "image" && vol.VolumeType != "storageClass" && vol.VolumeType != "clone" {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d].volumeType must be 'image', 'storageClass' or 'clone'", i))
 		}
 
-		if vol.VolumeType == "image" && vol.ImageName == "" {
-			errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageName is required when volumeType is 'image'", i))
+		if vol.VolumeType == "image" && vol.ImageName == "" && vol.ImageSelector == nil {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageName or imageSelector is required when volumeType is 'image'", i))
 		}
 
 		if vol.VolumeType == "storageClass" && vol.StorageClass == "" {
 			errs = append(errs, fmt.Sprintf("spec.volumes[%d].storageClass is required when volumeType is 'storageClass'", i))
 		}
+
+		errs = append(errs, validateImageSelector(i, vol)...)
+		errs = append(errs, validateImageSource(i, vol)...)
+		errs = append(errs, validateCloneSource(i, vol)...)
+		errs = append(errs, validateVolumeOptions(i, vol)...)
 	}
 
 	if len(r.Spec.Networks) == 0 {
@@ -105,23 +119,45 @@ func validateHarvesterMachine(r *HarvesterMachine) (admission.Warnings, error) {
 	}
 
 	if r.Spec.NetworkConfig != nil {
-		if r.Spec.NetworkConfig.Address == "" {
+		// An interfaces entry for the first network replaces address and gateway
+		primaryConfigured := len(r.Spec.Networks) > 0 &&
+			hasInterfaceConfig(r.Spec.NetworkConfig.Interfaces, r.Spec.Networks[0])
+
+		if r.Spec.NetworkConfig.Address == "" && !primaryConfigured {
 			errs = append(errs, "spec.networkConfig.address is required when networkConfig is set")
 		}
 
 		if r.Spec.NetworkConfig.Gateway == "" {
-			errs = append(errs, "spec.networkConfig.gateway is required when networkConfig is set")
+			if !primaryConfigured {
+				errs = append(errs, "spec.networkConfig.gateway is required when networkConfig is set")
+			}
 		} else if net.ParseIP(r.Spec.NetworkConfig.Gateway) == nil {
 			errs = append(errs, fmt.Sprintf("spec.networkConfig.gateway %q is not a valid IP address", r.Spec.NetworkConfig.Gateway))
 		}
+
+		errs = append(errs, validateStaticIPv6Config(r.Spec.NetworkConfig)...)
+		errs = append(errs, validateInterfaceConfigs("spec.networkConfig.interfaces", r.Spec.NetworkConfig.Interfaces)...)
 	}
 
 	errs = append(errs, validateMachineVMNetworkConfig(r)...)
+	errs = append(errs, validateAddressesFromPools(r)...)
+	errs = append(errs, validateHotplug(r)...)
+	errs = append(errs, validateCPUOptions(r)...)
+	errs = append(errs, validateMemoryOptions(r)...)
+	errs = append(errs, validateProvisioningTimeout("spec.provisioningTimeout", r.Spec.ProvisioningTimeout)...)
+	errs = append(errs, validateShutdownGracePeriod("spec.shutdownGracePeriod", r.Spec.ShutdownGracePeriod)...)
+	errs = append(errs, validateDeletionPolicy("spec.deletionPolicy", r.Spec.DeletionPolicy)...)
 
 	if fw := r.Spec.Firmware; fw != nil && fw.SecureBoot && !fw.EFI {
 		errs = append(errs, "spec.firmware.secureBoot requires spec.firmware.efi to be true")
 	}
 
+	if devices := r.Spec.Devices; devices != nil && devices.Watchdog != nil {
+		if action := devices.Watchdog.Action; action != "" && action != "reset" && action != "poweroff" {
+			errs = append(errs, fmt.Sprintf("spec.devices.watchdog.action %q must be 'reset' or 'poweroff'", action))
+		}
+	}
+
 	if len(errs) > 0 {
 		return nil, fmt.Errorf("validation failed for HarvesterMachine %s/%s: %s",
 			r.Namespace, r.Name, strings.Join(errs, "; "))
@@ -130,6 +166,119 @@ func validateHarvesterMachine(r *HarvesterMachine) (admission.Warnings, error) {
 	return nil, nil
 }
 
+// validateVMShape checks the CPU, memory and volumes of a machine that is not
+// built from a VM template version.
+func validateVMShape(r *HarvesterMachine) []string {
+	var errs []string
+
+	if r.Spec.Instancetype != "" {
+		errs = append(errs, validateInstancetype(r)...)
+	} else {
+		errs = append(errs, validateCPUAndMemory(r)...)
+	}
+
+	for _, ref := range []struct{ field, name string }{
+		{"instancetype", r.Spec.Instancetype},
+		{"preference", r.Spec.Preference},
+	} {
+		if ref.name == "" {
+			continue
+		}
+
+		for _, msg := range validation.IsDNS1123Subdomain(ref.name) {
+			errs = append(errs, fmt.Sprintf("spec.%s %q is not a valid name: %s", ref.field, ref.name, msg))
+		}
+	}
+
+	if len(r.Spec.Volumes) == 0 {
+		errs = append(errs, "spec.volumes must contain at least one volume")
+	}
+
+	return errs
+}
+
+// validateCPUAndMemory checks the CPU and memory of a machine without instancetype.
+func validateCPUAndMemory(r *HarvesterMachine) []string {
+	var errs []string
+
+	if r.Spec.CPU == 0 {
+		errs = append(errs, "spec.cpu must be greater than 0")
+	}
+
+	if r.Spec.Memory == "" {
+		errs = append(errs, "spec.memory is required")
+	} else {
+		_, err := resource.ParseQuantity(r.Spec.Memory)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("spec.memory %q is not a valid resource quantity: %v", r.Spec.Memory, err))
+		}
+	}
+
+	return errs
+}
+
+// validateInstancetype checks that a machine with an instancetype leaves its
+// CPU and memory to the instancetype. Hotplug raises cpu and memory, so it
+// needs them set on the machine.
+func validateInstancetype(r *HarvesterMachine) []string {
+	var errs []string
+
+	if r.Spec.CPU != 0 || r.Spec.Memory != "" {
+		errs = append(errs, "spec.cpu and spec.memory are mutually exclusive with spec.instancetype")
+	}
+
+	for _, s := range []struct {
+		field string
+		set   bool
+	}{
+		{"cpuOptions", r.Spec.CPUOptions != nil},
+		{"memoryOptions", r.Spec.MemoryOptions != nil},
+		{"hotplug", r.Spec.Hotplug != nil},
+	} {
+		if s.set {
+			errs = append(errs, fmt.Sprintf("spec.%s cannot be set with spec.instancetype", s.field))
+		}
+	}
+
+	return errs
+}
+
+// validateVMTemplateVersion checks that a machine built from a VM template
+// version leaves the shape of the VM to the template version.
+func validateVMTemplateVersion(r *HarvesterMachine) []string {
+	var errs []string
+
+	if strings.Count(r.Spec.VMTemplateVersion, "/") > 1 {
+		errs = append(errs, fmt.Sprintf("spec.vmTemplateVersion %q must have the format <NAMESPACE>/<NAME> or <NAME>",
+			r.Spec.VMTemplateVersion))
+	}
+
+	shape := []struct {
+		field string
+		set   bool
+	}{
+		{"cpu", r.Spec.CPU != 0},
+		{"memory", r.Spec.Memory != ""},
+		{"cpuOptions", r.Spec.CPUOptions != nil},
+		{"memoryOptions", r.Spec.MemoryOptions != nil},
+		{"instancetype", r.Spec.Instancetype != ""},
+		{"preference", r.Spec.Preference != ""},
+		{"volumes", len(r.Spec.Volumes) > 0},
+		{"firmware", r.Spec.Firmware != nil},
+		{"tpm", r.Spec.TPM != nil},
+		{"devices", r.Spec.Devices != nil},
+		{"hotplug", r.Spec.Hotplug != nil},
+	}
+
+	for _, s := range shape {
+		if s.set {
+			errs = append(errs, fmt.Sprintf("spec.%s cannot be set with spec.vmTemplateVersion, it comes from the template version", s.field))
+		}
+	}
+
+	return errs
+}
+
 // validateMachineVMNetworkConfig checks the machine-level pool-based network
 // configuration override.
 func validateMachineVMNetworkConfig(r *HarvesterMachine) []string {
@@ -164,5 +313,484 @@ func validateMachineVMNetworkConfig(r *HarvesterMachine) []string {
 		errs = append(errs, fmt.Sprintf("spec.vmNetworkConfig.subnetMask %q is not a valid IP address", vmCfg.SubnetMask))
 	}
 
+	errs = append(errs, validateIPv6NetworkConfig("spec.vmNetworkConfig.ipv6", vmCfg.IPv6)...)
+	errs = append(errs, validateInterfaceConfigs("spec.vmNetworkConfig.interfaces", vmCfg.Interfaces)...)
+
+	return errs
+}
+
+// validateHotplug checks that the hotplug maximums are at least the CPU and
+// memory the VM starts with.
+func validateHotplug(r *HarvesterMachine) []string {
+	hotplug := r.Spec.Hotplug
+	if hotplug == nil {
+		return nil
+	}
+
+	var errs []string
+
+	if hotplug.MaxCPU < r.Spec.CPU {
+		errs = append(errs, fmt.Sprintf("spec.hotplug.maxCPU %d must be at least spec.cpu %d", hotplug.MaxCPU, r.Spec.CPU))
+	}
+
+	maxMemory, err := resource.ParseQuantity(hotplug.MaxMemory)
+	if err != nil {
+		return append(errs, fmt.Sprintf("spec.hotplug.maxMemory %q is not a valid resource quantity: %v", hotplug.MaxMemory, err))
+	}
+
+	memory, err := resource.ParseQuantity(r.Spec.Memory)
+	if err == nil && maxMemory.Cmp(memory) < 0 {
+		errs = append(errs, fmt.Sprintf("spec.hotplug.maxMemory %s must be at least spec.memory %s", hotplug.MaxMemory, r.Spec.Memory))
+	}
+
+	return errs
+}
+
+// validateCPUOptions checks that the CPUs of a machine fit the topology of
+// its cpuOptions. Hotplug lays the CPUs out as sockets and cannot hotplug
+// dedicated CPUs, so it leaves the topology and placement to the defaults.
+func validateCPUOptions(r *HarvesterMachine) []string {
+	opts := r.Spec.CPUOptions
+	if opts == nil {
+		return nil
+	}
+
+	var errs []string
+
+	perSocket := max(opts.Sockets, 1) * max(opts.Threads, 1)
+	if r.Spec.CPU%perSocket != 0 {
+		errs = append(errs, fmt.Sprintf("spec.cpu %d must be a multiple of spec.cpuOptions.sockets times spec.cpuOptions.threads (%d)",
+			r.Spec.CPU, perSocket))
+	}
+
+	if opts.IsolateEmulatorThread && !opts.DedicatedCPUPlacement {
+		errs = append(errs, "spec.cpuOptions.isolateEmulatorThread requires spec.cpuOptions.dedicatedCPUPlacement to be true")
+	}
+
+	if strings.ContainsAny(opts.Model, " \t") {
+		errs = append(errs, fmt.Sprintf("spec.cpuOptions.model %q must not contain whitespace", opts.Model))
+	}
+
+	if r.Spec.Hotplug != nil {
+		if opts.Sockets != 0 || opts.Threads != 0 {
+			errs = append(errs, "spec.cpuOptions.sockets and threads cannot be set with spec.hotplug")
+		}
+
+		if opts.DedicatedCPUPlacement {
+			errs = append(errs, "spec.cpuOptions.dedicatedCPUPlacement cannot be set with spec.hotplug")
+		}
+	}
+
+	return errs
+}
+
+// validateMemoryOptions checks the hugepages and overcommit of a machine.
+// Hugepages and dedicated CPUs need the memory request to match the guest
+// memory, and hotplug lets KubeVirt derive the requests, so none of them can
+// be overcommitted.
+func validateMemoryOptions(r *HarvesterMachine) []string {
+	opts := r.Spec.MemoryOptions
+	if opts == nil {
+		return nil
+	}
+
+	var errs []string
+
+	if pageSize := opts.HugepagesPageSize; pageSize != "" {
+		pageBytes, ok := map[string]int64{"2Mi": 2 << 20, "1Gi": 1 << 30}[pageSize]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("spec.memoryOptions.hugepagesPageSize %q must be '2Mi' or '1Gi'", pageSize))
+		} else if memory, err := resource.ParseQuantity(r.Spec.Memory); err == nil && memory.Value()%pageBytes != 0 {
+			errs = append(errs, fmt.Sprintf("spec.memory %s must be a multiple of spec.memoryOptions.hugepagesPageSize %s",
+				r.Spec.Memory, pageSize))
+		}
+	}
+
+	if opts.OvercommitPercent == 0 {
+		return errs
+	}
+
+	if opts.OvercommitPercent < 100 {
+		errs = append(errs, fmt.Sprintf("spec.memoryOptions.overcommitPercent %d must be at least 100", opts.OvercommitPercent))
+	}
+
+	for _, s := range []struct {
+		field string
+		set   bool
+	}{
+		{"memoryOptions.hugepagesPageSize", opts.HugepagesPageSize != ""},
+		{"cpuOptions.dedicatedCPUPlacement", r.Spec.CPUOptions != nil && r.Spec.CPUOptions.DedicatedCPUPlacement},
+		{"hotplug", r.Spec.Hotplug != nil},
+	} {
+		if s.set {
+			errs = append(errs, fmt.Sprintf("spec.memoryOptions.overcommitPercent cannot be set with spec.%s", s.field))
+		}
+	}
+
+	return errs
+}
+
+// validateImageSelector checks the label selector that resolves the image of a volume.
+func validateImageSelector(i int, vol Volume) []string {
+	selector := vol.ImageSelector
+	if selector == nil {
+		return nil
+	}
+
+	if vol.VolumeType != "image" {
+		return []string{fmt.Sprintf("spec.volumes[%d].imageSelector is only allowed when volumeType is 'image'", i)}
+	}
+
+	var errs []string
+
+	if vol.ImageName != "" || vol.ImageSource != nil {
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageSelector is mutually exclusive with imageName and imageSource", i))
+	}
+
+	// An empty selector would match any image
+	if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 {
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageSelector must set matchLabels or matchExpressions", i))
+	}
+
+	_, err := metav1.LabelSelectorAsSelector(&selector.LabelSelector)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageSelector is invalid: %v", i, err))
+	}
+
+	return errs
+}
+
+// validateImageSource checks the URL and pinned checksum an image is imported
+// from, and that the image can be created under its imageName.
+func validateImageSource(i int, vol Volume) []string {
+	source := vol.ImageSource
+	if source == nil {
+		return nil
+	}
+
+	if vol.VolumeType != "image" {
+		return []string{fmt.Sprintf("spec.volumes[%d].imageSource is only allowed when volumeType is 'image'", i)}
+	}
+
+	var errs []string
+
+	imageURL, err := url.Parse(source.URL)
+	if err != nil || (imageURL.Scheme != "http" && imageURL.Scheme != "https") || imageURL.Host == "" {
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageSource.url %q must be an http or https URL", i, source.URL))
+	}
+
+	if !sha512Checksum.MatchString(source.Checksum) {
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageSource.checksum must be a SHA-512 checksum of 128 hexadecimal characters", i))
+	}
+
+	// The image is created under the name part of imageName
+	if vol.ImageName != "" {
+		name := vol.ImageName[strings.LastIndex(vol.ImageName, "/")+1:]
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d].imageName %q cannot name an imported image: %s", i, name, msg))
+		}
+	}
+
+	return errs
+}
+
+// validateCloneSource checks the source of a "clone" volume. Whether the
+// source exists in the target namespace is checked by the controller, which
+// has access to Harvester.
+func validateCloneSource(i int, vol Volume) []string {
+	if vol.VolumeType != "clone" {
+		if vol.CloneSource != nil {
+			return []string{fmt.Sprintf("spec.volumes[%d].cloneSource is only allowed when volumeType is 'clone'", i)}
+		}
+
+		return nil
+	}
+
+	if vol.CloneSource == nil {
+		return []string{fmt.Sprintf("spec.volumes[%d].cloneSource is required when volumeType is 'clone'", i)}
+	}
+
+	var errs []string
+
+	if vol.CloneSource.Kind != VolumeCloneSourceVolumeSnapshot && vol.CloneSource.Kind != VolumeCloneSourcePersistentVolumeClaim {
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].cloneSource.kind must be 'VolumeSnapshot' or 'PersistentVolumeClaim'", i))
+	}
+
+	if vol.CloneSource.Name == "" {
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].cloneSource.name is required", i))
+	}
+
+	return errs
+}
+
+// validateVolumeOptions checks the device and PVC options of a volume.
+func validateVolumeOptions(i int, vol Volume) []string {
+	var errs []string
+
+	switch vol.DeviceType {
+	case "", VolumeDeviceTypeDisk:
+	case VolumeDeviceTypeCDRom:
+		if vol.VolumeType != "image" {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d].type 'cdrom' requires volumeType 'image'", i))
+		}
+
+		if vol.Bus == "virtio" {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d].bus 'virtio' is not supported for type 'cdrom'", i))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].type must be 'disk' or 'cdrom'", i))
+	}
+
+	switch vol.Bus {
+	case "", "virtio", "scsi", "sata", "usb":
+	default:
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].bus must be one of 'virtio', 'scsi', 'sata' or 'usb'", i))
+	}
+
+	switch vol.AccessMode {
+	case "", corev1.ReadWriteOnce, corev1.ReadWriteMany:
+	default:
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].accessMode must be 'ReadWriteOnce' or 'ReadWriteMany'", i))
+	}
+
+	switch vol.VolumeMode {
+	case "", corev1.PersistentVolumeBlock, corev1.PersistentVolumeFilesystem:
+	default:
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].volumeMode must be 'Block' or 'Filesystem'", i))
+	}
+
+	switch vol.Cache {
+	case "", "none", "writethrough", "writeback":
+	default:
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].cache must be one of 'none', 'writethrough' or 'writeback'", i))
+	}
+
+	switch vol.IO {
+	case "", "threads":
+	case "native":
+		// QEMU only supports native IO on uncached, O_DIRECT disks
+		if vol.Cache != "" && vol.Cache != "none" {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d].io 'native' requires cache 'none'", i))
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("spec.volumes[%d].io must be 'native' or 'threads'", i))
+	}
+
+	return errs
+}
+
+// validateVolumeUpdate checks that the volume changes of a machine opted in to
+// inPlaceVolumeUpdates can be applied to its running VM: volumes can only
+// grow, and volumes appended to the list must be blank "storageClass" disks
+// that can be hotplugged. The volumes of other machines are not applied to
+// their VM, CAPI replaces them instead.
+func validateVolumeUpdate(oldObj, newObj *HarvesterMachine) []string {
+	if !oldObj.Spec.InPlaceVolumeUpdates || !newObj.Spec.InPlaceVolumeUpdates {
+		return nil
+	}
+
+	oldVolumes, newVolumes := oldObj.Spec.Volumes, newObj.Spec.Volumes
+
+	if len(newVolumes) < len(oldVolumes) {
+		return []string{"spec.volumes cannot be removed from an existing machine"}
+	}
+
+	var errs []string
+
+	for i, vol := range newVolumes {
+		if i >= len(oldVolumes) {
+			if vol.VolumeType != "storageClass" {
+				errs = append(errs, fmt.Sprintf("spec.volumes[%d] added to an existing machine must have volumeType 'storageClass'", i))
+			}
+
+			if vol.VolumeSize == nil {
+				errs = append(errs, fmt.Sprintf("spec.volumes[%d].volumeSize is required on a volume added to an existing machine", i))
+			}
+
+			// KubeVirt hotplugs disks on the SCSI bus only
+			if vol.Bus != "" && vol.Bus != "scsi" {
+				errs = append(errs, fmt.Sprintf("spec.volumes[%d].bus must be 'scsi' on a volume added to an existing machine", i))
+			}
+
+			continue
+		}
+
+		oldVol, newVol := oldVolumes[i], vol
+		oldVol.VolumeSize, newVol.VolumeSize = nil, nil
+
+		if !equality.Semantic.DeepEqual(newVol, oldVol) {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d] can only change its volumeSize", i))
+		}
+
+		oldSize := oldVolumes[i].VolumeSize
+		if oldSize != nil && (vol.VolumeSize == nil || vol.VolumeSize.Cmp(*oldSize) < 0) {
+			errs = append(errs, fmt.Sprintf("spec.volumes[%d].volumeSize cannot be decreased", i))
+		}
+	}
+
+	return errs
+}
+
+// validateAddressesFromPools checks the IPAM pools the address of the first
+// network is claimed from.
+func validateAddressesFromPools(r *HarvesterMachine) []string {
+	if len(r.Spec.AddressesFromPools) == 0 {
+		return nil
+	}
+
+	var errs []string
+
+	if r.Spec.NetworkConfig != nil {
+		errs = append(errs, "spec.addressesFromPools and spec.networkConfig are mutually exclusive")
+	}
+
+	for i, poolRef := range r.Spec.AddressesFromPools {
+		if poolRef.APIGroup == nil || *poolRef.APIGroup == "" {
+			errs = append(errs, fmt.Sprintf("spec.addressesFromPools[%d].apiGroup is required", i))
+		}
+
+		if poolRef.Kind == "" {
+			errs = append(errs, fmt.Sprintf("spec.addressesFromPools[%d].kind is required", i))
+		}
+
+		if poolRef.Name == "" {
+			errs = append(errs, fmt.Sprintf("spec.addressesFromPools[%d].name is required", i))
+		}
+	}
+
+	return errs
+}
+
+// validateStaticIPv6Config checks the IPv6 address of the static networkConfig.
+func validateStaticIPv6Config(cfg *NetworkConfig) []string {
+	var errs []string
+
+	if cfg.IPv6Address == "" {
+		if cfg.IPv6PrefixLength != 0 || cfg.IPv6Gateway != "" {
+			errs = append(errs, "spec.networkConfig.ipv6PrefixLength and ipv6Gateway require ipv6Address")
+		}
+
+		return errs
+	}
+
+	if !isIPv6Address(cfg.IPv6Address) {
+		errs = append(errs, fmt.Sprintf("spec.networkConfig.ipv6Address %q is not a valid IPv6 address", cfg.IPv6Address))
+	}
+
+	if cfg.IPv6PrefixLength < 1 || cfg.IPv6PrefixLength > 128 {
+		errs = append(errs, "spec.networkConfig.ipv6PrefixLength must be between 1 and 128 when ipv6Address is set")
+	}
+
+	if cfg.IPv6Gateway != "" && !isIPv6Address(cfg.IPv6Gateway) {
+		errs = append(errs, fmt.Sprintf("spec.networkConfig.ipv6Gateway %q is not a valid IPv6 address", cfg.IPv6Gateway))
+	}
+
+	return errs
+}
+
+// validateIPv6NetworkConfig checks the IPv6 part of a vmNetworkConfig found at path.
+func validateIPv6NetworkConfig(path string, cfg *IPv6NetworkConfig) []string {
+	if cfg == nil {
+		return nil
+	}
+
+	var errs []string
+
+	if len(cfg.IPPoolRefs) == 0 {
+		errs = append(errs, path+".ipPoolRefs must contain at least one IPPool")
+	}
+
+	if cfg.Gateway != "" && !isIPv6Address(cfg.Gateway) {
+		errs = append(errs, fmt.Sprintf("%s.gateway %q is not a valid IPv6 address", path, cfg.Gateway))
+	}
+
+	if cfg.PrefixLength < 1 || cfg.PrefixLength > 128 {
+		errs = append(errs, path+".prefixLength must be between 1 and 128")
+	}
+
+	return errs
+}
+
+// isIPv6Address reports whether s is a valid IPv6 address.
+func isIPv6Address(s string) bool {
+	ip := net.ParseIP(s)
+
+	return ip != nil && ip.To4() == nil
+}
+
+// hasInterfaceConfig reports whether interfaces holds an entry for network.
+func hasInterfaceConfig(interfaces []InterfaceConfig, network string) bool {
+	for _, iface := range interfaces {
+		if NetworkRefsEqual(iface.Network, network) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateInterfaceConfigs checks the per-network interface configurations found at path.
+func validateInterfaceConfigs(path string, interfaces []InterfaceConfig) []string {
+	var errs []string
+
+	for i, iface := range interfaces {
+		field := fmt.Sprintf("%s[%d]", path, i)
+
+		if iface.Network == "" {
+			errs = append(errs, field+".network is required")
+		}
+
+		for _, other := range interfaces[:i] {
+			if iface.Network != "" && NetworkRefsEqual(iface.Network, other.Network) {
+				errs = append(errs, fmt.Sprintf("%s.network %q is configured more than once", field, iface.Network))
+
+				break
+			}
+		}
+
+		switch iface.Addressing {
+		case InterfaceAddressingStatic:
+			if net.ParseIP(iface.Address) == nil {
+				errs = append(errs, fmt.Sprintf("%s.address %q must be a valid IP address with static addressing", field, iface.Address))
+			}
+		case InterfaceAddressingPool:
+			if iface.Address != "" {
+				errs = append(errs, field+".address is only allowed with static addressing")
+			}
+		case InterfaceAddressingDHCP, InterfaceAddressingNone:
+			if iface.Address != "" || iface.SubnetMask != "" || iface.Gateway != "" || len(iface.IPPoolRefs) > 0 {
+				errs = append(errs, fmt.Sprintf("%s: address, subnetMask, gateway and ipPoolRefs are not allowed with %s addressing",
+					field, iface.Addressing))
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("%s.addressing must be one of static, pool, dhcp or none", field))
+		}
+
+		if iface.Addressing == InterfaceAddressingStatic || iface.Addressing == InterfaceAddressingPool {
+			if net.ParseIP(iface.SubnetMask) == nil {
+				errs = append(errs, fmt.Sprintf("%s.subnetMask %q must be a valid subnet mask with %s addressing",
+					field, iface.SubnetMask, iface.Addressing))
+			}
+		}
+
+		if len(iface.IPPoolRefs) > 0 && iface.Addressing != InterfaceAddressingPool {
+			errs = append(errs, field+".ipPoolRefs is only allowed with pool addressing")
+		}
+
+		if iface.Gateway != "" && net.ParseIP(iface.Gateway) == nil {
+			errs = append(errs, fmt.Sprintf("%s.gateway %q is not a valid IP address", field, iface.Gateway))
+		}
+
+		for j, route := range iface.Routes {
+			if _, _, err := net.ParseCIDR(route.To); err != nil {
+				errs = append(errs, fmt.Sprintf("%s.routes[%d].to %q is not a valid CIDR", field, j, route.To))
+			}
+
+			if net.ParseIP(route.Via) == nil {
+				errs = append(errs, fmt.Sprintf("%s.routes[%d].via %q is not a valid IP address", field, j, route.Via))
+			}
+		}
+	}
+
 	return errs
 }
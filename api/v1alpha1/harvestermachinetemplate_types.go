@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
@@ -28,6 +29,15 @@ type HarvesterMachineTemplateSpec struct {
 	Template HarvesterMachineTemplateResource `json:"template,omitempty"`
 }
 
+// HarvesterMachineTemplateStatus defines the observed state of HarvesterMachineTemplate.
+type HarvesterMachineTemplateStatus struct {
+	// Capacity is the CPU and memory of the machines created from the
+	// template, from cpu and memory or from their instancetype. The cluster
+	// autoscaler reads it to scale node groups from zero.
+	// +optional
+	Capacity corev1.ResourceList `json:"capacity,omitempty"`
+}
+
 // HarvesterMachineTemplateResource describes the data needed to create a HarvesterMachine from a template.
 type HarvesterMachineTemplateResource struct {
 	// Standard object's metadata.
@@ -39,13 +49,15 @@ type HarvesterMachineTemplateResource struct {
 }
 
 //+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
 
 // HarvesterMachineTemplate is the Schema for the harvestermachinetemplates API.
 type HarvesterMachineTemplate struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec HarvesterMachineTemplateSpec `json:"spec,omitempty"`
+	Spec   HarvesterMachineTemplateSpec   `json:"spec,omitempty"`
+	Status HarvesterMachineTemplateStatus `json:"status,omitempty"`
 }
 
 //+kubebuilder:object:root=true
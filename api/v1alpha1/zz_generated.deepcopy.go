@@ -24,9 +24,45 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/cluster-api/api/core/v1beta2"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupPolicy) DeepCopyInto(out *BackupPolicy) {
+	*out = *in
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]MachineRole, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupPolicy.
+func (in *BackupPolicy) DeepCopy() *BackupPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CPUOptions) DeepCopyInto(out *CPUOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPUOptions.
+func (in *CPUOptions) DeepCopy() *CPUOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(CPUOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Firmware) DeepCopyInto(out *Firmware) {
 	*out = *in
@@ -42,6 +78,41 @@ func (in *Firmware) DeepCopy() *Firmware {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GuestDevices) DeepCopyInto(out *GuestDevices) {
+	*out = *in
+	if in.Watchdog != nil {
+		in, out := &in.Watchdog, &out.Watchdog
+		*out = new(WatchdogConfig)
+		**out = **in
+	}
+	if in.AutoattachSerialConsole != nil {
+		in, out := &in.AutoattachSerialConsole, &out.AutoattachSerialConsole
+		*out = new(bool)
+		**out = **in
+	}
+	if in.AutoattachGraphicsDevice != nil {
+		in, out := &in.AutoattachGraphicsDevice, &out.AutoattachGraphicsDevice
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Tablet != nil {
+		in, out := &in.Tablet, &out.Tablet
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GuestDevices.
+func (in *GuestDevices) DeepCopy() *GuestDevices {
+	if in == nil {
+		return nil
+	}
+	out := new(GuestDevices)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HarvesterCluster) DeepCopyInto(out *HarvesterCluster) {
 	*out = *in
@@ -113,6 +184,26 @@ func (in *HarvesterClusterSpec) DeepCopyInto(out *HarvesterClusterSpec) {
 		*out = new(VMNetworkConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.IPPoolFreeThreshold != nil {
+		in, out := &in.IPPoolFreeThreshold, &out.IPPoolFreeThreshold
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.ProvisioningTimeout != nil {
+		in, out := &in.ProvisioningTimeout, &out.ProvisioningTimeout
+		*out = new(ProvisioningTimeout)
+		**out = **in
+	}
+	if in.ShutdownGracePeriod != nil {
+		in, out := &in.ShutdownGracePeriod, &out.ShutdownGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.BackupPolicy != nil {
+		in, out := &in.BackupPolicy, &out.BackupPolicy
+		*out = new(BackupPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterClusterSpec.
@@ -143,6 +234,24 @@ func (in *HarvesterClusterStatus) DeepCopyInto(out *HarvesterClusterStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LastIPPoolAuditTime != nil {
+		in, out := &in.LastIPPoolAuditTime, &out.LastIPPoolAuditTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LeakedIPAllocations != nil {
+		in, out := &in.LeakedIPAllocations, &out.LeakedIPAllocations
+		*out = make([]LeakedIPAllocation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MachineBackups != nil {
+		in, out := &in.MachineBackups, &out.MachineBackups
+		*out = make([]MachineBackupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterClusterStatus.
@@ -261,6 +370,90 @@ func (in *HarvesterClusterValidator) DeepCopy() *HarvesterClusterValidator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterIPPool) DeepCopyInto(out *HarvesterIPPool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterIPPool.
+func (in *HarvesterIPPool) DeepCopy() *HarvesterIPPool {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterIPPool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarvesterIPPool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterIPPoolList) DeepCopyInto(out *HarvesterIPPoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HarvesterIPPool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterIPPoolList.
+func (in *HarvesterIPPoolList) DeepCopy() *HarvesterIPPoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterIPPoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarvesterIPPoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterIPPoolSpec) DeepCopyInto(out *HarvesterIPPoolSpec) {
+	*out = *in
+	out.IdentitySecret = in.IdentitySecret
+	if in.IPPoolRefs != nil {
+		in, out := &in.IPPoolRefs, &out.IPPoolRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Prefix != nil {
+		in, out := &in.Prefix, &out.Prefix
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterIPPoolSpec.
+func (in *HarvesterIPPoolSpec) DeepCopy() *HarvesterIPPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterIPPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HarvesterMachine) DeepCopyInto(out *HarvesterMachine) {
 	*out = *in
@@ -320,9 +513,161 @@ func (in *HarvesterMachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterMachinePool) DeepCopyInto(out *HarvesterMachinePool) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachinePool.
+func (in *HarvesterMachinePool) DeepCopy() *HarvesterMachinePool {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterMachinePool)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarvesterMachinePool) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterMachinePoolInstanceStatus) DeepCopyInto(out *HarvesterMachinePoolInstanceStatus) {
+	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]v1beta2.MachineAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.InterfaceAllocations != nil {
+		in, out := &in.InterfaceAllocations, &out.InterfaceAllocations
+		*out = make([]InterfaceAllocation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachinePoolInstanceStatus.
+func (in *HarvesterMachinePoolInstanceStatus) DeepCopy() *HarvesterMachinePoolInstanceStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterMachinePoolInstanceStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterMachinePoolList) DeepCopyInto(out *HarvesterMachinePoolList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HarvesterMachinePool, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachinePoolList.
+func (in *HarvesterMachinePoolList) DeepCopy() *HarvesterMachinePoolList {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterMachinePoolList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HarvesterMachinePoolList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterMachinePoolSpec) DeepCopyInto(out *HarvesterMachinePoolSpec) {
+	*out = *in
+	if in.ProviderIDList != nil {
+		in, out := &in.ProviderIDList, &out.ProviderIDList
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachinePoolSpec.
+func (in *HarvesterMachinePoolSpec) DeepCopy() *HarvesterMachinePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterMachinePoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterMachinePoolStatus) DeepCopyInto(out *HarvesterMachinePoolStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Initialization = in.Initialization
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = make([]HarvesterMachinePoolInstanceStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachinePoolStatus.
+func (in *HarvesterMachinePoolStatus) DeepCopy() *HarvesterMachinePoolStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterMachinePoolStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HarvesterMachineSpec) DeepCopyInto(out *HarvesterMachineSpec) {
 	*out = *in
+	if in.CPUOptions != nil {
+		in, out := &in.CPUOptions, &out.CPUOptions
+		*out = new(CPUOptions)
+		**out = **in
+	}
+	if in.MemoryOptions != nil {
+		in, out := &in.MemoryOptions, &out.MemoryOptions
+		*out = new(MemoryOptions)
+		**out = **in
+	}
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
 		*out = make([]Volume, len(*in))
@@ -355,6 +700,13 @@ func (in *HarvesterMachineSpec) DeepCopyInto(out *HarvesterMachineSpec) {
 		*out = new(VMNetworkConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.AddressesFromPools != nil {
+		in, out := &in.AddressesFromPools, &out.AddressesFromPools
+		*out = make([]corev1.TypedLocalObjectReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Firmware != nil {
 		in, out := &in.Firmware, &out.Firmware
 		*out = new(Firmware)
@@ -365,6 +717,26 @@ func (in *HarvesterMachineSpec) DeepCopyInto(out *HarvesterMachineSpec) {
 		*out = new(TPM)
 		**out = **in
 	}
+	if in.Devices != nil {
+		in, out := &in.Devices, &out.Devices
+		*out = new(GuestDevices)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Hotplug != nil {
+		in, out := &in.Hotplug, &out.Hotplug
+		*out = new(HotplugConfig)
+		**out = **in
+	}
+	if in.ProvisioningTimeout != nil {
+		in, out := &in.ProvisioningTimeout, &out.ProvisioningTimeout
+		*out = new(ProvisioningTimeout)
+		**out = **in
+	}
+	if in.ShutdownGracePeriod != nil {
+		in, out := &in.ShutdownGracePeriod, &out.ShutdownGracePeriod
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachineSpec.
@@ -393,6 +765,18 @@ func (in *HarvesterMachineStatus) DeepCopyInto(out *HarvesterMachineStatus) {
 		copy(*out, *in)
 	}
 	out.Initialization = in.Initialization
+	if in.InterfaceAllocations != nil {
+		in, out := &in.InterfaceAllocations, &out.InterfaceAllocations
+		*out = make([]InterfaceAllocation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]VolumeStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachineStatus.
@@ -411,6 +795,7 @@ func (in *HarvesterMachineTemplate) DeepCopyInto(out *HarvesterMachineTemplate)
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachineTemplate.
@@ -496,6 +881,28 @@ func (in *HarvesterMachineTemplateSpec) DeepCopy() *HarvesterMachineTemplateSpec
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HarvesterMachineTemplateStatus) DeepCopyInto(out *HarvesterMachineTemplateStatus) {
+	*out = *in
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		*out = make(corev1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HarvesterMachineTemplateStatus.
+func (in *HarvesterMachineTemplateStatus) DeepCopy() *HarvesterMachineTemplateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HarvesterMachineTemplateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HarvesterMachineValidator) DeepCopyInto(out *HarvesterMachineValidator) {
 	*out = *in
@@ -511,6 +918,72 @@ func (in *HarvesterMachineValidator) DeepCopy() *HarvesterMachineValidator {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HotplugConfig) DeepCopyInto(out *HotplugConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HotplugConfig.
+func (in *HotplugConfig) DeepCopy() *HotplugConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HotplugConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPv6NetworkConfig) DeepCopyInto(out *IPv6NetworkConfig) {
+	*out = *in
+	if in.IPPoolRefs != nil {
+		in, out := &in.IPPoolRefs, &out.IPPoolRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPv6NetworkConfig.
+func (in *IPv6NetworkConfig) DeepCopy() *IPv6NetworkConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IPv6NetworkConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSelector) DeepCopyInto(out *ImageSelector) {
+	*out = *in
+	in.LabelSelector.DeepCopyInto(&out.LabelSelector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSelector.
+func (in *ImageSelector) DeepCopy() *ImageSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSource) DeepCopyInto(out *ImageSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSource.
+func (in *ImageSource) DeepCopy() *ImageSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Initialization) DeepCopyInto(out *Initialization) {
 	*out = *in
@@ -526,6 +999,48 @@ func (in *Initialization) DeepCopy() *Initialization {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterfaceAllocation) DeepCopyInto(out *InterfaceAllocation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterfaceAllocation.
+func (in *InterfaceAllocation) DeepCopy() *InterfaceAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(InterfaceAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InterfaceConfig) DeepCopyInto(out *InterfaceConfig) {
+	*out = *in
+	if in.IPPoolRefs != nil {
+		in, out := &in.IPPoolRefs, &out.IPPoolRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]Route, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InterfaceConfig.
+func (in *InterfaceConfig) DeepCopy() *InterfaceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(InterfaceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IpPool) DeepCopyInto(out *IpPool) {
 	*out = *in
@@ -541,6 +1056,22 @@ func (in *IpPool) DeepCopy() *IpPool {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LeakedIPAllocation) DeepCopyInto(out *LeakedIPAllocation) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LeakedIPAllocation.
+func (in *LeakedIPAllocation) DeepCopy() *LeakedIPAllocation {
+	if in == nil {
+		return nil
+	}
+	out := new(LeakedIPAllocation)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Listener) DeepCopyInto(out *Listener) {
 	*out = *in
@@ -565,6 +1096,11 @@ func (in *LoadBalancerConfig) DeepCopyInto(out *LoadBalancerConfig) {
 		*out = make([]Listener, len(*in))
 		copy(*out, *in)
 	}
+	if in.IPFamilies != nil {
+		in, out := &in.IPFamilies, &out.IPFamilies
+		*out = make([]corev1.IPFamily, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerConfig.
@@ -577,6 +1113,40 @@ func (in *LoadBalancerConfig) DeepCopy() *LoadBalancerConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineBackupStatus) DeepCopyInto(out *MachineBackupStatus) {
+	*out = *in
+	if in.LastSuccessfulBackupTime != nil {
+		in, out := &in.LastSuccessfulBackupTime, &out.LastSuccessfulBackupTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineBackupStatus.
+func (in *MachineBackupStatus) DeepCopy() *MachineBackupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineBackupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MemoryOptions) DeepCopyInto(out *MemoryOptions) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MemoryOptions.
+func (in *MemoryOptions) DeepCopy() *MemoryOptions {
+	if in == nil {
+		return nil
+	}
+	out := new(MemoryOptions)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NetworkConfig) DeepCopyInto(out *NetworkConfig) {
 	*out = *in
@@ -590,6 +1160,13 @@ func (in *NetworkConfig) DeepCopyInto(out *NetworkConfig) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]InterfaceConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkConfig.
@@ -602,6 +1179,42 @@ func (in *NetworkConfig) DeepCopy() *NetworkConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProvisioningTimeout) DeepCopyInto(out *ProvisioningTimeout) {
+	*out = *in
+	out.Timeout = in.Timeout
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvisioningTimeout.
+func (in *ProvisioningTimeout) DeepCopy() *ProvisioningTimeout {
+	if in == nil {
+		return nil
+	}
+	out := new(ProvisioningTimeout)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Route) DeepCopyInto(out *Route) {
+	*out = *in
+	if in.Metric != nil {
+		in, out := &in.Metric, &out.Metric
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Route.
+func (in *Route) DeepCopy() *Route {
+	if in == nil {
+		return nil
+	}
+	out := new(Route)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretKey) DeepCopyInto(out *SecretKey) {
 	*out = *in
@@ -670,6 +1283,18 @@ func (in *VMNetworkConfig) DeepCopyInto(out *VMNetworkConfig) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]InterfaceConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.IPv6 != nil {
+		in, out := &in.IPv6, &out.IPv6
+		*out = new(IPv6NetworkConfig)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VMNetworkConfig.
@@ -685,6 +1310,21 @@ func (in *VMNetworkConfig) DeepCopy() *VMNetworkConfig {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Volume) DeepCopyInto(out *Volume) {
 	*out = *in
+	if in.ImageSelector != nil {
+		in, out := &in.ImageSelector, &out.ImageSelector
+		*out = new(ImageSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageSource != nil {
+		in, out := &in.ImageSource, &out.ImageSource
+		*out = new(ImageSource)
+		**out = **in
+	}
+	if in.CloneSource != nil {
+		in, out := &in.CloneSource, &out.CloneSource
+		*out = new(VolumeCloneSource)
+		**out = **in
+	}
 	if in.VolumeSize != nil {
 		in, out := &in.VolumeSize, &out.VolumeSize
 		x := (*in).DeepCopy()
@@ -701,3 +1341,58 @@ func (in *Volume) DeepCopy() *Volume {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeCloneSource) DeepCopyInto(out *VolumeCloneSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeCloneSource.
+func (in *VolumeCloneSource) DeepCopy() *VolumeCloneSource {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeCloneSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeStatus) DeepCopyInto(out *VolumeStatus) {
+	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeStatus.
+func (in *VolumeStatus) DeepCopy() *VolumeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WatchdogConfig) DeepCopyInto(out *WatchdogConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WatchdogConfig.
+func (in *WatchdogConfig) DeepCopy() *WatchdogConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WatchdogConfig)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -14,3 +14,9 @@ func (*HarvesterClusterTemplate) Hub() {}
 
 // Hub marks HarvesterMachineTemplate as a conversion hub.
 func (*HarvesterMachineTemplate) Hub() {}
+
+// Hub marks HarvesterMachinePool as a conversion hub.
+func (*HarvesterMachinePool) Hub() {}
+
+// Hub marks HarvesterIPPool as a conversion hub.
+func (*HarvesterIPPool) Hub() {}
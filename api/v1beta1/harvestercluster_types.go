@@ -19,6 +19,7 @@ package v1beta1
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 )
@@ -34,6 +35,16 @@ const (
 	DHCP = "dhcp"
 	// POOL is one of the possible values for the IPAMType field in the LoadBalancerConfig.
 	POOL = "pool"
+
+	// ReleaseLeakedIPsAnnotation, set to "true" on a HarvesterCluster, lets the
+	// IP pool audit release the allocations it found leaked.
+	ReleaseLeakedIPsAnnotation = "harvester.infrastructure.cluster.x-k8s.io/release-leaked-ips"
+
+	// ForgetLoadBalancerIPAnnotation, set to "true" on a HarvesterCluster, drops the
+	// load balancer address from the allocation history of its IP pool when the
+	// cluster is deleted, instead of keeping it for a cluster recreated with the
+	// same name.
+	ForgetLoadBalancerIPAnnotation = "harvester.infrastructure.cluster.x-k8s.io/forget-lb-ip"
 )
 
 const (
@@ -109,6 +120,38 @@ const (
 	FleetIntegrationReadyReason = "FleetIntegrationReady"
 	// FleetIntegrationFailedReason documents that Fleet integration failed.
 	FleetIntegrationFailedReason = "FleetIntegrationFailed"
+
+	// IPPoolAllocationsHealthyCondition documents whether the IP pools of the cluster hold
+	// allocations whose owner no longer exists.
+	IPPoolAllocationsHealthyCondition string = "IPPoolAllocationsHealthy"
+	// IPPoolAllocationsHealthyReason documents that every audited allocation has an owner.
+	IPPoolAllocationsHealthyReason = "IPPoolAllocationsHealthy"
+	// IPPoolAllocationsLeakedReason documents that some allocations have no owner anymore.
+	IPPoolAllocationsLeakedReason = "IPPoolAllocationsLeaked"
+	// IPPoolAuditFailedReason documents that the IP pools could not be audited.
+	IPPoolAuditFailedReason = "IPPoolAuditFailed"
+
+	// IPPoolCapacityAvailableCondition documents whether the IP pools of the cluster have
+	// more free addresses than spec.ipPoolFreeThreshold.
+	IPPoolCapacityAvailableCondition string = "IPPoolCapacityAvailable"
+	// IPPoolCapacityAvailableReason documents that every IP pool has enough free addresses.
+	IPPoolCapacityAvailableReason = "IPPoolCapacityAvailable"
+	// IPPoolCapacityLowReason documents that an IP pool has fewer free addresses than the threshold.
+	IPPoolCapacityLowReason = "IPPoolCapacityLow"
+	// IPPoolCapacityExhaustedReason documents that an IP pool has no free address.
+	IPPoolCapacityExhaustedReason = "IPPoolCapacityExhausted"
+	// IPPoolCapacityCheckFailedReason documents that the capacity of the IP pools could not be checked.
+	IPPoolCapacityCheckFailedReason = "IPPoolCapacityCheckFailed"
+
+	// BackupSchedulesReadyCondition documents whether the Harvester backup
+	// schedules of the VMs of the cluster follow spec.backupPolicy.
+	BackupSchedulesReadyCondition string = "BackupSchedulesReady"
+	// BackupSchedulesReadyReason documents that every VM covered by the backup
+	// policy has its backup schedule.
+	BackupSchedulesReadyReason = "BackupSchedulesReady"
+	// BackupSchedulesFailedReason documents that the backup schedules could not
+	// be synchronized with the backup policy.
+	BackupSchedulesFailedReason = "BackupSchedulesFailed"
 )
 
 const (
@@ -145,8 +188,82 @@ type HarvesterClusterSpec struct {
 	// VMNetworkConfig is the network configuration for VMs that use static IPs from a pool.
 	// +optional
 	VMNetworkConfig *VMNetworkConfig `json:"vmNetworkConfig,omitempty"`
+
+	// IPPoolFreeThreshold is the number (e.g. 5) or percentage (e.g. "10%") of free
+	// addresses below which an IP pool of the cluster is reported as running low
+	// in the IPPoolCapacityAvailable condition. Defaults to "10%".
+	// +optional
+	IPPoolFreeThreshold *intstr.IntOrString `json:"ipPoolFreeThreshold,omitempty"`
+
+	// ProvisioningTimeout is the default provisioning timeout of the machines
+	// of the cluster. The provisioningTimeout of a HarvesterMachine takes
+	// precedence over it.
+	// +optional
+	ProvisioningTimeout *ProvisioningTimeout `json:"provisioningTimeout,omitempty"`
+
+	// ShutdownGracePeriod is the default shutdown grace period of the machines
+	// of the cluster. The shutdownGracePeriod of a HarvesterMachine takes
+	// precedence over it.
+	// +optional
+	ShutdownGracePeriod *metav1.Duration `json:"shutdownGracePeriod,omitempty"`
+
+	// DeletionPolicy is the default deletion policy of the machines of the
+	// cluster. The deletionPolicy of a HarvesterMachine takes precedence over it.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// BackupPolicy schedules Harvester backups of the VMs of the cluster.
+	// +optional
+	BackupPolicy *BackupPolicy `json:"backupPolicy,omitempty"`
+}
+
+// BackupPolicy describes the Harvester backup schedules of the VMs of a cluster.
+type BackupPolicy struct {
+	// Target is where the backups are kept: "backup" copies the volumes to the
+	// backup target configured in Harvester, "snapshot" keeps them as volume
+	// snapshots in the Harvester cluster. Defaults to "backup".
+	// +optional
+	Target BackupTarget `json:"target,omitempty"`
+
+	// Schedule is the cron expression of the backups, e.g. "0 2 * * *".
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Retention is the number of backups kept for each VM. Defaults to 8.
+	// +kubebuilder:validation:Minimum=2
+	// +kubebuilder:validation:Maximum=250
+	// +optional
+	Retention int32 `json:"retention,omitempty"`
+
+	// Roles are the roles of the machines whose VMs are backed up. Defaults to
+	// both control plane and worker machines.
+	// +optional
+	Roles []MachineRole `json:"roles,omitempty"`
 }
 
+// BackupTarget is where the backups of a VM are kept.
+// +kubebuilder:validation:Enum=backup;snapshot
+type BackupTarget string
+
+const (
+	// BackupTargetBackup copies the volumes to the backup target of Harvester.
+	BackupTargetBackup BackupTarget = "backup"
+	// BackupTargetSnapshot keeps volume snapshots in the Harvester cluster.
+	BackupTargetSnapshot BackupTarget = "snapshot"
+)
+
+// MachineRole is the role of a machine in its cluster.
+// +kubebuilder:validation:Enum=controlplane;worker
+type MachineRole string
+
+const (
+	// MachineRoleControlPlane is the role of the control plane machines.
+	MachineRoleControlPlane MachineRole = "controlplane"
+	// MachineRoleWorker is the role of the other machines, machine pool
+	// instances included.
+	MachineRoleWorker MachineRole = "worker"
+)
+
 // VMNetworkConfig describes the network configuration for VM static IP allocation.
 type VMNetworkConfig struct {
 	// IPPoolRef is a reference to an existing IPPool in Harvester for VM IP allocation.
@@ -183,6 +300,38 @@ type VMNetworkConfig struct {
 	// DNSSearch is a list of DNS search domains.
 	// +optional
 	DNSSearch []string `json:"dnsSearch,omitempty"`
+
+	// Interfaces configures the addressing of individual network attachments.
+	// The address of the first network is allocated from the pools above
+	// unless an entry configures that network. Other attachments without an
+	// entry use DHCP.
+	// +optional
+	Interfaces []InterfaceConfig `json:"interfaces,omitempty"`
+
+	// IPv6 adds an IPv6 address, allocated from separate IPv6 pools, to the
+	// first network of dual-stack machines.
+	// +optional
+	IPv6 *IPv6NetworkConfig `json:"ipv6,omitempty"`
+}
+
+// IPv6NetworkConfig describes the IPv6 addressing of the first network of a
+// dual-stack machine.
+type IPv6NetworkConfig struct {
+	// IPPoolRefs is a list of references to existing IPv6 IPPools in Harvester.
+	// Pools are tried in order and follow the same network-aware selection as
+	// the IPv4 pools; pools of the other IP family are skipped.
+	// +kubebuilder:validation:MinItems=1
+	IPPoolRefs []string `json:"ipPoolRefs"`
+
+	// Gateway is the IPv6 gateway address. Leave it empty to rely on router
+	// advertisements for the IPv6 default route.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+
+	// PrefixLength is the prefix length of the IPv6 subnet (e.g. 64).
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=128
+	PrefixLength int32 `json:"prefixLength"`
 }
 
 // GetIPPoolRefs returns all configured IPPool references in priority order.
@@ -229,6 +378,17 @@ type LoadBalancerConfig struct {
 	// Description is a description of the load balancer that should be created.
 	// +optional
 	Description string `json:"description,omitempty"`
+
+	// IPFamilies lists the IP families of the control plane load balancer,
+	// primary family first: ["IPv4"] (default), ["IPv6"], or both for a
+	// dual-stack load balancer. The control plane endpoint uses the address of
+	// the primary family, so the ipPool or ipPoolRef must be of that family.
+	// A dual-stack load balancer needs ipamType dhcp, as an IP pool only
+	// provides addresses of one family.
+	// +kubebuilder:validation:MaxItems=2
+	// +kubebuilder:validation:items:Enum=IPv4;IPv6
+	// +optional
+	IPFamilies []corev1.IPFamily `json:"ipFamilies,omitempty"`
 }
 
 // IPAMType describes the way the LoadBalancer IP should be created, using DHCP or using an IPPool defined in Harvester.
@@ -241,8 +401,8 @@ type IpPool struct {
 	// The reference can have the format "namespace/name" or just "name" if the object is in the same namespace as the HarvesterCluster.
 	VMNetwork string `json:"vmNetwork"`
 
-	// Subnet is a string describing the subnet that should be used by the IP Pool, it should have the CIDR Format of an IPv4 Address.
-	// e.g. 172.17.1.0/24.
+	// Subnet is a string describing the subnet that should be used by the IP Pool, in CIDR format.
+	// e.g. 172.17.1.0/24 or 2001:db8:1::/64.
 	Subnet string `json:"subnet"`
 
 	// Gateway is the IP Address that should be used by the Gateway on the Subnet. It should be a valid address inside the subnet.
@@ -320,6 +480,52 @@ type HarvesterClusterStatus struct {
 	// in its attributes.
 	// +optional
 	FailureDomains []clusterv1.FailureDomain `json:"failureDomains,omitempty"`
+
+	// LastIPPoolAuditTime is the time of the last audit of the allocations of
+	// the IP pools used by the cluster.
+	// +optional
+	LastIPPoolAuditTime *metav1.Time `json:"lastIPPoolAuditTime,omitempty"`
+
+	// LeakedIPAllocations lists the allocations of the IP pools used by the
+	// cluster whose owner no longer exists, as found by the last audit.
+	// +optional
+	LeakedIPAllocations []LeakedIPAllocation `json:"leakedIPAllocations,omitempty"`
+
+	// MachineBackups lists the VMs backed up by the backup policy, with their
+	// backup schedule and the time of their last successful backup.
+	// +optional
+	MachineBackups []MachineBackupStatus `json:"machineBackups,omitempty"`
+}
+
+// MachineBackupStatus is the backup status of the VM of a machine.
+type MachineBackupStatus struct {
+	// Machine is the name of the VM of the machine.
+	Machine string `json:"machine"`
+
+	// Schedule is the name of the ScheduleVMBackup of the VM in the target
+	// namespace.
+	Schedule string `json:"schedule"`
+
+	// LastSuccessfulBackupTime is the creation time of the most recent backup
+	// of the VM ready to use.
+	// +optional
+	LastSuccessfulBackupTime *metav1.Time `json:"lastSuccessfulBackupTime,omitempty"`
+}
+
+// LeakedIPAllocation is an allocation of a Harvester IPPool whose owner no
+// longer exists.
+type LeakedIPAllocation struct {
+	// Pool is the name of the IPPool holding the allocation.
+	Pool string `json:"pool"`
+
+	// Address is the allocated IP address.
+	Address string `json:"address"`
+
+	// Owner is the owner recorded for the allocation in the pool.
+	Owner string `json:"owner"`
+
+	// Since is the time of the first audit that found the owner missing.
+	Since metav1.Time `json:"since"`
 }
 
 //+kubebuilder:object:root=true
@@ -24,6 +24,9 @@ import (
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // HarvesterClusterValidator implements admission.Validator for HarvesterCluster.
@@ -75,6 +78,15 @@ func validateHarvesterCluster(r *HarvesterCluster) (admission.Warnings, error) {
 		errs = append(errs, fmt.Sprintf("spec.loadBalancerConfig.ipamType must be %q or %q", DHCP, POOL))
 	}
 
+	if families := r.Spec.LoadBalancerConfig.IPFamilies; len(families) == 2 && families[0] == families[1] {
+		errs = append(errs, fmt.Sprintf("spec.loadBalancerConfig.ipFamilies must not repeat %s", families[0]))
+	}
+
+	// An IP pool has a single family, and the load balancer gets one address from it
+	if len(r.Spec.LoadBalancerConfig.IPFamilies) > 1 && r.Spec.LoadBalancerConfig.IPAMType == IPAMType(POOL) {
+		errs = append(errs, fmt.Sprintf("spec.loadBalancerConfig.ipFamilies cannot list two families with ipamType %q", POOL))
+	}
+
 	if r.Spec.VMNetworkConfig != nil {
 		vmCfg := r.Spec.VMNetworkConfig
 		if len(vmCfg.GetIPPoolRefs()) == 0 && vmCfg.IPPool == nil {
@@ -92,8 +104,17 @@ func validateHarvesterCluster(r *HarvesterCluster) (admission.Warnings, error) {
 		} else if net.ParseIP(vmCfg.SubnetMask) == nil {
 			errs = append(errs, fmt.Sprintf("spec.vmNetworkConfig.subnetMask %q is not a valid IP address", vmCfg.SubnetMask))
 		}
+
+		errs = append(errs, validateIPv6NetworkConfig("spec.vmNetworkConfig.ipv6", vmCfg.IPv6)...)
+		errs = append(errs, validateInterfaceConfigs("spec.vmNetworkConfig.interfaces", vmCfg.Interfaces)...)
 	}
 
+	errs = append(errs, validateIPPoolFreeThreshold("spec.ipPoolFreeThreshold", r.Spec.IPPoolFreeThreshold)...)
+	errs = append(errs, validateProvisioningTimeout("spec.provisioningTimeout", r.Spec.ProvisioningTimeout)...)
+	errs = append(errs, validateShutdownGracePeriod("spec.shutdownGracePeriod", r.Spec.ShutdownGracePeriod)...)
+	errs = append(errs, validateDeletionPolicy("spec.deletionPolicy", r.Spec.DeletionPolicy)...)
+	errs = append(errs, validateBackupPolicy("spec.backupPolicy", r.Spec.BackupPolicy)...)
+
 	if len(errs) > 0 {
 		return nil, fmt.Errorf("validation failed for HarvesterCluster %s/%s: %s",
 			r.Namespace, r.Name, strings.Join(errs, "; "))
@@ -101,3 +122,102 @@ func validateHarvesterCluster(r *HarvesterCluster) (admission.Warnings, error) {
 
 	return nil, nil
 }
+
+// validateIPPoolFreeThreshold checks that threshold is a non-negative number or
+// a percentage up to 100%.
+func validateIPPoolFreeThreshold(path string, threshold *intstr.IntOrString) []string {
+	if threshold == nil {
+		return nil
+	}
+
+	value, err := intstr.GetScaledValueFromIntOrPercent(threshold, 100, true) //nolint:mnd // percentage scale
+	if err != nil {
+		return []string{fmt.Sprintf("%s %q must be a number or a percentage", path, threshold.String())}
+	}
+
+	if value < 0 || (threshold.Type == intstr.String && value > 100) { //nolint:mnd // percentage scale
+		return []string{fmt.Sprintf("%s %q must be a non-negative number or a percentage up to 100%%", path, threshold.String())}
+	}
+
+	return nil
+}
+
+// validateProvisioningTimeout checks that the timeout is positive and that the
+// VM is not recreated a negative number of times.
+func validateProvisioningTimeout(path string, timeout *ProvisioningTimeout) []string {
+	if timeout == nil {
+		return nil
+	}
+
+	var errs []string
+
+	if timeout.Timeout.Duration <= 0 {
+		errs = append(errs, fmt.Sprintf("%s.timeout %s must be positive", path, timeout.Timeout.Duration))
+	}
+
+	if timeout.MaxRecreations < 0 {
+		errs = append(errs, fmt.Sprintf("%s.maxRecreations %d must not be negative", path, timeout.MaxRecreations))
+	}
+
+	return errs
+}
+
+// validateShutdownGracePeriod checks that the grace period is not negative.
+func validateShutdownGracePeriod(path string, gracePeriod *metav1.Duration) []string {
+	if gracePeriod == nil || gracePeriod.Duration >= 0 {
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s %s must not be negative", path, gracePeriod.Duration)}
+}
+
+// validateDeletionPolicy checks that the deletion policy is a known one.
+func validateDeletionPolicy(path string, policy DeletionPolicy) []string {
+	switch policy {
+	case "", DeletionPolicyDelete, DeletionPolicyRetain, DeletionPolicySnapshot:
+		return nil
+	}
+
+	return []string{fmt.Sprintf("%s %q must be 'Delete', 'Retain' or 'Snapshot'", path, policy)}
+}
+
+// validateBackupPolicy checks the target, schedule, retention and roles of the
+// backup policy.
+func validateBackupPolicy(path string, policy *BackupPolicy) []string {
+	if policy == nil {
+		return nil
+	}
+
+	errs := make([]string, 0)
+
+	switch policy.Target {
+	case "", BackupTargetBackup, BackupTargetSnapshot:
+	default:
+		errs = append(errs, fmt.Sprintf("%s.target %q must be 'backup' or 'snapshot'", path, policy.Target))
+	}
+
+	if len(strings.Fields(policy.Schedule)) != 5 {
+		errs = append(errs, fmt.Sprintf("%s.schedule %q must be a cron expression of 5 fields", path, policy.Schedule))
+	}
+
+	if policy.Retention != 0 && (policy.Retention < 2 || policy.Retention > 250) {
+		errs = append(errs, fmt.Sprintf("%s.retention %d must be between 2 and 250", path, policy.Retention))
+	}
+
+	seen := make(map[MachineRole]bool, len(policy.Roles))
+	for i, role := range policy.Roles {
+		switch role {
+		case MachineRoleControlPlane, MachineRoleWorker:
+		default:
+			errs = append(errs, fmt.Sprintf("%s.roles[%d] %q must be 'controlplane' or 'worker'", path, i, role))
+		}
+
+		if seen[role] {
+			errs = append(errs, fmt.Sprintf("%s.roles[%d] %q is duplicated", path, i, role))
+		}
+
+		seen[role] = true
+	}
+
+	return errs
+}
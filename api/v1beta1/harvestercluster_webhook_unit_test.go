@@ -19,6 +19,11 @@ package v1beta1
 import (
 	"strings"
 	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 func validCluster() *HarvesterCluster {
@@ -60,3 +65,274 @@ func TestValidateVMNetworkConfigPoolSources(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateClusterDualStack(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(c *HarvesterCluster)
+		wantErr string
+	}{
+		{
+			"dual-stack load balancer and IPv6 pools",
+			func(c *HarvesterCluster) {
+				c.Spec.LoadBalancerConfig.IPFamilies = []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol}
+				c.Spec.VMNetworkConfig = &VMNetworkConfig{
+					IPPoolRef:  "pool-a",
+					Gateway:    "10.0.0.1",
+					SubnetMask: "255.255.255.0",
+					IPv6:       &IPv6NetworkConfig{IPPoolRefs: []string{"pool-a-v6"}, Gateway: "2001:db8::1", PrefixLength: 64},
+				}
+			},
+			"",
+		},
+		{
+			"dual-stack load balancer from an IP pool",
+			func(c *HarvesterCluster) {
+				c.Spec.LoadBalancerConfig.IPAMType = IPAMType(POOL)
+				c.Spec.LoadBalancerConfig.IpPoolRef = "lb-pool"
+				c.Spec.LoadBalancerConfig.IPFamilies = []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}
+			},
+			"ipamType",
+		},
+		{
+			"repeated IP family",
+			func(c *HarvesterCluster) {
+				c.Spec.LoadBalancerConfig.IPFamilies = []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv4Protocol}
+			},
+			"ipFamilies",
+		},
+		{
+			"IPv4 gateway in the IPv6 configuration",
+			func(c *HarvesterCluster) {
+				c.Spec.VMNetworkConfig = &VMNetworkConfig{
+					IPPoolRef:  "pool-a",
+					Gateway:    "10.0.0.1",
+					SubnetMask: "255.255.255.0",
+					IPv6:       &IPv6NetworkConfig{IPPoolRefs: []string{"pool-a-v6"}, Gateway: "10.0.0.1", PrefixLength: 64},
+				}
+			},
+			"ipv6.gateway",
+		},
+		{
+			"IPv6 configuration without pools",
+			func(c *HarvesterCluster) {
+				c.Spec.VMNetworkConfig = &VMNetworkConfig{
+					IPPoolRef:  "pool-a",
+					Gateway:    "10.0.0.1",
+					SubnetMask: "255.255.255.0",
+					IPv6:       &IPv6NetworkConfig{PrefixLength: 64},
+				}
+			},
+			"ipv6.ipPoolRefs",
+		},
+	}
+	for _, tc := range cases {
+		c := validCluster()
+		tc.mutate(c)
+
+		_, err := validateHarvesterCluster(c)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestValidateIPPoolFreeThreshold(t *testing.T) {
+	cases := []struct {
+		name      string
+		threshold intstr.IntOrString
+		wantErr   bool
+	}{
+		{"absolute number", intstr.FromInt32(5), false},
+		{"percentage", intstr.FromString("10%"), false},
+		{"zero", intstr.FromInt32(0), false},
+		{"negative number", intstr.FromInt32(-1), true},
+		{"percentage above 100", intstr.FromString("150%"), true},
+		{"not a percentage", intstr.FromString("ten"), true},
+	}
+	for _, tc := range cases {
+		c := validCluster()
+		c.Spec.IPPoolFreeThreshold = &tc.threshold
+
+		_, err := validateHarvesterCluster(c)
+
+		if tc.wantErr && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if !tc.wantErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr && err != nil && !strings.Contains(err.Error(), "ipPoolFreeThreshold") {
+			t.Errorf("%s: error should mention ipPoolFreeThreshold: %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidateProvisioningTimeout(t *testing.T) {
+	cases := []struct {
+		name    string
+		timeout ProvisioningTimeout
+		wantErr string
+	}{
+		{"timeout only", ProvisioningTimeout{Timeout: metav1.Duration{Duration: 15 * time.Minute}}, ""},
+		{"timeout with recreations", ProvisioningTimeout{Timeout: metav1.Duration{Duration: time.Hour}, MaxRecreations: 2}, ""},
+		{"zero timeout", ProvisioningTimeout{}, "spec.provisioningTimeout.timeout 0s must be positive"},
+		{
+			"negative recreations",
+			ProvisioningTimeout{Timeout: metav1.Duration{Duration: time.Hour}, MaxRecreations: -1},
+			"spec.provisioningTimeout.maxRecreations -1 must not be negative",
+		},
+	}
+	for _, tc := range cases {
+		c := validCluster()
+		c.Spec.ProvisioningTimeout = &tc.timeout
+
+		m := validMachine()
+		m.Spec.ProvisioningTimeout = &tc.timeout
+
+		_, clusterErr := validateHarvesterCluster(c)
+		_, machineErr := validateHarvesterMachine(m)
+
+		for _, err := range []error{clusterErr, machineErr} {
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			}
+
+			if tc.wantErr != "" && err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+
+			if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+			}
+		}
+	}
+}
+
+func TestValidateShutdownGracePeriod(t *testing.T) {
+	cases := []struct {
+		name        string
+		gracePeriod time.Duration
+		wantErr     string
+	}{
+		{"grace period", 5 * time.Minute, ""},
+		{"zero grace period", 0, ""},
+		{"negative grace period", -time.Minute, "spec.shutdownGracePeriod -1m0s must not be negative"},
+	}
+	for _, tc := range cases {
+		c := validCluster()
+		c.Spec.ShutdownGracePeriod = &metav1.Duration{Duration: tc.gracePeriod}
+
+		m := validMachine()
+		m.Spec.ShutdownGracePeriod = &metav1.Duration{Duration: tc.gracePeriod}
+
+		_, clusterErr := validateHarvesterCluster(c)
+		_, machineErr := validateHarvesterMachine(m)
+
+		for _, err := range []error{clusterErr, machineErr} {
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			}
+
+			if tc.wantErr != "" && err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+
+			if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+			}
+		}
+	}
+}
+
+func TestValidateDeletionPolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  DeletionPolicy
+		wantErr string
+	}{
+		{"default policy", "", ""},
+		{"retain", DeletionPolicyRetain, ""},
+		{"snapshot", DeletionPolicySnapshot, ""},
+		{"unknown policy", "Orphan", "spec.deletionPolicy \"Orphan\" must be 'Delete', 'Retain' or 'Snapshot'"},
+	}
+	for _, tc := range cases {
+		c := validCluster()
+		c.Spec.DeletionPolicy = tc.policy
+
+		m := validMachine()
+		m.Spec.DeletionPolicy = tc.policy
+
+		_, clusterErr := validateHarvesterCluster(c)
+		_, machineErr := validateHarvesterMachine(m)
+
+		for _, err := range []error{clusterErr, machineErr} {
+			if tc.wantErr == "" && err != nil {
+				t.Errorf("%s: unexpected error: %v", tc.name, err)
+			}
+
+			if tc.wantErr != "" && err == nil {
+				t.Errorf("%s: expected an error", tc.name)
+			}
+
+			if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+			}
+		}
+	}
+}
+
+func TestValidateBackupPolicy(t *testing.T) {
+	cases := []struct {
+		name    string
+		policy  *BackupPolicy
+		wantErr string
+	}{
+		{"no policy", nil, ""},
+		{"defaults", &BackupPolicy{Schedule: "0 2 * * *"}, ""},
+		{"full policy", &BackupPolicy{
+			Target:    BackupTargetSnapshot,
+			Schedule:  "*/30 * * * *",
+			Retention: 10,
+			Roles:     []MachineRole{MachineRoleControlPlane},
+		}, ""},
+		{"unknown target", &BackupPolicy{Target: "s3", Schedule: "0 2 * * *"},
+			"spec.backupPolicy.target \"s3\" must be 'backup' or 'snapshot'"},
+		{"invalid schedule", &BackupPolicy{Schedule: "@daily"},
+			"spec.backupPolicy.schedule \"@daily\" must be a cron expression of 5 fields"},
+		{"retention too low", &BackupPolicy{Schedule: "0 2 * * *", Retention: 1},
+			"spec.backupPolicy.retention 1 must be between 2 and 250"},
+		{"unknown role", &BackupPolicy{Schedule: "0 2 * * *", Roles: []MachineRole{"etcd"}},
+			"spec.backupPolicy.roles[0] \"etcd\" must be 'controlplane' or 'worker'"},
+		{"duplicated role", &BackupPolicy{Schedule: "0 2 * * *", Roles: []MachineRole{MachineRoleWorker, MachineRoleWorker}},
+			"spec.backupPolicy.roles[1] \"worker\" is duplicated"},
+	}
+	for _, tc := range cases {
+		c := validCluster()
+		c.Spec.BackupPolicy = tc.policy
+
+		_, err := validateHarvesterCluster(c)
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
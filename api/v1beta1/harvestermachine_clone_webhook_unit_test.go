@@ -0,0 +1,99 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestValidateMachineCloneVolumes(t *testing.T) {
+	snapshotSource := &VolumeCloneSource{Kind: VolumeCloneSourceVolumeSnapshot, Name: "golden-sles-snap"}
+
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"clone from a VolumeSnapshot is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0] = Volume{VolumeType: "clone", CloneSource: snapshotSource}
+			},
+			"",
+		},
+		{
+			"clone from a PVC with a size and storage class is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0] = Volume{
+					VolumeType: "clone", StorageClass: "lh-sles", VolumeSize: new(resource.MustParse("100Gi")),
+					CloneSource: &VolumeCloneSource{Kind: VolumeCloneSourcePersistentVolumeClaim, Name: "golden-sles"},
+				}
+			},
+			"",
+		},
+		{
+			"clone without source is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0] = Volume{VolumeType: "clone"}
+			},
+			"cloneSource is required",
+		},
+		{
+			"clone source without name is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0] = Volume{VolumeType: "clone", CloneSource: &VolumeCloneSource{Kind: VolumeCloneSourceVolumeSnapshot}}
+			},
+			"cloneSource.name is required",
+		},
+		{
+			"clone source of an unknown kind is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0] = Volume{VolumeType: "clone", CloneSource: &VolumeCloneSource{Kind: "DataVolume", Name: "golden"}}
+			},
+			"cloneSource.kind must be",
+		},
+		{
+			"clone source on an image volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].CloneSource = snapshotSource
+			},
+			"cloneSource is only allowed",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateCreate(context.TODO(), m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
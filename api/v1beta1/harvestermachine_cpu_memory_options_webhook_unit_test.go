@@ -0,0 +1,146 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateMachineCPUAndMemoryOptions(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"topology, model and dedicated CPUs are valid",
+			func(m *HarvesterMachine) {
+				m.Spec.CPU = 8
+				m.Spec.CPUOptions = &CPUOptions{
+					Sockets: 2, Threads: 2, Model: "host-passthrough",
+					DedicatedCPUPlacement: true, IsolateEmulatorThread: true,
+				}
+			},
+			"",
+		},
+		{
+			"cpu that does not fill the sockets and threads is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.CPU = 6
+				m.Spec.CPUOptions = &CPUOptions{Sockets: 2, Threads: 2}
+			},
+			"spec.cpu 6 must be a multiple of spec.cpuOptions.sockets times spec.cpuOptions.threads (4)",
+		},
+		{
+			"isolated emulator thread without dedicated CPUs is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.CPUOptions = &CPUOptions{IsolateEmulatorThread: true}
+			},
+			"spec.cpuOptions.isolateEmulatorThread requires spec.cpuOptions.dedicatedCPUPlacement",
+		},
+		{
+			"sockets with hotplug are rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.CPUOptions = &CPUOptions{Sockets: 2}
+				m.Spec.Hotplug = &HotplugConfig{MaxCPU: 8, MaxMemory: "32Gi"}
+			},
+			"spec.cpuOptions.sockets and threads cannot be set with spec.hotplug",
+		},
+		{
+			"hugepages are valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Memory = "8Gi"
+				m.Spec.MemoryOptions = &MemoryOptions{HugepagesPageSize: "1Gi"}
+			},
+			"",
+		},
+		{
+			"memory that does not fill the hugepages is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Memory = "1536Mi"
+				m.Spec.MemoryOptions = &MemoryOptions{HugepagesPageSize: "1Gi"}
+			},
+			"spec.memory 1536Mi must be a multiple of spec.memoryOptions.hugepagesPageSize 1Gi",
+		},
+		{
+			"unknown hugepages size is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.MemoryOptions = &MemoryOptions{HugepagesPageSize: "4Ki"}
+			},
+			"spec.memoryOptions.hugepagesPageSize \"4Ki\" must be '2Mi' or '1Gi'",
+		},
+		{
+			"memory overcommit is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.MemoryOptions = &MemoryOptions{OvercommitPercent: 150}
+			},
+			"",
+		},
+		{
+			"overcommit below 100 percent is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.MemoryOptions = &MemoryOptions{OvercommitPercent: 50}
+			},
+			"spec.memoryOptions.overcommitPercent 50 must be at least 100",
+		},
+		{
+			"overcommit with dedicated CPUs is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.CPUOptions = &CPUOptions{DedicatedCPUPlacement: true}
+				m.Spec.MemoryOptions = &MemoryOptions{OvercommitPercent: 150}
+			},
+			"spec.memoryOptions.overcommitPercent cannot be set with spec.cpuOptions.dedicatedCPUPlacement",
+		},
+		{
+			"overcommit with hugepages is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.MemoryOptions = &MemoryOptions{HugepagesPageSize: "2Mi", OvercommitPercent: 150}
+			},
+			"spec.memoryOptions.overcommitPercent cannot be set with spec.memoryOptions.hugepagesPageSize",
+		},
+		{
+			"cpu options with an instancetype are rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.CPU = 0
+				m.Spec.Memory = ""
+				m.Spec.Instancetype = "u1.medium"
+				m.Spec.CPUOptions = &CPUOptions{Model: "host-passthrough"}
+			},
+			"spec.cpuOptions cannot be set with spec.instancetype",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateCreate(context.TODO(), m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMachineDevices(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"watchdog with the default action is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Devices = &GuestDevices{Watchdog: &WatchdogConfig{}}
+			},
+			"",
+		},
+		{
+			"watchdog powering off the VM with a virtio-rng device is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Devices = &GuestDevices{Watchdog: &WatchdogConfig{Action: "poweroff"}, RNG: true}
+			},
+			"",
+		},
+		{
+			"unknown watchdog action is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Devices = &GuestDevices{Watchdog: &WatchdogConfig{Action: "pause"}}
+			},
+			"spec.devices.watchdog.action \"pause\" must be 'reset' or 'poweroff'",
+		},
+		{
+			"devices with a template version are rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.CPU = 0
+				m.Spec.Memory = ""
+				m.Spec.Volumes = nil
+				m.Spec.VMTemplateVersion = "templates/sles-worker-v3"
+				m.Spec.Devices = &GuestDevices{RNG: true}
+			},
+			"spec.devices cannot be set with spec.vmTemplateVersion",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := validateHarvesterMachine(m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
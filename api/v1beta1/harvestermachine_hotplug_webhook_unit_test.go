@@ -0,0 +1,84 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMachineHotplug(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"maximums above the spec are valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Hotplug = &HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"}
+			},
+			"",
+		},
+		{
+			"maximums equal to the spec are valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Hotplug = &HotplugConfig{MaxCPU: 2, MaxMemory: "4096Mi"}
+			},
+			"",
+		},
+		{
+			"max cpu below cpu is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Hotplug = &HotplugConfig{MaxCPU: 1, MaxMemory: "16Gi"}
+			},
+			"maxCPU 1 must be at least",
+		},
+		{
+			"max memory below memory is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Hotplug = &HotplugConfig{MaxCPU: 8, MaxMemory: "2Gi"}
+			},
+			"maxMemory 2Gi must be at least",
+		},
+		{
+			"invalid max memory is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Hotplug = &HotplugConfig{MaxCPU: 8, MaxMemory: "lots"}
+			},
+			"not a valid resource quantity",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := validateHarvesterMachine(m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateMachineImageSelector(t *testing.T) {
+	slesStable := metav1.LabelSelector{MatchLabels: map[string]string{"os": "sles", "channel": "stable"}}
+
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"image selector without image name is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageName = ""
+				m.Spec.Volumes[0].ImageSelector = &ImageSelector{Namespace: "images", LabelSelector: slesStable}
+			},
+			"",
+		},
+		{
+			"image volume without image name or selector is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageName = ""
+			},
+			"imageName or imageSelector is required",
+		},
+		{
+			"image selector with an image name is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageSelector = &ImageSelector{LabelSelector: slesStable}
+			},
+			"mutually exclusive with imageName",
+		},
+		{
+			"image selector on a storage class volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0] = Volume{
+					VolumeType: "storageClass", StorageClass: "longhorn",
+					ImageSelector: &ImageSelector{LabelSelector: slesStable},
+				}
+			},
+			"imageSelector is only allowed",
+		},
+		{
+			"empty image selector is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageName = ""
+				m.Spec.Volumes[0].ImageSelector = &ImageSelector{}
+			},
+			"must set matchLabels or matchExpressions",
+		},
+		{
+			"image selector with an invalid expression is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageName = ""
+				m.Spec.Volumes[0].ImageSelector = &ImageSelector{LabelSelector: metav1.LabelSelector{
+					MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "os", Operator: "Matches"}},
+				}}
+			},
+			"imageSelector is invalid",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateCreate(context.TODO(), m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
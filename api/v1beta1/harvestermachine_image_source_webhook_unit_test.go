@@ -0,0 +1,93 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateMachineImageSource(t *testing.T) {
+	checksum := strings.Repeat("0123456789abcdef", 8)
+
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"image source with a SHA-512 checksum is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageSource = &ImageSource{URL: "https://download.example.com/leap.qcow2", Checksum: checksum}
+			},
+			"",
+		},
+		{
+			"image source on a storage class volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0] = Volume{
+					VolumeType: "storageClass", StorageClass: "longhorn",
+					ImageSource: &ImageSource{URL: "https://download.example.com/leap.qcow2", Checksum: checksum},
+				}
+			},
+			"imageSource is only allowed",
+		},
+		{
+			"image source with a file URL is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageSource = &ImageSource{URL: "file:///tmp/leap.qcow2", Checksum: checksum}
+			},
+			"must be an http or https URL",
+		},
+		{
+			"image source with a SHA-256 checksum is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageSource = &ImageSource{
+					URL: "https://download.example.com/leap.qcow2", Checksum: checksum[:64],
+				}
+			},
+			"must be a SHA-512 checksum",
+		},
+		{
+			"image source with an invalid image name is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageName = "default/Leap_15"
+				m.Spec.Volumes[0].ImageSource = &ImageSource{URL: "https://download.example.com/leap.qcow2", Checksum: checksum}
+			},
+			"cannot name an imported image",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateCreate(context.TODO(), m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateMachineInstancetype(t *testing.T) {
+	withInstancetype := func(m *HarvesterMachine) {
+		m.Spec.CPU = 0
+		m.Spec.Memory = ""
+		m.Spec.Instancetype = "u1.medium"
+	}
+
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"instancetype without cpu and memory is valid",
+			withInstancetype,
+			"",
+		},
+		{
+			"instancetype with a preference is valid",
+			func(m *HarvesterMachine) {
+				withInstancetype(m)
+				m.Spec.Preference = "rhel.9"
+			},
+			"",
+		},
+		{
+			"preference alone keeps cpu and memory",
+			func(m *HarvesterMachine) {
+				m.Spec.Preference = "rhel.9"
+			},
+			"",
+		},
+		{
+			"instancetype with cpu is rejected",
+			func(m *HarvesterMachine) {
+				withInstancetype(m)
+				m.Spec.CPU = 4
+			},
+			"spec.cpu and spec.memory are mutually exclusive with spec.instancetype",
+		},
+		{
+			"instancetype with memory is rejected",
+			func(m *HarvesterMachine) {
+				withInstancetype(m)
+				m.Spec.Memory = "8Gi"
+			},
+			"spec.cpu and spec.memory are mutually exclusive with spec.instancetype",
+		},
+		{
+			"instancetype with hotplug is rejected",
+			func(m *HarvesterMachine) {
+				withInstancetype(m)
+				m.Spec.Hotplug = &HotplugConfig{MaxCPU: 8, MaxMemory: "32Gi"}
+			},
+			"spec.hotplug cannot be set with spec.instancetype",
+		},
+		{
+			"invalid instancetype name is rejected",
+			func(m *HarvesterMachine) {
+				withInstancetype(m)
+				m.Spec.Instancetype = "U1_Medium"
+			},
+			"spec.instancetype \"U1_Medium\" is not a valid name",
+		},
+		{
+			"invalid preference name is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Preference = "rhel/9"
+			},
+			"spec.preference \"rhel/9\" is not a valid name",
+		},
+		{
+			"instancetype with a template version is rejected",
+			func(m *HarvesterMachine) {
+				withInstancetype(m)
+				m.Spec.Volumes = nil
+				m.Spec.VMTemplateVersion = "templates/sles-worker-v3"
+			},
+			"spec.instancetype cannot be set with spec.vmTemplateVersion",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateCreate(context.TODO(), m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -17,9 +17,12 @@ limitations under the License.
 package v1beta1
 
 import (
+	"strings"
+
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
 )
@@ -31,6 +34,18 @@ const (
 
 	// MachineFinalizerLegacy is the old finalizer name without path segment, kept for migration.
 	MachineFinalizerLegacy = "harvestermachine.infrastructure.cluster.x-k8s.io"
+
+	// RetainedClusterLabel is set on the PVCs and VM backups kept after the
+	// deletion of a machine to the name of the cluster the machine belonged to.
+	RetainedClusterLabel = "harvester.infrastructure.cluster.x-k8s.io/retained-cluster"
+
+	// RetainedMachineLabel is set on the PVCs and VM backups kept after the
+	// deletion of a machine to the name of the machine.
+	RetainedMachineLabel = "harvester.infrastructure.cluster.x-k8s.io/retained-machine"
+
+	// RetainedResourcesAnnotation lists, on a deleted HarvesterMachine, the
+	// Harvester resources kept by its deletionPolicy.
+	RetainedResourcesAnnotation = "harvester.infrastructure.cluster.x-k8s.io/retained-resources"
 )
 
 const (
@@ -48,6 +63,48 @@ const (
 	VMProvisioningFailedReason = "VMProvisioningFailed"
 	// VMProvisioningReadyReason documents that VM provisioning is complete.
 	VMProvisioningReadyReason = "VMProvisioningReady"
+	// VMUnschedulableReason documents that no Harvester node can run the VM, for lack
+	// of resources or because of its affinity.
+	VMUnschedulableReason = "VMUnschedulable"
+	// VMImagePullFailedReason documents that the virt-launcher pod of the VM cannot pull its image.
+	VMImagePullFailedReason = "VMImagePullFailed"
+	// VMCrashLoopBackOffReason documents that the virt-launcher pod of the VM keeps crashing.
+	VMCrashLoopBackOffReason = "VMCrashLoopBackOff"
+	// VMVolumeFailedReason documents that a volume of the VM cannot be provisioned or imported.
+	VMVolumeFailedReason = "VMVolumeFailed"
+	// VMNetworkAttachmentNotFoundReason documents that a NetworkAttachmentDefinition
+	// of the VM does not exist.
+	VMNetworkAttachmentNotFoundReason = "VMNetworkAttachmentNotFound"
+
+	// ProvisioningTimedOutCondition documents whether the VM exceeded the
+	// provisioning timeout without reporting an IP address.
+	ProvisioningTimedOutCondition string = "ProvisioningTimedOut"
+	// ProvisioningTimedOutReason documents that the VM did not report an IP
+	// address within the provisioning timeout.
+	ProvisioningTimedOutReason = "ProvisioningTimedOut"
+	// VMRecreatedReason documents that the VM was recreated after exceeding the
+	// provisioning timeout.
+	VMRecreatedReason = "VMRecreated"
+	// ProvisionedReason documents that the VM reported an IP address after
+	// exceeding the provisioning timeout.
+	ProvisionedReason = "Provisioned"
+
+	// DeletingCondition documents the progress of the deletion of the machine.
+	DeletingCondition string = "Deleting"
+	// VMShuttingDownReason documents that the guest of the VM is shutting down
+	// before the VM is deleted.
+	VMShuttingDownReason = "VMShuttingDown"
+	// VMShutDownReason documents that the guest of the VM shut down and the VM
+	// is deleted.
+	VMShutDownReason = "VMShutDown"
+	// VMShutdownTimedOutReason documents that the guest of the VM did not shut
+	// down within the grace period and the VM is deleted anyway.
+	VMShutdownTimedOutReason = "VMShutdownTimedOut"
+	// VMBackingUpReason documents that the VM is backed up before its deletion.
+	VMBackingUpReason = "VMBackingUp"
+	// VMBackupFailedReason documents that the backup of the VM taken before its
+	// deletion failed.
+	VMBackupFailedReason = "VMBackupFailed"
 
 	// VMRunningCondition documents whether the VM is running.
 	VMRunningCondition string = "VMRunning"
@@ -64,6 +121,52 @@ const (
 	VMIPPoolExhaustedReason = "VMIPPoolExhausted"
 	// VMIPAllocatedReason documents that an IP was successfully allocated.
 	VMIPAllocatedReason = "VMIPAllocated"
+	// VMIPAddressClaimsPendingReason documents that the IPAddressClaims of addressesFromPools are not bound yet.
+	VMIPAddressClaimsPendingReason = "VMIPAddressClaimsPending"
+
+	// VMResourcesUpToDateCondition documents whether the running VM has the CPU and memory of the spec.
+	// It is only set on machines with hotplug enabled and reports the progress of in-place updates.
+	VMResourcesUpToDateCondition string = "VMResourcesUpToDate"
+	// VMResourcesUpToDateReason documents that the VM runs with the CPU and memory of the spec.
+	VMResourcesUpToDateReason = "VMResourcesUpToDate"
+	// VMResourcesUpdatingReason documents that the CPU or memory of the VM is being hotplugged.
+	VMResourcesUpdatingReason = "VMResourcesUpdating"
+	// VMResourcesUpdateFailedReason documents that the CPU or memory change cannot be applied to the running VM.
+	VMResourcesUpdateFailedReason = "VMResourcesUpdateFailed"
+
+	// VolumesUpToDateCondition documents whether the PVCs of the running VM match the volumes of the spec.
+	// It reports the progress of volume expansions and hotplugs.
+	VolumesUpToDateCondition string = "VolumesUpToDate"
+	// VolumesUpToDateReason documents that every volume of the spec is attached with its size.
+	VolumesUpToDateReason = "VolumesUpToDate"
+	// VolumesUpdatingReason documents that volumes are being expanded or hotplugged.
+	VolumesUpdatingReason = "VolumesUpdating"
+	// VolumesUpdateFailedReason documents that a volume change cannot be applied to the running VM.
+	VolumesUpdateFailedReason = "VolumesUpdateFailed"
+
+	// VolumesClonedCondition documents whether the volumes cloned from a
+	// VolumeSnapshot or PVC are ready.
+	VolumesClonedCondition string = "VolumesCloned"
+	// VolumesClonedReason documents that the cloned volumes are ready.
+	VolumesClonedReason = "VolumesCloned"
+	// VolumeCloningReason documents that volumes are being cloned.
+	VolumeCloningReason = "VolumeCloning"
+	// VolumeCloneFailedReason documents that a volume cannot be cloned,
+	// for example because its source does not exist.
+	VolumeCloneFailedReason = "VolumeCloneFailed"
+
+	// VMImagesReadyCondition documents whether the images imported from the imageSource
+	// of the volumes are ready to boot from.
+	VMImagesReadyCondition string = "VMImagesReady"
+	// VMImagesReadyReason documents that the images are imported with their pinned checksum.
+	VMImagesReadyReason = "VMImagesReady"
+	// VMImageImportingReason documents that images are being downloaded and imported.
+	VMImageImportingReason = "VMImageImporting"
+	// VMImageImportFailedReason documents that the import of an image failed.
+	VMImageImportFailedReason = "VMImageImportFailed"
+	// VMImageChecksumMismatchReason documents that an existing image has another checksum
+	// than the pinned one.
+	VMImageChecksumMismatchReason = "VMImageChecksumMismatch"
 )
 
 // HarvesterMachineSpec defines the desired state of HarvesterMachine.
@@ -78,10 +181,36 @@ type HarvesterMachineSpec struct {
 	FailureDomain string `json:"failureDomain,omitempty"`
 
 	// CPU is the number of CPU to assign to the VM.
-	CPU uint32 `json:"cpu"`
+	// Required unless vmTemplateVersion or instancetype is set.
+	// +optional
+	CPU uint32 `json:"cpu,omitempty"`
 
 	// Memory is the memory size to assign to the VM (should be similar to pod.spec.containers.resources.limits).
-	Memory string `json:"memory"`
+	// Required unless vmTemplateVersion or instancetype is set.
+	// +optional
+	Memory string `json:"memory,omitempty"`
+
+	// CPUOptions tunes the topology, model and placement of the CPUs of the VM.
+	// Mutually exclusive with instancetype and vmTemplateVersion.
+	// +optional
+	CPUOptions *CPUOptions `json:"cpuOptions,omitempty"`
+
+	// MemoryOptions sets the hugepages and overcommit of the memory of the VM.
+	// Mutually exclusive with instancetype and vmTemplateVersion.
+	// +optional
+	MemoryOptions *MemoryOptions `json:"memoryOptions,omitempty"`
+
+	// Instancetype is the name of the KubeVirt VirtualMachineClusterInstancetype
+	// giving the VM its CPUs and memory, instead of cpu and memory. Changing
+	// it replaces the machine.
+	// +optional
+	Instancetype string `json:"instancetype,omitempty"`
+
+	// Preference is the name of the KubeVirt VirtualMachineClusterPreference
+	// giving the VM its preferred devices, firmware and machine settings.
+	// Settings of the HarvesterMachine, such as firmware, take precedence.
+	// +optional
+	Preference string `json:"preference,omitempty"`
 
 	// SSHUser is the user that should be used to connect to the VMs using SSH.
 	SSHUser string `json:"sshUser"`
@@ -91,7 +220,19 @@ type HarvesterMachineSpec struct {
 	SSHKeyPair string `json:"sshKeyPair"`
 
 	// Volumes is a list of Volumes to attach to the VM
-	Volumes []Volume `json:"volumes"`
+	// Required unless vmTemplateVersion is set.
+	// +optional
+	Volumes []Volume `json:"volumes,omitempty"`
+
+	// VMTemplateVersion is the Harvester VirtualMachineTemplateVersion the VM is
+	// built from, in the format "namespace/name" or just "name" if it is in the
+	// target namespace. The CPU, memory, devices, firmware and disks of the VM
+	// come from the template version; the machine only sets the cloud-init,
+	// networks, labels and failure domain affinity. The disks of the template
+	// version are created for each machine. Mutually exclusive with cpu,
+	// memory, instancetype, preference, volumes, firmware, tpm and hotplug.
+	// +optional
+	VMTemplateVersion string `json:"vmTemplateVersion,omitempty"`
 
 	// Networks is a list of Networks to attach to the VM.
 	// Each item in the list can have the format "namespace/name" or just "name" if the object is in the same namespace as the HarvesterMachine.
@@ -123,6 +264,19 @@ type HarvesterMachineSpec struct {
 	// +optional
 	VMNetworkConfig *VMNetworkConfig `json:"vmNetworkConfig,omitempty"`
 
+	// AddressesFromPools lists the IPAM pools (CAPI IPAM contract) the address
+	// of the first network is requested from: an IPAddressClaim is created for
+	// every entry and the VM is created once all of them are bound. At most one
+	// IPv4 and one IPv6 pool can be listed; the gateway and prefix of the
+	// claimed IPAddresses are written to the machine cloud-init. When set, the
+	// IP pools of vmNetworkConfig are not used for the first network, but its
+	// DNS settings and interfaces still apply. Use kind HarvesterIPPool and
+	// apiGroup infrastructure.cluster.x-k8s.io to allocate from Harvester
+	// IPPools. Mutually exclusive with networkConfig.
+	// +kubebuilder:validation:MaxItems=2
+	// +optional
+	AddressesFromPools []corev1.TypedLocalObjectReference `json:"addressesFromPools,omitempty"`
+
 	// Firmware selects the firmware used to boot the VM. When unset, the VM
 	// keeps booting with the current default (BIOS).
 	// +optional
@@ -133,6 +287,132 @@ type HarvesterMachineSpec struct {
 	// or attested boot setups.
 	// +optional
 	TPM *TPM `json:"tpm,omitempty"`
+
+	// Devices attaches optional guest devices to the VM, such as a watchdog
+	// or a virtio-rng device, and controls its consoles and tablet input.
+	// +optional
+	Devices *GuestDevices `json:"devices,omitempty"`
+
+	// Hotplug creates the VM with CPU and memory hotplug, so that cpu and
+	// memory can be raised up to the given maximums on the running VM. With
+	// the in-place updates of CAPI, such changes are then applied without
+	// replacing the machine. Changing hotplug itself replaces the machine.
+	// +optional
+	Hotplug *HotplugConfig `json:"hotplug,omitempty"`
+
+	// InPlaceVolumeUpdates applies the volume changes of the machine to its
+	// running VM: larger volumeSize values are expanded and appended
+	// "storageClass" volumes are hotplugged, also when the in-place updates of
+	// CAPI roll out the changes of a template. Without it, volume changes are
+	// not applied to the VM, and CAPI replaces the machines instead.
+	// +optional
+	InPlaceVolumeUpdates bool `json:"inPlaceVolumeUpdates,omitempty"`
+
+	// ProvisioningTimeout bounds the time the VM gets to report an IP address.
+	// Once exceeded, the tail of the guest console log is captured into a
+	// Secret and the VM is optionally recreated. It takes precedence over the
+	// provisioningTimeout of the HarvesterCluster.
+	// +optional
+	ProvisioningTimeout *ProvisioningTimeout `json:"provisioningTimeout,omitempty"`
+
+	// ShutdownGracePeriod is the time the guest gets to shut down, after an
+	// ACPI shutdown request, before the VM is deleted along with the machine.
+	// Without it, or with 0s, the VM is deleted at once. It takes precedence
+	// over the shutdownGracePeriod of the HarvesterCluster.
+	// +optional
+	ShutdownGracePeriod *metav1.Duration `json:"shutdownGracePeriod,omitempty"`
+
+	// DeletionPolicy is what happens to the disks of the VM when the machine
+	// is deleted. It takes precedence over the deletionPolicy of the
+	// HarvesterCluster, and defaults to Delete.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+}
+
+// DeletionPolicy is what happens to the disks of a VM when its machine is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain;Snapshot
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the PVCs of the VM.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the PVCs of the VM, labelled with the former
+	// cluster and machine.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicySnapshot backs the VM up to the Harvester backup target
+	// before deleting it and its PVCs.
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+// ProvisioningTimeout bounds the time the VM of a machine gets to report an IP address.
+type ProvisioningTimeout struct {
+	// Timeout is the time the VM gets, from its creation, to report an IP address.
+	Timeout metav1.Duration `json:"timeout"`
+
+	// MaxRecreations is the number of times the VM is deleted and recreated
+	// after exceeding the timeout. With 0, the default, the timed out VM is
+	// left in place.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxRecreations int32 `json:"maxRecreations,omitempty"`
+}
+
+// HotplugConfig sets the maximum CPU and memory of a VM with hotplug.
+type HotplugConfig struct {
+	// MaxCPU is the maximum number of CPUs the running VM can be scaled to.
+	// +kubebuilder:validation:Minimum=1
+	MaxCPU uint32 `json:"maxCPU"`
+
+	// MaxMemory is the maximum memory size the running VM can be scaled to.
+	MaxMemory string `json:"maxMemory"`
+}
+
+// CPUOptions describes the topology, model and placement of the CPUs of a VM.
+type CPUOptions struct {
+	// Sockets is the number of CPU sockets the cpu CPUs are spread over.
+	// cpu must be a multiple of sockets times threads. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Sockets uint32 `json:"sockets,omitempty"`
+
+	// Threads is the number of threads of each core. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Threads uint32 `json:"threads,omitempty"`
+
+	// Model is the CPU model exposed to the guest: "host-passthrough",
+	// "host-model" or a named libvirt model such as "Cascadelake-Server".
+	// When unset, the cluster default of KubeVirt applies.
+	// +optional
+	Model string `json:"model,omitempty"`
+
+	// DedicatedCPUPlacement pins each vCPU of the VM to a dedicated physical
+	// CPU of the Harvester node. It requires the CPU manager on the nodes.
+	// +optional
+	DedicatedCPUPlacement bool `json:"dedicatedCPUPlacement,omitempty"`
+
+	// IsolateEmulatorThread runs the QEMU emulator thread on one more
+	// dedicated physical CPU. Requires dedicatedCPUPlacement.
+	// +optional
+	IsolateEmulatorThread bool `json:"isolateEmulatorThread,omitempty"`
+}
+
+// MemoryOptions describes the hugepages and overcommit of the memory of a VM.
+type MemoryOptions struct {
+	// HugepagesPageSize backs the memory of the VM with hugepages of the given
+	// size, "2Mi" or "1Gi". memory must be a multiple of it, and the Harvester
+	// nodes must have enough hugepages of that size.
+	// +kubebuilder:validation:Enum="2Mi";"1Gi"
+	// +optional
+	HugepagesPageSize string `json:"hugepagesPageSize,omitempty"`
+
+	// OvercommitPercent is the ratio, in percent, of the guest memory to the
+	// memory requested from the Harvester node: with 150, a VM with 12Gi of
+	// memory requests 8Gi. The limit stays at the guest memory. Defaults to
+	// 100, no overcommit. Cannot be combined with hugepages or dedicated CPUs.
+	// +kubebuilder:validation:Minimum=100
+	// +optional
+	OvercommitPercent int32 `json:"overcommitPercent,omitempty"`
 }
 
 // Firmware describes the firmware configuration of a VM.
@@ -157,6 +437,43 @@ type TPM struct {
 	Persistent bool `json:"persistent,omitempty"`
 }
 
+// GuestDevices describes the optional guest devices of a VM.
+type GuestDevices struct {
+	// Watchdog attaches an i6300esb watchdog device, so that a hung guest
+	// is reset or powered off once its watchdog daemon stops feeding it.
+	// +optional
+	Watchdog *WatchdogConfig `json:"watchdog,omitempty"`
+
+	// RNG attaches a virtio-rng device feeding the guest with entropy from
+	// the host, for images that otherwise wait for entropy at boot.
+	// +optional
+	RNG bool `json:"rng,omitempty"`
+
+	// AutoattachSerialConsole attaches the default serial console of the VM.
+	// Defaults to true.
+	// +optional
+	AutoattachSerialConsole *bool `json:"autoattachSerialConsole,omitempty"`
+
+	// AutoattachGraphicsDevice attaches the default graphics device of the
+	// VM, used by the VNC console. Defaults to true.
+	// +optional
+	AutoattachGraphicsDevice *bool `json:"autoattachGraphicsDevice,omitempty"`
+
+	// Tablet attaches the USB tablet input device, which keeps the pointer of
+	// the VNC console in sync. Defaults to true.
+	// +optional
+	Tablet *bool `json:"tablet,omitempty"`
+}
+
+// WatchdogConfig describes the watchdog device of a VM.
+type WatchdogConfig struct {
+	// Action is what happens to the VM when the watchdog expires: "reset"
+	// reboots it and "poweroff" stops it. Defaults to "reset".
+	// +kubebuilder:validation:Enum=reset;poweroff
+	// +optional
+	Action string `json:"action,omitempty"`
+}
+
 // NetworkConfig defines static network configuration for a VM.
 type NetworkConfig struct {
 	// Address is the static IP address for the VM (e.g. "172.16.3.40").
@@ -172,12 +489,176 @@ type NetworkConfig struct {
 	// DNSSearch is a list of DNS search domains.
 	// +optional
 	DNSSearch []string `json:"dnsSearch,omitempty"`
+
+	// IPv6Address is the static IPv6 address of the first network, added to
+	// the IPv4 address for dual-stack machines (e.g. "2001:db8:1::40").
+	// +optional
+	IPv6Address string `json:"ipv6Address,omitempty"`
+
+	// IPv6PrefixLength is the prefix length of IPv6Address.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=128
+	// +optional
+	IPv6PrefixLength int32 `json:"ipv6PrefixLength,omitempty"`
+
+	// IPv6Gateway is the IPv6 gateway address of the first network.
+	// +optional
+	IPv6Gateway string `json:"ipv6Gateway,omitempty"`
+
+	// Interfaces configures the addressing of individual network attachments.
+	// An entry for the first network of spec.networks replaces address and
+	// gateway above. Attachments without an entry keep the default behavior:
+	// the first one uses address and gateway, the others use DHCP.
+	// +optional
+	Interfaces []InterfaceConfig `json:"interfaces,omitempty"`
+}
+
+// InterfaceAddressing selects how a network interface gets its address.
+// +kubebuilder:validation:Enum=static;pool;dhcp;none
+type InterfaceAddressing string
+
+const (
+	// InterfaceAddressingStatic assigns the address of the interface configuration.
+	InterfaceAddressingStatic InterfaceAddressing = "static"
+	// InterfaceAddressingPool allocates the address from a Harvester IPPool.
+	InterfaceAddressingPool InterfaceAddressing = "pool"
+	// InterfaceAddressingDHCP configures the interface with DHCP.
+	InterfaceAddressingDHCP InterfaceAddressing = "dhcp"
+	// InterfaceAddressingNone brings the interface up without an address.
+	InterfaceAddressingNone InterfaceAddressing = "none"
+)
+
+// InterfaceConfig describes the addressing of one network attachment of a VM.
+type InterfaceConfig struct {
+	// Network is the network attachment this configuration applies to. It
+	// designates an entry of spec.networks, in the "namespace/name" or "name" form.
+	Network string `json:"network"`
+
+	// Addressing selects how the interface gets its address: "static" uses
+	// address, "pool" allocates one from ipPoolRefs, "dhcp" uses DHCP and
+	// "none" brings the interface up without an address.
+	Addressing InterfaceAddressing `json:"addressing"`
+
+	// Address is the static IP address of the interface. Required when
+	// addressing is "static".
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// SubnetMask is the subnet mask of the interface (e.g. "255.255.255.0").
+	// Required when addressing is "static" or "pool".
+	// +optional
+	SubnetMask string `json:"subnetMask,omitempty"`
+
+	// Gateway is the default gateway reached through this interface. Leave it
+	// empty on all interfaces but one.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+
+	// IPPoolRefs lists the Harvester IPPools to allocate the address from when
+	// addressing is "pool". Pools are tried in order, and only the pools whose
+	// selector network matches this network are used. When empty, the pools of
+	// the effective vmNetworkConfig are used.
+	// +optional
+	IPPoolRefs []string `json:"ipPoolRefs,omitempty"`
+
+	// Routes are additional static routes reached through this interface.
+	// +optional
+	Routes []Route `json:"routes,omitempty"`
+
+	// MTU is the MTU of the interface. The guest default applies when unset.
+	// +kubebuilder:validation:Minimum=68
+	// +optional
+	MTU int32 `json:"mtu,omitempty"`
+}
+
+// NetworkRefsEqual reports whether two network references designate the same
+// network. Both accept the "name" and "namespace/name" forms: when either side
+// is unqualified only the name parts are compared, and two qualified references
+// must match exactly.
+func NetworkRefsEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+
+	aNS, aName := splitNetworkRef(a)
+	bNS, bName := splitNetworkRef(b)
+
+	if aNS == "" || bNS == "" {
+		return aName == bName
+	}
+
+	return false
+}
+
+func splitNetworkRef(ref string) (namespace, name string) {
+	if idx := strings.LastIndex(ref, "/"); idx >= 0 {
+		return ref[:idx], ref[idx+1:]
+	}
+
+	return "", ref
+}
+
+// Route is a static route of a network interface.
+type Route struct {
+	// To is the destination network in CIDR notation (e.g. "10.20.0.0/16").
+	To string `json:"to"`
+
+	// Via is the next hop IP address.
+	Via string `json:"via"`
+
+	// Metric is the metric of the route.
+	// +optional
+	Metric *int32 `json:"metric,omitempty"`
+}
+
+// InterfaceAllocation records an IP address allocated from an IPPool for one network attachment.
+type InterfaceAllocation struct {
+	// Network is the network attachment the address was allocated for.
+	Network string `json:"network"`
+
+	// IPAddress is the allocated IP address.
+	IPAddress string `json:"ipAddress"`
+
+	// PoolRef is the name of the IPPool the address was allocated from.
+	PoolRef string `json:"poolRef"`
+}
+
+// VolumeStatus reports the PVC backing a volume of the spec.
+type VolumeStatus struct {
+	// Index is the position of the volume in spec.volumes.
+	Index int `json:"index"`
+
+	// Name is the name of the disk in the VM.
+	Name string `json:"name"`
+
+	// PVCName is the name of the PVC backing the disk.
+	PVCName string `json:"pvcName"`
+
+	// Size is the size requested for the PVC.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// Capacity is the size of the PVC reported by the storage provider.
+	// +optional
+	Capacity *resource.Quantity `json:"capacity,omitempty"`
+
+	// Hotplugged is true when the volume was added to the running VM.
+	// +optional
+	Hotplugged bool `json:"hotplugged,omitempty"`
+
+	// ImageName is the "namespace/name" of the VM image the volume was created from.
+	// +optional
+	ImageName string `json:"imageName,omitempty"`
+
+	// ImageUID is the UID of the VM image the volume was created from.
+	// +optional
+	ImageUID types.UID `json:"imageUID,omitempty"`
 }
 
 // Volume defines a volume that should be attached to the VM.
 type Volume struct {
 	// VolumeType is the type of volume to attach.
-	// Choose between: "storageClass" or "image"
+	// Choose between: "storageClass", "image" or "clone"
 	VolumeType VolumeType `json:"volumeType"`
 
 	// ImageName is the name of the image to use if the volumeType is "image"
@@ -185,9 +666,25 @@ type Volume struct {
 	// +optional
 	ImageName string `json:"imageName,omitempty"`
 
-	// StorageClass is the name of the storage class to be used if the volumeType is "storageClass"
+	// ImageSelector selects the image of an "image" volume by label instead of imageName.
+	// The newest imported image with matching labels is resolved when the VM is created.
+	// +optional
+	ImageSelector *ImageSelector `json:"imageSelector,omitempty"`
+
+	// ImageSource downloads the image of an "image" volume from a URL when no image
+	// named imageName exists. The checksum pins the image: the machine does not boot
+	// from an existing image with another checksum.
+	// +optional
+	ImageSource *ImageSource `json:"imageSource,omitempty"`
+
+	// StorageClass is the name of the storage class to be used if the volumeType is "storageClass".
+	// For "clone" volumes, it defaults to the storage class of the source.
 	StorageClass string `json:"storageClass,omitempty"`
 
+	// CloneSource is the VolumeSnapshot or PVC a "clone" volume is cloned from.
+	// +optional
+	CloneSource *VolumeCloneSource `json:"cloneSource,omitempty"`
+
 	// VolumeSize is the desired size of the volume. This satisfies to standard Kubernetes *resource.Quantity syntax.
 	// Examples: 40.5Gi, 30M, etc. are valid
 	// +optional
@@ -197,12 +694,108 @@ type Volume struct {
 	// If absent, the sequence with which volumes appear in the manifest will be used.
 	// +optional
 	BootOrder int `json:"bootOrder,omitempty"`
+
+	// DeviceType is the device the volume is attached as: "disk" (default) or
+	// "cdrom", for example to boot an installer ISO.
+	// +optional
+	DeviceType VolumeDeviceType `json:"type,omitempty"`
+
+	// Bus is the bus the device is attached to: "virtio", "scsi", "sata" or "usb".
+	// Defaults to "virtio" for disks and "sata" for CD-ROMs. Volumes hotplugged
+	// into a running VM always use "scsi".
+	// +optional
+	Bus DiskBus `json:"bus,omitempty"`
+
+	// AccessMode is the access mode of the PVC. Defaults to ReadWriteMany,
+	// which live migration requires.
+	// +kubebuilder:validation:Enum=ReadWriteOnce;ReadWriteMany
+	// +optional
+	AccessMode corev1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+
+	// VolumeMode is the volume mode of the PVC. Defaults to Block.
+	// +kubebuilder:validation:Enum=Block;Filesystem
+	// +optional
+	VolumeMode corev1.PersistentVolumeMode `json:"volumeMode,omitempty"`
+
+	// Cache is the disk cache mode: "none", "writethrough" or "writeback".
+	// Defaults to the KubeVirt choice for the storage.
+	// +optional
+	Cache DiskCache `json:"cache,omitempty"`
+
+	// IO is the disk IO mode: "native" or "threads". "native" requires the
+	// "none" cache mode. Defaults to the KubeVirt choice for the storage.
+	// +optional
+	IO DiskIO `json:"io,omitempty"`
 }
 
-// VolumeType is an enum string. It can only take the values: "storageClass" or "image".
-// +kubebuilder:validation:Enum=storageClass;image
+// VolumeType is an enum string. It can only take the values: "storageClass", "image" or "clone".
+// +kubebuilder:validation:Enum=storageClass;image;clone
 type VolumeType string
 
+// ImageSelector selects VM images by label.
+type ImageSelector struct {
+	// Namespace is the namespace of the images. Defaults to the target namespace of the cluster.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// LabelSelector selects the images by label, for example os=sles,channel=stable.
+	metav1.LabelSelector `json:",inline"`
+}
+
+// ImageSource is the URL and checksum a VM image is imported from.
+type ImageSource struct {
+	// URL is the HTTP or HTTPS URL the image is downloaded from.
+	URL string `json:"url"`
+
+	// Checksum is the SHA-512 checksum of the image, as 128 hexadecimal characters.
+	// Harvester verifies the download against it.
+	Checksum string `json:"checksum"`
+}
+
+// VolumeCloneSourceKind is the kind of the source of a cloned volume.
+// +kubebuilder:validation:Enum=VolumeSnapshot;PersistentVolumeClaim
+type VolumeCloneSourceKind string
+
+const (
+	// VolumeCloneSourceVolumeSnapshot restores the volume from a VolumeSnapshot.
+	VolumeCloneSourceVolumeSnapshot VolumeCloneSourceKind = "VolumeSnapshot"
+	// VolumeCloneSourcePersistentVolumeClaim clones the volume from a PVC.
+	VolumeCloneSourcePersistentVolumeClaim VolumeCloneSourceKind = "PersistentVolumeClaim"
+)
+
+// VolumeCloneSource references the VolumeSnapshot or PVC a volume is cloned
+// from. The source must be in the target namespace of the cluster.
+type VolumeCloneSource struct {
+	// Kind is the kind of the source: "VolumeSnapshot" or "PersistentVolumeClaim".
+	Kind VolumeCloneSourceKind `json:"kind"`
+
+	// Name is the name of the source.
+	Name string `json:"name"`
+}
+
+// VolumeDeviceType is the device a volume is attached as.
+// +kubebuilder:validation:Enum=disk;cdrom
+type VolumeDeviceType string
+
+const (
+	// VolumeDeviceTypeDisk attaches the volume as a disk.
+	VolumeDeviceTypeDisk VolumeDeviceType = "disk"
+	// VolumeDeviceTypeCDRom attaches the volume as a CD-ROM.
+	VolumeDeviceTypeCDRom VolumeDeviceType = "cdrom"
+)
+
+// DiskBus is the bus a volume device is attached to.
+// +kubebuilder:validation:Enum=virtio;scsi;sata;usb
+type DiskBus string
+
+// DiskCache is the cache mode of a volume device.
+// +kubebuilder:validation:Enum=none;writethrough;writeback
+type DiskCache string
+
+// DiskIO is the IO mode of a volume device.
+// +kubebuilder:validation:Enum=native;threads
+type DiskIO string
+
 // Initialization tracks provisioning state for the CAPI v1beta2 contract
 // (status.initialization.provisioned). It is published alongside status.ready (the v1beta1
 // contract field) and kept in sync, so a single v1alpha1 object satisfies whichever contract
@@ -233,10 +826,41 @@ type HarvesterMachineStatus struct {
 	// +optional
 	AllocatedPoolRef string `json:"allocatedPoolRef,omitempty"`
 
+	// AllocatedIPv6Address is the IPv6 address allocated from the IPv6 pools
+	// of vmNetworkConfig.ipv6 for this machine.
+	// +optional
+	AllocatedIPv6Address string `json:"allocatedIPv6Address,omitempty"`
+
+	// AllocatedIPv6PoolRef is the name of the IPPool from which AllocatedIPv6Address was allocated.
+	// +optional
+	AllocatedIPv6PoolRef string `json:"allocatedIPv6PoolRef,omitempty"`
+
+	// InterfaceAllocations records the IP addresses allocated for the network
+	// attachments configured with "pool" addressing in interfaces. The pool
+	// allocation of the first attachment without an interfaces entry stays in
+	// AllocatedIPAddress.
+	// +optional
+	InterfaceAllocations []InterfaceAllocation `json:"interfaceAllocations,omitempty"`
+
+	// Volumes records the PVCs backing the volumes of the spec, in the order of spec.volumes.
+	// +optional
+	Volumes []VolumeStatus `json:"volumes,omitempty"`
+
 	// FailureDomain reports the failure domain the machine was placed in
 	// (CAPI contract field, mirrored to Machine.status.failureDomain).
 	// +optional
 	FailureDomain string `json:"failureDomain,omitempty"`
+
+	// ConsoleLogSecretRef is the name of the Secret, in the namespace of the
+	// HarvesterMachine, holding the tail of the guest console log captured
+	// when the VM exceeded the provisioning timeout.
+	// +optional
+	ConsoleLogSecretRef string `json:"consoleLogSecretRef,omitempty"`
+
+	// VMRecreations counts the VMs deleted and recreated after exceeding the
+	// provisioning timeout.
+	// +optional
+	VMRecreations int32 `json:"vmRecreations,omitempty"`
 }
 
 //+kubebuilder:object:root=true
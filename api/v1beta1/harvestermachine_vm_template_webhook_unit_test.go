@@ -0,0 +1,101 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateMachineVMTemplateVersion(t *testing.T) {
+	fromTemplate := func(m *HarvesterMachine) {
+		m.Spec.CPU = 0
+		m.Spec.Memory = ""
+		m.Spec.Volumes = nil
+		m.Spec.VMTemplateVersion = "templates/sles-worker-v3"
+	}
+
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"template version without cpu, memory and volumes is valid",
+			fromTemplate,
+			"",
+		},
+		{
+			"machine without template version requires volumes",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = nil
+			},
+			"spec.volumes must contain at least one volume",
+		},
+		{
+			"template version with cpu is rejected",
+			func(m *HarvesterMachine) {
+				fromTemplate(m)
+				m.Spec.CPU = 4
+			},
+			"spec.cpu cannot be set with spec.vmTemplateVersion",
+		},
+		{
+			"template version with volumes is rejected",
+			func(m *HarvesterMachine) {
+				fromTemplate(m)
+				m.Spec.Volumes = []Volume{{VolumeType: "image", ImageName: "default/leap"}}
+			},
+			"spec.volumes cannot be set with spec.vmTemplateVersion",
+		},
+		{
+			"template version with firmware is rejected",
+			func(m *HarvesterMachine) {
+				fromTemplate(m)
+				m.Spec.Firmware = &Firmware{EFI: true}
+			},
+			"spec.firmware cannot be set with spec.vmTemplateVersion",
+		},
+		{
+			"malformed template version is rejected",
+			func(m *HarvesterMachine) {
+				fromTemplate(m)
+				m.Spec.VMTemplateVersion = "templates/sles-worker/v3"
+			},
+			"must have the format <NAMESPACE>/<NAME> or <NAME>",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateCreate(context.TODO(), m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
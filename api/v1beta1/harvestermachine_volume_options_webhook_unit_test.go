@@ -0,0 +1,126 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestValidateMachineVolumeOptions(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"scsi disk with writethrough cache is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].Bus = "scsi"
+				m.Spec.Volumes[0].Cache = "writethrough"
+			},
+			"",
+		},
+		{
+			"cdrom image on the sata bus is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = append(m.Spec.Volumes, Volume{
+					VolumeType: "image", ImageName: "default/installer-iso", DeviceType: VolumeDeviceTypeCDRom, Bus: "sata",
+				})
+			},
+			"",
+		},
+		{
+			"ReadWriteOnce filesystem volume is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].AccessMode = corev1.ReadWriteOnce
+				m.Spec.Volumes[0].VolumeMode = corev1.PersistentVolumeFilesystem
+			},
+			"",
+		},
+		{
+			"native io with no cache is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].Cache = "none"
+				m.Spec.Volumes[0].IO = "native"
+			},
+			"",
+		},
+		{
+			"unknown bus is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].Bus = "ide"
+			},
+			"bus must be one of",
+		},
+		{
+			"cdrom on the virtio bus is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].DeviceType = VolumeDeviceTypeCDRom
+				m.Spec.Volumes[0].Bus = "virtio"
+			},
+			"bus 'virtio' is not supported for type 'cdrom'",
+		},
+		{
+			"cdrom from a storage class is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = append(m.Spec.Volumes, Volume{
+					VolumeType: "storageClass", StorageClass: "longhorn", VolumeSize: new(resource.MustParse("1Gi")),
+					DeviceType: VolumeDeviceTypeCDRom,
+				})
+			},
+			"type 'cdrom' requires volumeType 'image'",
+		},
+		{
+			"ReadOnlyMany access mode is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].AccessMode = corev1.ReadOnlyMany
+			},
+			"accessMode must be",
+		},
+		{
+			"native io with writeback cache is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].Cache = "writeback"
+				m.Spec.Volumes[0].IO = "native"
+			},
+			"io 'native' requires cache 'none'",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateCreate(context.TODO(), m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
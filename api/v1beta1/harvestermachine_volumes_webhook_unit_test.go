@@ -0,0 +1,141 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestValidateMachineVolumeUpdate(t *testing.T) {
+	sizedMachine := func() *HarvesterMachine {
+		m := validMachine()
+		m.Spec.InPlaceVolumeUpdates = true
+		m.Spec.Volumes[0].VolumeSize = new(resource.MustParse("40Gi"))
+
+		return m
+	}
+
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"growing a volume is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].VolumeSize = new(resource.MustParse("60Gi"))
+			},
+			"",
+		},
+		{
+			"appending a storage class volume is valid",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = append(m.Spec.Volumes, Volume{
+					VolumeType: "storageClass", StorageClass: "longhorn", VolumeSize: new(resource.MustParse("10Gi")),
+				})
+			},
+			"",
+		},
+		{
+			"shrinking a volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].VolumeSize = new(resource.MustParse("20Gi"))
+			},
+			"volumeSize cannot be decreased",
+		},
+		{
+			"removing a volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = m.Spec.Volumes[:0]
+			},
+			"spec.volumes",
+		},
+		{
+			"changing the image of a volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].ImageName = "default/sles"
+			},
+			"can only change its volumeSize",
+		},
+		{
+			"changing the bus of a volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes[0].Bus = "scsi"
+			},
+			"can only change its volumeSize",
+		},
+		{
+			"appending a volume on the sata bus is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = append(m.Spec.Volumes, Volume{
+					VolumeType: "storageClass", StorageClass: "longhorn", VolumeSize: new(resource.MustParse("10Gi")), Bus: "sata",
+				})
+			},
+			"bus must be 'scsi'",
+		},
+		{
+			"appending an image volume is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = append(m.Spec.Volumes, Volume{
+					VolumeType: "image", ImageName: "default/leap", VolumeSize: new(resource.MustParse("10Gi")),
+				})
+			},
+			"must have volumeType 'storageClass'",
+		},
+		{
+			"appending a volume without size is rejected",
+			func(m *HarvesterMachine) {
+				m.Spec.Volumes = append(m.Spec.Volumes, Volume{VolumeType: "storageClass", StorageClass: "longhorn"})
+			},
+			"volumeSize is required",
+		},
+		{
+			"volume changes are not checked without in-place volume updates",
+			func(m *HarvesterMachine) {
+				m.Spec.InPlaceVolumeUpdates = false
+				m.Spec.Volumes[0].VolumeSize = new(resource.MustParse("20Gi"))
+			},
+			"",
+		},
+	}
+	for _, tc := range cases {
+		oldMachine := sizedMachine()
+		oldMachine.Spec.Volumes = append(oldMachine.Spec.Volumes, Volume{
+			VolumeType: "clone", CloneSource: &VolumeCloneSource{Kind: VolumeCloneSourceVolumeSnapshot, Name: "data-snap"},
+		})
+		newMachine := oldMachine.DeepCopy()
+		tc.mutate(newMachine)
+
+		_, err := (&HarvesterMachineValidator{}).ValidateUpdate(context.TODO(), oldMachine, newMachine)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
@@ -19,6 +19,9 @@ package v1beta1
 import (
 	"strings"
 	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
 )
 
 func validMachine() *HarvesterMachine {
@@ -126,3 +129,214 @@ func TestValidateMachineVMNetworkConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateMachineNetworkConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"valid static and pool interfaces",
+			func(m *HarvesterMachine) {
+				m.Spec.Networks = []string{"default/workers", "default/storage"}
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Interfaces: []InterfaceConfig{
+						{Network: "default/workers", Addressing: InterfaceAddressingStatic, Address: "10.20.0.10",
+							SubnetMask: "255.255.255.0", Gateway: "10.20.0.1"},
+						{Network: "storage", Addressing: InterfaceAddressingPool, SubnetMask: "255.255.255.0",
+							IPPoolRefs: []string{"pool-storage"}, MTU: 9000,
+							Routes: []Route{{To: "10.21.0.0/16", Via: "10.30.0.1"}}},
+					},
+				}
+			},
+			"",
+		},
+		{
+			"address is still required when the first network has no entry",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Interfaces: []InterfaceConfig{{Network: "default/storage", Addressing: InterfaceAddressingDHCP}},
+				}
+			},
+			"address",
+		},
+		{
+			"static addressing without an address",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Interfaces: []InterfaceConfig{
+						{Network: "default/workers", Addressing: InterfaceAddressingStatic, SubnetMask: "255.255.255.0"},
+					},
+				}
+			},
+			"address",
+		},
+		{
+			"duplicate network",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Interfaces: []InterfaceConfig{
+						{Network: "default/workers", Addressing: InterfaceAddressingDHCP},
+						{Network: "workers", Addressing: InterfaceAddressingNone},
+					},
+				}
+			},
+			"more than once",
+		},
+		{
+			"ipPoolRefs with dhcp addressing",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Interfaces: []InterfaceConfig{
+						{Network: "default/workers", Addressing: InterfaceAddressingDHCP, IPPoolRefs: []string{"pool-workers"}},
+					},
+				}
+			},
+			"ipPoolRefs",
+		},
+		{
+			"invalid route destination",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Interfaces: []InterfaceConfig{
+						{Network: "default/workers", Addressing: InterfaceAddressingDHCP,
+							Routes: []Route{{To: "10.21.0.0", Via: "10.20.0.1"}}},
+					},
+				}
+			},
+			"routes",
+		},
+		{
+			"static dual-stack address",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Address: "10.20.0.10", Gateway: "10.20.0.1",
+					IPv6Address: "2001:db8::10", IPv6PrefixLength: 64, IPv6Gateway: "2001:db8::1",
+				}
+			},
+			"",
+		},
+		{
+			"static IPv6 address without prefix length",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{Address: "10.20.0.10", Gateway: "10.20.0.1", IPv6Address: "2001:db8::10"}
+			},
+			"ipv6PrefixLength",
+		},
+		{
+			"IPv4 address as static IPv6 address",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{
+					Address: "10.20.0.10", Gateway: "10.20.0.1", IPv6Address: "10.20.0.11", IPv6PrefixLength: 64,
+				}
+			},
+			"ipv6Address",
+		},
+		{
+			"vmNetworkConfig interfaces are validated",
+			func(m *HarvesterMachine) {
+				m.Spec.VMNetworkConfig = &VMNetworkConfig{
+					IPPoolRef:  "pool-workers",
+					Gateway:    "10.20.0.1",
+					SubnetMask: "255.255.255.0",
+					Interfaces: []InterfaceConfig{{Network: "default/storage", Addressing: InterfaceAddressingPool}},
+				}
+			},
+			"subnetMask",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := validateHarvesterMachine(m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
+
+func TestValidateMachineAddressesFromPools(t *testing.T) {
+	harvesterIPPool := corev1.TypedLocalObjectReference{
+		APIGroup: ptr.To(GroupVersion.Group),
+		Kind:     "HarvesterIPPool",
+		Name:     "workers",
+	}
+
+	cases := []struct {
+		name    string
+		mutate  func(m *HarvesterMachine)
+		wantErr string
+	}{
+		{
+			"valid pool reference",
+			func(m *HarvesterMachine) {
+				m.Spec.AddressesFromPools = []corev1.TypedLocalObjectReference{harvesterIPPool}
+			},
+			"",
+		},
+		{
+			"valid alongside the DNS settings of vmNetworkConfig",
+			func(m *HarvesterMachine) {
+				m.Spec.AddressesFromPools = []corev1.TypedLocalObjectReference{harvesterIPPool}
+				m.Spec.VMNetworkConfig = &VMNetworkConfig{
+					IPPoolRef:  "pool-workers",
+					Gateway:    "10.20.0.1",
+					SubnetMask: "255.255.255.0",
+					DNSServers: []string{"10.20.0.2"},
+				}
+			},
+			"",
+		},
+		{
+			"missing apiGroup",
+			func(m *HarvesterMachine) {
+				m.Spec.AddressesFromPools = []corev1.TypedLocalObjectReference{{Kind: "HarvesterIPPool", Name: "workers"}}
+			},
+			"spec.addressesFromPools[0].apiGroup is required",
+		},
+		{
+			"missing kind and name",
+			func(m *HarvesterMachine) {
+				m.Spec.AddressesFromPools = []corev1.TypedLocalObjectReference{{APIGroup: ptr.To("ipam.cluster.x-k8s.io")}}
+			},
+			"spec.addressesFromPools[0].name is required",
+		},
+		{
+			"mutually exclusive with static networkConfig",
+			func(m *HarvesterMachine) {
+				m.Spec.NetworkConfig = &NetworkConfig{Address: "10.20.0.10", Gateway: "10.20.0.1"}
+				m.Spec.AddressesFromPools = []corev1.TypedLocalObjectReference{harvesterIPPool}
+			},
+			"spec.addressesFromPools and spec.networkConfig are mutually exclusive",
+		},
+	}
+	for _, tc := range cases {
+		m := validMachine()
+		tc.mutate(m)
+
+		_, err := validateHarvesterMachine(m)
+
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if tc.wantErr != "" && err == nil {
+			t.Errorf("%s: expected an error", tc.name)
+		}
+
+		if tc.wantErr != "" && err != nil && !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: error should mention %q: %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
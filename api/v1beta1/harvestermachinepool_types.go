@@ -0,0 +1,172 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+)
+
+const (
+	// MachinePoolFinalizer allows ReconcileHarvesterMachinePool to clean up the VMs of the pool before
+	// removing it from the apiserver.
+	MachinePoolFinalizer = "harvestermachinepool.infrastructure.cluster.x-k8s.io/finalizer"
+)
+
+const (
+	// ReplicasReadyCondition documents whether all the instances of the pool are running.
+	ReplicasReadyCondition string = "ReplicasReady"
+	// ReplicasScalingReason documents that the pool is creating or deleting instances.
+	ReplicasScalingReason = "ReplicasScaling"
+	// ReplicasNotRunningReason documents that some instances of the pool are not running yet.
+	ReplicasNotRunningReason = "ReplicasNotRunning"
+	// ReplicasReadyReason documents that all the instances of the pool are running.
+	ReplicasReadyReason = "ReplicasReady"
+)
+
+// InstanceState is the lifecycle state of a HarvesterMachinePool instance.
+// +kubebuilder:validation:Enum=Provisioning;Running;Deleting
+type InstanceState string
+
+const (
+	// InstanceStateProvisioning means the VM of the instance is being created or has not reported an IP yet.
+	InstanceStateProvisioning InstanceState = "Provisioning"
+	// InstanceStateRunning means the VM of the instance is running and has a provider ID.
+	InstanceStateRunning InstanceState = "Running"
+	// InstanceStateDeleting means the instance is being removed from the pool.
+	InstanceStateDeleting InstanceState = "Deleting"
+)
+
+// HarvesterMachinePoolSpec defines the desired state of HarvesterMachinePool.
+type HarvesterMachinePoolSpec struct {
+	// ProviderIDList is the list of provider IDs of the instances of the pool.
+	// It is maintained by the controller (CAPI MachinePool contract field) and
+	// surfaced on MachinePool.spec.providerIDList.
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
+
+	// Template describes the VM created for every instance of the pool. The
+	// number of instances follows spec.replicas of the owner MachinePool.
+	// The providerID and failureDomain of the template are ignored: each
+	// instance gets its own.
+	Template HarvesterMachineTemplateResource `json:"template"`
+}
+
+// HarvesterMachinePoolInstanceStatus reports the state of one instance of the pool.
+type HarvesterMachinePoolInstanceStatus struct {
+	// InstanceName is the name of the instance, which is also the name of its VM in Harvester.
+	InstanceName string `json:"instanceName"`
+
+	// ProviderID is the provider ID of the instance, set once its VM is running.
+	// +optional
+	ProviderID string `json:"providerID,omitempty"`
+
+	// State is the lifecycle state of the instance.
+	// +optional
+	State InstanceState `json:"state,omitempty"`
+
+	// Addresses are the IP addresses reported by the VM of the instance.
+	// +optional
+	Addresses []clusterv1.MachineAddress `json:"addresses,omitempty"`
+
+	// AllocatedIPAddress is the IP address allocated from the VM IP pool for this instance.
+	// +optional
+	AllocatedIPAddress string `json:"allocatedIPAddress,omitempty"`
+
+	// AllocatedPoolRef is the name of the IPPool from which AllocatedIPAddress was allocated.
+	// +optional
+	AllocatedPoolRef string `json:"allocatedPoolRef,omitempty"`
+
+	// AllocatedIPv6Address is the IPv6 address allocated from the IPv6 pools for this instance.
+	// +optional
+	AllocatedIPv6Address string `json:"allocatedIPv6Address,omitempty"`
+
+	// AllocatedIPv6PoolRef is the name of the IPPool from which AllocatedIPv6Address was allocated.
+	// +optional
+	AllocatedIPv6PoolRef string `json:"allocatedIPv6PoolRef,omitempty"`
+
+	// InterfaceAllocations records the IP addresses allocated for the network
+	// attachments of the instance configured with "pool" addressing.
+	// +optional
+	InterfaceAllocations []InterfaceAllocation `json:"interfaceAllocations,omitempty"`
+
+	// Conditions of the instance. The Deleting condition reports the progress
+	// of the deletion of its VM, like on HarvesterMachines.
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// HarvesterMachinePoolStatus defines the observed state of HarvesterMachinePool.
+type HarvesterMachinePoolStatus struct {
+	// Ready is true when all the instances of the pool are running.
+	Ready bool `json:"ready,omitempty"`
+
+	// Replicas is the most recently observed number of instances in the pool
+	// (CAPI MachinePool contract field).
+	Replicas int32 `json:"replicas"`
+
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// Initialization provides the CAPI v1beta2 contract readiness field
+	// (status.initialization.provisioned). It is set the first time the pool
+	// reaches its desired number of running instances.
+	Initialization Initialization `json:"initialization,omitempty"`
+
+	// Instances reports the state of every instance of the pool.
+	// +optional
+	Instances []HarvesterMachinePoolInstanceStatus `json:"instances,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.replicas",description="Observed number of instances"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="All instances are running"
+
+// HarvesterMachinePool is the Schema for the harvestermachinepools API.
+type HarvesterMachinePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HarvesterMachinePoolSpec   `json:"spec,omitempty"`
+	Status HarvesterMachinePoolStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// HarvesterMachinePoolList contains a list of HarvesterMachinePool.
+type HarvesterMachinePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []HarvesterMachinePool `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HarvesterMachinePool{}, &HarvesterMachinePoolList{})
+}
+
+// GetConditions returns the set of conditions for this object.
+func (m *HarvesterMachinePool) GetConditions() []metav1.Condition {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the conditions on this object.
+func (m *HarvesterMachinePool) SetConditions(conditions []metav1.Condition) {
+	m.Status.Conditions = conditions
+}
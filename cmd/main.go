@@ -36,14 +36,20 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	runtimehooksv1 "sigs.k8s.io/cluster-api/api/runtime/hooks/v1alpha1"
+	runtimecatalog "sigs.k8s.io/cluster-api/exp/runtime/catalog"
+	runtimeserver "sigs.k8s.io/cluster-api/exp/runtime/server"
 
 	infrastructurev1alpha1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1alpha1"
 	infrastructurev1beta1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
 	"github.com/rancher-sandbox/cluster-api-provider-harvester/internal/controller"
+	"github.com/rancher-sandbox/cluster-api-provider-harvester/internal/extension"
 )
 
 const (
-	webhookPort = 9443
+	webhookPort          = 9443
+	runtimeExtensionPort = 9444
 )
 
 var (
@@ -58,6 +64,7 @@ func init() {
 	utilruntime.Must(infrastructurev1alpha1.AddToScheme(scheme))
 	utilruntime.Must(infrastructurev1beta1.AddToScheme(scheme))
 	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(ipamv1.AddToScheme(scheme))
 }
 
 func main() {
@@ -69,6 +76,8 @@ func main() {
 
 	var enableWebhooks bool
 
+	var enableRuntimeExtension bool
+
 	var probeAddr string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
@@ -78,6 +87,8 @@ func main() {
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
 		"Enable validating webhooks for HarvesterMachine and HarvesterCluster resources.")
+	flag.BoolVar(&enableRuntimeExtension, "enable-runtime-extension", false,
+		"Enable the runtime extension serving the CAPI in-place update hooks for CPU and memory hotplug.")
 
 	opts := zap.Options{
 		Development: true,
@@ -113,8 +124,9 @@ func main() {
 	ctx := ctrl.SetupSignalHandler()
 
 	err = (&controller.HarvesterMachineReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorder("harvestermachine-controller"),
 	}).SetupWithManager(ctx, mgr)
 	if err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "HarvesterMachine")
@@ -129,6 +141,33 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "HarvesterCluster")
 		os.Exit(1)
 	}
+
+	err = (&controller.HarvesterMachinePoolReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(ctx, mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HarvesterMachinePool")
+		os.Exit(1)
+	}
+
+	err = (&controller.HarvesterMachineTemplateReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(ctx, mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HarvesterMachineTemplate")
+		os.Exit(1)
+	}
+
+	err = (&controller.IPAddressClaimReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(ctx, mgr)
+	if err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "IPAddressClaim")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if enableWebhooks {
@@ -159,6 +198,16 @@ func main() {
 		setupLog.Info("webhooks enabled")
 	}
 
+	if enableRuntimeExtension {
+		err = setupRuntimeExtension(mgr)
+		if err != nil {
+			setupLog.Error(err, "unable to set up runtime extension")
+			os.Exit(1)
+		}
+
+		setupLog.Info("runtime extension enabled")
+	}
+
 	err = mgr.AddHealthzCheck("healthz", healthz.Ping)
 	if err != nil {
 		setupLog.Error(err, "unable to set up health check")
@@ -179,3 +228,36 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// setupRuntimeExtension adds to the manager the server of the in-place update hooks.
+func setupRuntimeExtension(mgr ctrl.Manager) error {
+	catalog := runtimecatalog.New()
+
+	err := runtimehooksv1.AddToCatalog(catalog)
+	if err != nil {
+		return err
+	}
+
+	srv, err := runtimeserver.New(runtimeserver.Options{
+		Catalog: catalog,
+		Port:    runtimeExtensionPort,
+	})
+	if err != nil {
+		return err
+	}
+
+	handlers := &extension.InPlaceUpdateHandlers{Client: mgr.GetClient()}
+
+	for _, handler := range []runtimeserver.ExtensionHandler{
+		{Hook: runtimehooksv1.CanUpdateMachine, Name: "can-update-machine", HandlerFunc: handlers.DoCanUpdateMachine},
+		{Hook: runtimehooksv1.CanUpdateMachineSet, Name: "can-update-machineset", HandlerFunc: handlers.DoCanUpdateMachineSet},
+		{Hook: runtimehooksv1.UpdateMachine, Name: "update-machine", HandlerFunc: handlers.DoUpdateMachine},
+	} {
+		err = srv.AddExtensionHandler(handler)
+		if err != nil {
+			return err
+		}
+	}
+
+	return mgr.Add(srv)
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// errAddressClaimsPending is returned while some IPAddressClaims of
+// addressesFromPools are not bound to an IPAddress yet.
+var errAddressClaimsPending = errors.New("waiting for the IPAddressClaims of addressesFromPools to be bound")
+
+// resolveClaimedNetworkConfig sets the effective network configuration of a
+// machine using addressesFromPools: the first network gets the addresses of its
+// IPAddressClaims, and the DNS settings and interfaces come from the effective
+// vmNetworkConfig, if any.
+func (r *HarvesterMachineReconciler) resolveClaimedNetworkConfig(hvScope *Scope) error {
+	addresses, err := claimAddresses(hvScope)
+	if err != nil {
+		return err
+	}
+
+	netCfg := &infrav1.NetworkConfig{}
+
+	if vmNetCfg := effectiveVMNetworkConfig(hvScope); vmNetCfg != nil {
+		netCfg.DNSServers = vmNetCfg.DNSServers
+		netCfg.DNSSearch = vmNetCfg.DNSSearch
+		netCfg.Interfaces = vmNetCfg.Interfaces
+	}
+
+	for _, address := range addresses {
+		prefix := int32(0)
+		if address.Spec.Prefix != nil {
+			prefix = *address.Spec.Prefix
+		}
+
+		switch locutil.IPFamilyOf(address.Spec.Address) {
+		case v1.IPv4Protocol:
+			if netCfg.Address != "" {
+				return errors.Errorf("addressesFromPools yields more than one IPv4 address (%s, %s)",
+					netCfg.Address, address.Spec.Address)
+			}
+
+			netCfg.Address = address.Spec.Address
+			netCfg.Gateway = address.Spec.Gateway
+			hvScope.EffectiveSubnetMask = net.IP(net.CIDRMask(int(prefix), 8*net.IPv4len)).String()
+		case v1.IPv6Protocol:
+			if netCfg.IPv6Address != "" {
+				return errors.Errorf("addressesFromPools yields more than one IPv6 address (%s, %s)",
+					netCfg.IPv6Address, address.Spec.Address)
+			}
+
+			netCfg.IPv6Address = address.Spec.Address
+			netCfg.IPv6PrefixLength = prefix
+			netCfg.IPv6Gateway = address.Spec.Gateway
+		default:
+			return errors.Errorf("IPAddress %s has an invalid address %q", address.Name, address.Spec.Address)
+		}
+	}
+
+	if netCfg.Address == "" {
+		return errors.New("addressesFromPools must include an IPv4 pool for the first network")
+	}
+
+	hvScope.EffectiveNetworkConfig = netCfg
+
+	return r.allocateInterfaceIPs(hvScope)
+}
+
+// addressClaimName returns the name of the IPAddressClaim of the index-th entry
+// of the addressesFromPools of a machine.
+func addressClaimName(machineName string, index int) string {
+	return machineName + "-" + strconv.Itoa(index)
+}
+
+// claimAddresses makes sure an IPAddressClaim exists for every entry of the
+// addressesFromPools of the machine and returns the IPAddresses bound to them.
+// It returns errAddressClaimsPending while some claims are not bound yet.
+func claimAddresses(hvScope *Scope) ([]ipamv1.IPAddress, error) {
+	machine := hvScope.HarvesterMachine
+	cl := hvScope.ReconcilerClient
+
+	addresses := make([]ipamv1.IPAddress, 0, len(machine.Spec.AddressesFromPools))
+	pending := make([]string, 0)
+
+	for i, poolRef := range machine.Spec.AddressesFromPools {
+		claim := &ipamv1.IPAddressClaim{}
+		claimKey := types.NamespacedName{Namespace: machine.Namespace, Name: addressClaimName(machine.Name, i)}
+
+		err := cl.Get(hvScope.Ctx, claimKey, claim)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, errors.Wrapf(err, "unable to get IPAddressClaim %s", claimKey.Name)
+			}
+
+			err = createAddressClaim(hvScope, claimKey, poolRef)
+			if err != nil {
+				return nil, err
+			}
+
+			pending = append(pending, claimKey.Name)
+
+			continue
+		}
+
+		if claim.Status.AddressRef.Name == "" {
+			pending = append(pending, claimKey.Name)
+
+			continue
+		}
+
+		address := ipamv1.IPAddress{}
+
+		err = cl.Get(hvScope.Ctx, types.NamespacedName{Namespace: machine.Namespace, Name: claim.Status.AddressRef.Name}, &address)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, errors.Wrapf(err, "unable to get IPAddress %s", claim.Status.AddressRef.Name)
+			}
+
+			pending = append(pending, claimKey.Name)
+
+			continue
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	if len(pending) > 0 {
+		return nil, errors.Wrapf(errAddressClaimsPending, "IPAddressClaims %v", pending)
+	}
+
+	return addresses, nil
+}
+
+// createAddressClaim creates the IPAddressClaim claimKey requesting an address
+// from poolRef, owned by the claim owner of the scope.
+func createAddressClaim(hvScope *Scope, claimKey types.NamespacedName, poolRef v1.TypedLocalObjectReference) error {
+	if poolRef.APIGroup == nil || *poolRef.APIGroup == "" {
+		return errors.Errorf("addressesFromPools entry %s has no apiGroup", poolRef.Name)
+	}
+
+	claim := &ipamv1.IPAddressClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claimKey.Name,
+			Namespace: claimKey.Namespace,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: hvScope.Cluster.Name},
+		},
+		Spec: ipamv1.IPAddressClaimSpec{
+			ClusterName: hvScope.Cluster.Name,
+			PoolRef: ipamv1.IPPoolReference{
+				Name:     poolRef.Name,
+				Kind:     poolRef.Kind,
+				APIGroup: *poolRef.APIGroup,
+			},
+		},
+	}
+
+	var owner client.Object = hvScope.HarvesterMachine
+	if hvScope.ClaimOwner != nil {
+		owner = hvScope.ClaimOwner
+	}
+
+	err := controllerutil.SetControllerReference(owner, claim, hvScope.ReconcilerClient.Scheme())
+	if err != nil {
+		return err
+	}
+
+	err = hvScope.ReconcilerClient.Create(hvScope.Ctx, claim)
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "unable to create IPAddressClaim %s", claimKey.Name)
+	}
+
+	hvScope.Logger.Info("Created IPAddressClaim", "claim", claimKey.Name, "pool", poolRef.Name)
+
+	return nil
+}
+
+// claimedVMIPs returns the addresses claimed from addressesFromPools for the
+// effective network configuration of the machine.
+func claimedVMIPs(hvScope *Scope) []string {
+	netCfg := hvScope.EffectiveNetworkConfig
+	if len(hvScope.HarvesterMachine.Spec.AddressesFromPools) == 0 || netCfg == nil {
+		return nil
+	}
+
+	ips := []string{netCfg.Address}
+	if netCfg.IPv6Address != "" {
+		ips = append(ips, netCfg.IPv6Address)
+	}
+
+	return ips
+}
+
+// deleteAddressClaims deletes the IPAddressClaims of addressesFromPools, which
+// releases their addresses. Errors are logged as warnings.
+func deleteAddressClaims(hvScope *Scope) {
+	machine := hvScope.HarvesterMachine
+
+	for i := range machine.Spec.AddressesFromPools {
+		claim := &ipamv1.IPAddressClaim{
+			ObjectMeta: metav1.ObjectMeta{Namespace: machine.Namespace, Name: addressClaimName(machine.Name, i)},
+		}
+
+		err := hvScope.ReconcilerClient.Delete(hvScope.Ctx, claim)
+		if err != nil && !apierrors.IsNotFound(err) {
+			hvScope.Logger.Info("Warning: failed to delete IPAddressClaim", "claim", claim.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// The status of the HarvesterClusters and HarvesterMachines is lost when the
+// management cluster is moved with clusterctl or restored from a backup, while
+// the objects created in Harvester stay. The ownership information kept on the
+// Harvester side is used to rebuild what the controllers rely on:
+//   - the IPPools record the owner of every allocation, from which the
+//     allocations of a machine are restored;
+//   - the IPPools, load balancers and VMs created for a cluster carry the
+//     clusterLabelName and clusterLabelNamespace labels, and the IPPools
+//     created by the controller the ipPoolRoleLabel label, from which the
+//     conditions marking them for deletion are restored.
+
+const (
+	// clusterLabelName and clusterLabelNamespace identify the HarvesterCluster
+	// the Harvester objects were created for.
+	clusterLabelName      = "harvestercluster/name"
+	clusterLabelNamespace = "harvestercluster/namespace"
+
+	// ipPoolRoleLabel marks the IPPools created by the controller, with the
+	// ipPoolRoleVM or ipPoolRoleLoadBalancer value.
+	ipPoolRoleLabel        = "harvestercluster/ippool-role"
+	ipPoolRoleVM           = "vm"
+	ipPoolRoleLoadBalancer = "loadbalancer"
+
+	vmIPPoolDescriptionPrefix = "VM IP Pool for cluster"
+)
+
+// clusterOwnershipLabels returns the labels identifying the Harvester objects
+// created for cluster. A name too long for a label value is left out.
+func clusterOwnershipLabels(cluster *infrav1.HarvesterCluster) map[string]string {
+	labels := map[string]string{clusterLabelNamespace: cluster.Namespace}
+
+	if len(validation.IsValidLabelValue(cluster.Name)) == 0 {
+		labels[clusterLabelName] = cluster.Name
+	}
+
+	return labels
+}
+
+// ipPoolCreatedFor reports whether pool was created by the controller for
+// cluster with the given role. Pools created before the ownership labels are
+// recognized by their generated name and description.
+func ipPoolCreatedFor(pool *lbv1beta1.IPPool, cluster *infrav1.HarvesterCluster, role string) bool {
+	if pool.Labels[ipPoolRoleLabel] != "" {
+		return pool.Labels[ipPoolRoleLabel] == role &&
+			pool.Labels[clusterLabelName] == cluster.Name &&
+			pool.Labels[clusterLabelNamespace] == cluster.Namespace
+	}
+
+	switch role {
+	case ipPoolRoleVM:
+		return pool.Name == locutil.GenerateRFC1035Name([]string{cluster.Namespace, cluster.Name, "vm-ippool"}) &&
+			pool.Spec.Description == fmt.Sprintf("%s %s/%s", vmIPPoolDescriptionPrefix, cluster.Namespace, cluster.Name)
+	case ipPoolRoleLoadBalancer:
+		return pool.Name == locutil.GenerateRFC1035Name([]string{cluster.Namespace, cluster.Name, "ippool"}) &&
+			pool.Spec.Description == cpIPPoolDescriptionPrefix+" "+cluster.Name
+	default:
+		return false
+	}
+}
+
+// restoreCreatedIPPoolConditions sets the VMIPPoolCreatedByController and
+// CustomIPPoolCreated conditions when they are missing while the referenced
+// pools were created by the controller for the cluster, so that the pools are
+// still deleted with the cluster.
+func restoreCreatedIPPoolConditions(scope *ClusterScope) error {
+	cluster := scope.HarvesterCluster
+
+	if vmNetCfg := cluster.Spec.VMNetworkConfig; vmNetCfg != nil && vmNetCfg.IPPoolRef != "" &&
+		!conditions.IsTrue(cluster, infrav1.VMIPPoolCreatedByControllerCondition) {
+		created, err := referencedIPPoolCreatedFor(scope, vmNetCfg.IPPoolRef, ipPoolRoleVM)
+		if err != nil {
+			return err
+		}
+
+		if created {
+			scope.Logger.Info("Restored the ownership of the VM IP pool", "pool", vmNetCfg.IPPoolRef)
+
+			conditions.Set(cluster, metav1.Condition{
+				Type:    infrav1.VMIPPoolCreatedByControllerCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  infrav1.VMIPPoolCreatedByControllerReason,
+				Message: fmt.Sprintf("VM IP pool %s was created by the controller", vmNetCfg.IPPoolRef),
+			})
+		}
+	}
+
+	lbCfg := cluster.Spec.LoadBalancerConfig
+	if lbCfg.IPAMType == infrav1.POOL && lbCfg.IpPoolRef != "" &&
+		!conditions.IsTrue(cluster, infrav1.CustomIPPoolCreatedCondition) {
+		created, err := referencedIPPoolCreatedFor(scope, lbCfg.IpPoolRef, ipPoolRoleLoadBalancer)
+		if err != nil {
+			return err
+		}
+
+		if created {
+			scope.Logger.Info("Restored the ownership of the load balancer IP pool", "pool", lbCfg.IpPoolRef)
+
+			conditions.Set(cluster, metav1.Condition{
+				Type:    infrav1.CustomIPPoolCreatedCondition,
+				Status:  metav1.ConditionTrue,
+				Reason:  infrav1.CustomIPPoolCreatedSuccessfullyReason,
+				Message: "Custom Pool was created successfully",
+			})
+		}
+	}
+
+	return nil
+}
+
+// referencedIPPoolCreatedFor reports whether the IPPool poolName exists and was
+// created by the controller for the cluster of scope with the given role.
+func referencedIPPoolCreatedFor(scope *ClusterScope, poolName string, role string) (bool, error) {
+	pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(scope.Ctx, poolName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return false, errors.Wrapf(err, "failed to get IP pool %s", poolName)
+	}
+
+	return ipPoolCreatedFor(pool, scope.HarvesterCluster, role), nil
+}
+
+// restoreVMIPAllocations fills the pool allocations missing from the status of
+// the machine from the allocations the IPPools hold for it, so that a machine
+// whose status was lost keeps its addresses and releases them on deletion.
+func restoreVMIPAllocations(hvScope *Scope) error {
+	machine := hvScope.HarvesterMachine
+	ownerID := machine.Namespace + "/" + machine.Name
+
+	if vmNetCfg := effectiveVMNetworkConfig(hvScope); vmNetCfg != nil {
+		if machine.Status.AllocatedIPAddress == "" {
+			ip, poolRef, err := findIPPoolAllocation(hvScope, vmNetCfg.GetIPPoolRefs(), ownerID, corev1.IPv4Protocol)
+			if err != nil {
+				return err
+			}
+
+			if ip != "" {
+				hvScope.Logger.Info("Restored VM IP allocation from pool", "ip", ip, "pool", poolRef)
+				machine.Status.AllocatedIPAddress = ip
+				machine.Status.AllocatedPoolRef = poolRef
+			}
+		}
+
+		if vmNetCfg.IPv6 != nil && machine.Status.AllocatedIPv6Address == "" {
+			ip, poolRef, err := findIPPoolAllocation(hvScope, vmNetCfg.IPv6.IPPoolRefs, ownerID, corev1.IPv6Protocol)
+			if err != nil {
+				return err
+			}
+
+			if ip != "" {
+				hvScope.Logger.Info("Restored VM IPv6 allocation from pool", "ip", ip, "pool", poolRef)
+				machine.Status.AllocatedIPv6Address = ip
+				machine.Status.AllocatedIPv6PoolRef = poolRef
+			}
+		}
+	}
+
+	for _, iface := range effectiveInterfaceConfigs(hvScope) {
+		if iface.Addressing != infrav1.InterfaceAddressingPool || interfaceAllocationFor(machine, iface.Network) != nil {
+			continue
+		}
+
+		poolRefs := iface.IPPoolRefs
+		if vmNetCfg := effectiveVMNetworkConfig(hvScope); len(poolRefs) == 0 && vmNetCfg != nil {
+			poolRefs = vmNetCfg.GetIPPoolRefs()
+		}
+
+		ip, poolRef, err := findIPPoolAllocation(hvScope, poolRefs, ownerID+"/"+iface.Network, corev1.IPv4Protocol)
+		if err != nil {
+			return err
+		}
+
+		if ip != "" {
+			hvScope.Logger.Info("Restored interface IP allocation from pool", "network", iface.Network, "ip", ip, "pool", poolRef)
+			machine.Status.InterfaceAllocations = append(machine.Status.InterfaceAllocations, infrav1.InterfaceAllocation{
+				Network:   iface.Network,
+				IPAddress: ip,
+				PoolRef:   poolRef,
+			})
+		}
+	}
+
+	return nil
+}
+
+// findIPPoolAllocation returns the address of the given family allocated to
+// ownerID in the first of poolRefs holding one, and the name of that pool.
+func findIPPoolAllocation(hvScope *Scope, poolRefs []string, ownerID string, family corev1.IPFamily) (string, string, error) {
+	for _, poolRef := range poolRefs {
+		pool, err := hvScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(hvScope.Ctx, poolRef, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return "", "", errors.Wrapf(err, "failed to get IP pool %s", poolRef)
+		}
+
+		for ip, owner := range pool.Status.Allocated {
+			if owner == ownerID && locutil.IPFamilyOf(ip) == family {
+				return ip, poolRef, nil
+			}
+		}
+	}
+
+	return "", "", nil
+}
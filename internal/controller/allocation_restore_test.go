@@ -0,0 +1,245 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// =============================================================================
+// Tests for the restoration of the allocation status after a move or restore
+// =============================================================================
+
+func newRestoreVMNetworkConfig() *infrav1.VMNetworkConfig {
+	return &infrav1.VMNetworkConfig{
+		IPPoolRefs: []string{"production-pool", "overflow-pool"},
+		Gateway:    "172.16.0.1",
+		SubnetMask: "255.255.0.0",
+		Interfaces: []infrav1.InterfaceConfig{
+			{Network: "default/storage", Addressing: infrav1.InterfaceAddressingPool, SubnetMask: "255.255.255.0",
+				IPPoolRefs: []string{"storage-pool"}},
+		},
+		IPv6: &infrav1.IPv6NetworkConfig{
+			IPPoolRefs:   []string{"production-v6-pool"},
+			Gateway:      "2001:db8:1::1",
+			PrefixLength: 64,
+		},
+	}
+}
+
+func newRestorePools() []*lbv1beta1.IPPool {
+	productionPool := newPerNICPool("production-pool", "default/production", "172.16.0.0/16", "172.16.3.40", "172.16.3.49")
+	productionPool.Status.Allocated = map[string]string{"172.16.3.40": "test-ns/worker-1"}
+
+	overflowPool := newPerNICPool("overflow-pool", "default/production", "172.16.0.0/16", "172.16.4.40", "172.16.4.49")
+	overflowPool.Status.Allocated = map[string]string{"172.16.4.42": "test-ns/worker-0"}
+
+	storagePool := newPerNICPool("storage-pool", "default/storage", "10.20.0.0/24", "10.20.0.40", "10.20.0.49")
+	storagePool.Status.Allocated = map[string]string{"10.20.0.43": "test-ns/worker-0/default/storage"}
+
+	ipv6Pool := newPerNICPool("production-v6-pool", "", "2001:db8:1::/64", "2001:db8:1::40", "2001:db8:1::49")
+	ipv6Pool.Status.Allocated = map[string]string{"2001:db8:1::44": "test-ns/worker-0"}
+
+	return []*lbv1beta1.IPPool{productionPool, overflowPool, storagePool, ipv6Pool}
+}
+
+func newRestoreClusterPool(name string, labels map[string]string, description string) *lbv1beta1.IPPool {
+	pool := newPerNICPool(name, "", "172.16.0.0/16", "172.16.5.10", "172.16.5.50")
+	pool.Labels = labels
+	pool.Spec.Description = description
+
+	return pool
+}
+
+func getScopeIPPool(scope *Scope, name string) *lbv1beta1.IPPool {
+	pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), name, metav1.GetOptions{})
+	Expect(err).ToNot(HaveOccurred())
+
+	return pool
+}
+
+var _ = Describe("restoreVMIPAllocations", func() {
+	It("should restore the allocations the pools hold for the machine", func() {
+		scope := newPerNICScope(newRestoreVMNetworkConfig(), newRestorePools()...)
+
+		Expect(restoreVMIPAllocations(scope)).To(Succeed())
+
+		status := scope.HarvesterMachine.Status
+		Expect(status.AllocatedIPAddress).To(Equal("172.16.4.42"))
+		Expect(status.AllocatedPoolRef).To(Equal("overflow-pool"))
+		Expect(status.AllocatedIPv6Address).To(Equal("2001:db8:1::44"))
+		Expect(status.AllocatedIPv6PoolRef).To(Equal("production-v6-pool"))
+		Expect(status.InterfaceAllocations).To(ConsistOf(infrav1.InterfaceAllocation{
+			Network: "default/storage", IPAddress: "10.20.0.43", PoolRef: "storage-pool",
+		}))
+	})
+
+	It("should keep the allocations already in the status", func() {
+		scope := newPerNICScope(newRestoreVMNetworkConfig(), newRestorePools()...)
+		scope.HarvesterMachine.Status.AllocatedIPAddress = "172.16.3.45"
+		scope.HarvesterMachine.Status.AllocatedPoolRef = "production-pool"
+
+		Expect(restoreVMIPAllocations(scope)).To(Succeed())
+		Expect(scope.HarvesterMachine.Status.AllocatedIPAddress).To(Equal("172.16.3.45"))
+		Expect(scope.HarvesterMachine.Status.AllocatedPoolRef).To(Equal("production-pool"))
+	})
+
+	It("should leave the status empty when no pool holds an allocation", func() {
+		scope := newPerNICScope(newRestoreVMNetworkConfig())
+
+		Expect(restoreVMIPAllocations(scope)).To(Succeed())
+		Expect(scope.HarvesterMachine.Status.AllocatedIPAddress).To(BeEmpty())
+		Expect(scope.HarvesterMachine.Status.InterfaceAllocations).To(BeEmpty())
+	})
+
+	It("should reuse the restored addresses instead of allocating from the first pool", func() {
+		scope := newPerNICScope(newRestoreVMNetworkConfig(), newRestorePools()...)
+		r := &HarvesterMachineReconciler{}
+
+		Expect(r.resolveNetworkConfig(scope)).To(Succeed())
+		Expect(scope.EffectiveNetworkConfig.Address).To(Equal("172.16.4.42"))
+		Expect(scope.EffectiveNetworkConfig.IPv6Address).To(Equal("2001:db8:1::44"))
+
+		productionPool := getScopeIPPool(scope, "production-pool")
+		Expect(productionPool.Status.Allocated).To(HaveLen(1))
+	})
+
+	It("should release the restored addresses on deletion", func() {
+		scope := newPerNICScope(newRestoreVMNetworkConfig(), newRestorePools()...)
+		r := &HarvesterMachineReconciler{}
+
+		r.releaseVMIP(scope)
+
+		for _, name := range []string{"overflow-pool", "storage-pool", "production-v6-pool"} {
+			Expect(getScopeIPPool(scope, name).Status.Allocated).To(BeEmpty(), name)
+		}
+
+		Expect(getScopeIPPool(scope, "production-pool").Status.Allocated).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("ipPoolCreatedFor", func() {
+	hvCluster := newAuditHarvesterCluster()
+	vmPoolName := "test-ns-test-hv-cluster-vm-ippool"
+	lbPoolName := "test-ns-test-hv-cluster-ippool"
+
+	It("should recognize the pools from their labels", func() {
+		labels := clusterOwnershipLabels(hvCluster)
+		labels[ipPoolRoleLabel] = ipPoolRoleVM
+		pool := newRestoreClusterPool("custom-name", labels, "")
+
+		Expect(ipPoolCreatedFor(pool, hvCluster, ipPoolRoleVM)).To(BeTrue())
+		Expect(ipPoolCreatedFor(pool, hvCluster, ipPoolRoleLoadBalancer)).To(BeFalse())
+
+		otherCluster := hvCluster.DeepCopy()
+		otherCluster.Name = "other-cluster"
+		Expect(ipPoolCreatedFor(pool, otherCluster, ipPoolRoleVM)).To(BeFalse())
+	})
+
+	It("should recognize the pools created before the labels", func() {
+		vmPool := newRestoreClusterPool(vmPoolName, nil, "VM IP Pool for cluster test-ns/test-hv-cluster")
+		lbPool := newRestoreClusterPool(lbPoolName, nil, cpIPPoolDescriptionPrefix+" test-hv-cluster")
+
+		Expect(ipPoolCreatedFor(vmPool, hvCluster, ipPoolRoleVM)).To(BeTrue())
+		Expect(ipPoolCreatedFor(lbPool, hvCluster, ipPoolRoleLoadBalancer)).To(BeTrue())
+	})
+
+	It("should not claim a pool created by a user under the generated name", func() {
+		pool := newRestoreClusterPool(vmPoolName, nil, "Shared production pool")
+
+		Expect(ipPoolCreatedFor(pool, hvCluster, ipPoolRoleVM)).To(BeFalse())
+	})
+
+	It("should leave out a cluster name that is not a valid label value", func() {
+		longCluster := hvCluster.DeepCopy()
+		longCluster.Name = strings.Repeat("a", 70)
+
+		labels := clusterOwnershipLabels(longCluster)
+		Expect(labels).To(HaveKeyWithValue(clusterLabelNamespace, "test-ns"))
+		Expect(labels).ToNot(HaveKey(clusterLabelName))
+	})
+})
+
+var _ = Describe("restoreCreatedIPPoolConditions", func() {
+	newRestoredCluster := func() *infrav1.HarvesterCluster {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Spec.VMNetworkConfig.IPPoolRefs = nil
+		hvCluster.Spec.VMNetworkConfig.IPPoolRef = "test-ns-test-hv-cluster-vm-ippool"
+		hvCluster.Spec.LoadBalancerConfig.IpPoolRef = "test-ns-test-hv-cluster-ippool"
+
+		return hvCluster
+	}
+
+	newCreatedPools := func(hvCluster *infrav1.HarvesterCluster) (*lbv1beta1.IPPool, *lbv1beta1.IPPool) {
+		vmLabels := clusterOwnershipLabels(hvCluster)
+		vmLabels[ipPoolRoleLabel] = ipPoolRoleVM
+		lbLabels := clusterOwnershipLabels(hvCluster)
+		lbLabels[ipPoolRoleLabel] = ipPoolRoleLoadBalancer
+
+		return newRestoreClusterPool("test-ns-test-hv-cluster-vm-ippool", vmLabels, ""),
+			newRestoreClusterPool("test-ns-test-hv-cluster-ippool", lbLabels, "")
+	}
+
+	It("should restore the conditions of the pools created by the controller", func() {
+		hvCluster := newRestoredCluster()
+		vmPool, lbPool := newCreatedPools(hvCluster)
+		_, scope := newAuditScope(hvCluster, nil, vmPool, lbPool)
+
+		Expect(restoreCreatedIPPoolConditions(scope)).To(Succeed())
+		Expect(conditions.IsTrue(hvCluster, infrav1.VMIPPoolCreatedByControllerCondition)).To(BeTrue())
+		Expect(conditions.IsTrue(hvCluster, infrav1.CustomIPPoolCreatedCondition)).To(BeTrue())
+	})
+
+	It("should not mark referenced pools for deletion", func() {
+		hvCluster := newRestoredCluster()
+		_, scope := newAuditScope(hvCluster, nil,
+			newRestoreClusterPool("test-ns-test-hv-cluster-vm-ippool", nil, "Shared pool"),
+			newRestoreClusterPool("test-ns-test-hv-cluster-ippool", nil, "Shared pool"))
+
+		Expect(restoreCreatedIPPoolConditions(scope)).To(Succeed())
+		Expect(conditions.Get(hvCluster, infrav1.VMIPPoolCreatedByControllerCondition)).To(BeNil())
+		Expect(conditions.Get(hvCluster, infrav1.CustomIPPoolCreatedCondition)).To(BeNil())
+	})
+
+	It("should let ReconcileDelete delete the restored pools", func() {
+		hvCluster := newRestoredCluster()
+		hvCluster.Finalizers = []string{infrav1.ClusterFinalizer}
+		vmPool, lbPool := newCreatedPools(hvCluster)
+		r, scope := newAuditScope(hvCluster, nil, vmPool, lbPool)
+
+		_, err := r.ReconcileDelete(scope)
+		Expect(err).ToNot(HaveOccurred())
+
+		for _, name := range []string{vmPool.Name, lbPool.Name} {
+			_, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), name, metav1.GetOptions{})
+			Expect(apierrors.IsNotFound(err)).To(BeTrue(), name)
+		}
+	})
+})
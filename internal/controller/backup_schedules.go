@@ -0,0 +1,364 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// The backupPolicy of a HarvesterCluster is turned into one Harvester
+// ScheduleVMBackup per VM of the cluster, found by the clusterLabelName and
+// clusterLabelNamespace labels the VMs carry. The schedules carry the same
+// labels, so that the ones of replaced or removed VMs are found and deleted.
+// The Harvester API module the provider builds against has no type for the
+// schedules, so they are handled as unstructured objects.
+
+const (
+	// backupScheduleSyncInterval is the period of the synchronization of the
+	// backup schedules with the VMs of the cluster.
+	backupScheduleSyncInterval = 5 * time.Minute
+
+	// defaultBackupRetention is the number of backups kept for each VM when the
+	// backup policy sets none.
+	defaultBackupRetention int32 = 8
+
+	// backupScheduleSuffix is appended to the name of a VM to name its
+	// backup schedule.
+	backupScheduleSuffix = "-backup"
+)
+
+// scheduleVMBackupGVR is the resource of the Harvester backup schedules.
+var scheduleVMBackupGVR = schema.GroupVersionResource{
+	Group:    "harvesterhci.io",
+	Version:  "v1beta1",
+	Resource: "schedulevmbackups",
+}
+
+// reconcileBackupSchedules keeps a backup schedule for every VM of the cluster
+// covered by spec.backupPolicy, deletes the other schedules of the cluster,
+// and reports the last successful backup of every VM in the status. It
+// returns the time until the next synchronization, or zero without backup
+// policy.
+// This is a best-effort operation: errors are reported in the
+// BackupSchedulesReady condition but don't block cluster provisioning.
+func (r *HarvesterClusterReconciler) reconcileBackupSchedules(scope *ClusterScope) time.Duration {
+	cluster := scope.HarvesterCluster
+
+	if cluster.Spec.BackupPolicy == nil {
+		// The condition is only set once schedules were created
+		if !conditions.Has(cluster, infrav1.BackupSchedulesReadyCondition) {
+			return 0
+		}
+
+		err := deleteBackupSchedules(scope, nil)
+		if err != nil {
+			scope.Logger.Info("Warning: failed to delete the backup schedules", "error", err)
+
+			return backupScheduleSyncInterval
+		}
+
+		conditions.Delete(cluster, infrav1.BackupSchedulesReadyCondition)
+		cluster.Status.MachineBackups = nil
+
+		return 0
+	}
+
+	err := r.syncBackupSchedules(scope)
+	if err != nil {
+		scope.Logger.Info("Warning: failed to synchronize the backup schedules", "error", err)
+
+		conditions.Set(cluster, metav1.Condition{
+			Type:    infrav1.BackupSchedulesReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.BackupSchedulesFailedReason,
+			Message: fmt.Sprintf("Failed to synchronize the backup schedules: %v", err),
+		})
+	}
+
+	return backupScheduleSyncInterval
+}
+
+// syncBackupSchedules creates or updates the backup schedules of the VMs of
+// the cluster covered by the backup policy, deletes the others and records the
+// backups of the VMs in the status.
+func (r *HarvesterClusterReconciler) syncBackupSchedules(scope *ClusterScope) error {
+	cluster := scope.HarvesterCluster
+	policy := cluster.Spec.BackupPolicy
+	ownershipLabels := clusterOwnershipLabels(cluster)
+
+	// Without the name label, the VMs of the clusters of the namespace can't
+	// be told apart
+	if _, ok := ownershipLabels[clusterLabelName]; !ok {
+		return errors.Errorf("the name of the cluster %s is too long to find its VMs by label", cluster.Name)
+	}
+
+	vms, err := scope.HarvesterClient.KubevirtV1().VirtualMachines(cluster.Spec.TargetNamespace).List(scope.Ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(ownershipLabels).String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to list the VMs of the cluster")
+	}
+
+	backedUp := make(map[string]string)
+	failed := make([]string, 0)
+
+	for i := range vms.Items {
+		vm := &vms.Items[i]
+		if !vm.DeletionTimestamp.IsZero() || !backupPolicyCovers(policy, vm) {
+			continue
+		}
+
+		scheduleName, applyErr := applyBackupSchedule(scope, vm.Name)
+		if applyErr != nil {
+			scope.Logger.Info("Warning: failed to apply the backup schedule of the VM", "vm", vm.Name, "error", applyErr)
+			failed = append(failed, vm.Name)
+
+			continue
+		}
+
+		backedUp[vm.Name] = scheduleName
+	}
+
+	err = deleteBackupSchedules(scope, backedUp)
+	if err != nil {
+		return err
+	}
+
+	err = recordMachineBackups(scope, backedUp)
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		slices.Sort(failed)
+
+		return errors.Errorf("unable to apply the backup schedules of VMs %s", strings.Join(failed, ", "))
+	}
+
+	conditions.Set(cluster, metav1.Condition{
+		Type:    infrav1.BackupSchedulesReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  infrav1.BackupSchedulesReadyReason,
+		Message: fmt.Sprintf("%d VMs backed up on schedule %q", len(backedUp), policy.Schedule),
+	})
+
+	return nil
+}
+
+// backupPolicyCovers reports whether the roles of the backup policy include
+// the one of the VM: control plane VMs carry the cpVMLabelKey label.
+func backupPolicyCovers(policy *infrav1.BackupPolicy, vm *kubevirtv1.VirtualMachine) bool {
+	if len(policy.Roles) == 0 {
+		return true
+	}
+
+	role := infrav1.MachineRoleWorker
+	if vm.Labels[cpVMLabelKey] != "" {
+		role = infrav1.MachineRoleControlPlane
+	}
+
+	return slices.Contains(policy.Roles, role)
+}
+
+// backupScheduleSpec returns the spec of the ScheduleVMBackup of the VM.
+func backupScheduleSpec(policy *infrav1.BackupPolicy, vmName string) map[string]any {
+	target := policy.Target
+	if target == "" {
+		target = infrav1.BackupTargetBackup
+	}
+
+	retention := policy.Retention
+	if retention == 0 {
+		retention = defaultBackupRetention
+	}
+
+	return map[string]any{
+		"cron":   policy.Schedule,
+		"retain": int64(retention),
+		"vmbackup": map[string]any{
+			"source": map[string]any{
+				"apiGroup": kubevirtv1.SchemeGroupVersion.Group,
+				"kind":     kubevirtv1.VirtualMachineGroupVersionKind.Kind,
+				"name":     vmName,
+			},
+			"type": string(target),
+		},
+	}
+}
+
+// applyBackupSchedule creates the backup schedule of the VM, or updates it
+// when it no longer follows the backup policy. It returns the name of the
+// schedule.
+func applyBackupSchedule(scope *ClusterScope, vmName string) (string, error) {
+	cluster := scope.HarvesterCluster
+	schedules := scope.HarvesterDynamicClient.Resource(scheduleVMBackupGVR).Namespace(cluster.Spec.TargetNamespace)
+	scheduleName := vmName + backupScheduleSuffix
+	spec := backupScheduleSpec(cluster.Spec.BackupPolicy, vmName)
+
+	schedule, err := schedules.Get(scope.Ctx, scheduleName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		schedule = &unstructured.Unstructured{Object: map[string]any{"spec": spec}}
+		schedule.SetGroupVersionKind(scheduleVMBackupGVR.GroupVersion().WithKind("ScheduleVMBackup"))
+		schedule.SetName(scheduleName)
+		schedule.SetNamespace(cluster.Spec.TargetNamespace)
+		schedule.SetLabels(clusterOwnershipLabels(cluster))
+
+		_, err = schedules.Create(scope.Ctx, schedule, metav1.CreateOptions{})
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to create ScheduleVMBackup %s", scheduleName)
+		}
+
+		scope.Logger.Info("Created the backup schedule of the VM", "vm", vmName, "schedule", scheduleName)
+
+		return scheduleName, nil
+	}
+
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get ScheduleVMBackup %s", scheduleName)
+	}
+
+	// Harvester fills in the other fields of the spec, e.g. maxFailure
+	changed := false
+
+	for _, path := range [][]string{{"cron"}, {"retain"}, {"vmbackup", "type"}} {
+		want, _, _ := unstructured.NestedFieldNoCopy(spec, path...)
+		current, _, _ := unstructured.NestedFieldNoCopy(schedule.Object, append([]string{"spec"}, path...)...)
+
+		if current == want {
+			continue
+		}
+
+		err = unstructured.SetNestedField(schedule.Object, want, append([]string{"spec"}, path...)...)
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to set spec.%s of ScheduleVMBackup %s", strings.Join(path, "."), scheduleName)
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return scheduleName, nil
+	}
+
+	_, err = schedules.Update(scope.Ctx, schedule, metav1.UpdateOptions{})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to update ScheduleVMBackup %s", scheduleName)
+	}
+
+	scope.Logger.Info("Updated the backup schedule of the VM", "vm", vmName, "schedule", scheduleName)
+
+	return scheduleName, nil
+}
+
+// deleteBackupSchedules deletes the backup schedules of the cluster whose name
+// is not in keep. The backups they took are left in place.
+func deleteBackupSchedules(scope *ClusterScope, keep map[string]string) error {
+	cluster := scope.HarvesterCluster
+	schedules := scope.HarvesterDynamicClient.Resource(scheduleVMBackupGVR).Namespace(cluster.Spec.TargetNamespace)
+
+	list, err := schedules.List(scope.Ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(clusterOwnershipLabels(cluster)).String(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to list the backup schedules of the cluster")
+	}
+
+	kept := make(map[string]bool, len(keep))
+	for _, scheduleName := range keep {
+		kept[scheduleName] = true
+	}
+
+	for _, schedule := range list.Items {
+		if kept[schedule.GetName()] {
+			continue
+		}
+
+		err = schedules.Delete(scope.Ctx, schedule.GetName(), metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "unable to delete ScheduleVMBackup %s", schedule.GetName())
+		}
+
+		scope.Logger.Info("Deleted the backup schedule of a former VM", "schedule", schedule.GetName())
+	}
+
+	return nil
+}
+
+// recordMachineBackups records in the status the backup schedule of every
+// backed up VM, with the creation time of its most recent backup ready to use.
+func recordMachineBackups(scope *ClusterScope, backedUp map[string]string) error {
+	cluster := scope.HarvesterCluster
+
+	backups, err := scope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineBackups(cluster.Spec.TargetNamespace).List(
+		scope.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return errors.Wrap(err, "unable to list the VM backups")
+	}
+
+	lastBackups := make(map[string]metav1.Time)
+
+	for _, backup := range backups.Items {
+		if backup.Status == nil || backup.Status.ReadyToUse == nil || !*backup.Status.ReadyToUse {
+			continue
+		}
+
+		created := backup.CreationTimestamp
+		if backup.Status.CreationTime != nil {
+			created = *backup.Status.CreationTime
+		}
+
+		vmName := backup.Spec.Source.Name
+		if last, ok := lastBackups[vmName]; !ok || created.After(last.Time) {
+			lastBackups[vmName] = created
+		}
+	}
+
+	machineBackups := make([]infrav1.MachineBackupStatus, 0, len(backedUp))
+
+	for vmName, scheduleName := range backedUp {
+		machineBackup := infrav1.MachineBackupStatus{Machine: vmName, Schedule: scheduleName}
+		if last, ok := lastBackups[vmName]; ok {
+			machineBackup.LastSuccessfulBackupTime = &last
+		}
+
+		machineBackups = append(machineBackups, machineBackup)
+	}
+
+	slices.SortFunc(machineBackups, func(a, b infrav1.MachineBackupStatus) int {
+		return strings.Compare(a.Machine, b.Machine)
+	})
+
+	cluster.Status.MachineBackups = machineBackups
+
+	return nil
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for the backup schedules of the VMs of a cluster
+// =============================================================================
+
+var _ = Describe("reconcileBackupSchedules", func() {
+	reconciler := &HarvesterClusterReconciler{}
+
+	newBackupCluster := func(policy *infrav1.BackupPolicy) *infrav1.HarvesterCluster {
+		return &infrav1.HarvesterCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+			Spec:       infrav1.HarvesterClusterSpec{TargetNamespace: "default", BackupPolicy: policy},
+		}
+	}
+
+	newClusterVM := func(name string, controlPlane bool) *kubevirtv1.VirtualMachine {
+		vm := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels:    clusterOwnershipLabels(newBackupCluster(nil)),
+			},
+		}
+
+		if controlPlane {
+			vm.Labels[cpVMLabelKey] = cpVMLabelValuePrefix + "-test-cluster"
+		}
+
+		return vm
+	}
+
+	newSchedule := func(name, cron string) *unstructured.Unstructured {
+		schedule := &unstructured.Unstructured{Object: map[string]any{
+			"spec": map[string]any{"cron": cron, "retain": int64(8), "maxFailure": int64(4)},
+		}}
+		schedule.SetGroupVersionKind(scheduleVMBackupGVR.GroupVersion().WithKind("ScheduleVMBackup"))
+		schedule.SetName(name)
+		schedule.SetNamespace("default")
+		schedule.SetLabels(clusterOwnershipLabels(newBackupCluster(nil)))
+
+		return schedule
+	}
+
+	newBackupScope := func(cluster *infrav1.HarvesterCluster, hvObjs []runtime.Object, schedules ...runtime.Object) *ClusterScope {
+		return &ClusterScope{
+			Ctx:              context.TODO(),
+			Logger:           log.FromContext(context.TODO()),
+			HarvesterCluster: cluster,
+			HarvesterClient:  hvfake.NewSimpleClientset(hvObjs...),
+			HarvesterDynamicClient: dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+				map[schema.GroupVersionResource]string{scheduleVMBackupGVR: "ScheduleVMBackupList"}, schedules...),
+		}
+	}
+
+	getSchedule := func(scope *ClusterScope, name string) (*unstructured.Unstructured, error) {
+		return scope.HarvesterDynamicClient.Resource(scheduleVMBackupGVR).Namespace("default").Get(
+			context.TODO(), name, metav1.GetOptions{})
+	}
+
+	specField := func(schedule *unstructured.Unstructured, fields ...string) any {
+		value, _, _ := unstructured.NestedFieldNoCopy(schedule.Object, append([]string{"spec"}, fields...)...)
+
+		return value
+	}
+
+	It("should do nothing without backup policy", func() {
+		scope := newBackupScope(newBackupCluster(nil), nil, newSchedule("cp-0-backup", "0 2 * * *"))
+
+		Expect(reconciler.reconcileBackupSchedules(scope)).To(BeZero())
+
+		_, err := getSchedule(scope, "cp-0-backup")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should schedule the backups of the VMs of the roles of the policy", func() {
+		policy := &infrav1.BackupPolicy{
+			Target:    infrav1.BackupTargetSnapshot,
+			Schedule:  "0 2 * * *",
+			Retention: 5,
+			Roles:     []infrav1.MachineRole{infrav1.MachineRoleControlPlane},
+		}
+		otherVM := newClusterVM("other-0", true)
+		otherVM.Labels[clusterLabelName] = "other-cluster"
+
+		scope := newBackupScope(newBackupCluster(policy), []runtime.Object{
+			newClusterVM("cp-0", true), newClusterVM("worker-0", false), otherVM,
+		})
+
+		Expect(reconciler.reconcileBackupSchedules(scope)).To(Equal(backupScheduleSyncInterval))
+
+		schedule, err := getSchedule(scope, "cp-0-backup")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(schedule.GetLabels()).To(HaveKeyWithValue(clusterLabelName, "test-cluster"))
+		Expect(specField(schedule, "cron")).To(Equal("0 2 * * *"))
+		Expect(specField(schedule, "retain")).To(Equal(int64(5)))
+		Expect(specField(schedule, "vmbackup", "type")).To(Equal("snapshot"))
+		Expect(specField(schedule, "vmbackup", "source", "name")).To(Equal("cp-0"))
+
+		_, err = getSchedule(scope, "worker-0-backup")
+		Expect(err).To(HaveOccurred())
+		_, err = getSchedule(scope, "other-0-backup")
+		Expect(err).To(HaveOccurred())
+
+		Expect(conditions.IsTrue(scope.HarvesterCluster, infrav1.BackupSchedulesReadyCondition)).To(BeTrue())
+		Expect(scope.HarvesterCluster.Status.MachineBackups).To(Equal([]infrav1.MachineBackupStatus{
+			{Machine: "cp-0", Schedule: "cp-0-backup"},
+		}))
+	})
+
+	It("should update the schedules to the policy and report the last successful backups", func() {
+		created := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		backups := []runtime.Object{
+			&harvesterv1beta1.VirtualMachineBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "cp-0-backup-1", Namespace: "default"},
+				Spec:       harvesterv1beta1.VirtualMachineBackupSpec{Source: corev1.TypedLocalObjectReference{Name: "cp-0"}},
+				Status:     &harvesterv1beta1.VirtualMachineBackupStatus{ReadyToUse: new(true), CreationTime: &created},
+			},
+			&harvesterv1beta1.VirtualMachineBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "cp-0-backup-2", Namespace: "default"},
+				Spec:       harvesterv1beta1.VirtualMachineBackupSpec{Source: corev1.TypedLocalObjectReference{Name: "cp-0"}},
+				Status:     &harvesterv1beta1.VirtualMachineBackupStatus{ReadyToUse: new(false)},
+			},
+		}
+
+		scope := newBackupScope(newBackupCluster(&infrav1.BackupPolicy{Schedule: "0 3 * * *"}),
+			append(backups, newClusterVM("cp-0", true)), newSchedule("cp-0-backup", "0 2 * * *"))
+
+		reconciler.reconcileBackupSchedules(scope)
+
+		schedule, err := getSchedule(scope, "cp-0-backup")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(specField(schedule, "cron")).To(Equal("0 3 * * *"))
+		Expect(specField(schedule, "vmbackup", "type")).To(Equal("backup"))
+		Expect(specField(schedule, "maxFailure")).To(Equal(int64(4)))
+
+		Expect(scope.HarvesterCluster.Status.MachineBackups).To(HaveLen(1))
+		Expect(scope.HarvesterCluster.Status.MachineBackups[0].LastSuccessfulBackupTime.Time).To(BeTemporally("==", created.Time))
+	})
+
+	It("should delete the schedules of the former VMs", func() {
+		scope := newBackupScope(newBackupCluster(&infrav1.BackupPolicy{Schedule: "0 2 * * *"}),
+			[]runtime.Object{newClusterVM("cp-1", true)}, newSchedule("cp-0-backup", "0 2 * * *"))
+
+		reconciler.reconcileBackupSchedules(scope)
+
+		_, err := getSchedule(scope, "cp-0-backup")
+		Expect(err).To(HaveOccurred())
+		_, err = getSchedule(scope, "cp-1-backup")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should delete every schedule once the policy is removed", func() {
+		cluster := newBackupCluster(nil)
+		cluster.Status.MachineBackups = []infrav1.MachineBackupStatus{{Machine: "cp-0", Schedule: "cp-0-backup"}}
+		conditions.Set(cluster, metav1.Condition{
+			Type:   infrav1.BackupSchedulesReadyCondition,
+			Status: metav1.ConditionTrue,
+			Reason: infrav1.BackupSchedulesReadyReason,
+		})
+		scope := newBackupScope(cluster, nil, newSchedule("cp-0-backup", "0 2 * * *"))
+
+		Expect(reconciler.reconcileBackupSchedules(scope)).To(BeZero())
+
+		_, err := getSchedule(scope, "cp-0-backup")
+		Expect(err).To(HaveOccurred())
+		Expect(conditions.Has(cluster, infrav1.BackupSchedulesReadyCondition)).To(BeFalse())
+		Expect(cluster.Status.MachineBackups).To(BeNil())
+	})
+})
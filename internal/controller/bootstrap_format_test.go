@@ -0,0 +1,201 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// =============================================================================
+// Tests for the bootstrap data formats (cloud-config, Ignition, Talos)
+// =============================================================================
+
+const testTalosMachineConfig = `version: v1alpha1
+machine:
+  type: worker
+  token: abcdef.0123456789abcdef
+cluster:
+  controlPlane:
+    endpoint: https://172.16.3.10:6443
+`
+
+func newBootstrapFormatTestScope(bootstrapData map[string][]byte, networkConfig *infrav1.NetworkConfig) *Scope {
+	sshKeyPair := &harvesterv1beta1.KeyPair{
+		ObjectMeta: metav1.ObjectMeta{Name: "capi-ssh-key", Namespace: "default"},
+		Spec:       harvesterv1beta1.KeyPairSpec{PublicKey: "ssh-rsa AAAA test@test"},
+	}
+
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	dataSecretName := testBootstrapDataSecretName
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: dataSecretName, Namespace: "test-ns"},
+		Data:       bootstrapData,
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(bootstrapSecret).Build()
+	logger := log.FromContext(context.TODO())
+
+	return &Scope{
+		Ctx:     context.TODO(),
+		Cluster: &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster"}},
+		Machine: &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "test-ns"},
+			Spec:       clusterv1.MachineSpec{Bootstrap: clusterv1.Bootstrap{DataSecretName: &dataSecretName}},
+		},
+		HarvesterMachine: &infrav1.HarvesterMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-fmt-0"},
+			Spec: infrav1.HarvesterMachineSpec{
+				CPU: 2, Memory: "4Gi",
+				SSHUser:    "core",
+				SSHKeyPair: "default/capi-ssh-key",
+				Networks:   []string{"default/production"},
+			},
+		},
+		HarvesterCluster: &infrav1.HarvesterCluster{
+			Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
+		},
+		HarvesterClient:        hvfake.NewSimpleClientset(sshKeyPair),
+		ReconcilerClient:       fakeClient,
+		Logger:                 &logger,
+		EffectiveNetworkConfig: networkConfig,
+	}
+}
+
+var _ = Describe("getBootstrapData format", func() {
+	It("should default to cloud-config when the format key is missing", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{"value": []byte("runcmd:\n  - echo hello\n")}, nil)
+
+		_, format, err := getBootstrapData(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal(locutil.BootstrapFormatCloudConfig))
+	})
+
+	It("should recognize a Talos machine config", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{
+			"value":  []byte(testTalosMachineConfig),
+			"format": []byte("cloud-config"),
+		}, nil)
+
+		_, format, err := getBootstrapData(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal(locutil.BootstrapFormatTalos))
+	})
+
+	It("should return an error for an unsupported format", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{
+			"value":  []byte("echo hello"),
+			"format": []byte("shell"),
+		}, nil)
+
+		_, _, err := getBootstrapData(scope)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unsupported bootstrap data format"))
+	})
+})
+
+var _ = Describe("buildVMTemplate bootstrap formats", func() {
+	It("should pass a Talos machine config through untouched with NoCloud network data", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{
+			"value":  []byte(testTalosMachineConfig),
+			"format": []byte("cloud-config"),
+		}, &infrav1.NetworkConfig{Address: "172.16.3.40", Gateway: "172.16.0.1"})
+		scope.HarvesterCluster.Spec.VMNetworkConfig = &infrav1.VMNetworkConfig{SubnetMask: "255.255.0.0", Gateway: "172.16.0.1"}
+
+		tmpl, err := buildVMTemplate(scope, nil, map[string]string{})
+		Expect(err).ToNot(HaveOccurred())
+
+		secret, err := scope.HarvesterClient.CoreV1().Secrets("default").Get(
+			context.TODO(), "test-fmt-0-cloud-init", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(secret.Data["userdata"])).To(Equal(testTalosMachineConfig))
+		Expect(string(secret.Data["networkdata"])).To(ContainSubstring("172.16.3.40"))
+
+		Expect(tmpl.Spec.Volumes).To(HaveLen(1))
+		Expect(tmpl.Spec.Volumes[0].CloudInitNoCloud).ToNot(BeNil())
+	})
+
+	It("should not add the cloud-config base or DHCP workaround to a Talos machine config", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{"value": []byte(testTalosMachineConfig)}, nil)
+
+		_, err := buildVMTemplate(scope, nil, map[string]string{})
+		Expect(err).ToNot(HaveOccurred())
+
+		secret, err := scope.HarvesterClient.CoreV1().Secrets("default").Get(
+			context.TODO(), "test-fmt-0-cloud-init", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		userdata := string(secret.Data["userdata"])
+		Expect(userdata).ToNot(ContainSubstring("qemu-guest-agent"))
+		Expect(userdata).ToNot(ContainSubstring("dhclient"))
+		Expect(userdata).ToNot(ContainSubstring("ssh-rsa"))
+	})
+
+	It("should serve Ignition through a config drive with the SSH key added", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{
+			"value":  []byte(`{"ignition":{"version":"3.4.0"}}`),
+			"format": []byte("ignition"),
+		}, &infrav1.NetworkConfig{Address: "172.16.3.40", Gateway: "172.16.0.1"})
+
+		tmpl, err := buildVMTemplate(scope, nil, map[string]string{})
+		Expect(err).ToNot(HaveOccurred())
+
+		secret, err := scope.HarvesterClient.CoreV1().Secrets("default").Get(
+			context.TODO(), "test-fmt-0-cloud-init", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		userdata := string(secret.Data["userdata"])
+		Expect(userdata).To(HavePrefix("{"))
+		Expect(userdata).To(ContainSubstring(`"name":"core"`))
+		Expect(userdata).To(ContainSubstring("ssh-rsa AAAA test@test"))
+		Expect(userdata).ToNot(ContainSubstring("#cloud-config"))
+		// Config drive network data uses the OpenStack format, not network-config v1.
+		Expect(secret.Data).ToNot(HaveKey("networkdata"))
+
+		Expect(tmpl.Spec.Volumes).To(HaveLen(1))
+		Expect(tmpl.Spec.Volumes[0].CloudInitNoCloud).To(BeNil())
+		Expect(tmpl.Spec.Volumes[0].CloudInitConfigDrive).ToNot(BeNil())
+		Expect(tmpl.Spec.Volumes[0].CloudInitConfigDrive.UserDataSecretRef.Name).To(Equal("test-fmt-0-cloud-init"))
+	})
+
+	It("should fail on malformed Ignition data", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{
+			"value":  []byte("#cloud-config\n"),
+			"format": []byte("ignition"),
+		}, nil)
+
+		_, err := buildVMTemplate(scope, nil, map[string]string{})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,211 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for IPv6 and dual-stack addressing
+// =============================================================================
+
+func newDualStackVMNetworkConfig(ipv6PoolRefs ...string) *infrav1.VMNetworkConfig {
+	return &infrav1.VMNetworkConfig{
+		IPPoolRef:  "production-pool",
+		Gateway:    "172.16.0.1",
+		SubnetMask: "255.255.0.0",
+		IPv6: &infrav1.IPv6NetworkConfig{
+			IPPoolRefs:   ipv6PoolRefs,
+			Gateway:      "2001:db8:1::1",
+			PrefixLength: 64,
+		},
+	}
+}
+
+var _ = Describe("buildNetworkDataStatic dual-stack", func() {
+	It("should add the IPv6 address to the first NIC", func() {
+		scope := newPerNICScope(nil)
+		scope.EffectiveNetworkConfig = &infrav1.NetworkConfig{
+			Address:          "172.16.3.40",
+			Gateway:          "172.16.0.1",
+			IPv6Address:      "2001:db8:1::40",
+			IPv6PrefixLength: 64,
+			IPv6Gateway:      "2001:db8:1::1",
+		}
+
+		result := buildNetworkDataStatic(scope)
+
+		Expect(result).To(ContainSubstring(`    name: eth0
+    subnets:
+      - type: static
+        address: 172.16.3.40
+        netmask: 255.255.0.0
+        gateway: 172.16.0.1
+      - type: static6
+        address: 2001:db8:1::40/64
+        gateway: 2001:db8:1::1
+  - type: physical
+    name: eth1
+`))
+	})
+
+	It("should not render an IPv6 subnet for IPv4-only machines", func() {
+		scope := newPerNICScope(nil)
+		scope.EffectiveNetworkConfig = &infrav1.NetworkConfig{Address: "172.16.3.40", Gateway: "172.16.0.1"}
+
+		Expect(buildNetworkDataStatic(scope)).ToNot(ContainSubstring("static6"))
+	})
+})
+
+var _ = Describe("resolveNetworkConfig dual-stack", func() {
+	It("should allocate one address per family from separate pools", func() {
+		scope := newPerNICScope(newDualStackVMNetworkConfig("production-pool", "production-v6-pool"),
+			newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"),
+			newPerNICPool("production-v6-pool", "", "2001:db8:1::/64", "2001:db8:1::40", "2001:db8:1::49"),
+		)
+		r := &HarvesterMachineReconciler{}
+
+		Expect(r.resolveNetworkConfig(scope)).To(Succeed())
+
+		status := scope.HarvesterMachine.Status
+		Expect(status.AllocatedIPAddress).To(HavePrefix("172.16.3."))
+		Expect(status.AllocatedPoolRef).To(Equal("production-pool"))
+		// The IPv4 pool listed among the IPv6 pools is skipped
+		Expect(status.AllocatedIPv6Address).To(HavePrefix("2001:db8:1::4"))
+		Expect(status.AllocatedIPv6PoolRef).To(Equal("production-v6-pool"))
+
+		Expect(scope.EffectiveNetworkConfig.IPv6Address).To(Equal(status.AllocatedIPv6Address))
+		Expect(scope.EffectiveNetworkConfig.IPv6PrefixLength).To(Equal(int32(64)))
+		Expect(scope.EffectiveNetworkConfig.IPv6Gateway).To(Equal("2001:db8:1::1"))
+		Expect(allocatedVMIPs(&status)).To(HaveLen(2))
+
+		// Idempotent: a second pass keeps the same addresses
+		Expect(r.resolveNetworkConfig(scope)).To(Succeed())
+		Expect(scope.HarvesterMachine.Status.AllocatedIPv6Address).To(Equal(status.AllocatedIPv6Address))
+	})
+
+	It("should fail when no IPv6 pool is configured", func() {
+		scope := newPerNICScope(newDualStackVMNetworkConfig("production-pool"),
+			newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"),
+		)
+		r := &HarvesterMachineReconciler{}
+
+		err := r.resolveNetworkConfig(scope)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("no IPv6 IP pool"))
+	})
+
+	It("should release the IPv6 address with the IPv4 one", func() {
+		ipv4Pool := newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49")
+		ipv4Pool.Status.Allocated["172.16.3.40"] = "test-ns/worker-0"
+		ipv6Pool := newPerNICPool("production-v6-pool", "", "2001:db8:1::/64", "2001:db8:1::40", "2001:db8:1::49")
+		ipv6Pool.Status.Allocated["2001:db8:1::40"] = "test-ns/worker-0"
+
+		scope := newPerNICScope(newDualStackVMNetworkConfig("production-v6-pool"), ipv4Pool, ipv6Pool)
+		scope.HarvesterMachine.Status.AllocatedIPAddress = "172.16.3.40"
+		scope.HarvesterMachine.Status.AllocatedPoolRef = "production-pool"
+		scope.HarvesterMachine.Status.AllocatedIPv6Address = "2001:db8:1::40"
+		scope.HarvesterMachine.Status.AllocatedIPv6PoolRef = "production-v6-pool"
+		r := &HarvesterMachineReconciler{}
+
+		r.releaseVMIP(scope)
+
+		for _, name := range []string{"production-pool", "production-v6-pool"} {
+			pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pool.Status.Allocated).To(BeEmpty())
+		}
+	})
+})
+
+var _ = Describe("dual-stack control plane load balancer", func() {
+	newLBScope := func(families ...corev1.IPFamily) *ClusterScope {
+		return &ClusterScope{
+			Ctx:    context.TODO(),
+			Logger: log.FromContext(context.TODO()),
+			HarvesterCluster: &infrav1.HarvesterCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"},
+				Spec: infrav1.HarvesterClusterSpec{
+					TargetNamespace:    "default",
+					LoadBalancerConfig: infrav1.LoadBalancerConfig{IPFamilies: families},
+				},
+			},
+			HarvesterClient: hvfake.NewSimpleClientset(),
+		}
+	}
+
+	It("should default the placeholder service to single-stack IPv4", func() {
+		scope := newLBScope()
+
+		Expect(createPlaceholderSVC("test-lb", scope, dhcpLbIP)).To(Succeed())
+
+		svc, err := scope.HarvesterClient.CoreV1().Services("default").Get(context.TODO(), "test-lb", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(svc.Spec.IPFamilies).To(Equal([]corev1.IPFamily{corev1.IPv4Protocol}))
+		Expect(*svc.Spec.IPFamilyPolicy).To(Equal(corev1.IPFamilyPolicySingleStack))
+	})
+
+	It("should require dual-stack when both families are configured", func() {
+		scope := newLBScope(corev1.IPv6Protocol, corev1.IPv4Protocol)
+
+		Expect(createPlaceholderSVC("test-lb", scope, dhcpLbIPv6)).To(Succeed())
+
+		svc, err := scope.HarvesterClient.CoreV1().Services("default").Get(context.TODO(), "test-lb", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(svc.Spec.IPFamilies).To(Equal([]corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol}))
+		Expect(*svc.Spec.IPFamilyPolicy).To(Equal(corev1.IPFamilyPolicyRequireDualStack))
+		Expect(svc.Spec.LoadBalancerIP).To(Equal("::"))
+	})
+
+	It("should refuse a dual-stack load balancer from an IP pool", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Finalizers = []string{infrav1.ClusterFinalizer}
+		hvCluster.Spec.LoadBalancerConfig.IPFamilies = []corev1.IPFamily{corev1.IPv4Protocol, corev1.IPv6Protocol}
+
+		r, scope := newCapacityScope(hvCluster)
+
+		_, err := r.ReconcileNormal(scope)
+		Expect(err).To(MatchError(ContainSubstring("ipFamilies")))
+		Expect(conditions.Get(hvCluster, infrav1.InfrastructureReadyCondition).Reason).To(
+			Equal(infrav1.InfrastructureProvisioningFailedReason))
+
+		services, err := scope.HarvesterClient.CoreV1().Services("default").List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(services.Items).To(BeEmpty())
+	})
+
+	It("should pick the ingress IP of the primary family for the endpoint", func() {
+		ingress := []corev1.LoadBalancerIngress{{IP: "172.16.3.10"}, {IP: "2001:db8:1::10"}}
+
+		Expect(ingressIPForFamily(ingress, corev1.IPv6Protocol)).To(Equal("2001:db8:1::10"))
+		Expect(ingressIPForFamily(ingress, corev1.IPv4Protocol)).To(Equal("172.16.3.10"))
+		Expect(ingressIPForFamily(ingress[:1], corev1.IPv6Protocol)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,97 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/utils/ptr"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// =============================================================================
+// Tests for the guest devices (watchdog, virtio-rng, consoles and tablet)
+// =============================================================================
+
+func newTabletDomain() *kubevirtv1.DomainSpec {
+	return &kubevirtv1.DomainSpec{
+		Devices: kubevirtv1.Devices{
+			Inputs: []kubevirtv1.Input{{Bus: "usb", Type: "tablet", Name: "tablet"}},
+		},
+	}
+}
+
+var _ = Describe("applyGuestDevices", func() {
+	It("should keep the tablet and default consoles without devices", func() {
+		domain := newTabletDomain()
+
+		applyGuestDevices(&infrav1.HarvesterMachine{}, domain)
+
+		Expect(domain.Devices.Inputs).To(HaveLen(1))
+		Expect(domain.Devices.Watchdog).To(BeNil())
+		Expect(domain.Devices.Rng).To(BeNil())
+		Expect(domain.Devices.AutoattachSerialConsole).To(BeNil())
+		Expect(domain.Devices.AutoattachGraphicsDevice).To(BeNil())
+	})
+
+	It("should attach an i6300esb watchdog resetting the VM by default", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{
+			Devices: &infrav1.GuestDevices{Watchdog: &infrav1.WatchdogConfig{}},
+		}}
+		domain := newTabletDomain()
+
+		applyGuestDevices(machine, domain)
+
+		Expect(domain.Devices.Watchdog.Name).To(Equal("watchdog"))
+		Expect(domain.Devices.Watchdog.I6300ESB.Action).To(Equal(kubevirtv1.WatchdogActionReset))
+	})
+
+	It("should attach the watchdog with the requested action and a virtio-rng device", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{
+			Devices: &infrav1.GuestDevices{
+				Watchdog: &infrav1.WatchdogConfig{Action: "poweroff"},
+				RNG:      true,
+			},
+		}}
+		domain := newTabletDomain()
+
+		applyGuestDevices(machine, domain)
+
+		Expect(domain.Devices.Watchdog.I6300ESB.Action).To(Equal(kubevirtv1.WatchdogActionPoweroff))
+		Expect(domain.Devices.Rng).ToNot(BeNil())
+	})
+
+	It("should set the consoles and remove the tablet of a headless VM", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{
+			Devices: &infrav1.GuestDevices{
+				AutoattachSerialConsole:  ptr.To(true),
+				AutoattachGraphicsDevice: ptr.To(false),
+				Tablet:                   ptr.To(false),
+			},
+		}}
+		domain := newTabletDomain()
+
+		applyGuestDevices(machine, domain)
+
+		Expect(domain.Devices.AutoattachSerialConsole).To(HaveValue(BeTrue()))
+		Expect(domain.Devices.AutoattachGraphicsDevice).To(HaveValue(BeFalse()))
+		Expect(domain.Devices.Inputs).To(BeEmpty())
+	})
+})
@@ -21,6 +21,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"maps"
 	"net"
 	"time"
 
@@ -29,6 +30,7 @@ import (
 	"github.com/go-logr/logr"
 	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -48,6 +50,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/dynamic"
 	kubeclient "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -83,6 +86,7 @@ const (
 	requeueTimeMedium            = 5 * time.Minute
 	requeueTimeLong              = 3 * time.Minute
 	dhcpLbIP                     = "0.0.0.0"
+	dhcpLbIPv6                   = "::"
 	failureThreshold             = 3
 	cloudProviderTargetNamespace = "kube-system"
 )
@@ -102,6 +106,9 @@ type ClusterScope struct {
 	Ctx              context.Context
 	HarvesterClient  lbclient.Interface
 	ReconcileClient  client.Client
+	// HarvesterDynamicClient handles the Harvester objects without typed
+	// client, such as the backup schedules.
+	HarvesterDynamicClient dynamic.Interface
 }
 
 //+kubebuilder:rbac:groups=provisioning.cattle.io,resources=clusters,verbs=get;list;watch
@@ -110,6 +117,8 @@ type ClusterScope struct {
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvesterclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvesterclusters/finalizers,verbs=update
 //+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status;machinesets;machines;machines/status;machinepools;machinepools/status,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvestermachines;harvestermachinepools,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 //+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;update;patch;delete
 
@@ -188,13 +197,21 @@ func (r *HarvesterClusterReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		return ctrl.Result{RequeueAfter: requeueTimeLong}, err
 	}
 
+	hvDynamicClient, err := dynamic.NewForConfig(hvRESTConfig)
+	if err != nil {
+		logger.Error(err, "unable to create dynamic client from restConfig")
+
+		return ctrl.Result{RequeueAfter: requeueTimeLong}, err
+	}
+
 	scope := &ClusterScope{
-		Cluster:          clusterOwner,
-		HarvesterCluster: &cluster,
-		Logger:           logger,
-		Ctx:              ctx,
-		HarvesterClient:  hvClient,
-		ReconcileClient:  r.Client,
+		Cluster:                clusterOwner,
+		HarvesterCluster:       &cluster,
+		Logger:                 logger,
+		Ctx:                    ctx,
+		HarvesterClient:        hvClient,
+		ReconcileClient:        r.Client,
+		HarvesterDynamicClient: hvDynamicClient,
 	}
 
 	// Handling DeletionTimestamp to decide if it is a Deletion or a Normal reconcile
@@ -294,6 +311,29 @@ func (r *HarvesterClusterReconciler) ReconcileNormal(scope *ClusterScope) (res c
 		return ctrl.Result{}, nil
 	}
 
+	// The webhooks are optional, so the controller also refuses a dual-stack load
+	// balancer from an IP pool, which would only get an address of the primary family.
+	if scope.HarvesterCluster.Spec.LoadBalancerConfig.IPAMType == infrav1.POOL &&
+		len(scope.HarvesterCluster.Spec.LoadBalancerConfig.IPFamilies) > 1 {
+		err = errors.New("spec.loadBalancerConfig.ipFamilies cannot list two families with ipamType pool")
+
+		conditions.Set(scope.HarvesterCluster, v1.Condition{
+			Type:    infrav1.InfrastructureReadyCondition,
+			Status:  v1.ConditionFalse,
+			Reason:  infrav1.InfrastructureProvisioningFailedReason,
+			Message: err.Error(),
+		})
+
+		return ctrl.Result{}, err
+	}
+
+	// Conditions lost with the status, e.g. on clusterctl move, are restored
+	// from the labels of the pools so that they are still deleted with the cluster.
+	err = restoreCreatedIPPoolConditions(scope)
+	if err != nil {
+		logger.Info("Warning: failed to restore the ownership of the IP pools", "error", err)
+	}
+
 	// Reconcile VM IP Pool if VMNetworkConfig is set
 	err = r.reconcileVMIPPool(scope)
 	if err != nil {
@@ -358,6 +398,13 @@ func (r *HarvesterClusterReconciler) ReconcileNormal(scope *ClusterScope) (res c
 		})
 	}
 
+	// Audit the IP pool allocations and check their capacity (best-effort, does not block provisioning)
+	nextIPPoolAudit := r.reconcileIPPoolAudit(scope)
+	nextIPPoolCapacityCheck := r.reconcileIPPoolCapacity(scope)
+
+	// Synchronize the backup schedules of the VMs (best-effort, does not block provisioning)
+	nextBackupSync := r.reconcileBackupSchedules(scope)
+
 	// Initializing return values
 	res = ctrl.Result{}
 
@@ -387,6 +434,9 @@ func (r *HarvesterClusterReconciler) ReconcileNormal(scope *ClusterScope) (res c
 			}
 
 			lbIP := dhcpLbIP
+			if loadBalancerIPFamilies(scope.HarvesterCluster)[0] == apiv1.IPv6Protocol {
+				lbIP = dhcpLbIPv6
+			}
 
 			if scope.HarvesterCluster.Spec.LoadBalancerConfig.IPAMType == infrav1.POOL {
 				lbIP, err = getIPFromIPPool(scope, lbNamespacedName)
@@ -416,7 +466,9 @@ func (r *HarvesterClusterReconciler) ReconcileNormal(scope *ClusterScope) (res c
 		}
 
 		// Requeue if the placeholder LoadBalancer IP is empty
-		if len(existingPlaceholderLB.Status.LoadBalancer.Ingress) == 0 || existingPlaceholderLB.Status.LoadBalancer.Ingress[0].IP == "" {
+		endpointIP := ingressIPForFamily(existingPlaceholderLB.Status.LoadBalancer.Ingress,
+			loadBalancerIPFamilies(scope.HarvesterCluster)[0])
+		if endpointIP == "" {
 			logger.Info("placeholder LoadBalancer IP is empty, waiting for IP to be set ...")
 
 			if scope.HarvesterCluster.Spec.LoadBalancerConfig.IPAMType == infrav1.POOL {
@@ -445,7 +497,7 @@ func (r *HarvesterClusterReconciler) ReconcileNormal(scope *ClusterScope) (res c
 
 		// res = ctrl.Result{RequeueAfter: 5 * time.Minute}
 		scope.HarvesterCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
-			Host: existingPlaceholderLB.Status.LoadBalancer.Ingress[0].IP,
+			Host: endpointIP,
 			Port: apiServerLBPort,
 		}
 		scope.HarvesterCluster.Status.Ready = true
@@ -540,10 +592,27 @@ func (r *HarvesterClusterReconciler) ReconcileNormal(scope *ClusterScope) (res c
 		r.reconcileFleetIntegration(scope)
 	}
 
+	// Both are zero when the cluster uses no IP pool.
+	if res.IsZero() {
+		res.RequeueAfter = min(nextIPPoolAudit, nextIPPoolCapacityCheck)
+	}
+
+	// Zero without backup policy.
+	if nextBackupSync > 0 && (res.RequeueAfter == 0 || nextBackupSync < res.RequeueAfter) {
+		res.RequeueAfter = nextBackupSync
+	}
+
 	return res, err
 }
 
 func createPlaceholderSVC(lbName string, scope *ClusterScope, lbIP string) error {
+	families := loadBalancerIPFamilies(scope.HarvesterCluster)
+
+	familyPolicy := apiv1.IPFamilyPolicySingleStack
+	if len(families) > 1 {
+		familyPolicy = apiv1.IPFamilyPolicyRequireDualStack
+	}
+
 	placeholderSVC := &apiv1.Service{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      lbName,
@@ -562,10 +631,9 @@ func createPlaceholderSVC(lbName string, scope *ClusterScope, lbIP string) error
 					TargetPort: intstr.FromInt(apiServerBackendPort),
 				},
 			},
-			Type: apiv1.ServiceTypeLoadBalancer,
-			IPFamilies: []apiv1.IPFamily{
-				apiv1.IPv4Protocol,
-			},
+			Type:           apiv1.ServiceTypeLoadBalancer,
+			IPFamilies:     families,
+			IPFamilyPolicy: &familyPolicy,
 			LoadBalancerIP: lbIP,
 		},
 	}
@@ -581,6 +649,30 @@ func createPlaceholderSVC(lbName string, scope *ClusterScope, lbIP string) error
 	return nil
 }
 
+// loadBalancerIPFamilies returns the IP families of the control plane load
+// balancer, primary family first. It defaults to IPv4.
+func loadBalancerIPFamilies(cluster *infrav1.HarvesterCluster) []apiv1.IPFamily {
+	if len(cluster.Spec.LoadBalancerConfig.IPFamilies) == 0 {
+		return []apiv1.IPFamily{apiv1.IPv4Protocol}
+	}
+
+	return cluster.Spec.LoadBalancerConfig.IPFamilies
+}
+
+// ingressIPForFamily returns the load balancer ingress IP of the given family,
+// so that a dual-stack load balancer publishes the address of its primary
+// family as the control plane endpoint. It returns an empty string while no
+// such IP is assigned.
+func ingressIPForFamily(ingress []apiv1.LoadBalancerIngress, family apiv1.IPFamily) string {
+	for _, entry := range ingress {
+		if locutil.IPFamilyOf(entry.IP) == family {
+			return entry.IP
+		}
+	}
+
+	return ""
+}
+
 func checkValidIpPoolDefinition(ipPool infrav1.IpPool) bool {
 	if ipPool == (infrav1.IpPool{}) {
 		return false
@@ -635,7 +727,20 @@ func getIPFromIPPool(scope *ClusterScope, lbNamespacedName string) (string, erro
 	return allocateIPFromPool(ipPool, lbNamespacedName, scope)
 }
 
+// allocateIPFromPool allocates an IP for the load balancer lbNamespacedName from
+// refPool, retrying when the pool is written concurrently.
 func allocateIPFromPool(refPool *lbv1beta1.IPPool, lbNamespacedName string, scope *ClusterScope) (string, error) {
+	ip, _, err := allocateFromIPPool(scope.Ctx, scope.HarvesterClient, refPool.Name, lbNamespacedName,
+		func(pool *lbv1beta1.IPPool) (string, error) {
+			return allocateLoadBalancerIP(pool, lbNamespacedName)
+		})
+
+	return ip, err
+}
+
+// allocateLoadBalancerIP reserves an IP for the load balancer lbNamespacedName
+// in refPool, preferring the IP it was allocated before.
+func allocateLoadBalancerIP(refPool *lbv1beta1.IPPool, lbNamespacedName string) (string, error) {
 	rangeSlice := make([]allocator.Range, 0)
 
 	var total int64
@@ -680,13 +785,81 @@ func allocateIPFromPool(refPool *lbv1beta1.IPPool, lbNamespacedName string, scop
 		}
 	}
 
-	// Update Pool in Harvester with the new allocated IP
-	_, err = scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Update(scope.Ctx, refPool, v1.UpdateOptions{})
+	return ipObj.Address.IP.String(), nil
+}
+
+// releaseLoadBalancerIP releases the address of the load balancer of the cluster
+// from the referenced LB IP pool. The address is kept in the allocation history
+// of the pool, so that a cluster recreated with the same name gets it back,
+// unless the cluster carries ForgetLoadBalancerIPAnnotation.
+func releaseLoadBalancerIP(scope *ClusterScope) error {
+	lbCfg := scope.HarvesterCluster.Spec.LoadBalancerConfig
+	if lbCfg.IPAMType != infrav1.POOL || lbCfg.IpPoolRef == "" {
+		return nil
+	}
+
+	lbName := locutil.GenerateRFC1035Name([]string{scope.HarvesterCluster.Namespace, scope.HarvesterCluster.Name, "lb"})
+	lbNamespacedName := scope.HarvesterCluster.Spec.TargetNamespace + "/" + lbName
+	forget := scope.HarvesterCluster.Annotations[infrav1.ForgetLoadBalancerIPAnnotation] == "true"
+
+	var released []string
+
+	_, updated, err := updateIPPool(scope.Ctx, scope.HarvesterClient, lbCfg.IpPoolRef, func(pool *lbv1beta1.IPPool) error {
+		released = nil
+
+		for ip, owner := range pool.Status.Allocated {
+			if owner == lbNamespacedName {
+				released = append(released, ip)
+			}
+		}
+
+		inHistory := false
+
+		for _, owner := range pool.Status.AllocatedHistory {
+			if owner == lbNamespacedName {
+				inHistory = true
+			}
+		}
+
+		if len(released) == 0 && (!forget || !inHistory) {
+			return errIPPoolUnchanged
+		}
+
+		err := locutil.NewStore(pool).ReleaseByID(lbNamespacedName, "")
+		if err != nil {
+			return err
+		}
+
+		if forget {
+			maps.DeleteFunc(pool.Status.AllocatedHistory, func(_ string, owner string) bool {
+				return owner == lbNamespacedName
+			})
+		}
+
+		return nil
+	})
 	if err != nil {
-		return "", err
+		if apierrors.IsNotFound(err) {
+			scope.Logger.Info("Load Balancer IP Pool not found, skipping IP release ...", "pool", lbCfg.IpPoolRef)
+
+			return nil
+		}
+
+		return errors.Wrapf(err, "failed to release the Load Balancer IP from IP Pool %s", lbCfg.IpPoolRef)
 	}
 
-	return ipObj.Address.IP.String(), nil
+	if !updated {
+		scope.Logger.V(1).Info("Load Balancer IP already released from pool", "pool", lbCfg.IpPoolRef)
+
+		return nil
+	}
+
+	for _, ip := range released {
+		caphvmetrics.IPPoolReleasesTotal.Inc()
+		scope.Logger.Info("Released Load Balancer IP back to pool", "ip", ip, "pool", lbCfg.IpPoolRef, "forget", forget)
+	}
+
+	return nil
 }
 
 func getLoadBalancerIP(ctx context.Context, harvesterCluster *infrav1.HarvesterCluster, hvClient lbclient.Interface) (string, error) {
@@ -714,12 +887,22 @@ func (r *HarvesterClusterReconciler) ReconcileDelete(scope *ClusterScope) (ctrl.
 
 		clusterName := scope.HarvesterCluster.Namespace + "/" + scope.HarvesterCluster.Name
 		caphvmetrics.ClusterReady.DeleteLabelValues(clusterName)
+		caphvmetrics.IPPoolLeakedAllocations.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+		caphvmetrics.IPPoolClusterAllocatedAddresses.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+		trackReportedIPPools(clusterName, nil)
 	}()
 
 	logger := log.FromContext(scope.Ctx)
 	logger.Info("Deleting Harvester Cluster ...", "cluster-name", scope.HarvesterCluster.Name, "cluster-namespace", scope.HarvesterCluster.Namespace)
 
-	err := scope.HarvesterClient.LoadbalancerV1beta1().LoadBalancers(scope.HarvesterCluster.Spec.TargetNamespace).Delete(
+	err := restoreCreatedIPPoolConditions(scope)
+	if err != nil {
+		logger.Error(err, "unable to restore the ownership of the IP pools")
+
+		return ctrl.Result{RequeueAfter: requeueTimeLong}, err
+	}
+
+	err = scope.HarvesterClient.LoadbalancerV1beta1().LoadBalancers(scope.HarvesterCluster.Spec.TargetNamespace).Delete(
 		scope.Ctx,
 		locutil.GenerateRFC1035Name([]string{scope.HarvesterCluster.Namespace, scope.HarvesterCluster.Name, "lb"}),
 		v1.DeleteOptions{})
@@ -735,6 +918,27 @@ func (r *HarvesterClusterReconciler) ReconcileDelete(scope *ClusterScope) (ctrl.
 
 	logger.V(5).Info("Load Balancer deleted successfully")
 
+	// The backup schedules would keep failing on the deleted VMs
+	if conditions.Has(scope.HarvesterCluster, infrav1.BackupSchedulesReadyCondition) {
+		err = deleteBackupSchedules(scope, nil)
+		if err != nil {
+			logger.Error(err, "unable to delete the backup schedules in Harvester")
+
+			return ctrl.Result{RequeueAfter: requeueTimeLong}, err
+		}
+	}
+
+	// A controller-created LB pool is deleted below; a referenced one is shared
+	// and keeps the reservation of the load balancer unless it is released here.
+	if !conditions.IsTrue(scope.HarvesterCluster, infrav1.CustomIPPoolCreatedCondition) {
+		err = releaseLoadBalancerIP(scope)
+		if err != nil {
+			logger.Error(err, "unable to release the Load Balancer IP")
+
+			return ctrl.Result{RequeueAfter: requeueTimeLong}, err
+		}
+	}
+
 	if conditions.IsTrue(scope.HarvesterCluster, infrav1.CustomIPPoolCreatedCondition) {
 		err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Delete(
 			scope.Ctx,
@@ -751,6 +955,7 @@ func (r *HarvesterClusterReconciler) ReconcileDelete(scope *ClusterScope) (ctrl.
 			logger.Info("no IP Pool to be deleted, skipping ...")
 		}
 
+		deleteIPPoolMetrics(scope.HarvesterCluster.Spec.LoadBalancerConfig.IpPoolRef)
 		logger.Info("Custom IP Pool deleted")
 		conditions.Delete(scope.HarvesterCluster, infrav1.CustomIPPoolCreatedCondition)
 	}
@@ -771,10 +976,9 @@ func (r *HarvesterClusterReconciler) ReconcileDelete(scope *ClusterScope) (ctrl.
 
 	logger.V(5).Info("Load Balancer Service deleted successfully") //nolint:mnd
 
-	err = scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Delete(
-		scope.Ctx,
-		locutil.GenerateRFC1035Name([]string{scope.HarvesterCluster.Namespace, scope.HarvesterCluster.Name, "ippool"}),
-		v1.DeleteOptions{})
+	generatedPoolName := locutil.GenerateRFC1035Name([]string{scope.HarvesterCluster.Namespace, scope.HarvesterCluster.Name, "ippool"})
+
+	err = scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Delete(scope.Ctx, generatedPoolName, v1.DeleteOptions{})
 	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			logger.Error(err, "unable to delete generated IP Pool in Harvester")
@@ -785,6 +989,7 @@ func (r *HarvesterClusterReconciler) ReconcileDelete(scope *ClusterScope) (ctrl.
 		logger.Info("no IP Pool to be deleted, skipping ...")
 	}
 
+	deleteIPPoolMetrics(generatedPoolName)
 	logger.V(5).Info("IP Pool deleted successfully") //nolint:mnd
 
 	// Delete VM IP pool only if it was created by the controller (not pre-existing).
@@ -806,6 +1011,7 @@ func (r *HarvesterClusterReconciler) ReconcileDelete(scope *ClusterScope) (ctrl.
 			logger.Info("VM IP Pool deleted (was created by controller)", "pool", vmPoolName)
 		}
 
+		deleteIPPoolMetrics(vmPoolName)
 		conditions.Delete(scope.HarvesterCluster, infrav1.VMIPPoolCreatedByControllerCondition)
 	} else if scope.HarvesterCluster.Spec.VMNetworkConfig != nil && scope.HarvesterCluster.Spec.VMNetworkConfig.IPPoolRef != "" {
 		logger.Info("Skipping VM IP Pool deletion (pre-existing pool)", "pool", scope.HarvesterCluster.Spec.VMNetworkConfig.IPPoolRef)
@@ -1182,12 +1388,17 @@ func (r *HarvesterClusterReconciler) reconcileVMIPPool(scope *ClusterScope) erro
 	}
 
 	// Create the pool
+	poolLabels := clusterOwnershipLabels(scope.HarvesterCluster)
+	poolLabels[ipPoolRoleLabel] = ipPoolRoleVM
+
 	ipPoolToCreate := &lbv1beta1.IPPool{
 		ObjectMeta: v1.ObjectMeta{
-			Name: poolName,
+			Name:   poolName,
+			Labels: poolLabels,
 		},
 		Spec: lbv1beta1.IPPoolSpec{
-			Description: fmt.Sprintf("VM IP Pool for cluster %s/%s", scope.HarvesterCluster.Namespace, scope.HarvesterCluster.Name),
+			Description: fmt.Sprintf("%s %s/%s", vmIPPoolDescriptionPrefix,
+				scope.HarvesterCluster.Namespace, scope.HarvesterCluster.Name),
 			Ranges: []lbv1beta1.Range{
 				{
 					Subnet:     vmNetCfg.IPPool.Subnet,
@@ -1369,6 +1580,7 @@ func createLoadBalancerIfNotExists(scope *ClusterScope) (err error) {
 		ObjectMeta: v1.ObjectMeta{
 			Name:      locutil.GenerateRFC1035Name([]string{scope.HarvesterCluster.Namespace, scope.HarvesterCluster.Name, "lb"}),
 			Namespace: scope.HarvesterCluster.Spec.TargetNamespace,
+			Labels:    clusterOwnershipLabels(scope.HarvesterCluster),
 		},
 		Spec: lbv1beta1.LoadBalancerSpec{
 			Description:  "Load Balancer for cluster " + scope.HarvesterCluster.Name,
@@ -1429,10 +1641,14 @@ func createIPPoolIfNotExists(ctx context.Context, cluster *infrav1.HarvesterClus
 	machineNetwork string,
 	targetVMNamespace string,
 ) (*lbv1beta1.IPPool, error) {
+	poolLabels := clusterOwnershipLabels(cluster)
+	poolLabels[ipPoolRoleLabel] = ipPoolRoleLoadBalancer
+
 	ipPoolToCreate := lbv1beta1.IPPool{
 		ObjectMeta: v1.ObjectMeta{
 			Name:      locutil.GenerateRFC1035Name([]string{cluster.Namespace, cluster.Name, "ippool"}),
 			Namespace: targetVMNamespace,
+			Labels:    poolLabels,
 		},
 		Spec: lbv1beta1.IPPoolSpec{
 			Description: cpIPPoolDescriptionPrefix + " " + cluster.Name,
@@ -24,6 +24,7 @@ import (
 	. "github.com/onsi/gomega"
 
 	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
 	kubevirtv1 "kubevirt.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -35,6 +36,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	k8stesting "k8s.io/client-go/testing"
 	"k8s.io/client-go/tools/clientcmd"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
@@ -1435,6 +1437,94 @@ var _ = Describe("ReconcileDelete comprehensive", func() {
 		_, err = hvFake.CoreV1().Services(ns).Get(context.TODO(), lbName, metav1.GetOptions{})
 		Expect(err).To(HaveOccurred()) // Should be NotFound
 	})
+
+	Context("with a shared load balancer IP pool", func() {
+		lbOwner := "default/" + locutil.GenerateRFC1035Name([]string{"ns1", "shared-lb", "lb"})
+
+		newSharedLBPoolScope := func(annotations map[string]string) (*HarvesterClusterReconciler, *ClusterScope, *hvfake.Clientset) {
+			pool := newPerNICPool("shared-lb-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19")
+			pool.Status.Allocated = map[string]string{
+				"10.0.0.10": lbOwner,
+				"10.0.0.11": "default/other-cluster-lb",
+			}
+			pool.Status.AllocatedHistory = map[string]string{"10.0.0.12": "default/old-cluster-lb"}
+			pool.Status.Available = 8
+
+			scheme := runtime.NewScheme()
+			_ = corev1.AddToScheme(scheme)
+			_ = infrav1.AddToScheme(scheme)
+			_ = clusterv1.AddToScheme(scheme)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+			hvCluster := &infrav1.HarvesterCluster{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "shared-lb", Namespace: "ns1", Annotations: annotations,
+					Finalizers: []string{infrav1.ClusterFinalizer},
+				},
+				Spec: infrav1.HarvesterClusterSpec{
+					TargetNamespace:    "default",
+					LoadBalancerConfig: infrav1.LoadBalancerConfig{IPAMType: infrav1.POOL, IpPoolRef: "shared-lb-pool"},
+				},
+			}
+
+			hvClient := hvfake.NewSimpleClientset(pool)
+
+			return &HarvesterClusterReconciler{Client: fakeClient, Scheme: scheme}, &ClusterScope{
+				Ctx: context.TODO(), Logger: log.FromContext(context.TODO()),
+				Cluster:          &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "shared-lb", Namespace: "ns1"}},
+				HarvesterCluster: hvCluster, HarvesterClient: hvClient, ReconcileClient: fakeClient,
+			}, hvClient
+		}
+
+		It("should release the load balancer IP and keep it in the history", func() {
+			r, scope, hvClient := newSharedLBPoolScope(nil)
+
+			result, err := r.ReconcileDelete(scope)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(scope.HarvesterCluster.Finalizers).To(BeEmpty())
+
+			pool := getTestIPPool(hvClient, "shared-lb-pool")
+			Expect(pool.Status.Allocated).To(Equal(map[string]string{"10.0.0.11": "default/other-cluster-lb"}))
+			Expect(pool.Status.AllocatedHistory).To(HaveKeyWithValue("10.0.0.10", lbOwner))
+			Expect(pool.Status.AllocatedHistory).To(HaveKeyWithValue("10.0.0.12", "default/old-cluster-lb"))
+			Expect(pool.Status.Available).To(Equal(int64(9)))
+		})
+
+		It("should drop the history entry with the forget annotation", func() {
+			r, scope, hvClient := newSharedLBPoolScope(map[string]string{infrav1.ForgetLoadBalancerIPAnnotation: "true"})
+
+			_, err := r.ReconcileDelete(scope)
+			Expect(err).ToNot(HaveOccurred())
+
+			pool := getTestIPPool(hvClient, "shared-lb-pool")
+			Expect(pool.Status.Allocated).ToNot(HaveKey("10.0.0.10"))
+			Expect(pool.Status.AllocatedHistory).To(Equal(map[string]string{"10.0.0.12": "default/old-cluster-lb"}))
+		})
+
+		It("should not block the deletion when the pool is gone", func() {
+			r, scope, _ := newSharedLBPoolScope(nil)
+			scope.HarvesterClient = hvfake.NewSimpleClientset()
+
+			result, err := r.ReconcileDelete(scope)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(scope.HarvesterCluster.Finalizers).To(BeEmpty())
+		})
+
+		It("should requeue when the pool cannot be updated", func() {
+			r, scope, hvClient := newSharedLBPoolScope(nil)
+			hvClient.PrependReactor("update", "ippools",
+				func(_ k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, errors.New("connection refused")
+				})
+
+			result, err := r.ReconcileDelete(scope)
+			Expect(err).To(MatchError(ContainSubstring("connection refused")))
+			Expect(result.RequeueAfter).To(Equal(requeueTimeLong))
+			Expect(scope.HarvesterCluster.Finalizers).ToNot(BeEmpty())
+		})
+	})
 })
 
 // =============================================================================
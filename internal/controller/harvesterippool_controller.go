@@ -0,0 +1,528 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+	harvclient "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+const (
+	// harvesterIPPoolKind is the pool kind of the IPAddressClaims served by the IPAddressClaimReconciler.
+	harvesterIPPoolKind = "HarvesterIPPool"
+	// claimOwnerSegment marks the Harvester IPPool allocations of IPAddressClaims,
+	// owned by "<namespace>/claim/<name>".
+	claimOwnerSegment = "claim"
+)
+
+// IPAddressClaimReconciler is the CAPI IPAM provider of HarvesterIPPools: it
+// serves the IPAddressClaims referencing a HarvesterIPPool with IPAddresses
+// allocated from the Harvester IPPools of the pool.
+type IPAddressClaimReconciler struct {
+	client.Client
+
+	Scheme *runtime.Scheme
+}
+
+// ClaimScope stores context data for the IPAddressClaim reconciler.
+type ClaimScope struct {
+	Ctx              context.Context
+	Claim            *ipamv1.IPAddressClaim
+	HarvesterIPPool  *infrav1.HarvesterIPPool
+	HarvesterClient  harvclient.Interface
+	ReconcilerClient client.Client
+	Logger           *logr.Logger
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvesterippools,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims/finalizers,verbs=update
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile reconciles the IPAddressClaims referencing a HarvesterIPPool.
+func (r *IPAddressClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, rerr error) {
+	logger := log.FromContext(ctx)
+	ctx = ctrl.LoggerInto(ctx, logger)
+
+	claim := &ipamv1.IPAddressClaim{}
+
+	err := r.Get(ctx, req.NamespacedName, claim)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("ipaddressclaim not found")
+
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Error happened when getting ipaddressclaim")
+
+		return ctrl.Result{}, err
+	}
+
+	if !isHarvesterIPPoolRef(claim.Spec.PoolRef) {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(claim, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Always attempt to Patch the IPAddressClaim object and status after each reconciliation.
+	defer func() {
+		err := patchHelper.Patch(ctx, claim)
+		if err != nil {
+			logger.Error(err, "failed to patch IPAddressClaim")
+
+			if rerr == nil {
+				rerr = err
+			}
+		}
+	}()
+
+	isPaused, err := r.isClaimPaused(ctx, claim)
+	if err != nil || isPaused {
+		if isPaused {
+			logger.Info("IPAddressClaim or its Cluster is paused, skipping")
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	claimScope := &ClaimScope{
+		Ctx:              ctx,
+		Claim:            claim,
+		ReconcilerClient: r.Client,
+		Logger:           &logger,
+	}
+
+	hvPool := &infrav1.HarvesterIPPool{}
+
+	err = r.Get(ctx, types.NamespacedName{Namespace: claim.Namespace, Name: claim.Spec.PoolRef.Name}, hvPool)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		if !claim.DeletionTimestamp.IsZero() {
+			logger.Info("Warning: HarvesterIPPool not found, the address of the claim cannot be released",
+				"pool", claim.Spec.PoolRef.Name)
+
+			return r.ReconcileDelete(claimScope) //nolint:contextcheck
+		}
+
+		conditions.Set(claim, metav1.Condition{
+			Type:    ipamv1.IPAddressClaimReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  ipamv1.IPAddressClaimReadyPoolNotReadyReason,
+			Message: fmt.Sprintf("HarvesterIPPool %s not found", claim.Spec.PoolRef.Name),
+		})
+
+		return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+	}
+
+	claimScope.HarvesterIPPool = hvPool
+
+	hvSecret := &corev1.Secret{}
+
+	err = r.Get(ctx, client.ObjectKey(hvPool.Spec.IdentitySecret), hvSecret)
+	if err != nil {
+		logger.Error(err, "unable to get the IdentitySecret of the HarvesterIPPool")
+
+		return ctrl.Result{}, err
+	}
+
+	hvClient, err := locutil.GetHarvesterClientFromSecret(hvSecret)
+	if err != nil {
+		logger.Error(err, "unable to create Harvester client from IdentitySecret "+hvSecret.Name)
+
+		return ctrl.Result{}, err
+	}
+
+	claimScope.HarvesterClient = hvClient
+
+	if !claim.DeletionTimestamp.IsZero() {
+		return r.ReconcileDelete(claimScope) //nolint:contextcheck
+	}
+
+	return r.ReconcileNormal(claimScope) //nolint:contextcheck
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *IPAddressClaimReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&ipamv1.IPAddressClaim{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			claim, ok := obj.(*ipamv1.IPAddressClaim)
+
+			return ok && isHarvesterIPPoolRef(claim.Spec.PoolRef)
+		}))).
+		Owns(&ipamv1.IPAddress{}).
+		Watches(
+			&infrav1.HarvesterIPPool{},
+			handler.EnqueueRequestsFromMapFunc(r.harvesterIPPoolToClaims),
+		).
+		Watches(
+			&clusterv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(r.clusterToClaims),
+			builder.WithPredicates(predicates.ClusterPausedTransitions(mgr.GetScheme(), ctrl.LoggerFrom(ctx))),
+		).
+		Complete(r)
+}
+
+// ReconcileNormal binds the claim to an IPAddress allocated from the Harvester
+// IPPools of its HarvesterIPPool.
+func (r *IPAddressClaimReconciler) ReconcileNormal(claimScope *ClaimScope) (ctrl.Result, error) {
+	claim := claimScope.Claim
+	logger := claimScope.Logger
+
+	if !controllerutil.ContainsFinalizer(claim, infrav1.IPAddressClaimFinalizer) {
+		controllerutil.AddFinalizer(claim, infrav1.IPAddressClaimFinalizer)
+
+		return ctrl.Result{}, nil
+	}
+
+	address := &ipamv1.IPAddress{}
+
+	err := claimScope.ReconcilerClient.Get(claimScope.Ctx,
+		types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}, address)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, errors.Wrapf(err, "unable to get IPAddress %s", claim.Name)
+		}
+
+		address, err = createClaimAddress(claimScope)
+		if err != nil {
+			logger.Error(err, "failed to allocate an IP address for the claim")
+
+			return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+		}
+	}
+
+	claim.Status.AddressRef.Name = address.Name
+
+	conditions.Set(claim, metav1.Condition{
+		Type:    ipamv1.IPAddressClaimReadyCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  clusterv1.ReadyReason,
+		Message: fmt.Sprintf("Allocated IP %s from HarvesterIPPool %s", address.Spec.Address, claimScope.HarvesterIPPool.Name),
+	})
+
+	return ctrl.Result{}, nil
+}
+
+// ReconcileDelete releases the address of the claim back to its Harvester
+// IPPool and deletes its IPAddress.
+func (r *IPAddressClaimReconciler) ReconcileDelete(claimScope *ClaimScope) (ctrl.Result, error) {
+	claim := claimScope.Claim
+
+	if claimScope.HarvesterIPPool != nil {
+		err := releaseClaimAddress(claimScope)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	address := &ipamv1.IPAddress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: claim.Namespace, Name: claim.Name},
+	}
+
+	err := claimScope.ReconcilerClient.Delete(claimScope.Ctx, address)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return ctrl.Result{}, errors.Wrapf(err, "unable to delete IPAddress %s", address.Name)
+	}
+
+	controllerutil.RemoveFinalizer(claim, infrav1.IPAddressClaimFinalizer)
+
+	return ctrl.Result{}, nil
+}
+
+// isClaimPaused reports whether the claim or the Cluster it belongs to is paused.
+func (r *IPAddressClaimReconciler) isClaimPaused(ctx context.Context, claim *ipamv1.IPAddressClaim) (bool, error) {
+	if claim.Spec.ClusterName == "" {
+		return annotations.HasPaused(claim), nil
+	}
+
+	cluster := &clusterv1.Cluster{}
+
+	err := r.Get(ctx, types.NamespacedName{Namespace: claim.Namespace, Name: claim.Spec.ClusterName}, cluster)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return annotations.HasPaused(claim), nil
+		}
+
+		return false, err
+	}
+
+	return annotations.IsPaused(cluster, claim), nil
+}
+
+// harvesterIPPoolToClaims maps a HarvesterIPPool to the IPAddressClaims referencing it.
+func (r *IPAddressClaimReconciler) harvesterIPPoolToClaims(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.claimRequests(ctx, obj.GetNamespace(), func(claim *ipamv1.IPAddressClaim) bool {
+		return claim.Spec.PoolRef.Name == obj.GetName()
+	})
+}
+
+// clusterToClaims maps a Cluster to the IPAddressClaims belonging to it.
+func (r *IPAddressClaimReconciler) clusterToClaims(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.claimRequests(ctx, obj.GetNamespace(), func(claim *ipamv1.IPAddressClaim) bool {
+		return claim.Spec.ClusterName == obj.GetName()
+	})
+}
+
+// claimRequests returns the requests of the HarvesterIPPool claims of namespace matching match.
+func (r *IPAddressClaimReconciler) claimRequests(ctx context.Context, namespace string,
+	match func(*ipamv1.IPAddressClaim) bool,
+) []reconcile.Request {
+	claims := &ipamv1.IPAddressClaimList{}
+
+	err := r.List(ctx, claims, client.InNamespace(namespace))
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list IPAddressClaims")
+
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(claims.Items))
+
+	for i := range claims.Items {
+		claim := &claims.Items[i]
+		if isHarvesterIPPoolRef(claim.Spec.PoolRef) && match(claim) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(claim)})
+		}
+	}
+
+	return requests
+}
+
+// isHarvesterIPPoolRef reports whether poolRef designates a HarvesterIPPool.
+func isHarvesterIPPoolRef(poolRef ipamv1.IPPoolReference) bool {
+	return poolRef.APIGroup == infrav1.GroupVersion.Group && poolRef.Kind == harvesterIPPoolKind
+}
+
+// claimOwnerID returns the owner of the Harvester IPPool allocation of a claim.
+// Claims are named after their machine, so their allocations are set apart
+// from the "<namespace>/<name>" ones of the machines and pool instances.
+func claimOwnerID(claim *ipamv1.IPAddressClaim) string {
+	return claim.Namespace + "/" + claimOwnerSegment + "/" + claim.Name
+}
+
+// createClaimAddress allocates an address for the claim from the Harvester
+// IPPools of its HarvesterIPPool and creates the IPAddress bound to the claim.
+// The failure reason is reported on the Ready condition of the claim.
+func createClaimAddress(claimScope *ClaimScope) (*ipamv1.IPAddress, error) {
+	claim := claimScope.Claim
+	hvPool := claimScope.HarvesterIPPool
+
+	ip, pool, err := allocateClaimIP(claimScope)
+	if err != nil {
+		reason := ipamv1.IPAddressClaimReadyAllocationFailedReason
+		if errors.Is(err, errHarvesterIPPoolExhausted) {
+			reason = ipamv1.IPAddressClaimReadyPoolExhaustedReason
+		}
+
+		conditions.Set(claim, metav1.Condition{
+			Type:    ipamv1.IPAddressClaimReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: err.Error(),
+		})
+
+		return nil, err
+	}
+
+	prefix, gateway, err := locutil.AddressPrefixAndGateway(pool, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	if hvPool.Spec.Prefix != nil {
+		prefix = *hvPool.Spec.Prefix
+	}
+
+	if hvPool.Spec.Gateway != "" {
+		gateway = hvPool.Spec.Gateway
+	}
+
+	address := &ipamv1.IPAddress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claim.Name,
+			Namespace: claim.Namespace,
+			Labels:    claim.Labels,
+		},
+		Spec: ipamv1.IPAddressSpec{
+			ClaimRef: ipamv1.IPAddressClaimReference{Name: claim.Name},
+			PoolRef:  claim.Spec.PoolRef,
+			Address:  ip,
+			Prefix:   &prefix,
+			Gateway:  gateway,
+		},
+	}
+
+	err = controllerutil.SetControllerReference(claim, address, claimScope.ReconcilerClient.Scheme())
+	if err != nil {
+		return nil, err
+	}
+
+	err = controllerutil.SetOwnerReference(hvPool, address, claimScope.ReconcilerClient.Scheme())
+	if err != nil {
+		return nil, err
+	}
+
+	err = claimScope.ReconcilerClient.Create(claimScope.Ctx, address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create IPAddress %s", address.Name)
+	}
+
+	claimScope.Logger.Info("Created IPAddress for claim", "ip", ip, "pool", pool.Name)
+
+	return address, nil
+}
+
+// errHarvesterIPPoolExhausted is returned when none of the Harvester IPPools of
+// a HarvesterIPPool has a free address.
+var errHarvesterIPPoolExhausted = errors.New("all the IPPools of the HarvesterIPPool are exhausted")
+
+// allocateClaimIP allocates an IP for the claim from the first Harvester IPPool
+// of its HarvesterIPPool with a free address. It returns the existing
+// allocation of the claim if one of the pools already holds it.
+func allocateClaimIP(claimScope *ClaimScope) (string, *lbv1beta1.IPPool, error) {
+	ownerID := claimOwnerID(claimScope.Claim)
+	logger := claimScope.Logger
+
+	var lastErr error
+
+	for _, poolRef := range claimScope.HarvesterIPPool.Spec.IPPoolRefs {
+		pool, err := claimScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
+			claimScope.Ctx, poolRef, metav1.GetOptions{})
+		if err != nil {
+			logger.V(1).Info("Failed to get IP pool, trying next", "pool", poolRef, "error", err)
+			lastErr = errors.Wrapf(err, "failed to get IP pool %s", poolRef)
+
+			continue
+		}
+
+		for ip, id := range pool.Status.Allocated {
+			if id == ownerID {
+				logger.Info("Found existing IP allocation in pool", "ip", ip, "pool", poolRef)
+
+				return ip, pool, nil
+			}
+		}
+
+		caphvmetrics.IPPoolAllocationsTotal.Inc()
+
+		var allocErr error
+
+		allocatedIP, pool, err := allocateFromIPPool(claimScope.Ctx, claimScope.HarvesterClient, poolRef, ownerID,
+			func(fresh *lbv1beta1.IPPool) (string, error) {
+				var ip string
+
+				ip, allocErr = locutil.AllocateVMIPFromPool(fresh, ownerID)
+
+				return ip, allocErr
+			})
+		if allocErr != nil {
+			logger.V(1).Info("Pool exhausted or allocation failed, trying next", "pool", poolRef, "error", allocErr)
+
+			continue
+		}
+
+		if err != nil {
+			caphvmetrics.IPPoolAllocationErrorsTotal.Inc()
+
+			return "", nil, errors.Wrapf(err, "failed to update IP pool %s after allocation", poolRef)
+		}
+
+		logger.Info("Allocated IP from pool", "ip", allocatedIP, "pool", poolRef, "owner", ownerID)
+
+		return allocatedIP, pool, nil
+	}
+
+	caphvmetrics.IPPoolAllocationErrorsTotal.Inc()
+
+	if lastErr != nil {
+		return "", nil, lastErr
+	}
+
+	return "", nil, errHarvesterIPPoolExhausted
+}
+
+// releaseClaimAddress releases the allocations of the claim from the Harvester
+// IPPools of its HarvesterIPPool. Pools that no longer exist are skipped.
+func releaseClaimAddress(claimScope *ClaimScope) error {
+	ownerID := claimOwnerID(claimScope.Claim)
+
+	for _, poolRef := range claimScope.HarvesterIPPool.Spec.IPPoolRefs {
+		_, released, err := updateIPPool(claimScope.Ctx, claimScope.HarvesterClient, poolRef,
+			func(pool *lbv1beta1.IPPool) error {
+				store := locutil.NewStore(pool)
+				if len(store.GetByID(ownerID, "")) == 0 {
+					return errIPPoolUnchanged
+				}
+
+				return store.ReleaseByID(ownerID, "")
+			})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return errors.Wrapf(err, "failed to release IP from pool %s", poolRef)
+		}
+
+		if !released {
+			continue
+		}
+
+		caphvmetrics.IPPoolReleasesTotal.Inc()
+		claimScope.Logger.Info("Released claim IP back to pool", "pool", poolRef, "owner", ownerID)
+	}
+
+	return nil
+}
@@ -20,12 +20,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
 	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
 	"github.com/pkg/errors"
 	kubevirtv1 "kubevirt.io/api/core/v1"
@@ -45,9 +47,11 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/utils/ptr"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
@@ -65,6 +69,8 @@ type HarvesterMachineReconciler struct {
 	client.Client
 
 	Scheme *runtime.Scheme
+	// Recorder records the events of HarvesterMachines, it may be nil.
+	Recorder events.EventRecorder
 }
 
 // Scope stores context data for the reconciler.
@@ -76,8 +82,19 @@ type Scope struct {
 	HarvesterMachine       *infrav1.HarvesterMachine
 	HarvesterClient        harvclient.Interface
 	ReconcilerClient       client.Client
+	Recorder               events.EventRecorder
 	Logger                 *logr.Logger
 	EffectiveNetworkConfig *infrav1.NetworkConfig
+	// EffectiveSubnetMask overrides the subnet mask of the vmNetworkConfig for
+	// the first network, set from the prefix of the addresses claimed from
+	// addressesFromPools.
+	EffectiveSubnetMask string
+	// ClaimOwner owns the IPAddressClaims of addressesFromPools. It defaults to
+	// the HarvesterMachine; the machine pool reconciler sets it to the pool.
+	ClaimOwner client.Object
+	// VMLabels are extra labels set on the VM, used by the machine pool
+	// reconciler to find the VMs of its instances.
+	VMLabels map[string]string
 }
 
 const (
@@ -104,6 +121,8 @@ var (
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvestermachines/finalizers,verbs=update
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvesterclusters,verbs=get;list
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=clusters;machines,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch
+//+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch
 
 // Reconcile reconciles the HarvesterMachine object.
 func (r *HarvesterMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, rerr error) {
@@ -221,6 +240,7 @@ func (r *HarvesterMachineReconciler) Reconcile(ctx context.Context, req ctrl.Req
 		HarvesterMachine: hvMachine,
 		HarvesterClient:  hvClient,
 		ReconcilerClient: r.Client,
+		Recorder:         r.Recorder,
 		Logger:           &logger,
 	}
 
@@ -240,6 +260,7 @@ func (r *HarvesterMachineReconciler) SetupWithManager(ctx context.Context, mgr c
 
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&infrav1.HarvesterMachine{}).
+		Owns(&ipamv1.IPAddressClaim{}).
 		// No pause-filtering predicates: pause/unpause transitions must reach the
 		// reconciler so the Paused condition (v1beta2 contract) is published.
 		Watches(
@@ -340,42 +361,47 @@ func (r *HarvesterMachineReconciler) ReconcileNormal(hvScope *Scope) (res reconc
 	// Resolve effective network config: pool allocation (machine-level
 	// vmNetworkConfig taking precedence over the cluster-level one) or
 	// machine-level static config
-	if effectiveVMNetworkConfig(hvScope) != nil && hvScope.HarvesterMachine.Spec.NetworkConfig == nil {
-		// Allocate IP from cluster-level VM IP pool
-		allocErr := r.allocateVMIP(hvScope)
-		if allocErr != nil {
-			logger.Error(allocErr, "failed to allocate VM IP from pool")
+	allocErr := r.resolveNetworkConfig(hvScope)
+	if errors.Is(allocErr, errAddressClaimsPending) {
+		logger.Info("Waiting for the IPAddressClaims of addressesFromPools to be bound ...")
 
-			conditions.Set(hvScope.HarvesterMachine, metav1.Condition{
-				Type:    infrav1.VMIPAllocatedCondition,
-				Status:  metav1.ConditionFalse,
-				Reason:  infrav1.VMIPAllocationFailedReason,
-				Message: fmt.Sprintf("Failed to allocate VM IP: %v", allocErr),
-			})
+		conditions.Set(hvScope.HarvesterMachine, metav1.Condition{
+			Type:    infrav1.VMIPAllocatedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.VMIPAddressClaimsPendingReason,
+			Message: allocErr.Error(),
+		})
 
-			return ctrl.Result{RequeueAfter: requeueTimeShort}, allocErr
-		}
+		return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+	}
 
-		vmNetCfg := effectiveVMNetworkConfig(hvScope)
-		hvScope.EffectiveNetworkConfig = &infrav1.NetworkConfig{
-			Address:    hvScope.HarvesterMachine.Status.AllocatedIPAddress,
-			Gateway:    vmNetCfg.Gateway,
-			DNSServers: vmNetCfg.DNSServers,
-			DNSSearch:  vmNetCfg.DNSSearch,
-		}
+	if allocErr != nil {
+		logger.Error(allocErr, "failed to allocate VM IP from pool")
 
+		conditions.Set(hvScope.HarvesterMachine, metav1.Condition{
+			Type:    infrav1.VMIPAllocatedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.VMIPAllocationFailedReason,
+			Message: fmt.Sprintf("Failed to allocate VM IP: %v", allocErr),
+		})
+
+		return ctrl.Result{RequeueAfter: requeueTimeShort}, allocErr
+	}
+
+	if allocatedIPs := append(allocatedVMIPs(&hvScope.HarvesterMachine.Status),
+		claimedVMIPs(hvScope)...); len(allocatedIPs) > 0 {
 		conditions.Set(hvScope.HarvesterMachine, metav1.Condition{
 			Type:    infrav1.VMIPAllocatedCondition,
 			Status:  metav1.ConditionTrue,
 			Reason:  infrav1.VMIPAllocatedReason,
-			Message: fmt.Sprintf("Allocated IP %s from pool", hvScope.HarvesterMachine.Status.AllocatedIPAddress),
+			Message: fmt.Sprintf("Allocated IP %s from pool", strings.Join(allocatedIPs, ", ")),
 		})
-	} else if hvScope.HarvesterMachine.Spec.NetworkConfig != nil {
-		// Use machine-level static network config
-		hvScope.EffectiveNetworkConfig = hvScope.HarvesterMachine.Spec.NetworkConfig
 	}
 
 	vmExists := false
+	resourcesUpdating := false
+	volumesUpdating := false
+	volumesCloning := false
 
 	// check if Harvester has a machine with the same name and namespace
 	existingVM, err := hvScope.HarvesterClient.KubevirtV1().VirtualMachines(hvScope.HarvesterCluster.Spec.TargetNamespace).Get(
@@ -392,6 +418,16 @@ func (r *HarvesterMachineReconciler) ReconcileNormal(hvScope *Scope) (res reconc
 	if (existingVM != nil) && (existingVM.Name == hvScope.HarvesterMachine.Name) {
 		vmExists = true
 
+		// A VM deleted to be recreated is only recreated once it is gone
+		if !existingVM.DeletionTimestamp.IsZero() {
+			logger.Info("Waiting for the deleted VM to be gone ...")
+
+			hvScope.HarvesterMachine.Status.Ready = false
+			hvScope.HarvesterMachine.Status.Initialization = machineInitializationNotProvisioned
+
+			return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+		}
+
 		// Set VMProvisioningReady condition for existing VM
 		conditions.Set(hvScope.HarvesterMachine, metav1.Condition{
 			Type:    infrav1.VMProvisioningReadyCondition,
@@ -400,7 +436,26 @@ func (r *HarvesterMachineReconciler) ReconcileNormal(hvScope *Scope) (res reconc
 			Message: "VM already exists and is provisioned",
 		})
 
+		volumesCloning = reconcileVolumeClones(hvScope, existingVM)
+
+		// Give up on a VM that does not report an IP address in time
+		if r.reconcileProvisioningTimeout(hvScope, existingVM) {
+			return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+		}
+
+		// Report why a VM that does not become ready cannot start, instead of
+		// waiting for it silently
+		if !existingVM.Status.Ready {
+			if failure := diagnoseVMFailure(hvScope, existingVM); failure != nil {
+				return r.reportVMFailure(hvScope, failure), nil
+			}
+		}
+
 		if isVMRunning(existingVM) {
+			// Apply the CPU and memory changes of in-place updates to the running VM
+			resourcesUpdating = reconcileVMResources(hvScope, existingVM)
+			volumesUpdating = reconcileVolumes(hvScope, existingVM)
+
 			ipAddresses, err := getIPAddressesFromVMI(hvScope.Ctx, existingVM, hvScope.HarvesterClient)
 			if err != nil {
 				hvScope.HarvesterMachine.Status.Ready = false
@@ -483,6 +538,24 @@ func (r *HarvesterMachineReconciler) ReconcileNormal(hvScope *Scope) (res reconc
 			Message: "VM provisioning in progress",
 		})
 
+		imagesReady, importErr := reconcileImageImports(hvScope)
+		if importErr != nil {
+			logger.Error(importErr, "unable to import the VM images of the volumes")
+
+			return ctrl.Result{}, importErr
+		}
+
+		if !imagesReady {
+			return ctrl.Result{RequeueAfter: imageImportPollInterval}, nil
+		}
+
+		err = checkVolumeCloneSources(hvScope)
+		if err != nil {
+			logger.Error(err, "unable to clone the volumes of the VM")
+
+			return ctrl.Result{}, err
+		}
+
 		caphvmetrics.MachineCreateTotal.Inc()
 
 		createStart := time.Now()
@@ -552,6 +625,10 @@ func (r *HarvesterMachineReconciler) ReconcileNormal(hvScope *Scope) (res reconc
 		return ctrl.Result{RequeueAfter: requeueDelay}, nil
 	}
 
+	if resourcesUpdating || volumesUpdating || volumesCloning {
+		return ctrl.Result{RequeueAfter: vmResourcesPollInterval}, nil
+	}
+
 	return ctrl.Result{}, nil
 }
 
@@ -672,23 +749,35 @@ func getIPAddressesFromVMI(
 	}
 
 	for _, nic := range vmInstance.Status.Interfaces {
-		if nic.IP == "" {
-			continue
+		// IPs holds every address of the NIC (IPv4 and IPv6 on dual-stack
+		// machines); IP only the primary one, reported by older KubeVirt versions.
+		nicIPs := nic.IPs
+		if len(nicIPs) == 0 && nic.IP != "" {
+			nicIPs = []string{nic.IP}
 		}
 
-		ipAddresses = append(ipAddresses, clusterv1.MachineAddress{
-			Type:    clusterv1.MachineExternalIP,
-			Address: nic.IP,
-		})
+		for _, ip := range nicIPs {
+			if ip == "" || strings.HasPrefix(ip, "fe80:") {
+				continue
+			}
+
+			ipAddresses = append(ipAddresses, clusterv1.MachineAddress{
+				Type:    clusterv1.MachineExternalIP,
+				Address: ip,
+			})
+		}
 	}
 
 	return ipAddresses, nil
 }
 
-// diskInfo holds the PVC name and volume index for each disk attached to a VM.
+// diskInfo holds the PVC name and volume index for each disk attached to a VM,
+// and the VM image the disk was created from.
 type diskInfo struct {
-	pvcName string
-	index   int
+	pvcName   string
+	index     int
+	imageName string
+	imageUID  types.UID
 }
 
 func createVMFromHarvesterMachine(hvScope *Scope) (*kubevirtv1.VirtualMachine, error) {
@@ -702,6 +791,9 @@ func createVMFromHarvesterMachine(hvScope *Scope) (*kubevirtv1.VirtualMachine, e
 		vmLabels[cpVMLabelKey] = cpVMLabelValuePrefix + "-" + hvScope.Cluster.Name
 	}
 
+	maps.Copy(vmLabels, clusterOwnershipLabels(hvScope.HarvesterCluster))
+	maps.Copy(vmLabels, hvScope.VMLabels)
+
 	vmiLabels := vmLabels
 
 	vmName := hvScope.HarvesterMachine.Name
@@ -719,13 +811,49 @@ func createVMFromHarvesterMachine(hvScope *Scope) (*kubevirtv1.VirtualMachine, e
 		diskRandomID := locutil.RandomID()
 		pvcName := fmt.Sprintf("%s-disk-%d-%s", vmName, i, diskRandomID)
 
-		pvc, err := buildPVCForVolume(&vol, pvcName, targetNS, hvScope)
+		disk := diskInfo{pvcName: pvcName, index: i}
+
+		vmImage, err := resolveVolumeImage(&vol, targetNS, hvScope)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to resolve the image of volume %d", i)
+		}
+
+		if vmImage != nil {
+			// Pin the volume to the resolved image, as a newer one may match its selector by now
+			vol.ImageName = vmImage.Namespace + "/" + vmImage.Name
+			vol.ImageSelector = nil
+			disk.imageName = vol.ImageName
+			disk.imageUID = vmImage.UID
+		}
+
+		pvc, err := buildPVCForVolume(&vol, vmImage, pvcName, targetNS, hvScope)
 		if err != nil {
 			return nil, errors.Wrapf(err, "unable to build PVC for volume %d", i)
 		}
 
 		pvcs = append(pvcs, pvc)
-		disks = append(disks, diskInfo{pvcName: pvcName, index: i})
+		disks = append(disks, disk)
+	}
+
+	var (
+		templateVersion *harvesterv1beta1.VirtualMachineTemplateVersion
+		claimNames      map[string]string
+	)
+
+	if hvScope.HarvesterMachine.Spec.VMTemplateVersion != "" {
+		templateVersion, err = getVMTemplateVersion(hvScope)
+		if err != nil {
+			return nil, err
+		}
+
+		var templatePVCs []*v1.PersistentVolumeClaim
+
+		templatePVCs, claimNames, err = buildTemplateVersionPVCs(templateVersion, vmName, targetNS)
+		if err != nil {
+			return nil, err
+		}
+
+		pvcs = append(pvcs, templatePVCs...)
 	}
 
 	pvcAnnotation, err := json.Marshal(pvcs)
@@ -738,6 +866,13 @@ func createVMFromHarvesterMachine(hvScope *Scope) (*kubevirtv1.VirtualMachine, e
 		return &kubevirtv1.VirtualMachine{}, errors.Wrap(err, "unable to build VM definition")
 	}
 
+	if templateVersion != nil {
+		vmTemplate, err = overlayVMTemplateVersion(templateVersion, vmTemplate, claimNames)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to build VM definition from the VM template version")
+		}
+	}
+
 	if vmTemplate.ObjectMeta.Labels == nil {
 		vmTemplate.ObjectMeta.Labels = make(map[string]string)
 	}
@@ -758,6 +893,8 @@ func createVMFromHarvesterMachine(hvScope *Scope) (*kubevirtv1.VirtualMachine, e
 		},
 	}
 
+	applyInstancetypeAndPreference(hvScope.HarvesterMachine, &ubuntuVM.Spec)
+
 	hvCreatedMachine, err := hvScope.HarvesterClient.KubevirtV1().VirtualMachines(targetNS).Create(
 		hvScope.Ctx,
 		ubuntuVM,
@@ -766,6 +903,8 @@ func createVMFromHarvesterMachine(hvScope *Scope) (*kubevirtv1.VirtualMachine, e
 		return hvCreatedMachine, err
 	}
 
+	recordVolumeStatuses(hvScope.HarvesterMachine, disks)
+
 	return hvCreatedMachine, nil
 }
 
@@ -783,16 +922,27 @@ func storageClassForImage(vmImage *harvesterv1beta1.VirtualMachineImage) string
 }
 
 // buildPVCForVolume creates a PersistentVolumeClaim for a single volume.
-// For "image" volumes, the PVC references a Harvester VM image (StorageClass resolved
-// from the image status).
+// For "image" volumes, the PVC references vmImage, the image resolved for the volume
+// (StorageClass resolved from the image status).
 // For "storageClass" volumes, the PVC uses the specified StorageClass directly (blank data disk).
+// For "clone" volumes, the PVC is cloned from the VolumeSnapshot or PVC of the volume.
 func buildPVCForVolume(
 	vol *infrav1.Volume,
+	vmImage *harvesterv1beta1.VirtualMachineImage,
 	pvcName string,
 	pvcNamespace string,
 	hvScope *Scope,
 ) (*v1.PersistentVolumeClaim, error) {
-	block := v1.PersistentVolumeBlock
+	// Live migration needs ReadWriteMany block volumes, which Longhorn provides
+	accessMode := v1.ReadWriteMany
+	if vol.AccessMode != "" {
+		accessMode = vol.AccessMode
+	}
+
+	volumeMode := v1.PersistentVolumeBlock
+	if vol.VolumeMode != "" {
+		volumeMode = vol.VolumeMode
+	}
 
 	pvc := &v1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
@@ -802,22 +952,23 @@ func buildPVCForVolume(
 		},
 		Spec: v1.PersistentVolumeClaimSpec{
 			AccessModes: []v1.PersistentVolumeAccessMode{
-				v1.ReadWriteMany,
+				accessMode,
 			},
 			Resources: v1.VolumeResourceRequirements{
-				Requests: v1.ResourceList{
-					"storage": *vol.VolumeSize,
-				},
+				Requests: v1.ResourceList{},
 			},
-			VolumeMode: &block,
+			VolumeMode: &volumeMode,
 		},
 	}
 
+	if vol.VolumeSize != nil {
+		pvc.Spec.Resources.Requests[v1.ResourceStorage] = *vol.VolumeSize
+	}
+
 	switch vol.VolumeType {
 	case "image":
-		vmImage, err := getImageByName(vol.ImageName, pvcNamespace, hvScope)
-		if err != nil {
-			return nil, errors.Wrapf(err, "unable to find VM image %s", vol.ImageName)
+		if vmImage == nil {
+			return nil, errors.Errorf("VM image %s of the volume is not resolved", vol.ImageName)
 		}
 
 		scName := storageClassForImage(vmImage)
@@ -827,6 +978,17 @@ func buildPVCForVolume(
 	case "storageClass":
 		scName := vol.StorageClass
 		pvc.Spec.StorageClassName = &scName
+
+	case "clone":
+		source, err := getCloneSource(hvScope, vol, pvcNamespace)
+		if err != nil {
+			return nil, err
+		}
+
+		err = applyCloneSource(pvc, vol, source)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return pvc, nil
@@ -840,22 +1002,48 @@ func getImageByName(imageName, defaultNamespace string, hvScope *Scope) (*harves
 		return nil, fmt.Errorf("imageName %q is malformed, expecting <NAMESPACE>/<NAME> format: %w", imageName, err)
 	}
 
-	foundImages, err := hvScope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineImages(vmImageNamespacedName.Namespace).List(
+	vmImage, err := findImage(vmImageNamespacedName, hvScope)
+	if err != nil {
+		return nil, err
+	}
+
+	if vmImage == nil {
+		return nil, fmt.Errorf(
+			"VM image %s not found in namespace %s (searched by display name and resource name)",
+			vmImageNamespacedName.Name, vmImageNamespacedName.Namespace,
+		)
+	}
+
+	return vmImage, nil
+}
+
+// findImage looks a Harvester VirtualMachineImage up by its resource name or
+// display name. The images of the namespace are only listed when no image has
+// the resource name. It returns nil when no image matches.
+func findImage(name types.NamespacedName, hvScope *Scope) (*harvesterv1beta1.VirtualMachineImage, error) {
+	vmImage, err := hvScope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineImages(name.Namespace).Get(
+		hvScope.Ctx, name.Name, metav1.GetOptions{})
+	if err == nil {
+		return vmImage, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	foundImages, err := hvScope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineImages(name.Namespace).List(
 		hvScope.Ctx, metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
 
 	for _, image := range foundImages.Items {
-		if image.Spec.DisplayName == vmImageNamespacedName.Name || image.Name == vmImageNamespacedName.Name {
+		if image.Spec.DisplayName == name.Name {
 			return &image, nil
 		}
 	}
 
-	return nil, fmt.Errorf(
-		"VM image %s not found in namespace %s (searched by display name and resource name)",
-		vmImageNamespacedName.Name, vmImageNamespacedName.Namespace,
-	)
+	return nil, nil
 }
 
 // buildVMTemplate creates a *kubevirtv1.VirtualMachineInstanceTemplateSpec from the CLI Flags and some computed values.
@@ -890,51 +1078,35 @@ try to specify the namespace using the format <NAMESPACE>/<NAME>`,
 
 	hvScope.Logger.V(3).Info("SSH Key Name " + keyName + " given does exist!") //nolint:mnd
 
-	// building cloud-init user data
-	cloudInitBase := `package_update: true
-packages:
-  - qemu-guest-agent
-  - iptables
-runcmd:
-  - - systemctl
-    - enable
-    - --now
-    - qemu-guest-agent.service`
-	cloudInitSSHSection := "\nssh_authorized_keys:\n  - " + sshKey.Spec.PublicKey + "\n"
-
-	cloudInitUserData, err1 := getCloudInitData(hvScope)
+	bootstrapData, bootstrapFormat, err1 := getBootstrapData(hvScope)
 	if err1 != nil {
 		err = fmt.Errorf("error during getting cloud init user data from Harvester: %w", err1)
 
 		return nil, err
 	}
 
-	// In DHCP mode, add dhclient bootcmd to work around wicked BPF filter bug on KubeVirt/virtio-net.
-	// Wicked uses AF_PACKET SOCK_DGRAM with a BPF filter that has link-layer offsets, but on SOCK_DGRAM
-	// the kernel presents network-layer data to BPF, causing all DHCP responses to be silently dropped.
-	// ISC dhclient uses AF_PACKET SOCK_RAW (LPF) which works correctly.
-	cloudInitDHCP := ""
-	if hvScope.EffectiveNetworkConfig == nil && len(hvScope.HarvesterMachine.Spec.Networks) > 0 {
-		cloudInitDHCP = buildDHCPCloudInit(hvScope)
-	}
-
-	finalCloudInit, err := locutil.MergeCloudInitData(cloudInitBase, cloudInitSSHSection, cloudInitDHCP, cloudInitUserData)
+	userData, err := buildUserData(hvScope, bootstrapData, bootstrapFormat, sshKey.Spec.PublicKey)
 	if err != nil {
-		err = fmt.Errorf("error during merging cloud init user data from Harvester: %w", err)
-
 		return nil, err
 	}
 
 	// Build cloud-init secret data with lowercase keys (required by KubeVirt)
 	secretData := map[string][]byte{
-		"userdata": finalCloudInit,
+		"userdata": userData,
 	}
 
 	// Build network-config for NICs. For static mode, generate v1 networkdata with IP configuration.
 	// For DHCP mode, skip networkdata entirely — dhclient handles network setup via bootcmd,
 	// and generating networkdata would cause wicked to interfere with the DHCP-assigned IP.
+	// Talos reads the same NoCloud network-config; Ignition systems get their network
+	// configuration from the Ignition config itself.
 	if hvScope.EffectiveNetworkConfig != nil && len(hvScope.HarvesterMachine.Spec.Networks) > 0 {
-		secretData["networkdata"] = []byte(buildNetworkDataStatic(hvScope))
+		if bootstrapFormat == locutil.BootstrapFormatIgnition {
+			hvScope.Logger.Info("Warning: static network configuration is not injected into Ignition bootstrap data, "+
+				"the bootstrap config must configure the allocated address", "address", hvScope.EffectiveNetworkConfig.Address)
+		} else {
+			secretData["networkdata"] = []byte(buildNetworkDataStatic(hvScope))
+		}
 	}
 
 	// create cloud-init secret for reference in Harvester.
@@ -946,7 +1118,7 @@ runcmd:
 		Data: secretData,
 	}
 
-	hvScope.Logger.V(5).Info("cloud-init final value is " + string(finalCloudInit)) //nolint:mnd
+	hvScope.Logger.V(5).Info("cloud-init final value is " + string(userData)) //nolint:mnd
 
 	// check if secret already exists
 	_, err = hvScope.HarvesterClient.CoreV1().Secrets(hvScope.HarvesterCluster.Spec.TargetNamespace).Get(
@@ -974,7 +1146,7 @@ runcmd:
 	kvDisks := make([]kubevirtv1.Disk, 0, len(disks)+1)
 
 	for _, d := range disks {
-		diskName := fmt.Sprintf("disk-%d", d.index)
+		diskName := volumeDiskName(d.index)
 
 		volumes = append(volumes, kubevirtv1.Volume{
 			Name: diskName,
@@ -987,40 +1159,18 @@ runcmd:
 			},
 		})
 
-		disk := kubevirtv1.Disk{
-			Name: diskName,
-			DiskDevice: kubevirtv1.DiskDevice{
-				Disk: &kubevirtv1.DiskTarget{
-					Bus: "virtio",
-				},
-			},
-		}
-
-		// Apply boot order if specified in the volume spec
+		vol := &infrav1.Volume{}
 		if d.index < len(hvScope.HarvesterMachine.Spec.Volumes) {
-			bootOrder := hvScope.HarvesterMachine.Spec.Volumes[d.index].BootOrder
-			if bootOrder > 0 {
-				bo := uint(bootOrder)
-				disk.BootOrder = &bo
-			}
+			vol = &hvScope.HarvesterMachine.Spec.Volumes[d.index]
 		}
 
-		kvDisks = append(kvDisks, disk)
+		kvDisks = append(kvDisks, buildVolumeDisk(diskName, vol, kubevirtv1.DiskBusVirtio))
 	}
 
 	// Append cloud-init disk last
 	volumes = append(volumes, kubevirtv1.Volume{
-		Name: "cloudinitdisk",
-		VolumeSource: kubevirtv1.VolumeSource{
-			CloudInitNoCloud: &kubevirtv1.CloudInitNoCloudSource{
-				UserDataSecretRef: &v1.LocalObjectReference{
-					Name: hvScope.HarvesterMachine.Name + "-cloud-init",
-				},
-				NetworkDataSecretRef: &v1.LocalObjectReference{
-					Name: hvScope.HarvesterMachine.Name + "-cloud-init",
-				},
-			},
-		},
+		Name:         "cloudinitdisk",
+		VolumeSource: buildCloudInitVolumeSource(hvScope.HarvesterMachine.Name+"-cloud-init", bootstrapFormat),
 	})
 	kvDisks = append(kvDisks, kubevirtv1.Disk{
 		Name: "cloudinitdisk",
@@ -1063,11 +1213,6 @@ runcmd:
 			Networks: getKubevirtNetworksFromHarvesterMachine(hvScope.HarvesterMachine),
 			Volumes:  volumes,
 			Domain: kubevirtv1.DomainSpec{
-				CPU: &kubevirtv1.CPU{
-					Cores:   hvScope.HarvesterMachine.Spec.CPU,
-					Sockets: 1,
-					Threads: 1,
-				},
 				Devices: kubevirtv1.Devices{
 					Inputs: []kubevirtv1.Input{
 						{
@@ -1079,28 +1224,64 @@ runcmd:
 					Interfaces: interfaces,
 					Disks:      kvDisks,
 				},
-				Memory: &kubevirtv1.Memory{
-					Guest: new(resource.MustParse(hvScope.HarvesterMachine.Spec.Memory)),
-				},
-				Resources: kubevirtv1.ResourceRequirements{
-					Requests: v1.ResourceList{
-						"memory": resource.MustParse(hvScope.HarvesterMachine.Spec.Memory),
-					},
-					Limits: v1.ResourceList{
-						"cpu":    *resource.NewQuantity(int64(hvScope.HarvesterMachine.Spec.CPU), resource.DecimalSI),
-						"memory": resource.MustParse(hvScope.HarvesterMachine.Spec.Memory),
-					},
-				},
 			},
 			Affinity: affinity,
 		},
 	}
 
-	applyFirmwareAndTPM(hvScope.HarvesterMachine, &vmTemplate.Spec.Domain)
+	applyShutdownGracePeriod(hvScope, &vmTemplate.Spec)
+
+	// The CPU, memory and firmware of machines built from a VM template version
+	// come from the version, see overlayVMTemplateVersion
+	if hvScope.HarvesterMachine.Spec.VMTemplateVersion == "" {
+		// Hotplug needs the CPUs and memory of the machine. The webhook rejects
+		// it with an instancetype, but webhooks are optional and pool templates
+		// do not go through them.
+		if hvScope.HarvesterMachine.Spec.Instancetype != "" && hvScope.HarvesterMachine.Spec.Hotplug != nil {
+			return nil, errors.New("spec.hotplug cannot be set with spec.instancetype")
+		}
+
+		// The CPUs and memory of machines with an instancetype come from the instancetype
+		if hvScope.HarvesterMachine.Spec.Instancetype == "" {
+			applyCPUAndMemory(hvScope.HarvesterMachine, &vmTemplate.Spec.Domain)
+			applyCPUOptions(hvScope.HarvesterMachine, &vmTemplate.Spec.Domain)
+			applyMemoryOptions(hvScope.HarvesterMachine, &vmTemplate.Spec.Domain)
+		}
+
+		applyFirmwareAndTPM(hvScope.HarvesterMachine, &vmTemplate.Spec.Domain)
+		applyGuestDevices(hvScope.HarvesterMachine, &vmTemplate.Spec.Domain)
+
+		err = applyHotplug(hvScope.HarvesterMachine, &vmTemplate.Spec.Domain)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	return vmTemplate, nil
 }
 
+// applyCPUAndMemory sets the CPUs and memory of the HarvesterMachine on the
+// kubevirt domain, with the matching resource requirements.
+func applyCPUAndMemory(machine *infrav1.HarvesterMachine, domain *kubevirtv1.DomainSpec) {
+	domain.CPU = &kubevirtv1.CPU{
+		Cores:   machine.Spec.CPU,
+		Sockets: 1,
+		Threads: 1,
+	}
+	domain.Memory = &kubevirtv1.Memory{
+		Guest: new(resource.MustParse(machine.Spec.Memory)),
+	}
+	domain.Resources = kubevirtv1.ResourceRequirements{
+		Requests: v1.ResourceList{
+			"memory": resource.MustParse(machine.Spec.Memory),
+		},
+		Limits: v1.ResourceList{
+			"cpu":    *resource.NewQuantity(int64(machine.Spec.CPU), resource.DecimalSI),
+			"memory": resource.MustParse(machine.Spec.Memory),
+		},
+	}
+}
+
 // applyFirmwareAndTPM maps the optional firmware and TPM settings of the
 // HarvesterMachine onto the kubevirt domain. The EFI secureBoot flag is always
 // pinned explicitly because kubevirt defaults it to true when EFI is enabled,
@@ -1125,6 +1306,41 @@ func applyFirmwareAndTPM(machine *infrav1.HarvesterMachine, domain *kubevirtv1.D
 	}
 }
 
+// applyGuestDevices maps the optional guest devices of the HarvesterMachine
+// onto the kubevirt domain. Without devices, the VM keeps the USB tablet and
+// the consoles KubeVirt attaches by default.
+func applyGuestDevices(machine *infrav1.HarvesterMachine, domain *kubevirtv1.DomainSpec) {
+	devices := machine.Spec.Devices
+	if devices == nil {
+		return
+	}
+
+	if watchdog := devices.Watchdog; watchdog != nil {
+		action := kubevirtv1.WatchdogActionReset
+		if watchdog.Action != "" {
+			action = kubevirtv1.WatchdogAction(watchdog.Action)
+		}
+
+		domain.Devices.Watchdog = &kubevirtv1.Watchdog{
+			Name: "watchdog",
+			WatchdogDevice: kubevirtv1.WatchdogDevice{
+				I6300ESB: &kubevirtv1.I6300ESBWatchdog{Action: action},
+			},
+		}
+	}
+
+	if devices.RNG {
+		domain.Devices.Rng = &kubevirtv1.Rng{}
+	}
+
+	domain.Devices.AutoattachSerialConsole = devices.AutoattachSerialConsole
+	domain.Devices.AutoattachGraphicsDevice = devices.AutoattachGraphicsDevice
+
+	if devices.Tablet != nil && !*devices.Tablet {
+		domain.Devices.Inputs = nil
+	}
+}
+
 // buildNetworkInterfaces creates kubevirt Interface specs for each network.
 func buildNetworkInterfaces(machine *infrav1.HarvesterMachine) []kubevirtv1.Interface {
 	interfaces := make([]kubevirtv1.Interface, 0, len(machine.Spec.Networks))
@@ -1140,8 +1356,9 @@ func buildNetworkInterfaces(machine *infrav1.HarvesterMachine) []kubevirtv1.Inte
 	return interfaces
 }
 
-// buildNetworkDataStatic generates cloud-init network-config v1 with static IP on eth0
-// and DHCP on additional NICs.
+// buildNetworkDataStatic generates cloud-init network-config v1. Each NIC follows
+// its interfaces entry when it has one; otherwise eth0 gets the static address of
+// the effective network configuration and the additional NICs use DHCP.
 func buildNetworkDataStatic(hvScope *Scope) string {
 	var b strings.Builder
 
@@ -1152,16 +1369,32 @@ func buildNetworkDataStatic(hvScope *Scope) string {
 		subnetMask = vmNetCfg.SubnetMask
 	}
 
+	if hvScope.EffectiveSubnetMask != "" {
+		subnetMask = hvScope.EffectiveSubnetMask
+	}
+
 	b.WriteString("version: 1\nconfig:\n")
 
-	for i := range hvScope.HarvesterMachine.Spec.Networks {
+	for i, network := range hvScope.HarvesterMachine.Spec.Networks {
 		ethName := "eth" + strconv.Itoa(i)
 
+		iface := interfaceConfigFor(netCfg.Interfaces, network)
+		if iface != nil {
+			writeInterfaceNetworkData(&b, hvScope.HarvesterMachine, ethName, iface)
+
+			if i == 0 && iface.Addressing != infrav1.InterfaceAddressingNone {
+				writeIPv6Subnet(&b, netCfg)
+			}
+
+			continue
+		}
+
 		fmt.Fprintf(&b, "  - type: physical\n    name: %s\n    subnets:\n", ethName)
 
 		if i == 0 {
 			fmt.Fprintf(&b, "      - type: static\n        address: %s\n        netmask: %s\n        gateway: %s\n",
 				netCfg.Address, subnetMask, netCfg.Gateway)
+			writeIPv6Subnet(&b, netCfg)
 		} else {
 			b.WriteString("      - type: dhcp\n")
 		}
@@ -1186,6 +1419,75 @@ func buildNetworkDataStatic(hvScope *Scope) string {
 	return b.String()
 }
 
+// writeIPv6Subnet adds the static IPv6 address of a dual-stack machine to the
+// subnets of the NIC being written, if the network configuration has one.
+func writeIPv6Subnet(b *strings.Builder, netCfg *infrav1.NetworkConfig) {
+	if netCfg.IPv6Address == "" {
+		return
+	}
+
+	fmt.Fprintf(b, "      - type: static6\n        address: %s/%d\n", netCfg.IPv6Address, netCfg.IPv6PrefixLength)
+
+	if netCfg.IPv6Gateway != "" {
+		fmt.Fprintf(b, "        gateway: %s\n", netCfg.IPv6Gateway)
+	}
+}
+
+// writeInterfaceNetworkData writes the network-config v1 entry of one NIC
+// configured by an interfaces entry: MTU, addressing and static routes.
+func writeInterfaceNetworkData(b *strings.Builder, machine *infrav1.HarvesterMachine, ethName string,
+	iface *infrav1.InterfaceConfig,
+) {
+	fmt.Fprintf(b, "  - type: physical\n    name: %s\n", ethName)
+
+	if iface.MTU > 0 {
+		fmt.Fprintf(b, "    mtu: %d\n", iface.MTU)
+	}
+
+	switch iface.Addressing {
+	case infrav1.InterfaceAddressingNone:
+		b.WriteString("    subnets:\n      - type: manual\n")
+
+		return
+	case infrav1.InterfaceAddressingDHCP:
+		b.WriteString("    subnets:\n      - type: dhcp\n")
+	default:
+		address := iface.Address
+		if iface.Addressing == infrav1.InterfaceAddressingPool {
+			if allocation := interfaceAllocationFor(machine, iface.Network); allocation != nil {
+				address = allocation.IPAddress
+			}
+		}
+
+		fmt.Fprintf(b, "    subnets:\n      - type: static\n        address: %s\n        netmask: %s\n",
+			address, iface.SubnetMask)
+
+		if iface.Gateway != "" {
+			fmt.Fprintf(b, "        gateway: %s\n", iface.Gateway)
+		}
+	}
+
+	if len(iface.Routes) == 0 {
+		return
+	}
+
+	b.WriteString("        routes:\n")
+
+	for _, route := range iface.Routes {
+		_, dst, err := net.ParseCIDR(route.To)
+		if err != nil {
+			continue
+		}
+
+		fmt.Fprintf(b, "          - network: %s\n            netmask: %s\n            gateway: %s\n",
+			dst.IP, net.IP(dst.Mask), route.Via)
+
+		if route.Metric != nil {
+			fmt.Fprintf(b, "            metric: %d\n", *route.Metric)
+		}
+	}
+}
+
 // buildDHCPCloudInit generates cloud-init YAML with write_files and bootcmd entries that
 // configure network interfaces via dhclient. This works around wicked's BPF filter bug:
 // wicked uses AF_PACKET SOCK_DGRAM with a BPF filter that has link-layer offsets, but on
@@ -1330,7 +1632,9 @@ func getKubevirtNetworksFromHarvesterMachine(harvesterMachine *infrav1.Harvester
 	return networks
 }
 
-func getCloudInitData(hvScope *Scope) (string, error) {
+// getBootstrapData returns the bootstrap data of the owner Machine and its format, read from
+// the "format" key of the bootstrap data secret when the bootstrap provider sets it.
+func getBootstrapData(hvScope *Scope) (string, locutil.BootstrapFormat, error) {
 	dataSecretNamespacedName := types.NamespacedName{
 		Namespace: hvScope.Machine.Namespace,
 		Name:      *hvScope.Machine.Spec.Bootstrap.DataSecretName,
@@ -1340,15 +1644,86 @@ func getCloudInitData(hvScope *Scope) (string, error) {
 
 	err := hvScope.ReconcilerClient.Get(hvScope.Ctx, dataSecretNamespacedName, dataSecret)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	userData, ok := dataSecret.Data["value"]
 	if !ok {
-		return "", fmt.Errorf("no userData key found in secret %s", dataSecretNamespacedName)
+		return "", "", fmt.Errorf("no userData key found in secret %s", dataSecretNamespacedName)
 	}
 
-	return string(userData), nil
+	format, err := locutil.DetectBootstrapFormat(string(dataSecret.Data["format"]), string(userData))
+	if err != nil {
+		return "", "", fmt.Errorf("invalid bootstrap data in secret %s: %w", dataSecretNamespacedName, err)
+	}
+
+	return string(userData), format, nil
+}
+
+// buildUserData returns the user data passed to the VM. Cloud-config bootstrap data is merged
+// with the provider base (guest agent, SSH key, DHCP workaround). Ignition configs only get the
+// SSH key added to the SSH user, and Talos machine configs are passed through untouched since
+// Talos neither runs cloud-init nor accepts SSH keys.
+func buildUserData(hvScope *Scope, bootstrapData string, format locutil.BootstrapFormat, publicKey string) ([]byte, error) {
+	switch format {
+	case locutil.BootstrapFormatTalos:
+		return []byte(bootstrapData), nil
+	case locutil.BootstrapFormatIgnition:
+		userData, err := locutil.InjectIgnitionSSHKey([]byte(bootstrapData), hvScope.HarvesterMachine.Spec.SSHUser, publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("error during adding SSH key to ignition user data: %w", err)
+		}
+
+		return userData, nil
+	}
+
+	// building cloud-init user data
+	cloudInitBase := `package_update: true
+packages:
+  - qemu-guest-agent
+  - iptables
+runcmd:
+  - - systemctl
+    - enable
+    - --now
+    - qemu-guest-agent.service`
+	cloudInitSSHSection := "\nssh_authorized_keys:\n  - " + publicKey + "\n"
+
+	// In DHCP mode, add dhclient bootcmd to work around wicked BPF filter bug on KubeVirt/virtio-net.
+	// Wicked uses AF_PACKET SOCK_DGRAM with a BPF filter that has link-layer offsets, but on SOCK_DGRAM
+	// the kernel presents network-layer data to BPF, causing all DHCP responses to be silently dropped.
+	// ISC dhclient uses AF_PACKET SOCK_RAW (LPF) which works correctly.
+	cloudInitDHCP := ""
+	if hvScope.EffectiveNetworkConfig == nil && len(hvScope.HarvesterMachine.Spec.Networks) > 0 {
+		cloudInitDHCP = buildDHCPCloudInit(hvScope)
+	}
+
+	finalCloudInit, err := locutil.MergeCloudInitData(cloudInitBase, cloudInitSSHSection, cloudInitDHCP, bootstrapData)
+	if err != nil {
+		return nil, fmt.Errorf("error during merging cloud init user data from Harvester: %w", err)
+	}
+
+	return finalCloudInit, nil
+}
+
+// buildCloudInitVolumeSource returns the KubeVirt volume source exposing the user data secret
+// to the VM: a config drive for Ignition, which is where Ignition looks for its config on
+// KubeVirt, and a NoCloud data source otherwise.
+func buildCloudInitVolumeSource(secretName string, format locutil.BootstrapFormat) kubevirtv1.VolumeSource {
+	if format == locutil.BootstrapFormatIgnition {
+		return kubevirtv1.VolumeSource{
+			CloudInitConfigDrive: &kubevirtv1.CloudInitConfigDriveSource{
+				UserDataSecretRef: &v1.LocalObjectReference{Name: secretName},
+			},
+		}
+	}
+
+	return kubevirtv1.VolumeSource{
+		CloudInitNoCloud: &kubevirtv1.CloudInitNoCloudSource{
+			UserDataSecretRef:    &v1.LocalObjectReference{Name: secretName},
+			NetworkDataSecretRef: &v1.LocalObjectReference{Name: secretName},
+		},
+	}
 }
 
 // effectiveVMNetworkConfig returns the pool-based network configuration that
@@ -1364,6 +1739,70 @@ func effectiveVMNetworkConfig(hvScope *Scope) *infrav1.VMNetworkConfig {
 	return hvScope.HarvesterCluster.Spec.VMNetworkConfig
 }
 
+// effectiveInterfaceConfigs returns the per-network interface configurations
+// that apply to the machine: those of the static networkConfig when set,
+// otherwise those of the effective vmNetworkConfig.
+//
+//nolint:funcorder
+func effectiveInterfaceConfigs(hvScope *Scope) []infrav1.InterfaceConfig {
+	if hvScope.HarvesterMachine.Spec.NetworkConfig != nil {
+		return hvScope.HarvesterMachine.Spec.NetworkConfig.Interfaces
+	}
+
+	if vmNetCfg := effectiveVMNetworkConfig(hvScope); vmNetCfg != nil {
+		return vmNetCfg.Interfaces
+	}
+
+	return nil
+}
+
+// interfaceConfigFor returns the entry of interfaces configuring network, or nil.
+//
+//nolint:funcorder
+func interfaceConfigFor(interfaces []infrav1.InterfaceConfig, network string) *infrav1.InterfaceConfig {
+	for i := range interfaces {
+		if infrav1.NetworkRefsEqual(interfaces[i].Network, network) {
+			return &interfaces[i]
+		}
+	}
+
+	return nil
+}
+
+// interfaceAllocationFor returns the pool allocation recorded for network, or nil.
+//
+//nolint:funcorder
+func interfaceAllocationFor(machine *infrav1.HarvesterMachine, network string) *infrav1.InterfaceAllocation {
+	for i := range machine.Status.InterfaceAllocations {
+		if infrav1.NetworkRefsEqual(machine.Status.InterfaceAllocations[i].Network, network) {
+			return &machine.Status.InterfaceAllocations[i]
+		}
+	}
+
+	return nil
+}
+
+// allocatedVMIPs returns all the pool IPs allocated to the machine.
+//
+//nolint:funcorder
+func allocatedVMIPs(status *infrav1.HarvesterMachineStatus) []string {
+	ips := make([]string, 0, len(status.InterfaceAllocations)+2)
+
+	if status.AllocatedIPAddress != "" {
+		ips = append(ips, status.AllocatedIPAddress)
+	}
+
+	if status.AllocatedIPv6Address != "" {
+		ips = append(ips, status.AllocatedIPv6Address)
+	}
+
+	for _, allocation := range status.InterfaceAllocations {
+		ips = append(ips, allocation.IPAddress)
+	}
+
+	return ips
+}
+
 // allocateVMIP allocates an IP from the effective VM IP pool configuration
 // (machine-level or cluster-level) for this machine.
 // It is idempotent: if an IP is already allocated, it returns early.
@@ -1390,15 +1829,180 @@ func (r *HarvesterMachineReconciler) allocateVMIP(hvScope *Scope) error {
 		return errors.New("no IPPool references configured, ensure reconcileVMIPPool has run")
 	}
 
-	machineID := machine.Namespace + "/" + machine.Name
+	ip, poolRef, err := allocateIPFromPools(hvScope, poolRefs, machine.Namespace+"/"+machine.Name,
+		machine.Spec.Networks, v1.IPv4Protocol)
+	if err != nil {
+		return err
+	}
+
+	machine.Status.AllocatedIPAddress = ip
+	machine.Status.AllocatedPoolRef = poolRef
+
+	return nil
+}
+
+// allocateVMIPv6 allocates an IPv6 address for the first network of a dual-stack
+// machine from the IPv6 pools of ipv6Cfg. The allocation shares the owner of the
+// IPv4 allocation since the two families live in distinct pools.
+// It is idempotent: if an IPv6 address is already allocated, it returns early.
+//
+//nolint:funcorder
+func (r *HarvesterMachineReconciler) allocateVMIPv6(hvScope *Scope, ipv6Cfg *infrav1.IPv6NetworkConfig) error {
+	machine := hvScope.HarvesterMachine
+
+	if machine.Status.AllocatedIPv6Address != "" {
+		hvScope.Logger.V(3).Info("VM IPv6 address already allocated", "ip", machine.Status.AllocatedIPv6Address)
+
+		return nil
+	}
+
+	ip, poolRef, err := allocateIPFromPools(hvScope, ipv6Cfg.IPPoolRefs, machine.Namespace+"/"+machine.Name,
+		machine.Spec.Networks, v1.IPv6Protocol)
+	if err != nil {
+		return errors.Wrap(err, "failed to allocate IPv6 address")
+	}
+
+	machine.Status.AllocatedIPv6Address = ip
+	machine.Status.AllocatedIPv6PoolRef = poolRef
+
+	return nil
+}
+
+// allocateInterfaceIPs allocates an IP for every network attachment configured
+// with pool addressing, from the pools of its interface configuration or of the
+// effective VM IP pool configuration. The pool allocation of each attachment is
+// owned by "<namespace>/<machine>/<network>" so that it stays distinct from the
+// allocation of the primary interface. It is idempotent: attachments with an
+// allocation recorded in the status are skipped.
+//
+//nolint:funcorder
+func (r *HarvesterMachineReconciler) allocateInterfaceIPs(hvScope *Scope) error {
+	machine := hvScope.HarvesterMachine
+
+	for _, iface := range effectiveInterfaceConfigs(hvScope) {
+		if iface.Addressing != infrav1.InterfaceAddressingPool || interfaceAllocationFor(machine, iface.Network) != nil {
+			continue
+		}
+
+		poolRefs := iface.IPPoolRefs
+		if vmNetCfg := effectiveVMNetworkConfig(hvScope); len(poolRefs) == 0 && vmNetCfg != nil {
+			poolRefs = vmNetCfg.GetIPPoolRefs()
+		}
+
+		if len(poolRefs) == 0 {
+			return errors.Errorf("no IPPool references configured for network %s", iface.Network)
+		}
+
+		ownerID := machine.Namespace + "/" + machine.Name + "/" + iface.Network
+
+		ip, poolRef, err := allocateIPFromPools(hvScope, poolRefs, ownerID, []string{iface.Network}, v1.IPv4Protocol)
+		if err != nil {
+			return errors.Wrapf(err, "failed to allocate IP for network %s", iface.Network)
+		}
+
+		machine.Status.InterfaceAllocations = append(machine.Status.InterfaceAllocations, infrav1.InterfaceAllocation{
+			Network:   iface.Network,
+			IPAddress: ip,
+			PoolRef:   poolRef,
+		})
+	}
+
+	return nil
+}
+
+// resolveNetworkConfig allocates the pool IPs of the machine and sets the
+// effective network configuration of the scope: the machine-level static
+// networkConfig when set, then the addresses claimed from addressesFromPools,
+// otherwise the pool-based vmNetworkConfig (machine-level taking precedence over
+// the cluster-level one). The primary interface gets an IP from the pools unless
+// an interfaces entry configures it.
+//
+//nolint:funcorder
+func (r *HarvesterMachineReconciler) resolveNetworkConfig(hvScope *Scope) error {
+	machine := hvScope.HarvesterMachine
+
+	if machine.Spec.NetworkConfig != nil {
+		hvScope.EffectiveNetworkConfig = machine.Spec.NetworkConfig
+
+		err := restoreVMIPAllocations(hvScope)
+		if err != nil {
+			return err
+		}
+
+		return r.allocateInterfaceIPs(hvScope)
+	}
+
+	if len(machine.Spec.AddressesFromPools) > 0 {
+		return r.resolveClaimedNetworkConfig(hvScope)
+	}
+
+	vmNetCfg := effectiveVMNetworkConfig(hvScope)
+	if vmNetCfg == nil {
+		return nil
+	}
+
+	// Allocations lost with the status, e.g. on clusterctl move, are taken back
+	// from the pools before allocating, which could otherwise pick another pool.
+	err := restoreVMIPAllocations(hvScope)
+	if err != nil {
+		return err
+	}
+
+	address := ""
+
+	if len(machine.Spec.Networks) == 0 || interfaceConfigFor(vmNetCfg.Interfaces, machine.Spec.Networks[0]) == nil {
+		err := r.allocateVMIP(hvScope)
+		if err != nil {
+			return err
+		}
+
+		address = machine.Status.AllocatedIPAddress
+	}
+
+	err = r.allocateInterfaceIPs(hvScope)
+	if err != nil {
+		return err
+	}
+
+	netCfg := &infrav1.NetworkConfig{
+		Address:    address,
+		Gateway:    vmNetCfg.Gateway,
+		DNSServers: vmNetCfg.DNSServers,
+		DNSSearch:  vmNetCfg.DNSSearch,
+		Interfaces: vmNetCfg.Interfaces,
+	}
+
+	if vmNetCfg.IPv6 != nil {
+		err = r.allocateVMIPv6(hvScope, vmNetCfg.IPv6)
+		if err != nil {
+			return err
+		}
+
+		netCfg.IPv6Address = machine.Status.AllocatedIPv6Address
+		netCfg.IPv6PrefixLength = vmNetCfg.IPv6.PrefixLength
+		netCfg.IPv6Gateway = vmNetCfg.IPv6.Gateway
+	}
+
+	hvScope.EffectiveNetworkConfig = netCfg
+
+	return nil
+}
+
+// allocateIPFromPools allocates an IP of the given family for ownerID from the
+// first of poolRefs with a free address. It returns the existing allocation of
+// ownerID if one of the pools already holds it. Pools of the other IP family or
+// assigned to a network other than networks are skipped, so that clusters can
+// dedicate distinct pools to control-plane and worker networks by listing them
+// all in ipPoolRefs.
+func allocateIPFromPools(hvScope *Scope, poolRefs []string, ownerID string, networks []string,
+	family v1.IPFamily,
+) (string, string, error) {
+	logger := hvScope.Logger
 
-	// Try each pool in order until allocation succeeds. Pools assigned to a
-	// network the machine is not attached to are skipped, so that clusters can
-	// dedicate distinct pools to control-plane and worker networks by listing
-	// them all in ipPoolRefs.
 	var lastErr error
 
 	skippedNetwork := 0
+	skippedFamily := 0
 
 	for _, poolRef := range poolRefs {
 		pool, err := hvScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
@@ -1410,22 +2014,28 @@ func (r *HarvesterMachineReconciler) allocateVMIP(hvScope *Scope) error {
 			continue
 		}
 
+		if poolFamily := locutil.PoolIPFamily(pool); poolFamily != family {
+			logger.V(1).Info("Pool serves another IP family, trying next", "pool", poolRef, "poolFamily", poolFamily)
+
+			skippedFamily++
+
+			continue
+		}
+
 		// Check for existing allocation in this pool
 		if pool.Status.Allocated != nil {
 			for ip, id := range pool.Status.Allocated {
-				if id == machineID {
-					machine.Status.AllocatedIPAddress = ip
-					machine.Status.AllocatedPoolRef = poolRef
+				if id == ownerID {
 					logger.Info("Found existing IP allocation in pool", "ip", ip, "pool", poolRef)
 
-					return nil
+					return ip, poolRef, nil
 				}
 			}
 		}
 
-		if !locutil.PoolMatchesNetworks(pool, machine.Spec.Networks) {
+		if !locutil.PoolMatchesNetworks(pool, networks) {
 			logger.V(1).Info("Pool assigned to another network, trying next",
-				"pool", poolRef, "poolNetwork", pool.Spec.Selector.Network, "machineNetworks", machine.Spec.Networks)
+				"pool", poolRef, "poolNetwork", pool.Spec.Selector.Network, "machineNetworks", networks)
 
 			skippedNetwork++
 
@@ -1435,7 +2045,16 @@ func (r *HarvesterMachineReconciler) allocateVMIP(hvScope *Scope) error {
 		// Try to allocate from this pool
 		caphvmetrics.IPPoolAllocationsTotal.Inc()
 
-		allocatedIP, allocErr := locutil.AllocateVMIPFromPool(pool, machineID)
+		var allocErr error
+
+		allocatedIP, _, err := allocateFromIPPool(hvScope.Ctx, hvScope.HarvesterClient, poolRef, ownerID,
+			func(fresh *lbv1beta1.IPPool) (string, error) {
+				var ip string
+
+				ip, allocErr = locutil.AllocateVMIPFromPool(fresh, ownerID)
+
+				return ip, allocErr
+			})
 		if allocErr != nil {
 			logger.V(1).Info("Pool exhausted or allocation failed, trying next", "pool", poolRef, "error", allocErr)
 			lastErr = errors.Wrapf(allocErr, "failed to allocate from pool %s", poolRef)
@@ -1443,35 +2062,36 @@ func (r *HarvesterMachineReconciler) allocateVMIP(hvScope *Scope) error {
 			continue
 		}
 
-		// Update pool in Harvester
-		_, err = hvScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Update(
-			hvScope.Ctx, pool, metav1.UpdateOptions{})
 		if err != nil {
 			caphvmetrics.IPPoolAllocationErrorsTotal.Inc()
 
-			return errors.Wrapf(err, "failed to update VM IP pool %s after allocation", poolRef)
+			return "", "", errors.Wrapf(err, "failed to update VM IP pool %s after allocation", poolRef)
 		}
 
-		machine.Status.AllocatedIPAddress = allocatedIP
-		machine.Status.AllocatedPoolRef = poolRef
-		logger.Info("Allocated VM IP from pool", "ip", allocatedIP, "pool", poolRef, "machine", machine.Name)
+		logger.Info("Allocated VM IP from pool", "ip", allocatedIP, "pool", poolRef, "owner", ownerID)
 
-		return nil
+		return allocatedIP, poolRef, nil
 	}
 
 	// All pools exhausted
 	caphvmetrics.IPPoolAllocationErrorsTotal.Inc()
 
 	if lastErr == nil && skippedNetwork > 0 {
-		return errors.Errorf(
+		return "", "", errors.Errorf(
 			"no IP pool among %v matches the machine networks %v (pools are assigned to other networks)",
-			poolRefs, machine.Spec.Networks)
+			poolRefs, networks)
+	}
+
+	if lastErr == nil && skippedFamily > 0 {
+		return "", "", errors.Errorf("no %s IP pool among %v", family, poolRefs)
 	}
 
-	return errors.Wrap(lastErr, "all configured IP pools exhausted")
+	return "", "", errors.Wrap(lastErr, "all configured IP pools exhausted")
 }
 
-// releaseVMIP releases the allocated IP back to the pool during machine deletion.
+// releaseVMIP releases the allocated IPs (primary interface and interfaces
+// with pool addressing) back to their pools during machine deletion, and
+// deletes the IPAddressClaims of addressesFromPools.
 // Errors are logged as warnings but do not block deletion.
 //
 //nolint:funcorder
@@ -1479,6 +2099,21 @@ func (r *HarvesterMachineReconciler) releaseVMIP(hvScope *Scope) {
 	machine := hvScope.HarvesterMachine
 	logger := hvScope.Logger
 
+	deleteAddressClaims(hvScope)
+
+	err := restoreVMIPAllocations(hvScope)
+	if err != nil {
+		logger.Info("Warning: failed to restore VM IP allocations before release", "error", err)
+	}
+
+	for _, allocation := range machine.Status.InterfaceAllocations {
+		releaseIPToPool(hvScope, allocation.PoolRef, allocation.IPAddress)
+	}
+
+	if machine.Status.AllocatedIPv6Address != "" && machine.Status.AllocatedIPv6PoolRef != "" {
+		releaseIPToPool(hvScope, machine.Status.AllocatedIPv6PoolRef, machine.Status.AllocatedIPv6Address)
+	}
+
 	if machine.Status.AllocatedIPAddress == "" {
 		return
 	}
@@ -1501,40 +2136,42 @@ func (r *HarvesterMachineReconciler) releaseVMIP(hvScope *Scope) {
 		return
 	}
 
-	pool, err := hvScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
-		hvScope.Ctx, poolRef, metav1.GetOptions{})
-	if err != nil {
-		logger.Info("Warning: failed to get VM IP pool for release, skipping", "error", err)
-
-		return
-	}
+	releaseIPToPool(hvScope, poolRef, machine.Status.AllocatedIPAddress)
+}
 
-	store := locutil.NewStore(pool)
-	ip := net.ParseIP(machine.Status.AllocatedIPAddress)
+// releaseIPToPool releases address back to the IPPool poolRef.
+// Errors are logged as warnings.
+func releaseIPToPool(hvScope *Scope, poolRef string, address string) {
+	logger := hvScope.Logger
 
+	ip := net.ParseIP(address)
 	if ip == nil {
-		logger.Info("Warning: failed to parse allocated IP for release", "ip", machine.Status.AllocatedIPAddress)
+		logger.Info("Warning: failed to parse allocated IP for release", "ip", address)
 
 		return
 	}
 
-	releaseErr := store.Release(ip)
-	if releaseErr != nil {
-		logger.Info("Warning: failed to release IP from pool", "error", releaseErr, "ip", machine.Status.AllocatedIPAddress)
+	_, released, err := updateIPPool(hvScope.Ctx, hvScope.HarvesterClient, poolRef, func(pool *lbv1beta1.IPPool) error {
+		if _, ok := pool.Status.Allocated[ip.String()]; !ok {
+			return errIPPoolUnchanged
+		}
+
+		return locutil.NewStore(pool).Release(ip)
+	})
+	if err != nil {
+		logger.Info("Warning: failed to release IP from pool", "error", err, "ip", address, "pool", poolRef)
 
 		return
 	}
 
-	_, err = hvScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Update(
-		hvScope.Ctx, pool, metav1.UpdateOptions{})
-	if err != nil {
-		logger.Info("Warning: failed to update pool after IP release", "error", err)
+	if !released {
+		logger.V(1).Info("VM IP already released from pool", "ip", address, "pool", poolRef)
 
 		return
 	}
 
 	caphvmetrics.IPPoolReleasesTotal.Inc()
-	logger.Info("Released VM IP back to pool", "ip", machine.Status.AllocatedIPAddress, "machine", machine.Name)
+	logger.Info("Released VM IP back to pool", "ip", address, "pool", poolRef, "machine", hvScope.HarvesterMachine.Name)
 }
 
 // removeEtcdMemberIfControlPlane removes the etcd member for a control-plane machine
@@ -1585,9 +2222,6 @@ func (r *HarvesterMachineReconciler) ReconcileDelete(hvScope Scope) (res ctrl.Re
 	logger := log.FromContext(hvScope.Ctx)
 	logger.Info("Deleting HarvesterMachine ...")
 
-	// Release allocated IP back to pool before deletion
-	r.releaseVMIP(&hvScope)
-
 	// Remove etcd member from workload cluster before VM deletion (control-plane only)
 	r.removeEtcdMemberIfControlPlane(&hvScope)
 
@@ -1617,13 +2251,41 @@ func (r *HarvesterMachineReconciler) ReconcileDelete(hvScope Scope) (res ctrl.Re
 			return ctrl.Result{Requeue: true}, err
 		}
 
-		// VM is gone — clean up any orphaned PVCs by name prefix
-		logger.Info("No VM found, cleaning up orphaned PVCs")
-		r.deletePVCsByPrefix(hvScope.Ctx, &hvScope, targetNS, machineName+"-disk-")
+		// Release the addresses of the VM only once it is gone: the guest keeps
+		// them during its shutdown grace period and the backup of its volumes
+		r.releaseVMIP(&hvScope)
+
+		// VM is gone — clean up the PVCs recorded in the status, or any orphaned
+		// PVCs by name prefix for machines without volume status, unless the
+		// deletion policy keeps them
+		switch {
+		case effectiveDeletionPolicy(&hvScope) == infrav1.DeletionPolicyRetain:
+			logger.Info("No VM found, retaining its PVCs")
+
+			retainPVCs(&hvScope, targetNS)
+		case len(hvScope.HarvesterMachine.Status.Volumes) > 0:
+			logger.Info("No VM found, cleaning up orphaned PVCs")
+
+			deleteVolumePVCs(&hvScope, targetNS)
+		default:
+			logger.Info("No VM found, cleaning up orphaned PVCs")
+
+			r.deletePVCsByPrefix(hvScope.Ctx, &hvScope, targetNS, machineName+"-disk-")
+		}
 	} else {
 		logger.V(5).Info("found VM: " + vm.Namespace + "/" + vm.Name)
 
 		if (vm != &kubevirtv1.VirtualMachine{}) {
+			// Let the guest shut down cleanly before deleting the VM
+			if !shutDownVM(&hvScope, vm) {
+				return ctrl.Result{RequeueAfter: requeueDelay}, nil
+			}
+
+			// Back the stopped VM up when the deletion policy asks for it
+			if !backUpVM(&hvScope, vm) {
+				return ctrl.Result{RequeueAfter: requeueDelay}, nil
+			}
+
 			// Delete VM first
 			err = hvScope.HarvesterClient.KubevirtV1().VirtualMachines(targetNS).Delete(
 				hvScope.Ctx, machineName, metav1.DeleteOptions{})
@@ -34,6 +34,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
 	"k8s.io/utils/ptr"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
@@ -636,10 +637,10 @@ var _ = Describe("buildAffinity", func() {
 })
 
 // =============================================================================
-// Tests for getCloudInitData
+// Tests for getBootstrapData
 // =============================================================================
 
-var _ = Describe("getCloudInitData", func() {
+var _ = Describe("getBootstrapData", func() {
 	It("should retrieve cloud-init data from bootstrap secret", func() {
 		scheme := runtime.NewScheme()
 		_ = corev1.AddToScheme(scheme)
@@ -662,7 +663,7 @@ var _ = Describe("getCloudInitData", func() {
 			ReconcilerClient: fakeClient,
 		}
 
-		data, err := getCloudInitData(scope)
+		data, _, err := getBootstrapData(scope)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(data).To(ContainSubstring("echo hello"))
 		Expect(data).To(ContainSubstring("runcmd:"))
@@ -685,7 +686,7 @@ var _ = Describe("getCloudInitData", func() {
 			ReconcilerClient: fakeClient,
 		}
 
-		_, err := getCloudInitData(scope)
+		_, _, err := getBootstrapData(scope)
 		Expect(err).To(HaveOccurred())
 	})
 
@@ -711,7 +712,7 @@ var _ = Describe("getCloudInitData", func() {
 			ReconcilerClient: fakeClient,
 		}
 
-		_, err := getCloudInitData(scope)
+		_, _, err := getBootstrapData(scope)
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(ContainSubstring("no userData key found"))
 	})
@@ -738,7 +739,7 @@ var _ = Describe("getCloudInitData", func() {
 			ReconcilerClient: fakeClient,
 		}
 
-		data, err := getCloudInitData(scope)
+		data, _, err := getBootstrapData(scope)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(data).To(Equal(""))
 	})
@@ -819,7 +820,7 @@ var _ = Describe("buildPVCForVolume", func() {
 				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
 			},
 		}
-		pvc, err := buildPVCForVolume(vol, "test-pvc", "default", scope)
+		pvc, err := buildPVCForVolume(vol, nil, "test-pvc", "default", scope)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(pvc.Name).To(Equal("test-pvc"))
 		Expect(pvc.Namespace).To(Equal("default"))
@@ -844,7 +845,7 @@ var _ = Describe("buildPVCForVolume", func() {
 				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "ns1"},
 			},
 		}
-		pvc, err := buildPVCForVolume(vol, "big-disk-0-xyz", "ns1", scope)
+		pvc, err := buildPVCForVolume(vol, nil, "big-disk-0-xyz", "ns1", scope)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(pvc.Name).To(Equal("big-disk-0-xyz"))
 		Expect(pvc.Namespace).To(Equal("ns1"))
@@ -865,12 +866,33 @@ var _ = Describe("buildPVCForVolume", func() {
 				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
 			},
 		}
-		pvc, err := buildPVCForVolume(vol, "data-pvc", "default", scope)
+		pvc, err := buildPVCForVolume(vol, nil, "data-pvc", "default", scope)
 		Expect(err).ToNot(HaveOccurred())
 		// storageClass type should NOT have imageId annotation
 		_, hasImageAnnotation := pvc.Annotations[hvAnnotationImageID]
 		Expect(hasImageAnnotation).To(BeFalse())
 	})
+
+	It("should use the access and volume modes of the volume", func() {
+		size := resource.MustParse("20Gi")
+		vol := &infrav1.Volume{
+			VolumeType:   "storageClass",
+			StorageClass: "local-path",
+			VolumeSize:   &size,
+			AccessMode:   corev1.ReadWriteOnce,
+			VolumeMode:   corev1.PersistentVolumeFilesystem,
+		}
+		scope := &Scope{
+			HarvesterMachine: &infrav1.HarvesterMachine{},
+			HarvesterCluster: &infrav1.HarvesterCluster{
+				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
+			},
+		}
+		pvc, err := buildPVCForVolume(vol, nil, "data-pvc", "default", scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Spec.AccessModes).To(Equal([]corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}))
+		Expect(*pvc.Spec.VolumeMode).To(Equal(corev1.PersistentVolumeFilesystem))
+	})
 })
 
 // =============================================================================
@@ -2844,7 +2866,7 @@ var _ = Describe("buildPVCForVolume edge cases", func() {
 				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
 			},
 		}
-		pvc, err := buildPVCForVolume(vol, "test-pvc", "default", scope)
+		pvc, err := buildPVCForVolume(vol, nil, "test-pvc", "default", scope)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(pvc.Name).To(Equal("test-pvc"))
 		// Unknown type should not set StorageClassName
@@ -2864,7 +2886,7 @@ var _ = Describe("buildPVCForVolume edge cases", func() {
 				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
 			},
 		}
-		pvc, err := buildPVCForVolume(vol, "boot-disk", "default", scope)
+		pvc, err := buildPVCForVolume(vol, nil, "boot-disk", "default", scope)
 		Expect(err).ToNot(HaveOccurred())
 		// storageClass type should NOT have imageId annotation
 		_, hasImageAnnotation := pvc.Annotations[hvAnnotationImageID]
@@ -2878,6 +2900,17 @@ var _ = Describe("buildPVCForVolume edge cases", func() {
 // =============================================================================
 
 var _ = Describe("buildPVCForVolume with image type", func() {
+	newImageScope := func(images ...runtime.Object) *Scope {
+		return &Scope{
+			Ctx:              context.TODO(),
+			HarvesterMachine: &infrav1.HarvesterMachine{},
+			HarvesterCluster: &infrav1.HarvesterCluster{
+				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
+			},
+			HarvesterClient: hvfake.NewSimpleClientset(images...),
+		}
+	}
+
 	It("should build a PVC for image volume type", func() {
 		size := resource.MustParse("40Gi")
 		vol := &infrav1.Volume{
@@ -2896,17 +2929,12 @@ var _ = Describe("buildPVCForVolume with image type", func() {
 				DisplayName: "test-image-display",
 			},
 		}
-		hvClient := hvfake.NewSimpleClientset(testImage)
+		scope := newImageScope(testImage)
 
-		scope := &Scope{
-			HarvesterMachine: &infrav1.HarvesterMachine{},
-			HarvesterCluster: &infrav1.HarvesterCluster{
-				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
-			},
-			HarvesterClient: hvClient,
-		}
+		vmImage, err := resolveVolumeImage(vol, "default", scope)
+		Expect(err).ToNot(HaveOccurred())
 
-		pvc, err := buildPVCForVolume(vol, "test-pvc", "default", scope)
+		pvc, err := buildPVCForVolume(vol, vmImage, "test-pvc", "default", scope)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(*pvc.Spec.StorageClassName).To(Equal("longhorn-image-abc123"))
 		Expect(pvc.Annotations[hvAnnotationImageID]).To(Equal("default/image-abc123"))
@@ -2929,43 +2957,49 @@ var _ = Describe("buildPVCForVolume with image type", func() {
 				DisplayName: "some-other-display-name",
 			},
 		}
-		hvClient := hvfake.NewSimpleClientset(testImage)
+		scope := newImageScope(testImage)
 
-		scope := &Scope{
-			HarvesterMachine: &infrav1.HarvesterMachine{},
-			HarvesterCluster: &infrav1.HarvesterCluster{
-				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
-			},
-			HarvesterClient: hvClient,
-		}
+		vmImage, err := resolveVolumeImage(vol, "default", scope)
+		Expect(err).ToNot(HaveOccurred())
 
-		pvc, err := buildPVCForVolume(vol, "test-pvc", "default", scope)
+		pvc, err := buildPVCForVolume(vol, vmImage, "test-pvc", "default", scope)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(*pvc.Spec.StorageClassName).To(Equal("longhorn-image-xyz789"))
 		Expect(pvc.Annotations[hvAnnotationImageID]).To(Equal("default/image-xyz789"))
 	})
 
-	It("should return error when image not found", func() {
-		size := resource.MustParse("40Gi")
+	It("should not look the image up again", func() {
 		vol := &infrav1.Volume{
 			VolumeType: "image",
-			ImageName:  "default/nonexistent-image",
-			VolumeSize: &size,
+			ImageName:  "default/image-xyz789",
 		}
 
-		hvClient := hvfake.NewSimpleClientset() // no images
+		vmImage := &harvesterv1beta1.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{Name: "image-xyz789", Namespace: "default"},
+			Status:     harvesterv1beta1.VirtualMachineImageStatus{StorageClassName: "lh-xyz789"},
+		}
+		scope := newImageScope()
 
-		scope := &Scope{
-			HarvesterMachine: &infrav1.HarvesterMachine{},
-			HarvesterCluster: &infrav1.HarvesterCluster{
-				Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
-			},
-			HarvesterClient: hvClient,
+		pvc, err := buildPVCForVolume(vol, vmImage, "test-pvc", "default", scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*pvc.Spec.StorageClassName).To(Equal("lh-xyz789"))
+		Expect(scope.HarvesterClient.(*hvfake.Clientset).Actions()).To(BeEmpty())
+	})
+
+	It("should return error when image not found", func() {
+		vol := &infrav1.Volume{
+			VolumeType: "image",
+			ImageName:  "default/nonexistent-image",
 		}
 
-		_, err := buildPVCForVolume(vol, "test-pvc", "default", scope)
+		scope := newImageScope() // no images
+
+		_, err := resolveVolumeImage(vol, "default", scope)
 		Expect(err).To(HaveOccurred())
-		Expect(err.Error()).To(ContainSubstring("unable to find VM image"))
+		Expect(err.Error()).To(ContainSubstring("VM image nonexistent-image not found"))
+
+		_, err = buildPVCForVolume(vol, nil, "test-pvc", "default", scope)
+		Expect(err).To(MatchError(ContainSubstring("is not resolved")))
 	})
 })
 
@@ -3560,6 +3594,38 @@ var _ = Describe("releaseVMIP", func() {
 // =============================================================================
 
 var _ = Describe("ReconcileDelete", func() {
+	newAllocatedPool := func() *lbv1beta1.IPPool {
+		return &lbv1beta1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool-1"},
+			Spec: lbv1beta1.IPPoolSpec{
+				Ranges: []lbv1beta1.Range{
+					{RangeStart: "172.16.4.40", RangeEnd: "172.16.4.49", Subnet: "172.16.0.0/16", Gateway: "172.16.0.1"},
+				},
+			},
+			Status: lbv1beta1.IPPoolStatus{
+				Allocated: map[string]string{"172.16.4.42": "test-ns/worker-0"},
+			},
+		}
+	}
+
+	newAllocatedScope := func(machine *infrav1.HarvesterMachine, objs ...runtime.Object) Scope {
+		machine.Finalizers = []string{infrav1.MachineFinalizer}
+		machine.Status.AllocatedIPAddress = "172.16.4.42"
+		machine.Status.AllocatedPoolRef = "pool-1"
+
+		scope := newVolumesScope(machine, append(objs, newAllocatedPool())...)
+		scope.Cluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+
+		return *scope
+	}
+
+	allocatedIPs := func(scope Scope) map[string]string {
+		pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), "pool-1", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		return pool.Status.Allocated
+	}
+
 	It("should delete VM, cloud-init secret, and PVCs", func() {
 		vm := &kubevirtv1.VirtualMachine{
 			ObjectMeta: metav1.ObjectMeta{Name: "test-cp-0", Namespace: "default"},
@@ -3713,4 +3779,66 @@ var _ = Describe("ReconcileDelete", func() {
 		Expect(err).To(HaveOccurred())
 		Expect(err.Error()).To(ContainSubstring("unable to remove finalizer"))
 	})
+
+	It("should keep the IP of the VM until the VM is gone", func(ctx SpecContext) {
+		vm := newVolumesVM()
+		vm.Spec.RunStrategy = new(kubevirtv1.RunStrategyAlways)
+		vmi := &kubevirtv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "default"}}
+		machine := newVolumesMachine()
+		machine.Spec.ShutdownGracePeriod = &metav1.Duration{Duration: 5 * time.Minute}
+		scope := newAllocatedScope(machine, vm, vmi)
+
+		r := &HarvesterMachineReconciler{}
+
+		// The guest is shutting down
+		_, err := r.ReconcileDelete(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocatedIPs(scope)).To(HaveKey("172.16.4.42"))
+
+		// The guest shut down, the VM is being deleted
+		Expect(scope.HarvesterClient.KubevirtV1().VirtualMachineInstances("default").Delete(
+			ctx, "worker-0", metav1.DeleteOptions{})).To(Succeed())
+
+		_, err = r.ReconcileDelete(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocatedIPs(scope)).To(HaveKey("172.16.4.42"))
+
+		// The VM is gone
+		_, err = r.ReconcileDelete(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocatedIPs(scope)).To(BeEmpty())
+		Expect(machine.Finalizers).To(BeEmpty())
+	})
+
+	It("should keep the IP of the VM during its deletion backup", func(ctx SpecContext) {
+		machine := newVolumesMachine()
+		machine.Spec.DeletionPolicy = infrav1.DeletionPolicySnapshot
+		scope := newAllocatedScope(machine, newVolumesVM())
+		scope.Recorder = events.NewFakeRecorder(10)
+
+		r := &HarvesterMachineReconciler{}
+
+		// The backup is in progress
+		_, err := r.ReconcileDelete(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocatedIPs(scope)).To(HaveKey("172.16.4.42"))
+
+		// The backup is ready, the VM is being deleted
+		backups := scope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineBackups("default")
+		backup, err := backups.Get(ctx, "worker-0-deletion", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		backup.Status = &harvesterv1beta1.VirtualMachineBackupStatus{ReadyToUse: new(true)}
+		_, err = backups.Update(ctx, backup, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		_, err = r.ReconcileDelete(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocatedIPs(scope)).To(HaveKey("172.16.4.42"))
+
+		// The VM is gone
+		_, err = r.ReconcileDelete(scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(allocatedIPs(scope)).To(BeEmpty())
+	})
 })
@@ -0,0 +1,802 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/paused"
+	"sigs.k8s.io/cluster-api/util/predicates"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+	harvclient "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+const (
+	// poolVMLabelName and poolVMLabelNamespace identify the HarvesterMachinePool
+	// owning a VM, so that VMs created by a reconcile whose status update was
+	// lost are adopted instead of leaked.
+	poolVMLabelName      = "harvestermachinepool/name"
+	poolVMLabelNamespace = "harvestermachinepool/namespace"
+)
+
+// HarvesterMachinePoolReconciler reconciles a HarvesterMachinePool object.
+type HarvesterMachinePoolReconciler struct {
+	client.Client
+
+	Scheme *runtime.Scheme
+}
+
+// MachinePoolScope stores context data for the machine pool reconciler.
+type MachinePoolScope struct {
+	Ctx                  context.Context
+	Cluster              *clusterv1.Cluster
+	MachinePool          *clusterv1.MachinePool
+	HarvesterCluster     *infrav1.HarvesterCluster
+	HarvesterMachinePool *infrav1.HarvesterMachinePool
+	HarvesterClient      harvclient.Interface
+	ReconcilerClient     client.Client
+	Logger               *logr.Logger
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvestermachinepools,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvestermachinepools/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvestermachinepools/finalizers,verbs=update
+
+// Reconcile reconciles the HarvesterMachinePool object.
+func (r *HarvesterMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, rerr error) {
+	logger := log.FromContext(ctx)
+	ctx = ctrl.LoggerInto(ctx, logger)
+
+	logger.Info("Reconciling HarvesterMachinePool ...")
+
+	hvPool := &infrav1.HarvesterMachinePool{}
+
+	err := r.Get(ctx, req.NamespacedName, hvPool)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("harvestermachinepool not found")
+
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Error happened when getting harvestermachinepool")
+
+		return ctrl.Result{}, err
+	}
+
+	patchHelper, err := patch.NewHelper(hvPool, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Always attempt to Patch the HarvesterMachinePool object and status after each reconciliation.
+	defer func() {
+		err := patchHelper.Patch(ctx, hvPool)
+		if err != nil {
+			logger.Error(err, "failed to patch HarvesterMachinePool")
+
+			if rerr == nil {
+				rerr = err
+			}
+		}
+	}()
+
+	ownerMachinePool, err := util.GetOwnerMachinePool(ctx, r.Client, hvPool.ObjectMeta)
+	if err != nil {
+		logger.Error(err, "unable to get owner machine pool")
+
+		return ctrl.Result{}, err
+	}
+
+	if ownerMachinePool == nil {
+		logger.Info("Waiting for MachinePool Controller to set OwnerRef on HarvesterMachinePool")
+
+		return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+	}
+
+	ownerCluster, err := util.GetClusterFromMetadata(ctx, r.Client, ownerMachinePool.ObjectMeta)
+	if err != nil {
+		logger.Info("HarvesterMachinePool owner MachinePool is missing cluster label or cluster does not exist")
+
+		return ctrl.Result{}, err
+	}
+
+	if ownerCluster == nil {
+		logger.Info("Please associate this machine pool with a cluster using the label " + clusterv1.ClusterNameLabel + ": <name of cluster>")
+
+		return ctrl.Result{}, nil
+	}
+
+	logger = logger.WithValues("machinePool", ownerMachinePool.Namespace+"/"+ownerMachinePool.Name,
+		"cluster", ownerCluster.Namespace+"/"+ownerCluster.Name)
+	ctx = ctrl.LoggerInto(ctx, logger)
+
+	isPaused, requeuePaused, err := paused.EnsurePausedCondition(ctx, r.Client, ownerCluster, hvPool)
+	if err != nil || isPaused || requeuePaused {
+		return ctrl.Result{}, err
+	}
+
+	hvCluster := &infrav1.HarvesterCluster{}
+
+	hvClusterKey := types.NamespacedName{
+		Namespace: ownerCluster.Namespace,
+		Name:      ownerCluster.Spec.InfrastructureRef.Name,
+	}
+
+	err = r.Get(ctx, hvClusterKey, hvCluster)
+	if err != nil {
+		logger.Error(err, "unable to find corresponding harvestercluster to harvestermachinepool")
+
+		return ctrl.Result{}, err
+	}
+
+	hvSecret, err := locutil.GetSecretForHarvesterConfig(ctx, hvCluster, r.Client)
+	if err != nil {
+		logger.Error(err, "unable to get Datasource secret")
+
+		return ctrl.Result{}, err
+	}
+
+	hvClient, err := locutil.GetHarvesterClientFromSecret(hvSecret)
+	if err != nil {
+		logger.Error(err, "unable to create Harvester client from Datasource secret "+hvSecret.Name)
+
+		return ctrl.Result{}, err
+	}
+
+	poolScope := &MachinePoolScope{
+		Ctx:                  ctx,
+		Cluster:              ownerCluster,
+		MachinePool:          ownerMachinePool,
+		HarvesterCluster:     hvCluster,
+		HarvesterMachinePool: hvPool,
+		HarvesterClient:      hvClient,
+		ReconcilerClient:     r.Client,
+		Logger:               &logger,
+	}
+
+	if !hvPool.DeletionTimestamp.IsZero() {
+		return r.ReconcileDelete(poolScope) //nolint:contextcheck
+	}
+
+	return r.ReconcileNormal(poolScope) //nolint:contextcheck
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HarvesterMachinePoolReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager) error {
+	clusterToHarvesterMachinePool, err := util.ClusterToTypedObjectsMapper(
+		mgr.GetClient(), &infrav1.HarvesterMachinePoolList{}, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.HarvesterMachinePool{}).
+		Owns(&ipamv1.IPAddressClaim{}).
+		Watches(
+			&clusterv1.MachinePool{},
+			handler.EnqueueRequestsFromMapFunc(util.MachinePoolToInfrastructureMapFunc(ctx,
+				infrav1.GroupVersion.WithKind("HarvesterMachinePool"))),
+		).
+		Watches(
+			&clusterv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(clusterToHarvesterMachinePool),
+			builder.WithPredicates(predicates.ClusterPausedTransitions(mgr.GetScheme(), ctrl.LoggerFrom(ctx))),
+		).
+		Complete(r)
+}
+
+// ReconcileNormal scales the VMs of the HarvesterMachinePool to the replicas of
+// the owner MachinePool and reports their state.
+func (r *HarvesterMachinePoolReconciler) ReconcileNormal(poolScope *MachinePoolScope) (ctrl.Result, error) {
+	pool := poolScope.HarvesterMachinePool
+	logger := poolScope.Logger
+
+	if !controllerutil.ContainsFinalizer(pool, infrav1.MachinePoolFinalizer) {
+		controllerutil.AddFinalizer(pool, infrav1.MachinePoolFinalizer)
+
+		return ctrl.Result{}, nil
+	}
+
+	infraProvisioned := poolScope.Cluster.Status.Initialization.InfrastructureProvisioned
+	if infraProvisioned == nil || !*infraProvisioned {
+		logger.Info("Waiting for Infrastructure to be ready ... ")
+
+		return ctrl.Result{RequeueAfter: 1 * time.Minute}, nil
+	}
+
+	if poolScope.MachinePool.Spec.Template.Spec.Bootstrap.DataSecretName == nil {
+		logger.Info("Waiting for MachinePool's Userdata to be set ... ")
+
+		return ctrl.Result{RequeueAfter: 1 * time.Minute}, nil
+	}
+
+	err := adoptPoolInstances(poolScope)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	desired := desiredPoolReplicas(poolScope.MachinePool)
+	scalePoolInstances(pool, desired)
+
+	var firstErr error
+
+	kept := make([]infrav1.HarvesterMachinePoolInstanceStatus, 0, len(pool.Status.Instances))
+	deleting := false
+	initialized := true
+
+	for i := range pool.Status.Instances {
+		instance := pool.Status.Instances[i]
+
+		if instance.State == infrav1.InstanceStateDeleting {
+			gone, err := r.deletePoolInstance(poolScope, &instance)
+			if err != nil {
+				logger.Error(err, "unable to delete machine pool instance", "instance", instance.InstanceName)
+
+				if firstErr == nil {
+					firstErr = err
+				}
+			}
+
+			if !gone {
+				kept = append(kept, instance)
+				deleting = true
+			}
+
+			continue
+		}
+
+		ready, err := r.reconcilePoolInstance(poolScope, &instance)
+		if err != nil {
+			logger.Error(err, "unable to reconcile machine pool instance", "instance", instance.InstanceName)
+
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+
+		if !ready {
+			initialized = false
+		}
+
+		kept = append(kept, instance)
+	}
+
+	pool.Status.Instances = kept
+
+	updatePoolStatus(pool, desired)
+
+	if firstErr != nil {
+		return ctrl.Result{}, firstErr
+	}
+
+	if deleting {
+		return ctrl.Result{RequeueAfter: requeueDelay}, nil
+	}
+
+	if !initialized {
+		// Same as for machines: nothing else triggers a reconcile once the
+		// node of a new instance registers, so requeue until every node is
+		// initialized.
+		return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// ReconcileDelete deletes all the VMs of a HarvesterMachinePool and removes its finalizer once they are gone.
+func (r *HarvesterMachinePoolReconciler) ReconcileDelete(poolScope *MachinePoolScope) (ctrl.Result, error) {
+	pool := poolScope.HarvesterMachinePool
+	logger := poolScope.Logger
+
+	logger.Info("Deleting HarvesterMachinePool ...")
+
+	err := adoptPoolInstances(poolScope)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	kept := make([]infrav1.HarvesterMachinePoolInstanceStatus, 0, len(pool.Status.Instances))
+
+	for i := range pool.Status.Instances {
+		instance := pool.Status.Instances[i]
+		instance.State = infrav1.InstanceStateDeleting
+
+		gone, err := r.deletePoolInstance(poolScope, &instance)
+		if err != nil {
+			return ctrl.Result{Requeue: true}, err
+		}
+
+		if !gone {
+			kept = append(kept, instance)
+		}
+	}
+
+	pool.Status.Instances = kept
+	pool.Spec.ProviderIDList = nil
+	pool.Status.Replicas = int32(len(kept)) //nolint:gosec // bounded by the MachinePool replicas
+
+	if len(kept) > 0 {
+		logger.Info("Waiting for the VMs of the pool to terminate", "remaining", len(kept))
+
+		return ctrl.Result{RequeueAfter: requeueDelay}, nil
+	}
+
+	controllerutil.RemoveFinalizer(pool, infrav1.MachinePoolFinalizer)
+
+	return ctrl.Result{}, nil
+}
+
+// reconcilePoolInstance makes sure the VM of an instance exists and reports
+// its state. It returns true once the VM runs and the workload cluster node of
+// the instance is initialized.
+func (r *HarvesterMachinePoolReconciler) reconcilePoolInstance(
+	poolScope *MachinePoolScope, instance *infrav1.HarvesterMachinePoolInstanceStatus,
+) (bool, error) {
+	hvScope := poolScope.instanceScope(instance)
+	machineReconciler := &HarvesterMachineReconciler{Client: r.Client, Scheme: r.Scheme}
+
+	allocErr := machineReconciler.resolveNetworkConfig(hvScope)
+
+	instance.AllocatedIPAddress = hvScope.HarvesterMachine.Status.AllocatedIPAddress
+	instance.AllocatedPoolRef = hvScope.HarvesterMachine.Status.AllocatedPoolRef
+	instance.AllocatedIPv6Address = hvScope.HarvesterMachine.Status.AllocatedIPv6Address
+	instance.AllocatedIPv6PoolRef = hvScope.HarvesterMachine.Status.AllocatedIPv6PoolRef
+	instance.InterfaceAllocations = hvScope.HarvesterMachine.Status.InterfaceAllocations
+
+	if errors.Is(allocErr, errAddressClaimsPending) {
+		poolScope.Logger.Info("Waiting for the IPAddressClaims of instance to be bound", "instance", instance.InstanceName)
+
+		return false, nil
+	}
+
+	if allocErr != nil {
+		conditions.Set(poolScope.HarvesterMachinePool, metav1.Condition{
+			Type:    infrav1.VMIPAllocatedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.VMIPAllocationFailedReason,
+			Message: fmt.Sprintf("Failed to allocate VM IP for instance %s: %v", instance.InstanceName, allocErr),
+		})
+
+		return false, errors.Wrapf(allocErr, "failed to allocate VM IP for instance %s", instance.InstanceName)
+	}
+
+	targetNS := poolScope.HarvesterCluster.Spec.TargetNamespace
+
+	vm, err := poolScope.HarvesterClient.KubevirtV1().VirtualMachines(targetNS).Get(
+		poolScope.Ctx, instance.InstanceName, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "unable to get VM of instance %s", instance.InstanceName)
+		}
+
+		if instance.ProviderID != "" {
+			// The VM was deleted outside of CAPI: recreate it, as a new node
+			poolScope.Logger.Info("VM of machine pool instance is missing, recreating it", "instance", instance.InstanceName)
+
+			instance.ProviderID = ""
+			instance.Addresses = nil
+			hvScope.HarvesterMachine.Spec.ProviderID = ""
+		}
+
+		instance.State = infrav1.InstanceStateProvisioning
+
+		imagesReady, importErr := reconcileImageImports(hvScope)
+		if importErr != nil {
+			return false, errors.Wrapf(importErr, "unable to import the VM images of instance %s", instance.InstanceName)
+		}
+
+		if !imagesReady {
+			poolScope.Logger.Info("Waiting for the VM images of instance to be imported", "instance", instance.InstanceName)
+
+			return false, nil
+		}
+
+		caphvmetrics.MachineCreateTotal.Inc()
+
+		createStart := time.Now()
+
+		_, err = createVMFromHarvesterMachine(hvScope)
+		if err != nil {
+			caphvmetrics.MachineCreateErrorsTotal.Inc()
+
+			return false, errors.Wrapf(err, "unable to create VM of instance %s", instance.InstanceName)
+		}
+
+		caphvmetrics.MachineCreationDuration.Observe(time.Since(createStart).Seconds())
+		poolScope.Logger.Info("Created VM for machine pool instance", "instance", instance.InstanceName)
+
+		instance.State = infrav1.InstanceStateProvisioning
+
+		return false, nil
+	}
+
+	if !vm.DeletionTimestamp.IsZero() {
+		poolScope.Logger.Info("VM of machine pool instance is being deleted, recreating it once gone", "instance", instance.InstanceName)
+
+		instance.State = infrav1.InstanceStateProvisioning
+
+		return false, nil
+	}
+
+	if !isVMRunning(vm) {
+		instance.State = infrav1.InstanceStateProvisioning
+
+		return false, nil
+	}
+
+	if instance.State == infrav1.InstanceStateRunning {
+		if vm.Status.Ready {
+			return true, nil
+		}
+
+		poolScope.Logger.Info("VM of running machine pool instance is no longer ready", "instance", instance.InstanceName)
+
+		instance.State = infrav1.InstanceStateProvisioning
+
+		return false, nil
+	}
+
+	addresses, err := getIPAddressesFromVMI(poolScope.Ctx, vm, poolScope.HarvesterClient)
+	if err != nil || len(addresses) == 0 {
+		poolScope.Logger.V(1).Info("VM of instance has no IP address yet", "instance", instance.InstanceName)
+
+		return false, nil
+	}
+
+	instance.Addresses = addresses
+
+	if instance.ProviderID == "" {
+		providerID, err := getProviderIDFromWorkloadCluster(hvScope)
+		if err != nil || providerID == "" {
+			providerID = "harvester://" + string(vm.UID)
+		}
+
+		instance.ProviderID = providerID
+		hvScope.HarvesterMachine.Spec.ProviderID = providerID
+	}
+
+	if !machineReconciler.initializeWorkloadNode(hvScope) {
+		return false, nil
+	}
+
+	instance.State = infrav1.InstanceStateRunning
+
+	return true, nil
+}
+
+// deletePoolInstance shuts the guest of an instance down, backs it up with the
+// Snapshot deletion policy and deletes its VM, then releases its IP and
+// deletes its volumes, unless the deletion policy retains them. It returns
+// true once the VM is gone and its volumes are handled.
+func (r *HarvesterMachinePoolReconciler) deletePoolInstance(
+	poolScope *MachinePoolScope, instance *infrav1.HarvesterMachinePoolInstanceStatus,
+) (bool, error) {
+	hvScope := poolScope.instanceScope(instance)
+	machineReconciler := &HarvesterMachineReconciler{Client: r.Client, Scheme: r.Scheme}
+	targetNS := poolScope.HarvesterCluster.Spec.TargetNamespace
+
+	err := poolScope.HarvesterClient.CoreV1().Secrets(targetNS).Delete(
+		poolScope.Ctx, instance.InstanceName+"-cloud-init", metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, errors.Wrapf(err, "unable to delete cloud-init secret of instance %s", instance.InstanceName)
+	}
+
+	vm, err := poolScope.HarvesterClient.KubevirtV1().VirtualMachines(targetNS).Get(
+		poolScope.Ctx, instance.InstanceName, metav1.GetOptions{})
+	if err == nil {
+		// Let the guest shut down cleanly before deleting the VM. The
+		// in-memory HarvesterMachine is rebuilt on every reconcile, so the
+		// Deleting condition, which holds the start of the grace period, is
+		// kept in the instance status.
+		shutDown := shutDownVM(hvScope, vm)
+		instance.Conditions = hvScope.HarvesterMachine.Status.Conditions
+
+		if !shutDown {
+			return false, nil
+		}
+
+		// Back the stopped VM up when the deletion policy asks for it
+		backedUp := backUpVM(hvScope, vm)
+		instance.Conditions = hvScope.HarvesterMachine.Status.Conditions
+
+		if !backedUp {
+			return false, nil
+		}
+
+		err = poolScope.HarvesterClient.KubevirtV1().VirtualMachines(targetNS).Delete(
+			poolScope.Ctx, instance.InstanceName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return false, errors.Wrapf(err, "unable to delete VM of instance %s", instance.InstanceName)
+		}
+
+		poolScope.Logger.Info("VM delete requested for machine pool instance", "instance", instance.InstanceName)
+
+		return false, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return false, errors.Wrapf(err, "unable to get VM of instance %s", instance.InstanceName)
+	}
+
+	// Release once the VM is gone, so that its address is not handed out
+	// while the guest still uses it, and only once: the pool store does not
+	// check the owner of an address, and it may already be allocated to
+	// another machine.
+	machineReconciler.releaseVMIP(hvScope)
+
+	instance.AllocatedIPAddress = ""
+	instance.AllocatedPoolRef = ""
+	instance.AllocatedIPv6Address = ""
+	instance.AllocatedIPv6PoolRef = ""
+	instance.InterfaceAllocations = nil
+
+	if effectiveDeletionPolicy(hvScope) == infrav1.DeletionPolicyRetain {
+		retainPVCs(hvScope, targetNS)
+	} else {
+		machineReconciler.deletePVCsByPrefix(poolScope.Ctx, hvScope, targetNS, instance.InstanceName+"-disk-")
+	}
+
+	return true, nil
+}
+
+// instanceScope builds the machine Scope of a pool instance, so that the VM
+// building path of HarvesterMachine (createVMFromHarvesterMachine,
+// buildVMTemplate, IP pool allocation) is reused as is. The instance is
+// represented by an in-memory HarvesterMachine carrying the pool template and
+// by a Machine carrying the bootstrap data of the MachinePool.
+func (s *MachinePoolScope) instanceScope(instance *infrav1.HarvesterMachinePoolInstanceStatus) *Scope {
+	pool := s.HarvesterMachinePool
+
+	hvMachine := &infrav1.HarvesterMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.InstanceName,
+			Namespace: pool.Namespace,
+		},
+		Spec: *pool.Spec.Template.Spec.DeepCopy(),
+		Status: infrav1.HarvesterMachineStatus{
+			AllocatedIPAddress:   instance.AllocatedIPAddress,
+			AllocatedPoolRef:     instance.AllocatedPoolRef,
+			AllocatedIPv6Address: instance.AllocatedIPv6Address,
+			AllocatedIPv6PoolRef: instance.AllocatedIPv6PoolRef,
+			InterfaceAllocations: instance.InterfaceAllocations,
+			Conditions:           instance.Conditions,
+		},
+	}
+
+	// A static address cannot be shared by several instances, and placement
+	// and identity are per instance.
+	hvMachine.Spec.NetworkConfig = nil
+	hvMachine.Spec.FailureDomain = ""
+	hvMachine.Spec.ProviderID = instance.ProviderID
+
+	// The resources kept by the deletion policy are labelled with the cluster
+	if s.Cluster != nil {
+		hvMachine.Labels = map[string]string{clusterv1.ClusterNameLabel: s.Cluster.Name}
+	}
+
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      instance.InstanceName,
+			Namespace: pool.Namespace,
+		},
+	}
+
+	if s.MachinePool != nil {
+		machine.Spec.Bootstrap = s.MachinePool.Spec.Template.Spec.Bootstrap
+	}
+
+	return &Scope{
+		Ctx:              s.Ctx,
+		Cluster:          s.Cluster,
+		Machine:          machine,
+		HarvesterCluster: s.HarvesterCluster,
+		HarvesterMachine: hvMachine,
+		HarvesterClient:  s.HarvesterClient,
+		ReconcilerClient: s.ReconcilerClient,
+		Logger:           s.Logger,
+		ClaimOwner:       pool,
+		VMLabels: map[string]string{
+			poolVMLabelName:      pool.Name,
+			poolVMLabelNamespace: pool.Namespace,
+		},
+	}
+}
+
+// adoptPoolInstances adds to the pool status the VMs labeled as belonging to
+// the pool but missing from it, which happens when the status update following
+// their creation was lost.
+func adoptPoolInstances(poolScope *MachinePoolScope) error {
+	pool := poolScope.HarvesterMachinePool
+
+	selector := labels.SelectorFromSet(labels.Set{
+		poolVMLabelName:      pool.Name,
+		poolVMLabelNamespace: pool.Namespace,
+	})
+
+	vms, err := poolScope.HarvesterClient.KubevirtV1().VirtualMachines(poolScope.HarvesterCluster.Spec.TargetNamespace).List(
+		poolScope.Ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return errors.Wrap(err, "unable to list the VMs of the machine pool")
+	}
+
+	known := make(map[string]struct{}, len(pool.Status.Instances))
+	for _, instance := range pool.Status.Instances {
+		known[instance.InstanceName] = struct{}{}
+	}
+
+	for _, vm := range vms.Items {
+		if _, ok := known[vm.Name]; ok {
+			continue
+		}
+
+		poolScope.Logger.Info("Adopting VM of the machine pool missing from its status", "vm", vm.Name)
+
+		pool.Status.Instances = append(pool.Status.Instances, infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: vm.Name,
+			State:        infrav1.InstanceStateProvisioning,
+		})
+	}
+
+	return nil
+}
+
+// desiredPoolReplicas returns the number of instances requested by the MachinePool, defaulting to 1.
+func desiredPoolReplicas(machinePool *clusterv1.MachinePool) int32 {
+	if machinePool.Spec.Replicas == nil {
+		return 1
+	}
+
+	return *machinePool.Spec.Replicas
+}
+
+// scalePoolInstances adds instances to or marks surplus instances for deletion
+// in the pool status so that the number of instances not being deleted matches
+// desired. Scaling down removes the instances that are not running first, then
+// the most recent ones.
+func scalePoolInstances(pool *infrav1.HarvesterMachinePool, desired int32) {
+	active := make([]int, 0, len(pool.Status.Instances))
+	names := make(map[string]struct{}, len(pool.Status.Instances))
+
+	for i, instance := range pool.Status.Instances {
+		names[instance.InstanceName] = struct{}{}
+
+		if instance.State != infrav1.InstanceStateDeleting {
+			active = append(active, i)
+		}
+	}
+
+	for surplus := len(active) - int(desired); surplus > 0; surplus-- {
+		victim := -1
+
+		for j := len(active) - 1; j >= 0; j-- {
+			if pool.Status.Instances[active[j]].State != infrav1.InstanceStateRunning {
+				victim = j
+
+				break
+			}
+		}
+
+		if victim < 0 {
+			victim = len(active) - 1
+		}
+
+		pool.Status.Instances[active[victim]].State = infrav1.InstanceStateDeleting
+		active = append(active[:victim], active[victim+1:]...)
+	}
+
+	for missing := int(desired) - len(active); missing > 0; missing-- {
+		name := pool.Name + "-" + locutil.RandomID()
+		for _, taken := names[name]; taken; _, taken = names[name] {
+			name = pool.Name + "-" + locutil.RandomID()
+		}
+
+		names[name] = struct{}{}
+
+		pool.Status.Instances = append(pool.Status.Instances, infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: name,
+			State:        infrav1.InstanceStateProvisioning,
+		})
+	}
+}
+
+// updatePoolStatus derives the CAPI contract fields (spec.providerIDList,
+// status.replicas, status.ready and status.initialization) and the
+// ReplicasReady condition from the instances of the pool.
+func updatePoolStatus(pool *infrav1.HarvesterMachinePool, desired int32) {
+	var active, running, deleting int32
+
+	providerIDs := make([]string, 0, len(pool.Status.Instances))
+
+	for _, instance := range pool.Status.Instances {
+		if instance.State == infrav1.InstanceStateDeleting {
+			deleting++
+
+			continue
+		}
+
+		active++
+
+		if instance.ProviderID != "" {
+			providerIDs = append(providerIDs, instance.ProviderID)
+		}
+
+		if instance.State == infrav1.InstanceStateRunning {
+			running++
+		}
+	}
+
+	sort.Strings(providerIDs)
+
+	pool.Spec.ProviderIDList = providerIDs
+	pool.Status.Replicas = active
+	pool.Status.Ready = active == desired && running == desired
+
+	if pool.Status.Ready {
+		pool.Status.Initialization.Provisioned = true
+	}
+
+	switch {
+	case active != desired || deleting > 0:
+		conditions.Set(pool, metav1.Condition{
+			Type:    infrav1.ReplicasReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.ReplicasScalingReason,
+			Message: fmt.Sprintf("Scaling to %d instances: %d active, %d being deleted", desired, active, deleting),
+		})
+	case running < desired:
+		conditions.Set(pool, metav1.Condition{
+			Type:    infrav1.ReplicasReadyCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.ReplicasNotRunningReason,
+			Message: fmt.Sprintf("%d of %d instances running", running, desired),
+		})
+	default:
+		conditions.Set(pool, metav1.Condition{
+			Type:    infrav1.ReplicasReadyCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.ReplicasReadyReason,
+			Message: fmt.Sprintf("%d instances running", running),
+		})
+	}
+}
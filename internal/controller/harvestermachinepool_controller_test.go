@@ -0,0 +1,573 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for HarvesterMachinePool (CAPI MachinePool infrastructure)
+// =============================================================================
+
+func newTestMachinePoolScope(hvObjects ...runtime.Object) *MachinePoolScope {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+
+	bootstrapSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: testBootstrapDataSecretName, Namespace: "test-ns"},
+		Data:       map[string][]byte{"value": []byte("runcmd:\n  - echo hello\n")},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(bootstrapSecret).Build()
+
+	sshKeyPair := &harvesterv1beta1.KeyPair{
+		ObjectMeta: metav1.ObjectMeta{Name: "capi-ssh-key", Namespace: "default"},
+		Spec:       harvesterv1beta1.KeyPairSpec{PublicKey: "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC test@test"},
+	}
+	hvClient := hvfake.NewSimpleClientset(append(hvObjects, sshKeyPair)...)
+	logger := log.FromContext(context.TODO())
+
+	dataSecretName := testBootstrapDataSecretName
+
+	return &MachinePoolScope{
+		Ctx:     context.TODO(),
+		Cluster: &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}},
+		MachinePool: &clusterv1.MachinePool{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "test-ns"},
+			Spec: clusterv1.MachinePoolSpec{
+				Replicas: ptr.To(int32(2)),
+				Template: clusterv1.MachineTemplateSpec{
+					Spec: clusterv1.MachineSpec{
+						Bootstrap: clusterv1.Bootstrap{DataSecretName: &dataSecretName},
+					},
+				},
+			},
+		},
+		HarvesterCluster: &infrav1.HarvesterCluster{
+			Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"},
+		},
+		HarvesterMachinePool: &infrav1.HarvesterMachinePool{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "test-ns"},
+			Spec: infrav1.HarvesterMachinePoolSpec{
+				Template: infrav1.HarvesterMachineTemplateResource{
+					Spec: infrav1.HarvesterMachineSpec{
+						CPU:        2,
+						Memory:     "4Gi",
+						SSHKeyPair: "default/capi-ssh-key",
+						Networks:   []string{"default/production"},
+						Volumes: []infrav1.Volume{
+							{VolumeType: "storageClass", StorageClass: "longhorn", VolumeSize: ptr.To(resource.MustParse("10Gi"))},
+						},
+					},
+				},
+			},
+		},
+		HarvesterClient:  hvClient,
+		ReconcilerClient: fakeClient,
+		Logger:           &logger,
+	}
+}
+
+var _ = Describe("scalePoolInstances", func() {
+	It("should add instances up to the desired count", func() {
+		pool := &infrav1.HarvesterMachinePool{ObjectMeta: metav1.ObjectMeta{Name: "workers"}}
+
+		scalePoolInstances(pool, 3)
+
+		Expect(pool.Status.Instances).To(HaveLen(3))
+
+		names := map[string]struct{}{}
+		for _, instance := range pool.Status.Instances {
+			Expect(instance.InstanceName).To(HavePrefix("workers-"))
+			Expect(instance.State).To(Equal(infrav1.InstanceStateProvisioning))
+			names[instance.InstanceName] = struct{}{}
+		}
+
+		Expect(names).To(HaveLen(3))
+	})
+
+	It("should remove instances that are not running first", func() {
+		pool := &infrav1.HarvesterMachinePool{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers"},
+			Status: infrav1.HarvesterMachinePoolStatus{
+				Instances: []infrav1.HarvesterMachinePoolInstanceStatus{
+					{InstanceName: "workers-a", State: infrav1.InstanceStateRunning},
+					{InstanceName: "workers-b", State: infrav1.InstanceStateProvisioning},
+					{InstanceName: "workers-c", State: infrav1.InstanceStateRunning},
+				},
+			},
+		}
+
+		scalePoolInstances(pool, 1)
+
+		Expect(pool.Status.Instances[0].State).To(Equal(infrav1.InstanceStateRunning))
+		Expect(pool.Status.Instances[1].State).To(Equal(infrav1.InstanceStateDeleting))
+		Expect(pool.Status.Instances[2].State).To(Equal(infrav1.InstanceStateDeleting))
+	})
+
+	It("should not count instances being deleted as active", func() {
+		pool := &infrav1.HarvesterMachinePool{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers"},
+			Status: infrav1.HarvesterMachinePoolStatus{
+				Instances: []infrav1.HarvesterMachinePoolInstanceStatus{
+					{InstanceName: "workers-a", State: infrav1.InstanceStateRunning},
+					{InstanceName: "workers-b", State: infrav1.InstanceStateDeleting},
+				},
+			},
+		}
+
+		scalePoolInstances(pool, 2)
+
+		Expect(pool.Status.Instances).To(HaveLen(3))
+		Expect(pool.Status.Instances[1].State).To(Equal(infrav1.InstanceStateDeleting))
+		Expect(pool.Status.Instances[2].State).To(Equal(infrav1.InstanceStateProvisioning))
+	})
+})
+
+var _ = Describe("updatePoolStatus", func() {
+	It("should report the provider IDs and mark the pool provisioned when all instances run", func() {
+		pool := &infrav1.HarvesterMachinePool{
+			Status: infrav1.HarvesterMachinePoolStatus{
+				Instances: []infrav1.HarvesterMachinePoolInstanceStatus{
+					{InstanceName: "workers-b", ProviderID: "harvester://uid-b", State: infrav1.InstanceStateRunning},
+					{InstanceName: "workers-a", ProviderID: "harvester://uid-a", State: infrav1.InstanceStateRunning},
+				},
+			},
+		}
+
+		updatePoolStatus(pool, 2)
+
+		Expect(pool.Spec.ProviderIDList).To(Equal([]string{"harvester://uid-a", "harvester://uid-b"}))
+		Expect(pool.Status.Replicas).To(Equal(int32(2)))
+		Expect(pool.Status.Ready).To(BeTrue())
+		Expect(pool.Status.Initialization.Provisioned).To(BeTrue())
+		Expect(conditions.IsTrue(pool, infrav1.ReplicasReadyCondition)).To(BeTrue())
+	})
+
+	It("should report scaling while instances are being deleted", func() {
+		pool := &infrav1.HarvesterMachinePool{
+			Status: infrav1.HarvesterMachinePoolStatus{
+				Initialization: infrav1.Initialization{Provisioned: true},
+				Instances: []infrav1.HarvesterMachinePoolInstanceStatus{
+					{InstanceName: "workers-a", ProviderID: "harvester://uid-a", State: infrav1.InstanceStateRunning},
+					{InstanceName: "workers-b", ProviderID: "harvester://uid-b", State: infrav1.InstanceStateDeleting},
+				},
+			},
+		}
+
+		updatePoolStatus(pool, 1)
+
+		Expect(pool.Spec.ProviderIDList).To(Equal([]string{"harvester://uid-a"}))
+		Expect(pool.Status.Replicas).To(Equal(int32(1)))
+		Expect(pool.Status.Ready).To(BeFalse())
+		// Initialization is a one-time signal and survives scaling.
+		Expect(pool.Status.Initialization.Provisioned).To(BeTrue())
+
+		cond := conditions.Get(pool, infrav1.ReplicasReadyCondition)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(infrav1.ReplicasScalingReason))
+	})
+
+	It("should report instances that are not running yet", func() {
+		pool := &infrav1.HarvesterMachinePool{
+			Status: infrav1.HarvesterMachinePoolStatus{
+				Instances: []infrav1.HarvesterMachinePoolInstanceStatus{
+					{InstanceName: "workers-a", State: infrav1.InstanceStateProvisioning},
+				},
+			},
+		}
+
+		updatePoolStatus(pool, 1)
+
+		Expect(pool.Spec.ProviderIDList).To(BeEmpty())
+		Expect(pool.Status.Ready).To(BeFalse())
+		Expect(pool.Status.Initialization.Provisioned).To(BeFalse())
+
+		cond := conditions.Get(pool, infrav1.ReplicasReadyCondition)
+		Expect(cond).ToNot(BeNil())
+		Expect(cond.Reason).To(Equal(infrav1.ReplicasNotRunningReason))
+	})
+})
+
+var _ = Describe("instanceScope", func() {
+	It("should build a machine scope from the pool template and the MachinePool bootstrap", func() {
+		poolScope := newTestMachinePoolScope()
+		poolScope.HarvesterMachinePool.Spec.Template.Spec.NetworkConfig = &infrav1.NetworkConfig{Address: "10.0.0.5"}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName:       "workers-abc1d",
+			ProviderID:         "harvester://uid",
+			AllocatedIPAddress: "172.16.3.40",
+			AllocatedPoolRef:   "capi-vm-pool",
+		}
+
+		hvScope := poolScope.instanceScope(instance)
+
+		Expect(hvScope.HarvesterMachine.Name).To(Equal("workers-abc1d"))
+		Expect(hvScope.HarvesterMachine.Namespace).To(Equal("test-ns"))
+		Expect(hvScope.HarvesterMachine.Spec.CPU).To(Equal(uint32(2)))
+		Expect(hvScope.HarvesterMachine.Spec.ProviderID).To(Equal("harvester://uid"))
+		// A static address cannot be shared by the instances of a pool.
+		Expect(hvScope.HarvesterMachine.Spec.NetworkConfig).To(BeNil())
+		Expect(hvScope.HarvesterMachine.Status.AllocatedIPAddress).To(Equal("172.16.3.40"))
+		Expect(hvScope.HarvesterMachine.Status.AllocatedPoolRef).To(Equal("capi-vm-pool"))
+		Expect(hvScope.Machine.Spec.Bootstrap.DataSecretName).To(HaveValue(Equal(testBootstrapDataSecretName)))
+		Expect(hvScope.VMLabels).To(HaveKeyWithValue(poolVMLabelName, "workers"))
+		Expect(hvScope.VMLabels).To(HaveKeyWithValue(poolVMLabelNamespace, "test-ns"))
+		// The pool template must not be modified.
+		Expect(poolScope.HarvesterMachinePool.Spec.Template.Spec.NetworkConfig).ToNot(BeNil())
+	})
+})
+
+var _ = Describe("reconcilePoolInstance", func() {
+	It("should create a labeled VM for a new instance", func() {
+		poolScope := newTestMachinePoolScope()
+		r := &HarvesterMachinePoolReconciler{}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: "workers-abc1d",
+			State:        infrav1.InstanceStateProvisioning,
+		}
+
+		ready, err := r.reconcilePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(instance.State).To(Equal(infrav1.InstanceStateProvisioning))
+
+		vm, err := poolScope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(
+			context.TODO(), "workers-abc1d", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vm.Labels).To(HaveKeyWithValue(poolVMLabelName, "workers"))
+		Expect(vm.Labels).To(HaveKeyWithValue(poolVMLabelNamespace, "test-ns"))
+		Expect(vm.Spec.Template.Spec.Hostname).To(Equal("workers-abc1d"))
+
+		_, err = poolScope.HarvesterClient.CoreV1().Secrets("default").Get(
+			context.TODO(), "workers-abc1d-cloud-init", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should allocate an IP per instance from the cluster VM IP pool", func() {
+		pool := &lbv1beta1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "capi-vm-pool"},
+			Spec: lbv1beta1.IPPoolSpec{
+				Ranges: []lbv1beta1.Range{
+					{RangeStart: "172.16.3.40", RangeEnd: "172.16.3.49", Subnet: "172.16.0.0/16", Gateway: "172.16.0.1"},
+				},
+			},
+			Status: lbv1beta1.IPPoolStatus{Allocated: map[string]string{}},
+		}
+		poolScope := newTestMachinePoolScope(pool)
+		poolScope.HarvesterCluster.Spec.VMNetworkConfig = &infrav1.VMNetworkConfig{
+			IPPoolRef:  "capi-vm-pool",
+			Gateway:    "172.16.0.1",
+			SubnetMask: "255.255.0.0",
+		}
+		r := &HarvesterMachinePoolReconciler{}
+		first := &infrav1.HarvesterMachinePoolInstanceStatus{InstanceName: "workers-aaa1a"}
+		second := &infrav1.HarvesterMachinePoolInstanceStatus{InstanceName: "workers-bbb2b"}
+
+		_, err := r.reconcilePoolInstance(poolScope, first)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = r.reconcilePoolInstance(poolScope, second)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(first.AllocatedIPAddress).ToNot(BeEmpty())
+		Expect(second.AllocatedIPAddress).ToNot(BeEmpty())
+		Expect(first.AllocatedIPAddress).ToNot(Equal(second.AllocatedIPAddress))
+		Expect(first.AllocatedPoolRef).To(Equal("capi-vm-pool"))
+
+		updated, err := poolScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
+			context.TODO(), "capi-vm-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Status.Allocated).To(HaveKeyWithValue(first.AllocatedIPAddress, "test-ns/workers-aaa1a"))
+		Expect(updated.Status.Allocated).To(HaveKeyWithValue(second.AllocatedIPAddress, "test-ns/workers-bbb2b"))
+	})
+
+	It("should keep an instance provisioning while its VM is not running", func() {
+		vm := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers-abc1d", Namespace: "default"},
+			Spec:       kubevirtv1.VirtualMachineSpec{RunStrategy: ptr.To(kubevirtv1.RunStrategyHalted)},
+		}
+		poolScope := newTestMachinePoolScope(vm)
+		r := &HarvesterMachinePoolReconciler{}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: "workers-abc1d",
+			State:        infrav1.InstanceStateRunning,
+		}
+
+		ready, err := r.reconcilePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(instance.State).To(Equal(infrav1.InstanceStateProvisioning))
+	})
+
+	It("should flag a running instance whose VM is no longer ready", func() {
+		vm := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers-abc1d", Namespace: "default"},
+			Spec:       kubevirtv1.VirtualMachineSpec{RunStrategy: ptr.To(kubevirtv1.RunStrategyAlways)},
+		}
+		poolScope := newTestMachinePoolScope(vm)
+		r := &HarvesterMachinePoolReconciler{}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: "workers-abc1d",
+			State:        infrav1.InstanceStateRunning,
+			ProviderID:   "harvester://abc",
+		}
+
+		ready, err := r.reconcilePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(instance.State).To(Equal(infrav1.InstanceStateProvisioning))
+
+		vm.Status.Ready = true
+		poolScope = newTestMachinePoolScope(vm)
+		instance.State = infrav1.InstanceStateRunning
+
+		ready, err = r.reconcilePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeTrue())
+	})
+
+	It("should recreate the VM of a running instance deleted outside CAPI", func() {
+		poolScope := newTestMachinePoolScope()
+		r := &HarvesterMachinePoolReconciler{}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: "workers-abc1d",
+			State:        infrav1.InstanceStateRunning,
+			ProviderID:   "harvester://abc",
+			Addresses:    []clusterv1.MachineAddress{{Type: clusterv1.MachineInternalIP, Address: "172.16.3.40"}},
+		}
+
+		ready, err := r.reconcilePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(instance.State).To(Equal(infrav1.InstanceStateProvisioning))
+		Expect(instance.ProviderID).To(BeEmpty())
+		Expect(instance.Addresses).To(BeEmpty())
+
+		_, err = poolScope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(
+			context.TODO(), "workers-abc1d", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+})
+
+var _ = Describe("deletePoolInstance", func() {
+	It("should release the instance IP and delete its VM", func() {
+		pool := &lbv1beta1.IPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "capi-vm-pool"},
+			Status: lbv1beta1.IPPoolStatus{
+				Allocated: map[string]string{"172.16.3.40": "test-ns/workers-abc1d"},
+			},
+		}
+		vm := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers-abc1d", Namespace: "default"},
+		}
+		poolScope := newTestMachinePoolScope(pool, vm)
+		r := &HarvesterMachinePoolReconciler{}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName:       "workers-abc1d",
+			State:              infrav1.InstanceStateDeleting,
+			AllocatedIPAddress: "172.16.3.40",
+			AllocatedPoolRef:   "capi-vm-pool",
+		}
+
+		gone, err := r.deletePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		// The VM deletion was requested; the instance is gone on the next pass.
+		Expect(gone).To(BeFalse())
+
+		// The guest may still use its address until the VM is gone
+		Expect(instance.AllocatedIPAddress).To(Equal("172.16.3.40"))
+
+		updated, err := poolScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
+			context.TODO(), "capi-vm-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Status.Allocated).To(HaveKey("172.16.3.40"))
+
+		gone, err = r.deletePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gone).To(BeTrue())
+		Expect(instance.AllocatedIPAddress).To(BeEmpty())
+
+		updated, err = poolScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
+			context.TODO(), "capi-vm-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Status.Allocated).ToNot(HaveKey("172.16.3.40"))
+	})
+
+	It("should shut the guest down before deleting the VM", func(ctx SpecContext) {
+		vm := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers-abc1d", Namespace: "default"},
+			Spec:       kubevirtv1.VirtualMachineSpec{RunStrategy: new(kubevirtv1.RunStrategyAlways)},
+		}
+		vmi := &kubevirtv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Name: "workers-abc1d", Namespace: "default"}}
+		poolScope := newTestMachinePoolScope(vm, vmi)
+		poolScope.HarvesterMachinePool.Spec.Template.Spec.ShutdownGracePeriod = &metav1.Duration{Duration: 5 * time.Minute}
+		r := &HarvesterMachinePoolReconciler{}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: "workers-abc1d",
+			State:        infrav1.InstanceStateDeleting,
+		}
+
+		gone, err := r.deletePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gone).To(BeFalse())
+
+		current, err := poolScope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(ctx, "workers-abc1d", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*current.Spec.RunStrategy).To(Equal(kubevirtv1.RunStrategyHalted))
+
+		// The start of the grace period is kept across reconciles
+		condition := meta.FindStatusCondition(instance.Conditions, infrav1.DeletingCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Reason).To(Equal(infrav1.VMShuttingDownReason))
+		started := condition.LastTransitionTime
+
+		gone, err = r.deletePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gone).To(BeFalse())
+		Expect(meta.FindStatusCondition(instance.Conditions, infrav1.DeletingCondition).LastTransitionTime).To(Equal(started))
+
+		// The guest shut down, the VM is deleted
+		Expect(poolScope.HarvesterClient.KubevirtV1().VirtualMachineInstances("default").Delete(
+			ctx, "workers-abc1d", metav1.DeleteOptions{})).To(Succeed())
+
+		gone, err = r.deletePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gone).To(BeFalse())
+		Expect(meta.FindStatusCondition(instance.Conditions, infrav1.DeletingCondition).Reason).To(Equal(infrav1.VMShutDownReason))
+
+		_, err = poolScope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(ctx, "workers-abc1d", metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should retain the volumes of the instance with the Retain policy", func(ctx SpecContext) {
+		vm := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers-abc1d", Namespace: "default"},
+		}
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers-abc1d-disk-0-xyz12", Namespace: "default"},
+		}
+		poolScope := newTestMachinePoolScope(vm, pvc)
+		poolScope.HarvesterCluster.Spec.DeletionPolicy = infrav1.DeletionPolicyRetain
+		r := &HarvesterMachinePoolReconciler{}
+		instance := &infrav1.HarvesterMachinePoolInstanceStatus{
+			InstanceName: "workers-abc1d",
+			State:        infrav1.InstanceStateDeleting,
+		}
+
+		gone, err := r.deletePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gone).To(BeFalse())
+
+		gone, err = r.deletePoolInstance(poolScope, instance)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gone).To(BeTrue())
+
+		retained, err := poolScope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(
+			ctx, "workers-abc1d-disk-0-xyz12", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(retained.Labels).To(HaveKeyWithValue(infrav1.RetainedClusterLabel, "test-cluster"))
+		Expect(retained.Labels).To(HaveKeyWithValue(infrav1.RetainedMachineLabel, "workers-abc1d"))
+	})
+})
+
+var _ = Describe("adoptPoolInstances", func() {
+	It("should add labeled VMs missing from the status", func() {
+		adopted := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "workers-lost1",
+				Namespace: "default",
+				Labels:    map[string]string{poolVMLabelName: "workers", poolVMLabelNamespace: "test-ns"},
+			},
+		}
+		foreign := &kubevirtv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other-vm",
+				Namespace: "default",
+				Labels:    map[string]string{poolVMLabelName: "workers", poolVMLabelNamespace: "other-ns"},
+			},
+		}
+		poolScope := newTestMachinePoolScope(adopted, foreign)
+
+		err := adoptPoolInstances(poolScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(poolScope.HarvesterMachinePool.Status.Instances).To(HaveLen(1))
+		Expect(poolScope.HarvesterMachinePool.Status.Instances[0].InstanceName).To(Equal("workers-lost1"))
+
+		// Adoption is idempotent.
+		err = adoptPoolInstances(poolScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(poolScope.HarvesterMachinePool.Status.Instances).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("HarvesterMachinePool ReconcileNormal", func() {
+	It("should scale up to the MachinePool replicas and publish the instances", func() {
+		poolScope := newTestMachinePoolScope()
+		poolScope.HarvesterMachinePool.Finalizers = []string{infrav1.MachinePoolFinalizer}
+		poolScope.Cluster.Status.Initialization.InfrastructureProvisioned = ptr.To(true)
+		r := &HarvesterMachinePoolReconciler{}
+
+		result, err := r.ReconcileNormal(poolScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RequeueAfter).To(Equal(requeueTimeShort))
+
+		pool := poolScope.HarvesterMachinePool
+		Expect(pool.Status.Instances).To(HaveLen(2))
+		Expect(pool.Status.Replicas).To(Equal(int32(2)))
+		Expect(pool.Status.Ready).To(BeFalse())
+
+		vms, err := poolScope.HarvesterClient.KubevirtV1().VirtualMachines("default").List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vms.Items).To(HaveLen(2))
+	})
+
+	It("should wait for the MachinePool bootstrap data", func() {
+		poolScope := newTestMachinePoolScope()
+		poolScope.HarvesterMachinePool.Finalizers = []string{infrav1.MachinePoolFinalizer}
+		poolScope.Cluster.Status.Initialization.InfrastructureProvisioned = ptr.To(true)
+		poolScope.MachinePool.Spec.Template.Spec.Bootstrap.DataSecretName = nil
+		r := &HarvesterMachinePoolReconciler{}
+
+		_, err := r.ReconcileNormal(poolScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(poolScope.HarvesterMachinePool.Status.Instances).To(BeEmpty())
+	})
+})
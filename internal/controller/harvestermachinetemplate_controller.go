@@ -0,0 +1,173 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// instancetypeResyncPeriod is how often the capacity of the templates with an
+// instancetype is read again, as instancetypes are not watched in Harvester.
+const instancetypeResyncPeriod = 10 * time.Minute
+
+// HarvesterMachineTemplateReconciler reconciles the capacity of HarvesterMachineTemplates.
+type HarvesterMachineTemplateReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvestermachinetemplates,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=harvestermachinetemplates/status,verbs=get;update;patch
+
+// Reconcile publishes the capacity of the machines of a HarvesterMachineTemplate,
+// so that the cluster autoscaler can scale their node groups from zero.
+func (r *HarvesterMachineTemplateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (res ctrl.Result, rerr error) {
+	logger := log.FromContext(ctx)
+
+	template := &infrav1.HarvesterMachineTemplate{}
+
+	err := r.Get(ctx, req.NamespacedName, template)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	patchHelper, err := patch.NewHelper(template, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		err := patchHelper.Patch(ctx, template)
+		if err != nil && rerr == nil {
+			rerr = err
+		}
+	}()
+
+	spec := &template.Spec.Template.Spec
+
+	switch {
+	case spec.Instancetype != "":
+		capacity, err := r.instancetypeCapacity(ctx, template)
+		if err != nil {
+			logger.Info("Warning: unable to read the capacity of the instancetype", "instancetype", spec.Instancetype, "error", err)
+
+			return ctrl.Result{RequeueAfter: requeueTimeShort}, nil
+		}
+
+		if capacity != nil {
+			template.Status.Capacity = capacity
+		}
+
+		return ctrl.Result{RequeueAfter: instancetypeResyncPeriod}, nil
+
+	case spec.VMTemplateVersion != "":
+		// The CPUs and memory of VM template versions are not published
+		template.Status.Capacity = nil
+
+	default:
+		template.Status.Capacity = specCapacity(spec)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *HarvesterMachineTemplateReconciler) SetupWithManager(_ context.Context, mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&infrav1.HarvesterMachineTemplate{}).
+		Complete(r)
+}
+
+// specCapacity returns the capacity given by the cpu and memory of a machine spec.
+func specCapacity(spec *infrav1.HarvesterMachineSpec) corev1.ResourceList {
+	capacity := corev1.ResourceList{}
+
+	if spec.CPU > 0 {
+		capacity[corev1.ResourceCPU] = *resource.NewQuantity(int64(spec.CPU), resource.DecimalSI)
+	}
+
+	if memory, err := resource.ParseQuantity(spec.Memory); err == nil {
+		capacity[corev1.ResourceMemory] = memory
+	}
+
+	return capacity
+}
+
+// instancetypeCapacity reads the capacity of a template from its instancetype
+// in the Harvester cluster of the template. It returns nil until the template
+// is associated with a cluster, through the cluster name label set by
+// ClusterClasses or the owner reference set by MachineDeployments.
+func (r *HarvesterMachineTemplateReconciler) instancetypeCapacity(
+	ctx context.Context, template *infrav1.HarvesterMachineTemplate,
+) (corev1.ResourceList, error) {
+	var (
+		cluster *clusterv1.Cluster
+		err     error
+	)
+
+	if _, ok := template.Labels[clusterv1.ClusterNameLabel]; ok {
+		cluster, err = util.GetClusterFromMetadata(ctx, r.Client, template.ObjectMeta)
+	} else {
+		cluster, err = util.GetOwnerCluster(ctx, r.Client, template.ObjectMeta)
+	}
+
+	if err != nil || cluster == nil {
+		return nil, err
+	}
+
+	hvCluster := &infrav1.HarvesterCluster{}
+
+	err = r.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}, hvCluster)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get the HarvesterCluster of the template")
+	}
+
+	hvSecret, err := locutil.GetSecretForHarvesterConfig(ctx, hvCluster, r.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	hvClient, err := locutil.GetHarvesterClientFromSecret(hvSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return getInstancetypeCapacity(ctx, hvClient, template.Spec.Template.Spec.Instancetype)
+}
@@ -0,0 +1,309 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for the CAPI IPAM provider backed by Harvester IPPools
+// =============================================================================
+
+func newIPAMTestClient(objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	_ = corev1.AddToScheme(scheme)
+	_ = infrav1.AddToScheme(scheme)
+	_ = clusterv1.AddToScheme(scheme)
+	_ = ipamv1.AddToScheme(scheme)
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&ipamv1.IPAddressClaim{}).Build()
+}
+
+func newHarvesterIPPoolClaim(name string) *ipamv1.IPAddressClaim {
+	return &ipamv1.IPAddressClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test-ns", UID: "claim-uid"},
+		Spec: ipamv1.IPAddressClaimSpec{
+			ClusterName: "test-cluster",
+			PoolRef: ipamv1.IPPoolReference{
+				Name:     "workers",
+				Kind:     harvesterIPPoolKind,
+				APIGroup: infrav1.GroupVersion.Group,
+			},
+		},
+	}
+}
+
+func newClaimScope(claim *ipamv1.IPAddressClaim, poolRefs []string, pools ...*lbv1beta1.IPPool) *ClaimScope {
+	objs := make([]runtime.Object, 0, len(pools))
+	for _, pool := range pools {
+		objs = append(objs, pool)
+	}
+
+	logger := log.FromContext(context.TODO())
+
+	return &ClaimScope{
+		Ctx:   context.TODO(),
+		Claim: claim,
+		HarvesterIPPool: &infrav1.HarvesterIPPool{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "test-ns", UID: "pool-uid"},
+			Spec:       infrav1.HarvesterIPPoolSpec{IPPoolRefs: poolRefs},
+		},
+		HarvesterClient:  hvfake.NewSimpleClientset(objs...),
+		ReconcilerClient: newIPAMTestClient(claim),
+		Logger:           &logger,
+	}
+}
+
+var _ = Describe("IPAddressClaimReconciler", func() {
+	r := &IPAddressClaimReconciler{}
+
+	It("should add its finalizer before allocating", func() {
+		claimScope := newClaimScope(newHarvesterIPPoolClaim("worker-0-0"), []string{"production-pool"},
+			newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"))
+
+		_, err := r.ReconcileNormal(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(claimScope.Claim.Finalizers).To(ContainElement(infrav1.IPAddressClaimFinalizer))
+		Expect(claimScope.Claim.Status.AddressRef.Name).To(BeEmpty())
+	})
+
+	It("should bind the claim to an IPAddress allocated from the Harvester pool", func() {
+		claim := newHarvesterIPPoolClaim("worker-0-0")
+		claim.Finalizers = []string{infrav1.IPAddressClaimFinalizer}
+		claimScope := newClaimScope(claim, []string{"production-pool"},
+			newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"))
+
+		_, err := r.ReconcileNormal(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(claim.Status.AddressRef.Name).To(Equal("worker-0-0"))
+		Expect(conditions.IsTrue(claim, ipamv1.IPAddressClaimReadyCondition)).To(BeTrue())
+
+		address := &ipamv1.IPAddress{}
+		Expect(claimScope.ReconcilerClient.Get(context.TODO(),
+			types.NamespacedName{Namespace: "test-ns", Name: "worker-0-0"}, address)).To(Succeed())
+		Expect(address.Spec.Address).To(HavePrefix("172.16.3.4"))
+		Expect(*address.Spec.Prefix).To(Equal(int32(16)))
+		Expect(address.Spec.Gateway).To(Equal("172.16.0.1"))
+		Expect(address.Spec.ClaimRef.Name).To(Equal("worker-0-0"))
+		Expect(address.OwnerReferences).To(HaveLen(2))
+
+		pool, err := claimScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
+			context.TODO(), "production-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pool.Status.Allocated).To(HaveKeyWithValue(address.Spec.Address, "test-ns/claim/worker-0-0"))
+
+		// Idempotent: a second pass keeps the same address
+		_, err = r.ReconcileNormal(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(claim.Status.AddressRef.Name).To(Equal("worker-0-0"))
+	})
+
+	It("should not take over the allocation of a machine named like the claim", func() {
+		pool := newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49")
+		pool.Status.Allocated["172.16.3.40"] = "test-ns/worker-0-0"
+
+		claim := newHarvesterIPPoolClaim("worker-0-0")
+		claim.Finalizers = []string{infrav1.IPAddressClaimFinalizer}
+		claimScope := newClaimScope(claim, []string{"production-pool"}, pool)
+
+		_, err := r.ReconcileNormal(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+
+		address := &ipamv1.IPAddress{}
+		Expect(claimScope.ReconcilerClient.Get(context.TODO(),
+			types.NamespacedName{Namespace: "test-ns", Name: "worker-0-0"}, address)).To(Succeed())
+		Expect(address.Spec.Address).ToNot(Equal("172.16.3.40"))
+	})
+
+	It("should publish the prefix and gateway overrides of the HarvesterIPPool", func() {
+		claim := newHarvesterIPPoolClaim("worker-0-0")
+		claim.Finalizers = []string{infrav1.IPAddressClaimFinalizer}
+		claimScope := newClaimScope(claim, []string{"production-pool"},
+			newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"))
+		claimScope.HarvesterIPPool.Spec.Prefix = ptr.To(int32(24))
+		claimScope.HarvesterIPPool.Spec.Gateway = "172.16.3.1"
+
+		_, err := r.ReconcileNormal(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+
+		address := &ipamv1.IPAddress{}
+		Expect(claimScope.ReconcilerClient.Get(context.TODO(),
+			types.NamespacedName{Namespace: "test-ns", Name: "worker-0-0"}, address)).To(Succeed())
+		Expect(*address.Spec.Prefix).To(Equal(int32(24)))
+		Expect(address.Spec.Gateway).To(Equal("172.16.3.1"))
+	})
+
+	It("should fall back to the next pool and report exhaustion", func() {
+		full := newPerNICPool("full-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.40")
+		full.Status.Allocated["172.16.3.40"] = "test-ns/other"
+
+		claim := newHarvesterIPPoolClaim("worker-0-0")
+		claim.Finalizers = []string{infrav1.IPAddressClaimFinalizer}
+		claimScope := newClaimScope(claim, []string{"full-pool", "spare-pool"}, full,
+			newPerNICPool("spare-pool", "", "10.20.0.0/24", "10.20.0.10", "10.20.0.19"))
+
+		_, err := r.ReconcileNormal(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conditions.IsTrue(claim, ipamv1.IPAddressClaimReadyCondition)).To(BeTrue())
+
+		exhausted := newHarvesterIPPoolClaim("worker-1-0")
+		exhausted.Finalizers = []string{infrav1.IPAddressClaimFinalizer}
+		claimScope = newClaimScope(exhausted, []string{"full-pool"}, full)
+
+		_, err = r.ReconcileNormal(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exhausted.Status.AddressRef.Name).To(BeEmpty())
+		Expect(conditions.GetReason(exhausted, ipamv1.IPAddressClaimReadyCondition)).To(
+			Equal(ipamv1.IPAddressClaimReadyPoolExhaustedReason))
+	})
+
+	It("should release the address and remove its finalizer on deletion", func() {
+		pool := newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49")
+		pool.Status.Allocated["172.16.3.40"] = "test-ns/claim/worker-0-0"
+		pool.Status.Allocated["172.16.3.41"] = "test-ns/claim/worker-1-0"
+
+		claim := newHarvesterIPPoolClaim("worker-0-0")
+		claim.Finalizers = []string{infrav1.IPAddressClaimFinalizer}
+		claimScope := newClaimScope(claim, []string{"production-pool"}, pool)
+		Expect(claimScope.ReconcilerClient.Create(context.TODO(), &ipamv1.IPAddress{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0-0", Namespace: "test-ns"},
+		})).To(Succeed())
+
+		_, err := r.ReconcileDelete(claimScope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(claim.Finalizers).To(BeEmpty())
+
+		err = claimScope.ReconcilerClient.Get(context.TODO(),
+			types.NamespacedName{Namespace: "test-ns", Name: "worker-0-0"}, &ipamv1.IPAddress{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		pool, err = claimScope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
+			context.TODO(), "production-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pool.Status.Allocated).To(Equal(map[string]string{"172.16.3.41": "test-ns/claim/worker-1-0"}))
+	})
+
+	It("should only serve claims of HarvesterIPPools", func() {
+		Expect(isHarvesterIPPoolRef(newHarvesterIPPoolClaim("worker-0-0").Spec.PoolRef)).To(BeTrue())
+		Expect(isHarvesterIPPoolRef(ipamv1.IPPoolReference{
+			Name: "workers", Kind: "InClusterIPPool", APIGroup: "ipam.cluster.x-k8s.io",
+		})).To(BeFalse())
+	})
+})
+
+var _ = Describe("HarvesterMachine addressesFromPools", func() {
+	newClaimingScope := func(objs ...client.Object) *Scope {
+		scope := newPerNICScope(&infrav1.VMNetworkConfig{
+			IPPoolRef:  "production-pool",
+			Gateway:    "172.16.0.1",
+			SubnetMask: "255.255.0.0",
+			DNSServers: []string{"172.16.0.2"},
+		})
+		scope.HarvesterMachine.UID = "machine-uid"
+		scope.HarvesterMachine.Spec.AddressesFromPools = []corev1.TypedLocalObjectReference{{
+			APIGroup: ptr.To(infrav1.GroupVersion.Group),
+			Kind:     harvesterIPPoolKind,
+			Name:     "workers",
+		}}
+		scope.Cluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+		scope.ReconcilerClient = newIPAMTestClient(objs...)
+
+		return scope
+	}
+
+	It("should create a claim and wait for it to be bound", func() {
+		scope := newClaimingScope()
+		r := &HarvesterMachineReconciler{}
+
+		err := r.resolveNetworkConfig(scope)
+		Expect(errors.Is(err, errAddressClaimsPending)).To(BeTrue())
+
+		claim := &ipamv1.IPAddressClaim{}
+		Expect(scope.ReconcilerClient.Get(context.TODO(),
+			types.NamespacedName{Namespace: "test-ns", Name: "worker-0-0"}, claim)).To(Succeed())
+		Expect(claim.Spec.PoolRef.Kind).To(Equal(harvesterIPPoolKind))
+		Expect(claim.Spec.ClusterName).To(Equal("test-cluster"))
+		Expect(claim.Labels).To(HaveKeyWithValue(clusterv1.ClusterNameLabel, "test-cluster"))
+		Expect(claim.OwnerReferences).To(HaveLen(1))
+		Expect(claim.OwnerReferences[0].Name).To(Equal("worker-0"))
+
+		// Nothing is allocated from the vmNetworkConfig pools
+		Expect(scope.HarvesterMachine.Status.AllocatedIPAddress).To(BeEmpty())
+	})
+
+	It("should configure the first network from the bound IPAddress", func() {
+		claim := newHarvesterIPPoolClaim("worker-0-0")
+		claim.Status.AddressRef.Name = "worker-0-0"
+		address := &ipamv1.IPAddress{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0-0", Namespace: "test-ns"},
+			Spec: ipamv1.IPAddressSpec{
+				Address: "10.20.0.15",
+				Prefix:  ptr.To(int32(24)),
+				Gateway: "10.20.0.1",
+			},
+		}
+		scope := newClaimingScope(claim, address)
+		r := &HarvesterMachineReconciler{}
+
+		Expect(r.resolveNetworkConfig(scope)).To(Succeed())
+		Expect(scope.EffectiveNetworkConfig.Address).To(Equal("10.20.0.15"))
+		Expect(scope.EffectiveNetworkConfig.Gateway).To(Equal("10.20.0.1"))
+		Expect(scope.EffectiveNetworkConfig.DNSServers).To(Equal([]string{"172.16.0.2"}))
+		Expect(claimedVMIPs(scope)).To(Equal([]string{"10.20.0.15"}))
+
+		Expect(buildNetworkDataStatic(scope)).To(ContainSubstring(`      - type: static
+        address: 10.20.0.15
+        netmask: 255.255.255.0
+        gateway: 10.20.0.1
+`))
+	})
+
+	It("should delete the claims when the machine releases its IPs", func() {
+		scope := newClaimingScope(newHarvesterIPPoolClaim("worker-0-0"))
+		r := &HarvesterMachineReconciler{}
+
+		r.releaseVMIP(scope)
+
+		err := scope.ReconcilerClient.Get(context.TODO(),
+			types.NamespacedName{Namespace: "test-ns", Name: "worker-0-0"}, &ipamv1.IPAddressClaim{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
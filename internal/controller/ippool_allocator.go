@@ -0,0 +1,163 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+	harvclient "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned"
+)
+
+// IPPools are cluster-scoped and shared by every machine, claim and load
+// balancer allocating from them, so all the writes to status.allocated go
+// through updateIPPool: the pool is read fresh, mutated and written back with
+// its resourceVersion, and the whole sequence is retried on conflicts.
+
+// ipPoolUpdateBackoff paces the retries of conflicting IPPool updates. It allows
+// more attempts than retry.DefaultRetry since a whole MachineDeployment scaling
+// up contends for the same pool, and the jitter spreads the retries.
+var ipPoolUpdateBackoff = wait.Backoff{
+	Steps:    10,
+	Duration: 10 * time.Millisecond,
+	Factor:   1.5,
+	Jitter:   1.0,
+}
+
+// maxIPPoolAllocationAttempts bounds how many times an allocation is redone when
+// the address is found held by another owner after the write.
+const maxIPPoolAllocationAttempts = 5
+
+// errIPPoolUnchanged is returned by an IPPool mutation to skip the update.
+var errIPPoolUnchanged = errors.New("IP pool unchanged")
+
+// updateIPPool applies mutate to a fresh copy of the IPPool poolName and
+// updates it, retrying from a fresh copy on resourceVersion conflicts. It
+// returns the last copy of the pool and whether it was updated; mutate returns
+// errIPPoolUnchanged when there is nothing to write.
+func updateIPPool(ctx context.Context, hvClient harvclient.Interface, poolName string,
+	mutate func(pool *lbv1beta1.IPPool) error,
+) (*lbv1beta1.IPPool, bool, error) {
+	var (
+		pool    *lbv1beta1.IPPool
+		updated bool
+	)
+
+	err := retry.RetryOnConflict(ipPoolUpdateBackoff, func() error {
+		updated = false
+
+		fresh, err := hvClient.LoadbalancerV1beta1().IPPools().Get(ctx, poolName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		pool = fresh
+
+		err = mutate(pool)
+		if err != nil {
+			return err
+		}
+
+		written, err := hvClient.LoadbalancerV1beta1().IPPools().Update(ctx, pool, metav1.UpdateOptions{})
+		if err != nil {
+			if apierrors.IsConflict(err) {
+				caphvmetrics.IPPoolAllocationConflictsTotal.Inc()
+			}
+
+			return err
+		}
+
+		pool = written
+		updated = true
+
+		return nil
+	})
+	if errors.Is(err, errIPPoolUnchanged) {
+		return pool, false, nil
+	}
+
+	return pool, updated, err
+}
+
+// allocateFromIPPool allocates an address for ownerID from the IPPool poolName
+// with allocate, which reserves it in the pool passed to it. The existing
+// allocation of ownerID is returned as is. After the write the pool is read
+// again, and the allocation is redone if the address is no longer held by
+// ownerID, so that a stale write of another allocator cannot silently drop it.
+func allocateFromIPPool(ctx context.Context, hvClient harvclient.Interface, poolName string, ownerID string,
+	allocate func(pool *lbv1beta1.IPPool) (string, error),
+) (string, *lbv1beta1.IPPool, error) {
+	for range maxIPPoolAllocationAttempts {
+		var ip string
+
+		pool, updated, err := updateIPPool(ctx, hvClient, poolName, func(pool *lbv1beta1.IPPool) error {
+			if existing := ipPoolAllocationOf(pool, ownerID); existing != "" {
+				ip = existing
+
+				return errIPPoolUnchanged
+			}
+
+			var allocErr error
+
+			ip, allocErr = allocate(pool)
+
+			return allocErr
+		})
+		if err != nil {
+			return "", nil, err
+		}
+
+		if !updated {
+			return ip, pool, nil
+		}
+
+		written, err := hvClient.LoadbalancerV1beta1().IPPools().Get(ctx, poolName, metav1.GetOptions{})
+		if err != nil {
+			return "", nil, errors.Wrapf(err, "failed to verify the allocation of %s in IP pool %s", ip, poolName)
+		}
+
+		if written.Status.Allocated[ip] == ownerID {
+			return ip, written, nil
+		}
+
+		// Another allocator overwrote the pool with a copy missing the address.
+		caphvmetrics.IPPoolAllocationConflictsTotal.Inc()
+	}
+
+	return "", nil, errors.Errorf("allocation for %s in IP pool %s kept being overwritten after %d attempts",
+		ownerID, poolName, maxIPPoolAllocationAttempts)
+}
+
+// ipPoolAllocationOf returns the address allocated to ownerID in pool, if any.
+func ipPoolAllocationOf(pool *lbv1beta1.IPPool, ownerID string) string {
+	for ip, id := range pool.Status.Allocated {
+		if id == ownerID {
+			return ip
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,303 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stesting "k8s.io/client-go/testing"
+
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// =============================================================================
+// Tests for the conflict-safe IP pool allocator
+// =============================================================================
+
+var ipPoolResource = lbv1beta1.SchemeGroupVersion.WithResource("ippools")
+
+// enforceIPPoolResourceVersion makes the fake clientset reject IPPool updates
+// carrying a stale resourceVersion, as the API server does.
+func enforceIPPoolResourceVersion(hvClient *hvfake.Clientset) {
+	hvClient.PrependReactor("update", "ippools", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pool := action.(k8stesting.UpdateAction).GetObject().(*lbv1beta1.IPPool)
+
+		current, err := hvClient.Tracker().Get(ipPoolResource, "", pool.Name)
+		if err != nil {
+			return true, nil, err
+		}
+
+		if current.(*lbv1beta1.IPPool).ResourceVersion != pool.ResourceVersion {
+			return true, nil, apierrors.NewConflict(ipPoolResource.GroupResource(), pool.Name,
+				errors.New("the object has been modified"))
+		}
+
+		written := pool.DeepCopy()
+		version, _ := strconv.Atoi(pool.ResourceVersion)
+		written.ResourceVersion = strconv.Itoa(version + 1)
+
+		return true, written, hvClient.Tracker().Update(ipPoolResource, written, "")
+	})
+}
+
+func getTestIPPool(hvClient *hvfake.Clientset, name string) *lbv1beta1.IPPool {
+	pool, err := hvClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), name, metav1.GetOptions{})
+	Expect(err).ToNot(HaveOccurred())
+
+	return pool
+}
+
+var _ = Describe("updateIPPool", func() {
+	It("should retry from a fresh copy on conflicts", func() {
+		hvClient := hvfake.NewSimpleClientset(newPerNICPool("shared-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19"))
+
+		conflicts := 0
+		hvClient.PrependReactor("update", "ippools", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+			if conflicts < 2 {
+				conflicts++
+
+				return true, nil, apierrors.NewConflict(ipPoolResource.GroupResource(), "shared-pool",
+					errors.New("the object has been modified"))
+			}
+
+			return false, nil, nil
+		})
+
+		before := testutil.ToFloat64(caphvmetrics.IPPoolAllocationConflictsTotal)
+		mutations := 0
+
+		_, updated, err := updateIPPool(context.TODO(), hvClient, "shared-pool", func(pool *lbv1beta1.IPPool) error {
+			mutations++
+			Expect(pool.Status.Allocated).ToNot(HaveKey("10.0.0.10"))
+			pool.Status.Allocated["10.0.0.10"] = "test-ns/worker-0"
+
+			return nil
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated).To(BeTrue())
+		Expect(mutations).To(Equal(3))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolAllocationConflictsTotal) - before).To(Equal(2.0))
+		Expect(getTestIPPool(hvClient, "shared-pool").Status.Allocated).To(HaveKeyWithValue("10.0.0.10", "test-ns/worker-0"))
+	})
+
+	It("should not write the pool when the mutation leaves it unchanged", func() {
+		hvClient := hvfake.NewSimpleClientset(newPerNICPool("shared-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19"))
+
+		pool, updated, err := updateIPPool(context.TODO(), hvClient, "shared-pool", func(_ *lbv1beta1.IPPool) error {
+			return errIPPoolUnchanged
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated).To(BeFalse())
+		Expect(pool.Name).To(Equal("shared-pool"))
+
+		for _, action := range hvClient.Actions() {
+			Expect(action.GetVerb()).ToNot(Equal("update"))
+		}
+	})
+
+	It("should return errors other than conflicts without retrying", func() {
+		hvClient := hvfake.NewSimpleClientset(newPerNICPool("shared-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19"))
+		hvClient.PrependReactor("update", "ippools", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, errors.New("connection refused")
+		})
+
+		mutations := 0
+
+		_, _, err := updateIPPool(context.TODO(), hvClient, "shared-pool", func(_ *lbv1beta1.IPPool) error {
+			mutations++
+
+			return nil
+		})
+		Expect(err).To(MatchError(ContainSubstring("connection refused")))
+		Expect(mutations).To(Equal(1))
+	})
+})
+
+var _ = Describe("allocateFromIPPool", func() {
+	It("should return the existing allocation of the owner", func() {
+		pool := newPerNICPool("shared-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19")
+		pool.Status.Allocated["10.0.0.14"] = "test-ns/worker-0"
+		hvClient := hvfake.NewSimpleClientset(pool)
+
+		ip, _, err := allocateFromIPPool(context.TODO(), hvClient, "shared-pool", "test-ns/worker-0",
+			func(_ *lbv1beta1.IPPool) (string, error) {
+				Fail("the existing allocation should be reused")
+
+				return "", nil
+			})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip).To(Equal("10.0.0.14"))
+	})
+
+	It("should redo an allocation dropped by a stale write", func() {
+		hvClient := hvfake.NewSimpleClientset(newPerNICPool("shared-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19"))
+
+		// The first write is immediately overwritten by a writer holding the
+		// pool as it was before the allocation.
+		stale := getTestIPPool(hvClient, "shared-pool")
+		overwritten := false
+		hvClient.PrependReactor("update", "ippools", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if overwritten {
+				return false, nil, nil
+			}
+
+			overwritten = true
+			written := action.(k8stesting.UpdateAction).GetObject()
+
+			return true, written, hvClient.Tracker().Update(ipPoolResource, stale, "")
+		})
+
+		before := testutil.ToFloat64(caphvmetrics.IPPoolAllocationConflictsTotal)
+
+		ip, pool, err := allocateFromIPPool(context.TODO(), hvClient, "shared-pool", "test-ns/worker-0",
+			func(fresh *lbv1beta1.IPPool) (string, error) {
+				return locutil.AllocateVMIPFromPool(fresh, "test-ns/worker-0")
+			})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pool.Status.Allocated).To(HaveKeyWithValue(ip, "test-ns/worker-0"))
+		Expect(getTestIPPool(hvClient, "shared-pool").Status.Allocated).To(HaveKeyWithValue(ip, "test-ns/worker-0"))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolAllocationConflictsTotal) - before).To(Equal(1.0))
+	})
+})
+
+var _ = Describe("IP pool allocation under concurrent reconciles", func() {
+	It("should give distinct addresses to machines scaling up concurrently", func() {
+		const machines = 8
+
+		hvClient := hvfake.NewSimpleClientset(newPerNICPool("shared-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19"))
+		enforceIPPoolResourceVersion(hvClient)
+
+		before := testutil.ToFloat64(caphvmetrics.IPPoolAllocationConflictsTotal)
+
+		var (
+			wg    sync.WaitGroup
+			ready sync.WaitGroup
+			mu    sync.Mutex
+			ips   = map[string]string{}
+		)
+
+		// Every machine reads the pool before any of them writes it back, so
+		// all but one of the first updates conflict.
+		ready.Add(machines)
+
+		for i := range machines {
+			wg.Add(1)
+
+			go func(owner string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				first := true
+
+				ip, _, err := allocateFromIPPool(context.TODO(), hvClient, "shared-pool", owner,
+					func(fresh *lbv1beta1.IPPool) (string, error) {
+						if first {
+							first = false
+
+							ready.Done()
+							ready.Wait()
+						}
+
+						return locutil.AllocateVMIPFromPool(fresh, owner)
+					})
+				Expect(err).ToNot(HaveOccurred())
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				ips[ip] = owner
+			}(fmt.Sprintf("test-ns/worker-%d", i))
+		}
+
+		wg.Wait()
+
+		Expect(ips).To(HaveLen(machines))
+		Expect(getTestIPPool(hvClient, "shared-pool").Status.Allocated).To(Equal(ips))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolAllocationConflictsTotal) - before).To(
+			BeNumerically(">=", machines-1))
+	})
+
+	It("should keep concurrent releases and allocations of the same pool", func() {
+		pool := newPerNICPool("shared-pool", "", "10.0.0.0/24", "10.0.0.10", "10.0.0.19")
+		for i := range 4 {
+			pool.Status.Allocated[fmt.Sprintf("10.0.0.1%d", i)] = fmt.Sprintf("test-ns/old-%d", i)
+		}
+
+		pool.Status.Available = 6
+
+		hvClient := hvfake.NewSimpleClientset(pool)
+		enforceIPPoolResourceVersion(hvClient)
+
+		var (
+			wg  sync.WaitGroup
+			mu  sync.Mutex
+			ips = map[string]string{}
+		)
+
+		for i := range 4 {
+			wg.Add(2)
+
+			go func(address string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				scope := newPerNICScope(nil)
+				scope.HarvesterClient = hvClient
+
+				releaseIPToPool(scope, "shared-pool", address)
+			}(fmt.Sprintf("10.0.0.1%d", i))
+
+			go func(owner string) {
+				defer GinkgoRecover()
+				defer wg.Done()
+
+				scope := newPerNICScope(nil)
+				scope.HarvesterClient = hvClient
+
+				ip, _, err := allocateIPFromPools(scope, []string{"shared-pool"}, owner, nil, corev1.IPv4Protocol)
+				Expect(err).ToNot(HaveOccurred())
+
+				mu.Lock()
+				defer mu.Unlock()
+
+				ips[ip] = owner
+			}(fmt.Sprintf("test-ns/new-%d", i))
+		}
+
+		wg.Wait()
+
+		final := getTestIPPool(hvClient, "shared-pool")
+		Expect(final.Status.Allocated).To(Equal(ips))
+		Expect(final.Status.Available).To(Equal(int64(6)))
+	})
+})
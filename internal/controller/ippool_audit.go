@@ -0,0 +1,405 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// The allocations of the IP pools are only released by the deletion of their
+// owner, so an owner removed without it (finalizer removed by hand, aborted
+// test run) leaks its address forever. The IP pool audit periodically checks
+// the allocations of the pools used by a HarvesterCluster against the objects
+// that can own them:
+//   - "<namespace>/<name>" and "<namespace>/<name>/<network>" allocations of the
+//     HarvesterMachines and HarvesterMachinePool instances of the namespace of
+//     the HarvesterCluster;
+//   - "<namespace>/claim/<name>" allocations of the IPAddressClaims of the
+//     namespace of the HarvesterCluster;
+//   - "<targetNamespace>/<name>" allocations of the load balancers of the
+//     target namespace.
+// Allocations of other namespaces belong to other clusters and are ignored.
+
+const (
+	// ipPoolAuditInterval is the period of the IP pool audit. A leaked
+	// allocation is only released once found by two audits, so that addresses
+	// allocated just before their owner was created are left alone.
+	ipPoolAuditInterval = 10 * time.Minute
+
+	// maxLeakedIPAllocationsInMessage bounds the allocations listed in the
+	// message of the IPPoolAllocationsHealthy condition.
+	maxLeakedIPAllocationsInMessage = 5
+)
+
+// ipPoolOwners holds the names of the objects that can own an allocation of the
+// IP pools of a cluster.
+type ipPoolOwners struct {
+	namespace   string
+	names       map[string]struct{}
+	claimNames  map[string]struct{}
+	lbNamespace string
+	lbNames     map[string]struct{}
+}
+
+// owns reports whether the allocation owner ownerID falls in the scope of the
+// audit, and whether its owner exists.
+func (o *ipPoolOwners) owns(ownerID string) (bool, bool) {
+	namespace, rest, _ := strings.Cut(ownerID, "/")
+	name, suffix, _ := strings.Cut(rest, "/")
+
+	audited := false
+
+	if namespace == o.namespace {
+		audited = true
+
+		if _, ok := o.names[name]; ok {
+			return true, true
+		}
+
+		if _, ok := o.claimNames[suffix]; ok && name == claimOwnerSegment {
+			return true, true
+		}
+	}
+
+	if namespace == o.lbNamespace {
+		audited = true
+
+		if _, ok := o.lbNames[name]; ok {
+			return true, true
+		}
+	}
+
+	return audited, false
+}
+
+// auditedIPPools returns the IP pools used by the cluster: its VM pools and the
+// pool of its load balancer.
+func auditedIPPools(cluster *infrav1.HarvesterCluster) []string {
+	pools := make([]string, 0)
+
+	if vmNetCfg := cluster.Spec.VMNetworkConfig; vmNetCfg != nil {
+		pools = append(pools, vmNetCfg.GetIPPoolRefs()...)
+
+		if vmNetCfg.IPv6 != nil {
+			pools = append(pools, vmNetCfg.IPv6.IPPoolRefs...)
+		}
+
+		for _, iface := range vmNetCfg.Interfaces {
+			pools = append(pools, iface.IPPoolRefs...)
+		}
+	}
+
+	lbCfg := cluster.Spec.LoadBalancerConfig
+	if lbCfg.IPAMType == infrav1.POOL && lbCfg.IpPoolRef != "" {
+		pools = append(pools, lbCfg.IpPoolRef)
+	}
+
+	slices.Sort(pools)
+
+	return slices.Compact(pools)
+}
+
+// reconcileIPPoolAudit audits the allocations of the IP pools of the cluster
+// when the last audit is older than ipPoolAuditInterval. It returns the time
+// until the next audit, or zero when the cluster uses no IP pool.
+// This is a best-effort operation: errors are reported in the
+// IPPoolAllocationsHealthy condition but don't block cluster provisioning.
+func (r *HarvesterClusterReconciler) reconcileIPPoolAudit(scope *ClusterScope) time.Duration {
+	cluster := scope.HarvesterCluster
+
+	pools := auditedIPPools(cluster)
+	if len(pools) == 0 {
+		return 0
+	}
+
+	if last := cluster.Status.LastIPPoolAuditTime; last != nil {
+		if elapsed := time.Since(last.Time); elapsed < ipPoolAuditInterval {
+			return ipPoolAuditInterval - elapsed
+		}
+	}
+
+	err := r.auditIPPools(scope, pools)
+	if err != nil {
+		scope.Logger.Info("Warning: failed to audit the IP pool allocations", "error", err)
+
+		conditions.Set(cluster, metav1.Condition{
+			Type:    infrav1.IPPoolAllocationsHealthyCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  infrav1.IPPoolAuditFailedReason,
+			Message: fmt.Sprintf("Failed to audit the IP pool allocations: %v", err),
+		})
+	}
+
+	now := metav1.Now()
+	cluster.Status.LastIPPoolAuditTime = &now
+
+	return ipPoolAuditInterval
+}
+
+// auditIPPools records the leaked allocations of pools in the status and the
+// conditions of the cluster, and releases the ones already found by the
+// previous audit when the cluster carries ReleaseLeakedIPsAnnotation.
+func (r *HarvesterClusterReconciler) auditIPPools(scope *ClusterScope, pools []string) error {
+	cluster := scope.HarvesterCluster
+	clusterName := cluster.Namespace + "/" + cluster.Name
+
+	owners, err := r.listIPPoolOwners(scope)
+	if err != nil {
+		return err
+	}
+
+	previous := make(map[string]metav1.Time, len(cluster.Status.LeakedIPAllocations))
+	for _, allocation := range cluster.Status.LeakedIPAllocations {
+		previous[allocation.Pool+"/"+allocation.Address+"/"+allocation.Owner] = allocation.Since
+	}
+
+	release := cluster.Annotations[infrav1.ReleaseLeakedIPsAnnotation] == "true"
+	now := metav1.Now()
+	leaked := make([]infrav1.LeakedIPAllocation, 0)
+	leakedPerPool := make(map[string]int, len(pools))
+
+	for _, poolName := range pools {
+		pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(scope.Ctx, poolName, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return errors.Wrapf(err, "failed to get IP pool %s", poolName)
+		}
+
+		addresses := make([]string, 0, len(pool.Status.Allocated))
+		for address := range pool.Status.Allocated {
+			addresses = append(addresses, address)
+		}
+
+		slices.Sort(addresses)
+
+		for _, address := range addresses {
+			owner := pool.Status.Allocated[address]
+
+			audited, alive := owners.owns(owner)
+			if !audited || alive {
+				continue
+			}
+
+			since, seen := previous[poolName+"/"+address+"/"+owner]
+			if !seen {
+				since = now
+			}
+
+			if release && seen && releaseLeakedIP(scope, poolName, address, owner) {
+				continue
+			}
+
+			leaked = append(leaked, infrav1.LeakedIPAllocation{Pool: poolName, Address: address, Owner: owner, Since: since})
+			leakedPerPool[poolName]++
+		}
+	}
+
+	caphvmetrics.IPPoolLeakedAllocations.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+
+	for _, poolName := range pools {
+		caphvmetrics.IPPoolLeakedAllocations.WithLabelValues(clusterName, poolName).Set(float64(leakedPerPool[poolName]))
+	}
+
+	cluster.Status.LeakedIPAllocations = leaked
+
+	if len(leaked) == 0 {
+		conditions.Set(cluster, metav1.Condition{
+			Type:    infrav1.IPPoolAllocationsHealthyCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.IPPoolAllocationsHealthyReason,
+			Message: fmt.Sprintf("Every allocation of the IP pools %v has an owner", pools),
+		})
+
+		return nil
+	}
+
+	conditions.Set(cluster, metav1.Condition{
+		Type:    infrav1.IPPoolAllocationsHealthyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  infrav1.IPPoolAllocationsLeakedReason,
+		Message: leakedIPAllocationsMessage(leaked, release),
+	})
+
+	return nil
+}
+
+// listIPPoolOwners lists the objects that can own an allocation of the IP pools
+// of the cluster.
+func (r *HarvesterClusterReconciler) listIPPoolOwners(scope *ClusterScope) (*ipPoolOwners, error) {
+	cluster := scope.HarvesterCluster
+	targetNamespace := cluster.Spec.TargetNamespace
+
+	owners := &ipPoolOwners{
+		namespace:   cluster.Namespace,
+		names:       make(map[string]struct{}),
+		claimNames:  make(map[string]struct{}),
+		lbNamespace: targetNamespace,
+		lbNames:     make(map[string]struct{}),
+	}
+
+	machines := &infrav1.HarvesterMachineList{}
+
+	err := r.List(scope.Ctx, machines, client.InNamespace(cluster.Namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list HarvesterMachines")
+	}
+
+	for _, machine := range machines.Items {
+		owners.names[machine.Name] = struct{}{}
+	}
+
+	machinePools := &infrav1.HarvesterMachinePoolList{}
+
+	err = r.List(scope.Ctx, machinePools, client.InNamespace(cluster.Namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list HarvesterMachinePools")
+	}
+
+	for _, pool := range machinePools.Items {
+		for _, instance := range pool.Status.Instances {
+			owners.names[instance.InstanceName] = struct{}{}
+		}
+	}
+
+	claims := &ipamv1.IPAddressClaimList{}
+
+	err = r.List(scope.Ctx, claims, client.InNamespace(cluster.Namespace))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list IPAddressClaims")
+	}
+
+	for _, claim := range claims.Items {
+		owners.claimNames[claim.Name] = struct{}{}
+	}
+
+	// VMs are named after their machine or pool instance, which covers the
+	// instances whose status update was lost.
+	vms, err := scope.HarvesterClient.KubevirtV1().VirtualMachines(targetNamespace).List(scope.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the VMs of the target namespace")
+	}
+
+	for _, vm := range vms.Items {
+		owners.names[vm.Name] = struct{}{}
+	}
+
+	lbs, err := scope.HarvesterClient.LoadbalancerV1beta1().LoadBalancers(targetNamespace).List(scope.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the load balancers of the target namespace")
+	}
+
+	for _, lb := range lbs.Items {
+		owners.lbNames[lb.Name] = struct{}{}
+	}
+
+	// The placeholder load balancer of a cluster without control plane
+	// machines is a Service.
+	services, err := scope.HarvesterClient.CoreV1().Services(targetNamespace).List(scope.Ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the services of the target namespace")
+	}
+
+	for _, svc := range services.Items {
+		if svc.Spec.Type == apiv1.ServiceTypeLoadBalancer {
+			owners.lbNames[svc.Name] = struct{}{}
+		}
+	}
+
+	// The address of a load balancer is allocated before it is created.
+	harvesterClusters := &infrav1.HarvesterClusterList{}
+
+	err = r.List(scope.Ctx, harvesterClusters)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list HarvesterClusters")
+	}
+
+	for _, hvCluster := range harvesterClusters.Items {
+		if hvCluster.Spec.TargetNamespace == targetNamespace {
+			owners.lbNames[locutil.GenerateRFC1035Name([]string{hvCluster.Namespace, hvCluster.Name, "lb"})] = struct{}{}
+		}
+	}
+
+	return owners, nil
+}
+
+// releaseLeakedIP releases the allocation of address to owner in the IP pool
+// poolName. It returns whether the allocation is gone; errors are logged as
+// warnings.
+func releaseLeakedIP(scope *ClusterScope, poolName, address, owner string) bool {
+	_, released, err := updateIPPool(scope.Ctx, scope.HarvesterClient, poolName, func(pool *lbv1beta1.IPPool) error {
+		if pool.Status.Allocated[address] != owner {
+			return errIPPoolUnchanged
+		}
+
+		return locutil.NewStore(pool).Release(net.ParseIP(address))
+	})
+	if err != nil {
+		scope.Logger.Info("Warning: failed to release leaked IP", "error", err, "ip", address, "pool", poolName)
+
+		return false
+	}
+
+	if released {
+		caphvmetrics.IPPoolLeakedReleasesTotal.Inc()
+		scope.Logger.Info("Released leaked IP back to pool", "ip", address, "pool", poolName, "owner", owner)
+	}
+
+	return true
+}
+
+// leakedIPAllocationsMessage summarizes leaked for the message of the
+// IPPoolAllocationsHealthy condition.
+func leakedIPAllocationsMessage(leaked []infrav1.LeakedIPAllocation, release bool) string {
+	entries := make([]string, 0, maxLeakedIPAllocationsInMessage)
+	for i := range min(len(leaked), maxLeakedIPAllocationsInMessage) {
+		entries = append(entries, fmt.Sprintf("%s in %s (owner %s)", leaked[i].Address, leaked[i].Pool, leaked[i].Owner))
+	}
+
+	message := fmt.Sprintf("%d IP pool allocation(s) without owner: %s", len(leaked), strings.Join(entries, ", "))
+	if len(leaked) > maxLeakedIPAllocationsInMessage {
+		message += fmt.Sprintf(" and %d more", len(leaked)-maxLeakedIPAllocationsInMessage)
+	}
+
+	if release {
+		return message + "; they are released at the next audit"
+	}
+
+	return message + fmt.Sprintf("; set the %s annotation to \"true\" to release them", infrav1.ReleaseLeakedIPsAnnotation)
+}
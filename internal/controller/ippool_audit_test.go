@@ -0,0 +1,274 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for the IP pool leak audit
+// =============================================================================
+
+func newAuditHarvesterCluster() *infrav1.HarvesterCluster {
+	return &infrav1.HarvesterCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-hv-cluster", Namespace: "test-ns"},
+		Spec: infrav1.HarvesterClusterSpec{
+			TargetNamespace: "default",
+			VMNetworkConfig: &infrav1.VMNetworkConfig{
+				IPPoolRefs: []string{"vm-pool"},
+				Gateway:    "172.16.0.1",
+				SubnetMask: "255.255.0.0",
+			},
+			LoadBalancerConfig: infrav1.LoadBalancerConfig{IPAMType: infrav1.POOL, IpPoolRef: "lb-pool"},
+		},
+	}
+}
+
+func newAuditScope(hvCluster *infrav1.HarvesterCluster, objs []client.Object, hvObjs ...runtime.Object) (
+	*HarvesterClusterReconciler, *ClusterScope,
+) {
+	fakeClient := newIPAMTestClient(append(objs, hvCluster)...)
+
+	return &HarvesterClusterReconciler{Client: fakeClient, Scheme: fakeClient.Scheme()}, &ClusterScope{
+		Ctx:              context.TODO(),
+		Logger:           log.FromContext(context.TODO()),
+		HarvesterCluster: hvCluster,
+		HarvesterClient:  hvfake.NewSimpleClientset(hvObjs...),
+		ReconcileClient:  fakeClient,
+	}
+}
+
+func newAuditPools() (*lbv1beta1.IPPool, *lbv1beta1.IPPool) {
+	vmPool := newPerNICPool("vm-pool", "", "172.16.0.0/16", "172.16.3.10", "172.16.3.50")
+	vmPool.Status.Allocated = map[string]string{
+		"172.16.3.10": "test-ns/worker-0",
+		"172.16.3.11": "test-ns/worker-0/default/storage",
+		"172.16.3.12": "test-ns/pool-a-x1y2z",
+		"172.16.3.13": "test-ns/claim/worker-0-0",
+		"172.16.3.14": "test-ns/gone-machine",
+		"172.16.3.15": "test-ns/gone-machine/default/storage",
+		"172.16.3.16": "other-ns/other-machine",
+	}
+
+	lbPool := newPerNICPool("lb-pool", "", "172.16.0.0/16", "172.16.4.10", "172.16.4.50")
+	lbPool.Status.Allocated = map[string]string{
+		"172.16.4.10": "default/test-ns-test-hv-cluster-lb",
+		"172.16.4.11": "default/guest-svc-lb",
+		"172.16.4.12": "default/gone-lb",
+	}
+
+	return vmPool, lbPool
+}
+
+func newAuditOwners() []client.Object {
+	return []client.Object{
+		&infrav1.HarvesterMachine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns"}},
+		&infrav1.HarvesterMachinePool{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool-a", Namespace: "test-ns"},
+			Status: infrav1.HarvesterMachinePoolStatus{
+				Instances: []infrav1.HarvesterMachinePoolInstanceStatus{{InstanceName: "pool-a-x1y2z"}},
+			},
+		},
+		newHarvesterIPPoolClaim("worker-0-0"),
+	}
+}
+
+func leakedOwners(hvCluster *infrav1.HarvesterCluster) []string {
+	owners := make([]string, 0, len(hvCluster.Status.LeakedIPAllocations))
+	for _, allocation := range hvCluster.Status.LeakedIPAllocations {
+		owners = append(owners, allocation.Owner)
+	}
+
+	return owners
+}
+
+var _ = Describe("auditedIPPools", func() {
+	It("should list the VM, IPv6, interface and load balancer pools once", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Spec.VMNetworkConfig.IPv6 = &infrav1.IPv6NetworkConfig{IPPoolRefs: []string{"vm-pool-v6"}, PrefixLength: 64}
+		hvCluster.Spec.VMNetworkConfig.Interfaces = []infrav1.InterfaceConfig{
+			{Network: "default/storage", Addressing: infrav1.InterfaceAddressingPool, IPPoolRefs: []string{"storage-pool", "vm-pool"}},
+		}
+
+		Expect(auditedIPPools(hvCluster)).To(Equal([]string{"lb-pool", "storage-pool", "vm-pool", "vm-pool-v6"}))
+	})
+
+	It("should ignore the load balancer pool with DHCP addressing", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Spec.LoadBalancerConfig.IPAMType = infrav1.DHCP
+		hvCluster.Spec.VMNetworkConfig = nil
+
+		Expect(auditedIPPools(hvCluster)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("reconcileIPPoolAudit", func() {
+	It("should report the allocations whose owner no longer exists", func() {
+		hvCluster := newAuditHarvesterCluster()
+		vmPool, lbPool := newAuditPools()
+		guestLB := &lbv1beta1.LoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: "guest-svc-lb", Namespace: "default"}}
+
+		r, scope := newAuditScope(hvCluster, newAuditOwners(), vmPool, lbPool, guestLB)
+
+		next := r.reconcileIPPoolAudit(scope)
+		Expect(next).To(Equal(ipPoolAuditInterval))
+		Expect(hvCluster.Status.LastIPPoolAuditTime).ToNot(BeNil())
+		Expect(leakedOwners(hvCluster)).To(ConsistOf(
+			"test-ns/gone-machine", "test-ns/gone-machine/default/storage", "default/gone-lb"))
+
+		condition := conditions.Get(hvCluster, infrav1.IPPoolAllocationsHealthyCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrav1.IPPoolAllocationsLeakedReason))
+		Expect(condition.Message).To(ContainSubstring("3 IP pool allocation(s) without owner"))
+		Expect(condition.Message).To(ContainSubstring(infrav1.ReleaseLeakedIPsAnnotation))
+
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolLeakedAllocations.WithLabelValues(
+			"test-ns/test-hv-cluster", "vm-pool"))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolLeakedAllocations.WithLabelValues(
+			"test-ns/test-hv-cluster", "lb-pool"))).To(Equal(1.0))
+	})
+
+	It("should count the VMs of the target namespace as owners", func() {
+		hvCluster := newAuditHarvesterCluster()
+		vmPool, lbPool := newAuditPools()
+		vm := &kubevirtv1.VirtualMachine{ObjectMeta: metav1.ObjectMeta{Name: "gone-machine", Namespace: "default"}}
+		guestLB := &lbv1beta1.LoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: "gone-lb", Namespace: "default"}}
+
+		r, scope := newAuditScope(hvCluster, newAuditOwners(), vmPool, lbPool, vm, guestLB)
+
+		r.reconcileIPPoolAudit(scope)
+		Expect(leakedOwners(hvCluster)).To(ConsistOf("default/guest-svc-lb"))
+	})
+
+	It("should report healthy pools", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Spec.LoadBalancerConfig.IPAMType = infrav1.DHCP
+
+		vmPool := newPerNICPool("vm-pool", "", "172.16.0.0/16", "172.16.3.10", "172.16.3.50")
+		vmPool.Status.Allocated = map[string]string{"172.16.3.10": "test-ns/worker-0"}
+
+		r, scope := newAuditScope(hvCluster, newAuditOwners(), vmPool)
+
+		r.reconcileIPPoolAudit(scope)
+		Expect(hvCluster.Status.LeakedIPAllocations).To(BeEmpty())
+		Expect(conditions.IsTrue(hvCluster, infrav1.IPPoolAllocationsHealthyCondition)).To(BeTrue())
+	})
+
+	It("should not audit again before the interval", func() {
+		hvCluster := newAuditHarvesterCluster()
+		lastAudit := metav1.NewTime(time.Now().Add(-time.Minute))
+		hvCluster.Status.LastIPPoolAuditTime = &lastAudit
+		vmPool, lbPool := newAuditPools()
+
+		r, scope := newAuditScope(hvCluster, newAuditOwners(), vmPool, lbPool)
+
+		next := r.reconcileIPPoolAudit(scope)
+		Expect(next).To(BeNumerically("<=", ipPoolAuditInterval-time.Minute))
+		Expect(next).To(BeNumerically(">", 0))
+		Expect(hvCluster.Status.LastIPPoolAuditTime).To(Equal(&lastAudit))
+		Expect(hvCluster.Status.LeakedIPAllocations).To(BeEmpty())
+	})
+
+	It("should not release leaked allocations without the annotation", func() {
+		hvCluster := newAuditHarvesterCluster()
+		vmPool, lbPool := newAuditPools()
+
+		r, scope := newAuditScope(hvCluster, newAuditOwners(), vmPool, lbPool)
+
+		r.reconcileIPPoolAudit(scope)
+		hvCluster.Status.LastIPPoolAuditTime = nil
+		r.reconcileIPPoolAudit(scope)
+
+		pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), "vm-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pool.Status.Allocated).To(HaveKeyWithValue("172.16.3.14", "test-ns/gone-machine"))
+		Expect(leakedOwners(hvCluster)).To(HaveLen(3))
+	})
+
+	It("should release leaked allocations found by two audits when the annotation is set", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Annotations = map[string]string{infrav1.ReleaseLeakedIPsAnnotation: "true"}
+		vmPool, lbPool := newAuditPools()
+		guestLB := &lbv1beta1.LoadBalancer{ObjectMeta: metav1.ObjectMeta{Name: "guest-svc-lb", Namespace: "default"}}
+
+		r, scope := newAuditScope(hvCluster, newAuditOwners(), vmPool, lbPool, guestLB)
+
+		// The first audit only reports the leaked allocations.
+		r.reconcileIPPoolAudit(scope)
+		Expect(leakedOwners(hvCluster)).To(HaveLen(3))
+		Expect(conditions.Get(hvCluster, infrav1.IPPoolAllocationsHealthyCondition).Message).To(
+			ContainSubstring("released at the next audit"))
+
+		before := testutil.ToFloat64(caphvmetrics.IPPoolLeakedReleasesTotal)
+
+		hvCluster.Status.LastIPPoolAuditTime = nil
+		r.reconcileIPPoolAudit(scope)
+
+		Expect(hvCluster.Status.LeakedIPAllocations).To(BeEmpty())
+		Expect(conditions.IsTrue(hvCluster, infrav1.IPPoolAllocationsHealthyCondition)).To(BeTrue())
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolLeakedReleasesTotal) - before).To(Equal(3.0))
+
+		pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), "vm-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pool.Status.Allocated).ToNot(HaveKey("172.16.3.14"))
+		Expect(pool.Status.Allocated).ToNot(HaveKey("172.16.3.15"))
+		Expect(pool.Status.Allocated).To(HaveKeyWithValue("172.16.3.16", "other-ns/other-machine"))
+		Expect(pool.Status.Allocated).To(HaveLen(5))
+	})
+
+	It("should report an audit failure without blocking", func() {
+		hvCluster := newAuditHarvesterCluster()
+		vmPool, lbPool := newAuditPools()
+
+		_, scope := newAuditScope(hvCluster, nil, vmPool, lbPool)
+
+		// A client whose scheme lacks the IPAM types cannot list the claims.
+		scheme := runtime.NewScheme()
+		_ = infrav1.AddToScheme(scheme)
+		r := &HarvesterClusterReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).Build(), Scheme: scheme}
+
+		next := r.reconcileIPPoolAudit(scope)
+		Expect(next).To(Equal(ipPoolAuditInterval))
+
+		condition := conditions.Get(hvCluster, infrav1.IPPoolAllocationsHealthyCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionUnknown))
+		Expect(condition.Reason).To(Equal(infrav1.IPPoolAuditFailedReason))
+	})
+})
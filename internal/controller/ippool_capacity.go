@@ -0,0 +1,334 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	ipamv1 "sigs.k8s.io/cluster-api/api/ipam/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// ipPoolCapacityInterval is the period of the IP pool capacity check.
+const ipPoolCapacityInterval = 5 * time.Minute
+
+// defaultIPPoolFreeThreshold applies when spec.ipPoolFreeThreshold is not set.
+var defaultIPPoolFreeThreshold = intstr.FromString("10%")
+
+// caphvIPPoolOwners holds what identifies the allocations made by CAPHV for any
+// cluster: the namespaces of the HarvesterClusters, where machines, pool
+// instances and claims live, and the control plane load balancers.
+type caphvIPPoolOwners struct {
+	namespaces map[string]struct{}
+	lbOwners   map[string]struct{}
+}
+
+// owns reports whether the allocation owner ownerID was allocated by CAPHV.
+func (o *caphvIPPoolOwners) owns(ownerID string) bool {
+	if _, ok := o.lbOwners[ownerID]; ok {
+		return true
+	}
+
+	namespace, _, _ := strings.Cut(ownerID, "/")
+	_, ok := o.namespaces[namespace]
+
+	return ok
+}
+
+// ipPoolReporters records the clusters publishing the capacity metrics of each
+// IP pool, as a pool can be shared by several clusters.
+var ipPoolReporters = struct {
+	sync.Mutex
+
+	clusters map[string]map[string]struct{}
+}{clusters: map[string]map[string]struct{}{}}
+
+// trackReportedIPPools records the pools whose capacity metrics the cluster
+// publishes, and deletes the metrics of the pools no cluster publishes anymore.
+// A deleted cluster publishes none.
+func trackReportedIPPools(clusterName string, pools []string) {
+	ipPoolReporters.Lock()
+	defer ipPoolReporters.Unlock()
+
+	for poolName, clusters := range ipPoolReporters.clusters {
+		if slices.Contains(pools, poolName) {
+			continue
+		}
+
+		delete(clusters, clusterName)
+
+		if len(clusters) == 0 {
+			delete(ipPoolReporters.clusters, poolName)
+			deleteIPPoolMetrics(poolName)
+		}
+	}
+
+	for _, poolName := range pools {
+		if ipPoolReporters.clusters[poolName] == nil {
+			ipPoolReporters.clusters[poolName] = map[string]struct{}{}
+		}
+
+		ipPoolReporters.clusters[poolName][clusterName] = struct{}{}
+	}
+}
+
+// deleteIPPoolMetrics deletes the capacity metrics of an IP pool.
+func deleteIPPoolMetrics(poolName string) {
+	caphvmetrics.IPPoolTotalAddresses.DeleteLabelValues(poolName)
+	caphvmetrics.IPPoolAllocatedAddresses.DeleteLabelValues(poolName)
+	caphvmetrics.IPPoolAvailableAddresses.DeleteLabelValues(poolName)
+	caphvmetrics.IPPoolCAPHVAllocatedAddresses.DeleteLabelValues(poolName)
+	caphvmetrics.IPPoolClusterAllocatedAddresses.DeletePartialMatch(prometheus.Labels{"pool": poolName})
+}
+
+// reconcileIPPoolCapacity publishes the capacity metrics of the IP pools of the
+// cluster and warns in the IPPoolCapacityAvailable condition when one of them
+// has fewer free addresses than spec.ipPoolFreeThreshold. It returns the time
+// until the next check, or zero when the cluster uses no IP pool.
+// This is a best-effort operation: errors are reported in the condition but
+// don't block cluster provisioning.
+func (r *HarvesterClusterReconciler) reconcileIPPoolCapacity(scope *ClusterScope) time.Duration {
+	pools := auditedIPPools(scope.HarvesterCluster)
+
+	trackReportedIPPools(scope.HarvesterCluster.Namespace+"/"+scope.HarvesterCluster.Name, pools)
+
+	if len(pools) == 0 {
+		return 0
+	}
+
+	err := r.checkIPPoolCapacity(scope, pools)
+	if err != nil {
+		scope.Logger.Info("Warning: failed to check the IP pool capacity", "error", err)
+
+		conditions.Set(scope.HarvesterCluster, metav1.Condition{
+			Type:    infrav1.IPPoolCapacityAvailableCondition,
+			Status:  metav1.ConditionUnknown,
+			Reason:  infrav1.IPPoolCapacityCheckFailedReason,
+			Message: fmt.Sprintf("Failed to check the IP pool capacity: %v", err),
+		})
+	}
+
+	return ipPoolCapacityInterval
+}
+
+// checkIPPoolCapacity records the capacity of pools in the metrics and in the
+// IPPoolCapacityAvailable condition of the cluster.
+func (r *HarvesterClusterReconciler) checkIPPoolCapacity(scope *ClusterScope, pools []string) error {
+	cluster := scope.HarvesterCluster
+	clusterName := cluster.Namespace + "/" + cluster.Name
+
+	threshold := defaultIPPoolFreeThreshold
+	if cluster.Spec.IPPoolFreeThreshold != nil {
+		threshold = *cluster.Spec.IPPoolFreeThreshold
+	}
+
+	caphvOwners, err := r.listCAPHVIPPoolOwners(scope)
+	if err != nil {
+		return err
+	}
+
+	clusterOwners, err := r.listClusterIPPoolOwners(scope)
+	if err != nil {
+		return err
+	}
+
+	exhausted := make([]string, 0)
+	low := make([]string, 0)
+
+	caphvmetrics.IPPoolClusterAllocatedAddresses.DeletePartialMatch(prometheus.Labels{"cluster": clusterName})
+
+	for _, poolName := range pools {
+		pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(scope.Ctx, poolName, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				deleteIPPoolMetrics(poolName)
+
+				continue
+			}
+
+			return errors.Wrapf(err, "failed to get IP pool %s", poolName)
+		}
+
+		caphvAllocated, clusterAllocated := 0, 0
+
+		for _, owner := range pool.Status.Allocated {
+			if caphvOwners.owns(owner) {
+				caphvAllocated++
+			}
+
+			if _, alive := clusterOwners.owns(owner); alive {
+				clusterAllocated++
+			}
+		}
+
+		caphvmetrics.IPPoolTotalAddresses.WithLabelValues(poolName).Set(float64(pool.Status.Total))
+		caphvmetrics.IPPoolAllocatedAddresses.WithLabelValues(poolName).Set(float64(len(pool.Status.Allocated)))
+		caphvmetrics.IPPoolAvailableAddresses.WithLabelValues(poolName).Set(float64(pool.Status.Available))
+		caphvmetrics.IPPoolCAPHVAllocatedAddresses.WithLabelValues(poolName).Set(float64(caphvAllocated))
+		caphvmetrics.IPPoolClusterAllocatedAddresses.WithLabelValues(clusterName, poolName).Set(float64(clusterAllocated))
+
+		minFree, err := intstr.GetScaledValueFromIntOrPercent(&threshold, int(pool.Status.Total), true)
+		if err != nil {
+			return errors.Wrapf(err, "invalid IP pool free threshold %q", threshold.String())
+		}
+
+		entry := fmt.Sprintf("%s (%d/%d free)", poolName, pool.Status.Available, pool.Status.Total)
+
+		switch {
+		case pool.Status.Available <= 0:
+			exhausted = append(exhausted, entry)
+		case pool.Status.Available < int64(minFree):
+			low = append(low, entry)
+		}
+	}
+
+	switch {
+	case len(exhausted) > 0:
+		message := "IP pools without free address: " + strings.Join(exhausted, ", ")
+		if len(low) > 0 {
+			message += fmt.Sprintf("; IP pools below the free threshold of %s: %s", threshold.String(), strings.Join(low, ", "))
+		}
+
+		conditions.Set(cluster, metav1.Condition{
+			Type:    infrav1.IPPoolCapacityAvailableCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.IPPoolCapacityExhaustedReason,
+			Message: message,
+		})
+	case len(low) > 0:
+		conditions.Set(cluster, metav1.Condition{
+			Type:   infrav1.IPPoolCapacityAvailableCondition,
+			Status: metav1.ConditionFalse,
+			Reason: infrav1.IPPoolCapacityLowReason,
+			Message: fmt.Sprintf("IP pools below the free threshold of %s: %s",
+				threshold.String(), strings.Join(low, ", ")),
+		})
+	default:
+		conditions.Set(cluster, metav1.Condition{
+			Type:    infrav1.IPPoolCapacityAvailableCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.IPPoolCapacityAvailableReason,
+			Message: fmt.Sprintf("The IP pools %v have at least %s of free addresses", pools, threshold.String()),
+		})
+	}
+
+	return nil
+}
+
+// listCAPHVIPPoolOwners lists what identifies the allocations made by CAPHV.
+func (r *HarvesterClusterReconciler) listCAPHVIPPoolOwners(scope *ClusterScope) (*caphvIPPoolOwners, error) {
+	harvesterClusters := &infrav1.HarvesterClusterList{}
+
+	err := r.List(scope.Ctx, harvesterClusters)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list HarvesterClusters")
+	}
+
+	owners := &caphvIPPoolOwners{
+		namespaces: make(map[string]struct{}),
+		lbOwners:   make(map[string]struct{}),
+	}
+
+	for _, hvCluster := range harvesterClusters.Items {
+		owners.namespaces[hvCluster.Namespace] = struct{}{}
+
+		lbName := locutil.GenerateRFC1035Name([]string{hvCluster.Namespace, hvCluster.Name, "lb"})
+		owners.lbOwners[hvCluster.Spec.TargetNamespace+"/"+lbName] = struct{}{}
+	}
+
+	return owners, nil
+}
+
+// listClusterIPPoolOwners lists the objects of the cluster that can own an
+// allocation: its HarvesterMachines, HarvesterMachinePool instances and
+// IPAddressClaims, and its control plane load balancer.
+func (r *HarvesterClusterReconciler) listClusterIPPoolOwners(scope *ClusterScope) (*ipPoolOwners, error) {
+	cluster := scope.HarvesterCluster
+
+	owners := &ipPoolOwners{
+		namespace:   cluster.Namespace,
+		names:       make(map[string]struct{}),
+		claimNames:  make(map[string]struct{}),
+		lbNamespace: cluster.Spec.TargetNamespace,
+		lbNames: map[string]struct{}{
+			locutil.GenerateRFC1035Name([]string{cluster.Namespace, cluster.Name, "lb"}): {},
+		},
+	}
+
+	if scope.Cluster == nil {
+		return owners, nil
+	}
+
+	listOptions := []client.ListOption{
+		client.InNamespace(cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: scope.Cluster.Name},
+	}
+
+	machines := &infrav1.HarvesterMachineList{}
+
+	err := r.List(scope.Ctx, machines, listOptions...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list HarvesterMachines")
+	}
+
+	for _, machine := range machines.Items {
+		owners.names[machine.Name] = struct{}{}
+	}
+
+	machinePools := &infrav1.HarvesterMachinePoolList{}
+
+	err = r.List(scope.Ctx, machinePools, listOptions...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list HarvesterMachinePools")
+	}
+
+	for _, pool := range machinePools.Items {
+		for _, instance := range pool.Status.Instances {
+			owners.names[instance.InstanceName] = struct{}{}
+		}
+	}
+
+	claims := &ipamv1.IPAddressClaimList{}
+
+	err = r.List(scope.Ctx, claims, listOptions...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list IPAddressClaims")
+	}
+
+	for _, claim := range claims.Items {
+		owners.claimNames[claim.Name] = struct{}{}
+	}
+
+	return owners, nil
+}
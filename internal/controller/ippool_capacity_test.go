@@ -0,0 +1,198 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	caphvmetrics "github.com/rancher-sandbox/cluster-api-provider-harvester/internal/metrics"
+)
+
+// =============================================================================
+// Tests for the IP pool capacity check
+// =============================================================================
+
+func newCapacityPool(name string, total int64, allocated map[string]string) *lbv1beta1.IPPool {
+	pool := newPerNICPool(name, "", "172.16.0.0/16", "172.16.3.10", "172.16.3.50")
+	pool.Status.Allocated = allocated
+	pool.Status.Total = total
+	pool.Status.Available = total - int64(len(allocated))
+
+	return pool
+}
+
+func newCapacityOwners() []client.Object {
+	clusterLabels := map[string]string{clusterv1.ClusterNameLabel: "test-cluster"}
+
+	return []client.Object{
+		&infrav1.HarvesterMachine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns", Labels: clusterLabels}},
+		&infrav1.HarvesterMachine{ObjectMeta: metav1.ObjectMeta{
+			Name: "other-0", Namespace: "test-ns",
+			Labels: map[string]string{clusterv1.ClusterNameLabel: "other-cluster"},
+		}},
+		&infrav1.HarvesterMachinePool{
+			ObjectMeta: metav1.ObjectMeta{Name: "pool-a", Namespace: "test-ns", Labels: clusterLabels},
+			Status: infrav1.HarvesterMachinePoolStatus{
+				Instances: []infrav1.HarvesterMachinePoolInstanceStatus{{InstanceName: "pool-a-x1y2z"}},
+			},
+		},
+	}
+}
+
+func newCapacityScope(hvCluster *infrav1.HarvesterCluster, pools ...*lbv1beta1.IPPool) (
+	*HarvesterClusterReconciler, *ClusterScope,
+) {
+	hvObjs := make([]runtime.Object, 0, len(pools))
+	for _, pool := range pools {
+		hvObjs = append(hvObjs, pool)
+	}
+
+	r, scope := newAuditScope(hvCluster, newCapacityOwners(), hvObjs...)
+	scope.Cluster = &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "test-cluster", Namespace: "test-ns"}}
+
+	return r, scope
+}
+
+var _ = Describe("reconcileIPPoolCapacity", func() {
+	It("should publish the capacity of the pools of the cluster", func() {
+		hvCluster := newAuditHarvesterCluster()
+		vmPool := newCapacityPool("vm-pool", 41, map[string]string{
+			"172.16.3.10": "test-ns/worker-0",
+			"172.16.3.11": "test-ns/worker-0/default/storage",
+			"172.16.3.12": "test-ns/pool-a-x1y2z",
+			"172.16.3.13": "test-ns/other-0",
+			"172.16.3.14": "guest-ns/guest-svc-lb",
+		})
+		lbPool := newCapacityPool("lb-pool", 41, map[string]string{
+			"172.16.4.10": "default/test-ns-test-hv-cluster-lb",
+		})
+
+		r, scope := newCapacityScope(hvCluster, vmPool, lbPool)
+
+		next := r.reconcileIPPoolCapacity(scope)
+		Expect(next).To(Equal(ipPoolCapacityInterval))
+
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolTotalAddresses.WithLabelValues("vm-pool"))).To(Equal(41.0))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolAllocatedAddresses.WithLabelValues("vm-pool"))).To(Equal(5.0))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolAvailableAddresses.WithLabelValues("vm-pool"))).To(Equal(36.0))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolCAPHVAllocatedAddresses.WithLabelValues("vm-pool"))).To(Equal(4.0))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolClusterAllocatedAddresses.WithLabelValues(
+			"test-ns/test-hv-cluster", "vm-pool"))).To(Equal(3.0))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolCAPHVAllocatedAddresses.WithLabelValues("lb-pool"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolClusterAllocatedAddresses.WithLabelValues(
+			"test-ns/test-hv-cluster", "lb-pool"))).To(Equal(1.0))
+
+		Expect(conditions.IsTrue(hvCluster, infrav1.IPPoolCapacityAvailableCondition)).To(BeTrue())
+	})
+
+	It("should warn when a pool drops below the default threshold", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Spec.LoadBalancerConfig.IPAMType = infrav1.DHCP
+
+		allocated := map[string]string{}
+		for _, ip := range []string{"172.16.3.10", "172.16.3.11", "172.16.3.12", "172.16.3.13", "172.16.3.14",
+			"172.16.3.15", "172.16.3.16", "172.16.3.17", "172.16.3.18"} {
+			allocated[ip] = "test-ns/worker-0"
+		}
+
+		r, scope := newCapacityScope(hvCluster, newCapacityPool("vm-pool", 10, allocated))
+
+		r.reconcileIPPoolCapacity(scope)
+
+		condition := conditions.Get(hvCluster, infrav1.IPPoolCapacityAvailableCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+
+		threshold := intstr.FromInt32(2)
+		hvCluster.Spec.IPPoolFreeThreshold = &threshold
+
+		r.reconcileIPPoolCapacity(scope)
+
+		condition = conditions.Get(hvCluster, infrav1.IPPoolCapacityAvailableCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrav1.IPPoolCapacityLowReason))
+		Expect(condition.Message).To(ContainSubstring("vm-pool (1/10 free)"))
+	})
+
+	It("should report exhausted pools", func() {
+		hvCluster := newAuditHarvesterCluster()
+		vmPool := newCapacityPool("vm-pool", 1, map[string]string{"172.16.3.10": "test-ns/worker-0"})
+		lbPool := newCapacityPool("lb-pool", 100, map[string]string{})
+		lbPool.Status.Available = 5
+
+		r, scope := newCapacityScope(hvCluster, vmPool, lbPool)
+
+		r.reconcileIPPoolCapacity(scope)
+
+		condition := conditions.Get(hvCluster, infrav1.IPPoolCapacityAvailableCondition)
+		Expect(condition).ToNot(BeNil())
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrav1.IPPoolCapacityExhaustedReason))
+		Expect(condition.Message).To(ContainSubstring("vm-pool (0/1 free)"))
+		Expect(condition.Message).To(ContainSubstring("lb-pool (5/100 free)"))
+	})
+
+	It("should skip clusters without IP pools", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Spec.LoadBalancerConfig.IPAMType = infrav1.DHCP
+		hvCluster.Spec.VMNetworkConfig = nil
+
+		r, scope := newCapacityScope(hvCluster)
+
+		Expect(r.reconcileIPPoolCapacity(scope)).To(BeZero())
+		Expect(conditions.Get(hvCluster, infrav1.IPPoolCapacityAvailableCondition)).To(BeNil())
+	})
+
+	It("should delete the metrics of a shared pool once no cluster reports it", func() {
+		trackReportedIPPools("ns/cluster-a", []string{"shared-pool"})
+		trackReportedIPPools("ns/cluster-b", []string{"shared-pool"})
+		caphvmetrics.IPPoolTotalAddresses.WithLabelValues("shared-pool").Set(10)
+		caphvmetrics.IPPoolClusterAllocatedAddresses.WithLabelValues("ns/cluster-b", "shared-pool").Set(1)
+
+		trackReportedIPPools("ns/cluster-a", nil)
+		Expect(testutil.ToFloat64(caphvmetrics.IPPoolTotalAddresses.WithLabelValues("shared-pool"))).To(Equal(10.0))
+
+		trackReportedIPPools("ns/cluster-b", nil)
+		// DeleteLabelValues reports whether the series still existed.
+		Expect(caphvmetrics.IPPoolTotalAddresses.DeleteLabelValues("shared-pool")).To(BeFalse())
+		Expect(caphvmetrics.IPPoolClusterAllocatedAddresses.DeleteLabelValues("ns/cluster-b", "shared-pool")).To(BeFalse())
+	})
+
+	It("should delete the metrics of a pool that no longer exists", func() {
+		hvCluster := newAuditHarvesterCluster()
+		hvCluster.Spec.LoadBalancerConfig.IPAMType = infrav1.DHCP
+		caphvmetrics.IPPoolTotalAddresses.WithLabelValues("vm-pool").Set(10)
+
+		r, scope := newCapacityScope(hvCluster)
+
+		r.reconcileIPPoolCapacity(scope)
+		Expect(caphvmetrics.IPPoolTotalAddresses.DeleteLabelValues("vm-pool")).To(BeFalse())
+	})
+})
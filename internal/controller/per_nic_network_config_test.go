@@ -0,0 +1,282 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for the per-NIC network configuration (spec.*.interfaces)
+// =============================================================================
+
+func newPerNICPool(name, network, subnet, rangeStart, rangeEnd string) *lbv1beta1.IPPool {
+	return &lbv1beta1.IPPool{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: lbv1beta1.IPPoolSpec{
+			Ranges: []lbv1beta1.Range{
+				{RangeStart: rangeStart, RangeEnd: rangeEnd, Subnet: subnet},
+			},
+			Selector: lbv1beta1.Selector{Network: network},
+		},
+		Status: lbv1beta1.IPPoolStatus{Allocated: map[string]string{}},
+	}
+}
+
+func newPerNICScope(vmNetCfg *infrav1.VMNetworkConfig, pools ...*lbv1beta1.IPPool) *Scope {
+	objs := make([]runtime.Object, 0, len(pools))
+	for _, pool := range pools {
+		objs = append(objs, pool)
+	}
+
+	logger := log.FromContext(context.TODO())
+
+	return &Scope{
+		Ctx: context.TODO(),
+		HarvesterMachine: &infrav1.HarvesterMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns"},
+			Spec: infrav1.HarvesterMachineSpec{
+				Networks: []string{"default/production", "default/storage", "default/mgmt"},
+			},
+		},
+		HarvesterCluster: &infrav1.HarvesterCluster{
+			Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default", VMNetworkConfig: vmNetCfg},
+		},
+		HarvesterClient: hvfake.NewSimpleClientset(objs...),
+		Logger:          &logger,
+	}
+}
+
+var _ = Describe("buildNetworkDataStatic per-NIC", func() {
+	It("should configure each NIC from its interfaces entry", func() {
+		scope := newPerNICScope(nil)
+		scope.HarvesterMachine.Status.InterfaceAllocations = []infrav1.InterfaceAllocation{
+			{Network: "default/mgmt", IPAddress: "10.30.0.15", PoolRef: "mgmt-pool"},
+		}
+		scope.EffectiveNetworkConfig = &infrav1.NetworkConfig{
+			Address:    "172.16.3.40",
+			Gateway:    "172.16.0.1",
+			DNSServers: []string{"172.16.0.1"},
+			Interfaces: []infrav1.InterfaceConfig{
+				{
+					Network:    "default/storage",
+					Addressing: infrav1.InterfaceAddressingStatic,
+					Address:    "10.20.0.40",
+					SubnetMask: "255.255.255.0",
+					MTU:        9000,
+					Routes:     []infrav1.Route{{To: "10.21.0.0/16", Via: "10.20.0.1", Metric: ptr.To(int32(100))}},
+				},
+				{Network: "mgmt", Addressing: infrav1.InterfaceAddressingPool, SubnetMask: "255.255.255.0"},
+			},
+		}
+
+		result := buildNetworkDataStatic(scope)
+
+		// eth0 keeps the default static configuration
+		Expect(result).To(ContainSubstring(`  - type: physical
+    name: eth0
+    subnets:
+      - type: static
+        address: 172.16.3.40
+        netmask: 255.255.0.0
+        gateway: 172.16.0.1
+`))
+		Expect(result).To(ContainSubstring(`  - type: physical
+    name: eth1
+    mtu: 9000
+    subnets:
+      - type: static
+        address: 10.20.0.40
+        netmask: 255.255.255.0
+        routes:
+          - network: 10.21.0.0
+            netmask: 255.255.0.0
+            gateway: 10.20.0.1
+            metric: 100
+`))
+		// The unqualified network reference matches default/mgmt
+		Expect(result).To(ContainSubstring(`  - type: physical
+    name: eth2
+    subnets:
+      - type: static
+        address: 10.30.0.15
+        netmask: 255.255.255.0
+`))
+		Expect(result).To(ContainSubstring("type: nameserver"))
+	})
+
+	It("should support DHCP and unaddressed NICs, including the first one", func() {
+		scope := newPerNICScope(nil)
+		scope.EffectiveNetworkConfig = &infrav1.NetworkConfig{
+			Interfaces: []infrav1.InterfaceConfig{
+				{Network: "default/production", Addressing: infrav1.InterfaceAddressingDHCP},
+				{Network: "default/storage", Addressing: infrav1.InterfaceAddressingNone, MTU: 9000},
+				{Network: "default/mgmt", Addressing: infrav1.InterfaceAddressingStatic, Address: "10.30.0.5",
+					SubnetMask: "255.255.255.0", Gateway: "10.30.0.1"},
+			},
+		}
+
+		result := buildNetworkDataStatic(scope)
+
+		Expect(result).To(ContainSubstring("    name: eth0\n    subnets:\n      - type: dhcp\n"))
+		Expect(result).To(ContainSubstring("    name: eth1\n    mtu: 9000\n    subnets:\n      - type: manual\n"))
+		Expect(result).To(ContainSubstring("        address: 10.30.0.5\n        netmask: 255.255.255.0\n        gateway: 10.30.0.1\n"))
+		Expect(result).ToNot(ContainSubstring("type: nameserver"))
+	})
+})
+
+var _ = Describe("allocateInterfaceIPs", func() {
+	It("should allocate one IP per pool-addressed NIC from the pool of its network", func() {
+		vmNetCfg := &infrav1.VMNetworkConfig{
+			IPPoolRefs: []string{"production-pool", "storage-pool"},
+			Gateway:    "172.16.0.1",
+			SubnetMask: "255.255.0.0",
+			Interfaces: []infrav1.InterfaceConfig{
+				{Network: "default/storage", Addressing: infrav1.InterfaceAddressingPool, SubnetMask: "255.255.255.0"},
+				{Network: "default/mgmt", Addressing: infrav1.InterfaceAddressingPool, SubnetMask: "255.255.255.0",
+					IPPoolRefs: []string{"mgmt-pool"}},
+			},
+		}
+		scope := newPerNICScope(vmNetCfg,
+			newPerNICPool("production-pool", "default/production", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"),
+			newPerNICPool("storage-pool", "default/storage", "10.20.0.0/24", "10.20.0.40", "10.20.0.49"),
+			newPerNICPool("mgmt-pool", "", "10.30.0.0/24", "10.30.0.10", "10.30.0.19"),
+		)
+		r := &HarvesterMachineReconciler{}
+
+		Expect(r.allocateInterfaceIPs(scope)).To(Succeed())
+
+		allocations := scope.HarvesterMachine.Status.InterfaceAllocations
+		Expect(allocations).To(HaveLen(2))
+		Expect(allocations[0].Network).To(Equal("default/storage"))
+		Expect(allocations[0].PoolRef).To(Equal("storage-pool"))
+		Expect(allocations[0].IPAddress).To(HavePrefix("10.20.0."))
+		Expect(allocations[1].PoolRef).To(Equal("mgmt-pool"))
+
+		storagePool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(
+			context.TODO(), "storage-pool", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(storagePool.Status.Allocated).To(HaveKeyWithValue(allocations[0].IPAddress, "test-ns/worker-0/default/storage"))
+
+		// Idempotent: a second pass keeps the recorded allocations
+		Expect(r.allocateInterfaceIPs(scope)).To(Succeed())
+		Expect(scope.HarvesterMachine.Status.InterfaceAllocations).To(HaveLen(2))
+	})
+
+	It("should fail when no pool matches the network of the NIC", func() {
+		vmNetCfg := &infrav1.VMNetworkConfig{
+			IPPoolRef: "production-pool",
+			Interfaces: []infrav1.InterfaceConfig{
+				{Network: "default/storage", Addressing: infrav1.InterfaceAddressingPool, SubnetMask: "255.255.255.0"},
+			},
+		}
+		scope := newPerNICScope(vmNetCfg,
+			newPerNICPool("production-pool", "default/production", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"))
+		r := &HarvesterMachineReconciler{}
+
+		err := r.allocateInterfaceIPs(scope)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("default/storage"))
+		Expect(scope.HarvesterMachine.Status.InterfaceAllocations).To(BeEmpty())
+	})
+})
+
+var _ = Describe("resolveNetworkConfig per-NIC", func() {
+	It("should skip the primary pool allocation when an entry configures the first network", func() {
+		vmNetCfg := &infrav1.VMNetworkConfig{
+			IPPoolRef:  "production-pool",
+			Gateway:    "172.16.0.1",
+			SubnetMask: "255.255.0.0",
+			Interfaces: []infrav1.InterfaceConfig{
+				{Network: "default/production", Addressing: infrav1.InterfaceAddressingDHCP},
+				{Network: "default/storage", Addressing: infrav1.InterfaceAddressingPool, SubnetMask: "255.255.255.0",
+					IPPoolRefs: []string{"storage-pool"}},
+			},
+		}
+		scope := newPerNICScope(vmNetCfg,
+			newPerNICPool("production-pool", "default/production", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"),
+			newPerNICPool("storage-pool", "default/storage", "10.20.0.0/24", "10.20.0.40", "10.20.0.49"),
+		)
+		r := &HarvesterMachineReconciler{}
+
+		Expect(r.resolveNetworkConfig(scope)).To(Succeed())
+		Expect(scope.HarvesterMachine.Status.AllocatedIPAddress).To(BeEmpty())
+		Expect(scope.HarvesterMachine.Status.InterfaceAllocations).To(HaveLen(1))
+		Expect(scope.EffectiveNetworkConfig).ToNot(BeNil())
+		Expect(scope.EffectiveNetworkConfig.Interfaces).To(HaveLen(2))
+	})
+
+	It("should allocate the primary IP and the NIC IPs for a pool-based configuration", func() {
+		vmNetCfg := &infrav1.VMNetworkConfig{
+			IPPoolRef:  "production-pool",
+			Gateway:    "172.16.0.1",
+			SubnetMask: "255.255.0.0",
+			Interfaces: []infrav1.InterfaceConfig{
+				{Network: "default/storage", Addressing: infrav1.InterfaceAddressingPool, SubnetMask: "255.255.255.0",
+					IPPoolRefs: []string{"storage-pool"}},
+			},
+		}
+		scope := newPerNICScope(vmNetCfg,
+			newPerNICPool("production-pool", "default/production", "172.16.0.0/16", "172.16.3.40", "172.16.3.49"),
+			newPerNICPool("storage-pool", "default/storage", "10.20.0.0/24", "10.20.0.40", "10.20.0.49"),
+		)
+		r := &HarvesterMachineReconciler{}
+
+		Expect(r.resolveNetworkConfig(scope)).To(Succeed())
+		Expect(scope.HarvesterMachine.Status.AllocatedIPAddress).To(HavePrefix("172.16.3."))
+		Expect(scope.EffectiveNetworkConfig.Address).To(Equal(scope.HarvesterMachine.Status.AllocatedIPAddress))
+		Expect(allocatedVMIPs(&scope.HarvesterMachine.Status)).To(HaveLen(2))
+	})
+})
+
+var _ = Describe("releaseVMIP per-NIC", func() {
+	It("should release the primary and every NIC allocation", func() {
+		productionPool := newPerNICPool("production-pool", "", "172.16.0.0/16", "172.16.3.40", "172.16.3.49")
+		productionPool.Status.Allocated["172.16.3.40"] = "test-ns/worker-0"
+		storagePool := newPerNICPool("storage-pool", "", "10.20.0.0/24", "10.20.0.40", "10.20.0.49")
+		storagePool.Status.Allocated["10.20.0.40"] = "test-ns/worker-0/default/storage"
+
+		scope := newPerNICScope(&infrav1.VMNetworkConfig{IPPoolRef: "production-pool"}, productionPool, storagePool)
+		scope.HarvesterMachine.Status.AllocatedIPAddress = "172.16.3.40"
+		scope.HarvesterMachine.Status.AllocatedPoolRef = "production-pool"
+		scope.HarvesterMachine.Status.InterfaceAllocations = []infrav1.InterfaceAllocation{
+			{Network: "default/storage", IPAddress: "10.20.0.40", PoolRef: "storage-pool"},
+		}
+		r := &HarvesterMachineReconciler{}
+
+		r.releaseVMIP(scope)
+
+		for _, name := range []string{"production-pool", "storage-pool"} {
+			pool, err := scope.HarvesterClient.LoadbalancerV1beta1().IPPools().Get(context.TODO(), name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pool.Status.Allocated).To(BeEmpty())
+		}
+	})
+})
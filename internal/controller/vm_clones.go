@@ -0,0 +1,273 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v4/apis/volumesnapshot/v1"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// cloneSource holds what the PVC of a "clone" volume takes from its source.
+type cloneSource struct {
+	dataSource   corev1.TypedLocalObjectReference
+	storageClass string
+	size         *resource.Quantity
+	imageID      string
+}
+
+// hasCloneVolumes reports whether a machine has volumes cloned from a
+// VolumeSnapshot or PVC.
+func hasCloneVolumes(machine *infrav1.HarvesterMachine) bool {
+	for _, vol := range machine.Spec.Volumes {
+		if vol.VolumeType == "clone" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getCloneSource gets the VolumeSnapshot or PVC a "clone" volume is cloned
+// from in the namespace. The storage class, size and image of the clone
+// default to those of the source PVC, or of the PVC the snapshot was taken from.
+func getCloneSource(hvScope *Scope, vol *infrav1.Volume, namespace string) (*cloneSource, error) {
+	if vol.CloneSource == nil {
+		return nil, errors.New("cloneSource is required when volumeType is 'clone'")
+	}
+
+	pvcs := hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(namespace)
+	name := vol.CloneSource.Name
+
+	switch vol.CloneSource.Kind {
+	case infrav1.VolumeCloneSourceVolumeSnapshot:
+		snapshot, err := hvScope.HarvesterClient.SnapshotV1().VolumeSnapshots(namespace).Get(hvScope.Ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get VolumeSnapshot %s in namespace %s", name, namespace)
+		}
+
+		source := &cloneSource{
+			dataSource: corev1.TypedLocalObjectReference{
+				APIGroup: ptr.To(snapshotv1.GroupName),
+				Kind:     string(infrav1.VolumeCloneSourceVolumeSnapshot),
+				Name:     name,
+			},
+		}
+
+		if snapshot.Status != nil {
+			source.size = copyQuantity(snapshot.Status.RestoreSize)
+		}
+
+		// The snapshotted PVC may be gone, in which case the volume must set its storage class
+		if claimName := snapshot.Spec.Source.PersistentVolumeClaimName; claimName != nil {
+			pvc, err := pvcs.Get(hvScope.Ctx, *claimName, metav1.GetOptions{})
+			if err == nil {
+				source.storageClass = ptr.Deref(pvc.Spec.StorageClassName, "")
+				source.imageID = pvc.Annotations[hvAnnotationImageID]
+			}
+		}
+
+		return source, nil
+
+	case infrav1.VolumeCloneSourcePersistentVolumeClaim:
+		pvc, err := pvcs.Get(hvScope.Ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get PVC %s in namespace %s", name, namespace)
+		}
+
+		source := &cloneSource{
+			dataSource: corev1.TypedLocalObjectReference{
+				Kind: string(infrav1.VolumeCloneSourcePersistentVolumeClaim),
+				Name: name,
+			},
+			storageClass: ptr.Deref(pvc.Spec.StorageClassName, ""),
+			imageID:      pvc.Annotations[hvAnnotationImageID],
+		}
+
+		if size, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+			source.size = &size
+		}
+
+		return source, nil
+	}
+
+	return nil, errors.Errorf("unsupported cloneSource kind %q", vol.CloneSource.Kind)
+}
+
+// applyCloneSource sets the data source of the PVC of a "clone" volume, along
+// with the storage class, size and image it takes from the source.
+func applyCloneSource(pvc *corev1.PersistentVolumeClaim, vol *infrav1.Volume, source *cloneSource) error {
+	storageClass := vol.StorageClass
+	if storageClass == "" {
+		storageClass = source.storageClass
+	}
+
+	if storageClass == "" {
+		return errors.Errorf("storageClass is required, the storage class of %s %s is unknown",
+			source.dataSource.Kind, source.dataSource.Name)
+	}
+
+	switch {
+	case vol.VolumeSize == nil && source.size == nil:
+		return errors.Errorf("volumeSize is required, the size of %s %s is unknown",
+			source.dataSource.Kind, source.dataSource.Name)
+	case vol.VolumeSize == nil:
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = *source.size
+	case source.size != nil && vol.VolumeSize.Cmp(*source.size) < 0:
+		return errors.Errorf("volumeSize %s is smaller than the %s size of %s %s",
+			vol.VolumeSize.String(), source.size.String(), source.dataSource.Kind, source.dataSource.Name)
+	}
+
+	pvc.Spec.StorageClassName = &storageClass
+	pvc.Spec.DataSource = &source.dataSource
+	pvc.Spec.DataSourceRef = &corev1.TypedObjectReference{
+		APIGroup: source.dataSource.APIGroup,
+		Kind:     source.dataSource.Kind,
+		Name:     source.dataSource.Name,
+	}
+
+	if source.imageID != "" {
+		pvc.Annotations[hvAnnotationImageID] = source.imageID
+	}
+
+	return nil
+}
+
+// checkVolumeCloneSources checks, before the VM is created, that the sources
+// of its "clone" volumes exist in the target namespace, and reports the result
+// in the VolumesCloned condition.
+func checkVolumeCloneSources(hvScope *Scope) error {
+	machine := hvScope.HarvesterMachine
+	if !hasCloneVolumes(machine) {
+		return nil
+	}
+
+	namespace := hvScope.HarvesterCluster.Spec.TargetNamespace
+
+	for i := range machine.Spec.Volumes {
+		vol := &machine.Spec.Volumes[i]
+		if vol.VolumeType != "clone" {
+			continue
+		}
+
+		_, err := getCloneSource(hvScope, vol, namespace)
+		if err != nil {
+			setVolumeCloneFailed(machine, fmt.Sprintf("Volume %d cannot be cloned: %v", i, err))
+
+			return errors.Wrapf(err, "unable to clone volume %d", i)
+		}
+	}
+
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VolumesClonedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  infrav1.VolumeCloningReason,
+		Message: "Waiting for the VM to be created",
+	})
+
+	return nil
+}
+
+// reconcileVolumeClones reports the progress of the "clone" volumes of the VM
+// in the VolumesCloned condition. A clone is ready once its PVC is bound. It
+// reports whether volumes are still being cloned.
+func reconcileVolumeClones(hvScope *Scope, vm *kubevirtv1.VirtualMachine) bool {
+	machine := hvScope.HarvesterMachine
+	if !hasCloneVolumes(machine) || conditions.IsTrue(machine, infrav1.VolumesClonedCondition) {
+		return false
+	}
+
+	var pending []string
+
+	for i, vol := range machine.Spec.Volumes {
+		if vol.VolumeType != "clone" {
+			continue
+		}
+
+		status := findVolumeStatus(machine, i)
+		if status == nil {
+			continue
+		}
+
+		// Harvester creates the PVCs from the VM annotation, so they may not exist yet
+		pvc, err := hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(vm.Namespace).Get(
+			hvScope.Ctx, status.PVCName, metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			hvScope.Logger.Info("Warning: unable to get the PVC of a cloned volume", "pvc", status.PVCName, "error", err)
+		}
+
+		if err != nil {
+			pending = append(pending, status.Name+" waiting for its PVC")
+
+			continue
+		}
+
+		switch pvc.Status.Phase {
+		case corev1.ClaimBound:
+			continue
+		case corev1.ClaimLost:
+			setVolumeCloneFailed(machine, fmt.Sprintf("PVC %s of %s is lost", status.PVCName, status.Name))
+
+			return false
+		}
+
+		pending = append(pending, fmt.Sprintf("%s cloning from %s %s", status.Name, vol.CloneSource.Kind, vol.CloneSource.Name))
+	}
+
+	if len(pending) > 0 {
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.VolumesClonedCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.VolumeCloningReason,
+			Message: strings.Join(pending, ", "),
+		})
+
+		return true
+	}
+
+	conditions.Set(machine, metav1.Condition{
+		Type:   infrav1.VolumesClonedCondition,
+		Status: metav1.ConditionTrue,
+		Reason: infrav1.VolumesClonedReason,
+	})
+
+	return false
+}
+
+// setVolumeCloneFailed reports in the VolumesCloned condition that a volume
+// cannot be cloned.
+func setVolumeCloneFailed(machine *infrav1.HarvesterMachine, message string) {
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VolumesClonedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  infrav1.VolumeCloneFailedReason,
+		Message: message,
+	})
+}
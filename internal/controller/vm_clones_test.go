@@ -0,0 +1,184 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v4/apis/volumesnapshot/v1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// =============================================================================
+// Tests for the volumes cloned from VolumeSnapshots and PVCs
+// =============================================================================
+
+func newCloneMachine(kind infrav1.VolumeCloneSourceKind, name string) *infrav1.HarvesterMachine {
+	machine := newVolumesMachine()
+	machine.Spec.Volumes = []infrav1.Volume{{
+		VolumeType:  "clone",
+		CloneSource: &infrav1.VolumeCloneSource{Kind: kind, Name: name},
+	}}
+
+	return machine
+}
+
+func newGoldenPVC() *corev1.PersistentVolumeClaim {
+	pvc := newVolumesPVC("golden-sles", "80Gi", "80Gi")
+	pvc.Annotations = map[string]string{hvAnnotationImageID: "default/image-sles"}
+	pvc.Spec.StorageClassName = ptr.To("lh-sles")
+
+	return pvc
+}
+
+func newGoldenSnapshot() *snapshotv1.VolumeSnapshot {
+	return &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "golden-sles-snap", Namespace: "default"},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			Source: snapshotv1.VolumeSnapshotSource{PersistentVolumeClaimName: ptr.To("golden-sles")},
+		},
+		Status: &snapshotv1.VolumeSnapshotStatus{RestoreSize: new(resource.MustParse("80Gi"))},
+	}
+}
+
+var _ = Describe("buildPVCForVolume with clone type", func() {
+	It("should clone a PVC with its storage class, size and image", func() {
+		machine := newCloneMachine(infrav1.VolumeCloneSourcePersistentVolumeClaim, "golden-sles")
+		scope := newVolumesScope(machine, newGoldenPVC())
+
+		pvc, err := buildPVCForVolume(&machine.Spec.Volumes[0], nil, "worker-0-disk-0-abcde", "default", scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Spec.DataSource.Kind).To(Equal("PersistentVolumeClaim"))
+		Expect(pvc.Spec.DataSourceRef.Name).To(Equal("golden-sles"))
+		Expect(*pvc.Spec.StorageClassName).To(Equal("lh-sles"))
+		Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("80Gi"))
+		Expect(pvc.Annotations[hvAnnotationImageID]).To(Equal("default/image-sles"))
+	})
+
+	It("should restore a VolumeSnapshot with the storage class of its PVC", func() {
+		machine := newCloneMachine(infrav1.VolumeCloneSourceVolumeSnapshot, "golden-sles-snap")
+		machine.Spec.Volumes[0].VolumeSize = new(resource.MustParse("100Gi"))
+		scope := newVolumesScope(machine, newGoldenPVC(), newGoldenSnapshot())
+
+		pvc, err := buildPVCForVolume(&machine.Spec.Volumes[0], nil, "worker-0-disk-0-abcde", "default", scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*pvc.Spec.DataSource.APIGroup).To(Equal("snapshot.storage.k8s.io"))
+		Expect(pvc.Spec.DataSource.Kind).To(Equal("VolumeSnapshot"))
+		Expect(*pvc.Spec.StorageClassName).To(Equal("lh-sles"))
+		Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("100Gi"))
+	})
+
+	It("should require a storage class once the snapshotted PVC is gone", func() {
+		machine := newCloneMachine(infrav1.VolumeCloneSourceVolumeSnapshot, "golden-sles-snap")
+		scope := newVolumesScope(machine, newGoldenSnapshot())
+
+		_, err := buildPVCForVolume(&machine.Spec.Volumes[0], nil, "worker-0-disk-0-abcde", "default", scope)
+		Expect(err).To(MatchError(ContainSubstring("storageClass is required")))
+
+		machine.Spec.Volumes[0].StorageClass = "lh-sles"
+		pvc, err := buildPVCForVolume(&machine.Spec.Volumes[0], nil, "worker-0-disk-0-abcde", "default", scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*pvc.Spec.StorageClassName).To(Equal("lh-sles"))
+	})
+
+	It("should reject a clone smaller than its source", func() {
+		machine := newCloneMachine(infrav1.VolumeCloneSourcePersistentVolumeClaim, "golden-sles")
+		machine.Spec.Volumes[0].VolumeSize = new(resource.MustParse("40Gi"))
+		scope := newVolumesScope(machine, newGoldenPVC())
+
+		_, err := buildPVCForVolume(&machine.Spec.Volumes[0], nil, "worker-0-disk-0-abcde", "default", scope)
+		Expect(err).To(MatchError(ContainSubstring("smaller than the 80Gi size")))
+	})
+})
+
+var _ = Describe("checkVolumeCloneSources", func() {
+	It("should ignore machines without clone volumes", func() {
+		machine := newVolumesMachine("40Gi")
+
+		Expect(checkVolumeCloneSources(newVolumesScope(machine))).To(Succeed())
+		Expect(conditions.Get(machine, infrav1.VolumesClonedCondition)).To(BeNil())
+	})
+
+	It("should report a missing source", func() {
+		machine := newCloneMachine(infrav1.VolumeCloneSourceVolumeSnapshot, "missing-snap")
+
+		Expect(checkVolumeCloneSources(newVolumesScope(machine))).ToNot(Succeed())
+
+		condition := conditions.Get(machine, infrav1.VolumesClonedCondition)
+		Expect(condition.Reason).To(Equal(infrav1.VolumeCloneFailedReason))
+		Expect(condition.Message).To(ContainSubstring("missing-snap"))
+	})
+
+	It("should report the clone in progress when the source exists", func() {
+		machine := newCloneMachine(infrav1.VolumeCloneSourcePersistentVolumeClaim, "golden-sles")
+
+		Expect(checkVolumeCloneSources(newVolumesScope(machine, newGoldenPVC()))).To(Succeed())
+		Expect(conditions.Get(machine, infrav1.VolumesClonedCondition).Reason).To(Equal(infrav1.VolumeCloningReason))
+	})
+})
+
+var _ = Describe("reconcileVolumeClones", func() {
+	newClonedMachine := func() *infrav1.HarvesterMachine {
+		machine := newCloneMachine(infrav1.VolumeCloneSourcePersistentVolumeClaim, "golden-sles")
+		machine.Status.Volumes = []infrav1.VolumeStatus{{Index: 0, Name: "disk-0", PVCName: "worker-0-disk-0-abcde"}}
+
+		return machine
+	}
+
+	It("should wait for the PVC created by Harvester", func() {
+		machine := newClonedMachine()
+
+		Expect(reconcileVolumeClones(newVolumesScope(machine), newVolumesVM("worker-0-disk-0-abcde"))).To(BeTrue())
+		Expect(conditions.Get(machine, infrav1.VolumesClonedCondition).Message).To(ContainSubstring("waiting for its PVC"))
+	})
+
+	It("should report the clone in progress while the PVC is pending", func() {
+		machine := newClonedMachine()
+		pvc := newVolumesPVC("worker-0-disk-0-abcde", "80Gi", "0")
+		pvc.Status.Phase = corev1.ClaimPending
+
+		Expect(reconcileVolumeClones(newVolumesScope(machine, pvc), newVolumesVM("worker-0-disk-0-abcde"))).To(BeTrue())
+		Expect(conditions.Get(machine, infrav1.VolumesClonedCondition).Message).To(
+			Equal("disk-0 cloning from PersistentVolumeClaim golden-sles"))
+	})
+
+	It("should report the volumes cloned once the PVC is bound", func() {
+		machine := newClonedMachine()
+		pvc := newVolumesPVC("worker-0-disk-0-abcde", "80Gi", "80Gi")
+		pvc.Status.Phase = corev1.ClaimBound
+
+		Expect(reconcileVolumeClones(newVolumesScope(machine, pvc), newVolumesVM("worker-0-disk-0-abcde"))).To(BeFalse())
+		Expect(conditions.IsTrue(machine, infrav1.VolumesClonedCondition)).To(BeTrue())
+	})
+
+	It("should fail when the PVC is lost", func() {
+		machine := newClonedMachine()
+		pvc := newVolumesPVC("worker-0-disk-0-abcde", "80Gi", "80Gi")
+		pvc.Status.Phase = corev1.ClaimLost
+
+		Expect(reconcileVolumeClones(newVolumesScope(machine, pvc), newVolumesVM("worker-0-disk-0-abcde"))).To(BeFalse())
+		Expect(conditions.Get(machine, infrav1.VolumesClonedCondition).Reason).To(Equal(infrav1.VolumeCloneFailedReason))
+	})
+})
@@ -0,0 +1,240 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+const (
+	// resourcesRetainedReason is the reason of the event listing the resources
+	// kept by the deletion policy of a machine.
+	resourcesRetainedReason = "ResourcesRetained"
+	// deletionBackupSuffix is appended to the name of the VM to name the backup
+	// taken before its deletion.
+	deletionBackupSuffix = "-deletion"
+)
+
+// effectiveDeletionPolicy returns the deletion policy of the machine, falling
+// back to the one of its cluster, and to Delete.
+func effectiveDeletionPolicy(hvScope *Scope) infrav1.DeletionPolicy {
+	if hvScope.HarvesterMachine.Spec.DeletionPolicy != "" {
+		return hvScope.HarvesterMachine.Spec.DeletionPolicy
+	}
+
+	if hvScope.HarvesterCluster.Spec.DeletionPolicy != "" {
+		return hvScope.HarvesterCluster.Spec.DeletionPolicy
+	}
+
+	return infrav1.DeletionPolicyDelete
+}
+
+// retainedLabels returns the labels set on the resources kept after the
+// deletion of the machine.
+func retainedLabels(hvScope *Scope) map[string]string {
+	labels := map[string]string{infrav1.RetainedMachineLabel: hvScope.HarvesterMachine.Name}
+	if clusterName, ok := hvScope.HarvesterMachine.Labels[clusterv1.ClusterNameLabel]; ok {
+		labels[infrav1.RetainedClusterLabel] = clusterName
+	}
+
+	return labels
+}
+
+// backUpVM backs the VM up to the Harvester backup target with the Snapshot
+// deletion policy, reporting the progress in the Deleting condition. It
+// returns true once the VM can be deleted. A failed backup blocks the deletion
+// until it is deleted or the deletion policy is changed.
+func backUpVM(hvScope *Scope, vm *kubevirtv1.VirtualMachine) bool {
+	if effectiveDeletionPolicy(hvScope) != infrav1.DeletionPolicySnapshot || !vm.DeletionTimestamp.IsZero() {
+		return true
+	}
+
+	machine := hvScope.HarvesterMachine
+	backups := hvScope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineBackups(vm.Namespace)
+	backupName := vm.Name + deletionBackupSuffix
+
+	backup, err := backups.Get(hvScope.Ctx, backupName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		backup, err = backups.Create(hvScope.Ctx, &harvesterv1beta1.VirtualMachineBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      backupName,
+				Namespace: vm.Namespace,
+				Labels:    retainedLabels(hvScope),
+			},
+			Spec: harvesterv1beta1.VirtualMachineBackupSpec{
+				Source: corev1.TypedLocalObjectReference{
+					APIGroup: &kubevirtv1.SchemeGroupVersion.Group,
+					Kind:     kubevirtv1.VirtualMachineGroupVersionKind.Kind,
+					Name:     vm.Name,
+				},
+				// A snapshot lives in the volumes of the VM, deleted with it
+				Type: harvesterv1beta1.Backup,
+			},
+		}, metav1.CreateOptions{})
+		if err == nil {
+			hvScope.Logger.Info("Backing up the VM before deleting it", "backup", backupName)
+		}
+	}
+
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to back up the VM", "backup", backupName, "error", err)
+
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.DeletingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.VMBackupFailedReason,
+			Message: fmt.Sprintf("Unable to back up the VM to VirtualMachineBackup %s: %s", backupName, err),
+		})
+
+		return false
+	}
+
+	status := backup.Status
+	if status != nil && status.ReadyToUse != nil && *status.ReadyToUse {
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.DeletingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.VMBackingUpReason,
+			Message: fmt.Sprintf("Backed up the VM to VirtualMachineBackup %s, deleting the VM", backupName),
+		})
+		reportRetainedResources(hvScope, []string{"virtualmachinebackup/" + backupName})
+
+		return true
+	}
+
+	if status != nil && status.Error != nil && status.Error.Message != nil {
+		hvScope.Logger.Info("Warning: the backup of the VM failed", "backup", backupName, "error", *status.Error.Message)
+
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.DeletingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.VMBackupFailedReason,
+			Message: fmt.Sprintf("VirtualMachineBackup %s failed: %s", backupName, *status.Error.Message),
+		})
+
+		return false
+	}
+
+	progress := 0
+	if status != nil {
+		progress = status.Progress
+	}
+
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.DeletingCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  infrav1.VMBackingUpReason,
+		Message: fmt.Sprintf("Waiting for VirtualMachineBackup %s to complete, %d%% done", backupName, progress),
+	})
+
+	return false
+}
+
+// retainPVCs labels the PVCs of the deleted VM with the former cluster and
+// machine instead of deleting them, and reports them as retained.
+func retainPVCs(hvScope *Scope, namespace string) {
+	var pvcNames []string
+
+	if len(hvScope.HarvesterMachine.Status.Volumes) > 0 {
+		for _, volume := range hvScope.HarvesterMachine.Status.Volumes {
+			pvcNames = append(pvcNames, volume.PVCName)
+		}
+	} else {
+		pvcList, err := hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(namespace).List(hvScope.Ctx, metav1.ListOptions{})
+		if err != nil {
+			hvScope.Logger.Info("Warning: failed to list PVCs to retain", "error", err)
+
+			return
+		}
+
+		for _, pvc := range pvcList.Items {
+			if strings.HasPrefix(pvc.Name, hvScope.HarvesterMachine.Name+"-disk-") {
+				pvcNames = append(pvcNames, pvc.Name)
+			}
+		}
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"labels": retainedLabels(hvScope)},
+	})
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to build the patch of the retained PVCs", "error", err)
+
+		return
+	}
+
+	retained := make([]string, 0, len(pvcNames))
+
+	for _, pvcName := range pvcNames {
+		_, err = hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(namespace).Patch(
+			hvScope.Ctx, pvcName, types.MergePatchType, patch, metav1.PatchOptions{})
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				hvScope.Logger.Info("Warning: failed to label retained PVC", "pvc", pvcName, "error", err)
+			}
+
+			continue
+		}
+
+		retained = append(retained, "persistentvolumeclaim/"+pvcName)
+	}
+
+	hvScope.Logger.Info("Retained the PVCs of the VM", "pvcs", pvcNames)
+
+	reportRetainedResources(hvScope, retained)
+}
+
+// reportRetainedResources lists the resources kept after the deletion of the
+// machine in its RetainedResourcesAnnotation and in an event, once.
+func reportRetainedResources(hvScope *Scope, resources []string) {
+	if len(resources) == 0 {
+		return
+	}
+
+	machine := hvScope.HarvesterMachine
+	value := strings.Join(resources, ",")
+
+	if machine.Annotations[infrav1.RetainedResourcesAnnotation] == value {
+		return
+	}
+
+	if machine.Annotations == nil {
+		machine.Annotations = map[string]string{}
+	}
+
+	machine.Annotations[infrav1.RetainedResourcesAnnotation] = value
+
+	if hvScope.Recorder != nil {
+		hvScope.Recorder.Eventf(machine, nil, corev1.EventTypeNormal, resourcesRetainedReason, "Delete",
+			"Retained %s in namespace %s", value, hvScope.HarvesterCluster.Spec.TargetNamespace)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// =============================================================================
+// Tests for the deletion policy of machines
+// =============================================================================
+
+var _ = Describe("effectiveDeletionPolicy", func() {
+	It("should default to Delete", func() {
+		scope := newVolumesScope(newVolumesMachine())
+
+		Expect(effectiveDeletionPolicy(scope)).To(Equal(infrav1.DeletionPolicyDelete))
+	})
+
+	It("should fall back to the policy of the cluster", func() {
+		scope := newVolumesScope(newVolumesMachine())
+		scope.HarvesterCluster.Spec.DeletionPolicy = infrav1.DeletionPolicyRetain
+
+		Expect(effectiveDeletionPolicy(scope)).To(Equal(infrav1.DeletionPolicyRetain))
+
+		scope.HarvesterMachine.Spec.DeletionPolicy = infrav1.DeletionPolicySnapshot
+
+		Expect(effectiveDeletionPolicy(scope)).To(Equal(infrav1.DeletionPolicySnapshot))
+	})
+})
+
+var _ = Describe("backUpVM", func() {
+	newPolicyMachine := func(policy infrav1.DeletionPolicy) *infrav1.HarvesterMachine {
+		machine := newVolumesMachine()
+		machine.Labels = map[string]string{clusterv1.ClusterNameLabel: "test-cluster"}
+		machine.Spec.DeletionPolicy = policy
+
+		return machine
+	}
+
+	newPolicyScope := func(policy infrav1.DeletionPolicy, objs ...runtime.Object) (*Scope, *events.FakeRecorder) {
+		recorder := events.NewFakeRecorder(10)
+		scope := newVolumesScope(newPolicyMachine(policy), objs...)
+		scope.Recorder = recorder
+
+		return scope, recorder
+	}
+
+	newBackup := func(status *harvesterv1beta1.VirtualMachineBackupStatus) *harvesterv1beta1.VirtualMachineBackup {
+		return &harvesterv1beta1.VirtualMachineBackup{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0-deletion", Namespace: "default"},
+			Status:     status,
+		}
+	}
+
+	It("should let the VM be deleted at once without the Snapshot policy", func(ctx SpecContext) {
+		scope, _ := newPolicyScope(infrav1.DeletionPolicyRetain)
+
+		Expect(backUpVM(scope, newVolumesVM())).To(BeTrue())
+
+		backups, err := scope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineBackups("default").List(ctx, metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backups.Items).To(BeEmpty())
+	})
+
+	It("should back the VM up and wait for the backup", func(ctx SpecContext) {
+		scope, _ := newPolicyScope(infrav1.DeletionPolicySnapshot)
+
+		Expect(backUpVM(scope, newVolumesVM())).To(BeFalse())
+
+		backup, err := scope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineBackups("default").Get(ctx, "worker-0-deletion", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(backup.Spec.Type).To(Equal(harvesterv1beta1.Backup))
+		Expect(backup.Spec.Source.Kind).To(Equal("VirtualMachine"))
+		Expect(backup.Spec.Source.Name).To(Equal("worker-0"))
+		Expect(backup.Labels).To(HaveKeyWithValue(infrav1.RetainedClusterLabel, "test-cluster"))
+		Expect(backup.Labels).To(HaveKeyWithValue(infrav1.RetainedMachineLabel, "worker-0"))
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.DeletingCondition)
+		Expect(condition.Reason).To(Equal(infrav1.VMBackingUpReason))
+		Expect(condition.Message).To(ContainSubstring("0% done"))
+	})
+
+	It("should let the VM be deleted once the backup is ready", func() {
+		scope, recorder := newPolicyScope(infrav1.DeletionPolicySnapshot,
+			newBackup(&harvesterv1beta1.VirtualMachineBackupStatus{ReadyToUse: new(true)}))
+
+		Expect(backUpVM(scope, newVolumesVM())).To(BeTrue())
+		Expect(scope.HarvesterMachine.Annotations).To(HaveKeyWithValue(
+			infrav1.RetainedResourcesAnnotation, "virtualmachinebackup/worker-0-deletion"))
+		Expect(recorder.Events).To(Receive(ContainSubstring("Retained virtualmachinebackup/worker-0-deletion")))
+
+		// The resources are reported once
+		Expect(backUpVM(scope, newVolumesVM())).To(BeTrue())
+		Expect(recorder.Events).ToNot(Receive())
+	})
+
+	It("should block the deletion when the backup failed", func() {
+		scope, _ := newPolicyScope(infrav1.DeletionPolicySnapshot,
+			newBackup(&harvesterv1beta1.VirtualMachineBackupStatus{
+				Error: &harvesterv1beta1.Error{Message: new("backup target is not set")},
+			}))
+
+		Expect(backUpVM(scope, newVolumesVM())).To(BeFalse())
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.DeletingCondition)
+		Expect(condition.Reason).To(Equal(infrav1.VMBackupFailedReason))
+		Expect(condition.Message).To(ContainSubstring("backup target is not set"))
+	})
+})
+
+var _ = Describe("retainPVCs", func() {
+	It("should label the PVCs of the machine and report them", func(ctx SpecContext) {
+		machine := newVolumesMachine()
+		machine.Labels = map[string]string{clusterv1.ClusterNameLabel: "test-cluster"}
+		scope := newVolumesScope(machine,
+			newVolumesPVC("worker-0-disk-0-abcde", "10Gi", "10Gi"),
+			newVolumesPVC("worker-1-disk-0-fghij", "10Gi", "10Gi"))
+		recorder := events.NewFakeRecorder(10)
+		scope.Recorder = recorder
+
+		retainPVCs(scope, "default")
+
+		pvc, err := scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(ctx, "worker-0-disk-0-abcde", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Labels).To(HaveKeyWithValue(infrav1.RetainedClusterLabel, "test-cluster"))
+		Expect(pvc.Labels).To(HaveKeyWithValue(infrav1.RetainedMachineLabel, "worker-0"))
+
+		other, err := scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(ctx, "worker-1-disk-0-fghij", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(other.Labels).To(BeEmpty())
+
+		Expect(scope.HarvesterMachine.Annotations).To(HaveKeyWithValue(
+			infrav1.RetainedResourcesAnnotation, "persistentvolumeclaim/worker-0-disk-0-abcde"))
+		Expect(recorder.Events).To(Receive(HavePrefix(corev1.EventTypeNormal + " " + resourcesRetainedReason)))
+	})
+})
@@ -0,0 +1,293 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// vmFailure describes why the VM of a machine does not start.
+type vmFailure struct {
+	// reason is the VMProvisioningReady reason reporting the failure.
+	reason  string
+	message string
+	// terminal marks the failures the VM does not recover from without
+	// replacing the machine.
+	terminal bool
+}
+
+// diagnoseVMFailure looks for the reason why the VM of a machine is not ready:
+// its printable status, the containers of its virt-launcher pod and the
+// warning events of the VM and its volumes. It returns nil while the VM is
+// starting normally.
+func diagnoseVMFailure(hvScope *Scope, vm *kubevirtv1.VirtualMachine) *vmFailure {
+	switch vm.Status.PrintableStatus {
+	case kubevirtv1.VirtualMachineStatusUnschedulable:
+		return &vmFailure{
+			reason:  infrav1.VMUnschedulableReason,
+			message: "No Harvester node can run the VM: " + vmConditionMessage(vm, kubevirtv1.VirtualMachineConditionType(corev1.PodScheduled)),
+		}
+
+	case kubevirtv1.VirtualMachineStatusErrImagePull, kubevirtv1.VirtualMachineStatusImagePullBackOff:
+		return &vmFailure{
+			reason:  infrav1.VMImagePullFailedReason,
+			message: "The virt-launcher pod of the VM cannot pull its image: " + launcherWaitingMessage(hvScope, vm),
+		}
+
+	case kubevirtv1.VirtualMachineStatusCrashLoopBackOff:
+		return &vmFailure{
+			reason:   infrav1.VMCrashLoopBackOffReason,
+			message:  "The virt-launcher pod of the VM keeps crashing: " + launcherWaitingMessage(hvScope, vm),
+			terminal: true,
+		}
+
+	case kubevirtv1.VirtualMachineStatusPvcNotFound, kubevirtv1.VirtualMachineStatusDataVolumeError:
+		return &vmFailure{
+			reason:   infrav1.VMVolumeFailedReason,
+			message:  "A volume of the VM cannot be provisioned: " + vmConditionMessage(vm, kubevirtv1.VirtualMachineFailure),
+			terminal: true,
+		}
+	}
+
+	// A missing NetworkAttachmentDefinition or a failed volume import only
+	// surfaces in the warning events of the VMI and of the volumes. Events
+	// outlive the problems they report, so they are only looked at until the
+	// machine is provisioned.
+	if hvScope.HarvesterMachine.Status.Initialization.Provisioned {
+		return nil
+	}
+
+	return failureFromEvents(hvScope, vm)
+}
+
+// vmConditionMessage returns the message of a condition of the VM, or its
+// printable status when the condition has none.
+func vmConditionMessage(vm *kubevirtv1.VirtualMachine, conditionType kubevirtv1.VirtualMachineConditionType) string {
+	for _, condition := range vm.Status.Conditions {
+		if condition.Type == conditionType && condition.Message != "" {
+			return condition.Message
+		}
+	}
+
+	return string(vm.Status.PrintableStatus)
+}
+
+// launcherWaitingMessage returns why a container of the virt-launcher pod of
+// the VM is waiting, or the printable status of the VM when the pod cannot be
+// read.
+func launcherWaitingMessage(hvScope *Scope, vm *kubevirtv1.VirtualMachine) string {
+	pods, err := listLauncherPods(hvScope, vm)
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to list the virt-launcher pods of the VM", "error", err)
+
+		return string(vm.Status.PrintableStatus)
+	}
+
+	for _, pod := range pods {
+		for _, status := range slices.Concat(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses) {
+			waiting := status.State.Waiting
+			if waiting == nil || waiting.Reason == "" || waiting.Reason == "ContainerCreating" || waiting.Reason == "PodInitializing" {
+				continue
+			}
+
+			if waiting.Message == "" {
+				return waiting.Reason
+			}
+
+			return waiting.Reason + ": " + waiting.Message
+		}
+	}
+
+	return string(vm.Status.PrintableStatus)
+}
+
+// listLauncherPods lists the virt-launcher pods of the VM.
+func listLauncherPods(hvScope *Scope, vm *kubevirtv1.VirtualMachine) ([]corev1.Pod, error) {
+	selector := labels.SelectorFromSet(labels.Set{
+		kubevirtv1.AppLabel:      "virt-launcher",
+		"harvesterhci.io/vmName": vm.Name,
+	})
+
+	pods, err := hvScope.HarvesterClient.CoreV1().Pods(vm.Namespace).List(hvScope.Ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pods.Items, nil
+}
+
+// failureFromEvents maps the latest warning event of the VMI or of the
+// volumes of the VM, recorded since the VM was created, to a failure. The
+// events are listed by involved object, rather than across the namespace.
+func failureFromEvents(hvScope *Scope, vm *kubevirtv1.VirtualMachine) *vmFailure {
+	var latest *corev1.Event
+
+	for _, name := range eventObjectNames(vm) {
+		events, err := hvScope.HarvesterClient.CoreV1().Events(vm.Namespace).List(hvScope.Ctx, metav1.ListOptions{
+			FieldSelector: fields.AndSelectors(
+				fields.OneTermEqualSelector("involvedObject.name", name),
+				fields.OneTermEqualSelector("type", corev1.EventTypeWarning),
+			).String(),
+		})
+		if err != nil {
+			hvScope.Logger.Info("Warning: unable to list the events of the VM", "object", name, "error", err)
+
+			return nil
+		}
+
+		for i := range events.Items {
+			event := &events.Items[i]
+
+			if event.Type != corev1.EventTypeWarning || event.InvolvedObject.Name != name ||
+				eventTime(event).Before(vm.CreationTimestamp.Time) {
+				continue
+			}
+
+			switch event.InvolvedObject.Kind {
+			case "VirtualMachineInstance", "DataVolume", "PersistentVolumeClaim":
+			default:
+				continue
+			}
+
+			if latest == nil || eventTime(event).After(eventTime(latest)) {
+				latest = event
+			}
+		}
+	}
+
+	if latest == nil {
+		return nil
+	}
+
+	message := strings.ToLower(latest.Message)
+
+	switch {
+	case strings.Contains(message, "network-attachment-definition") && strings.Contains(message, "not found"):
+		return &vmFailure{
+			reason:   infrav1.VMNetworkAttachmentNotFoundReason,
+			message:  "A network of the VM does not exist: " + latest.Message,
+			terminal: true,
+		}
+
+	case latest.InvolvedObject.Kind == "DataVolume":
+		return &vmFailure{
+			reason:   infrav1.VMVolumeFailedReason,
+			message:  "The import of volume " + latest.InvolvedObject.Name + " failed: " + latest.Message,
+			terminal: true,
+		}
+
+	case latest.InvolvedObject.Kind == "PersistentVolumeClaim":
+		return &vmFailure{
+			reason:  infrav1.VMVolumeFailedReason,
+			message: "Volume " + latest.InvolvedObject.Name + " cannot be provisioned: " + latest.Message,
+		}
+	}
+
+	return nil
+}
+
+// eventObjectNames returns the names of the objects whose events can report
+// why the VM does not start: its VMI, named after the VM, and its volumes.
+func eventObjectNames(vm *kubevirtv1.VirtualMachine) []string {
+	names := []string{vm.Name}
+
+	if vm.Spec.Template == nil {
+		return names
+	}
+
+	for _, volume := range vm.Spec.Template.Spec.Volumes {
+		switch {
+		case volume.PersistentVolumeClaim != nil:
+			names = append(names, volume.PersistentVolumeClaim.ClaimName)
+		case volume.DataVolume != nil:
+			names = append(names, volume.DataVolume.Name)
+		}
+	}
+
+	return names
+}
+
+// eventTime returns when an event was last seen.
+func eventTime(event *corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// reportVMFailure reports a failure of the VM in the VMProvisioningReady
+// condition. Terminal failures also mark the owner Machine for remediation,
+// so that a MachineHealthCheck replaces it.
+func (r *HarvesterMachineReconciler) reportVMFailure(hvScope *Scope, failure *vmFailure) ctrl.Result {
+	hvScope.HarvesterMachine.Status.Ready = false
+
+	conditions.Set(hvScope.HarvesterMachine, metav1.Condition{
+		Type:    infrav1.VMProvisioningReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  failure.reason,
+		Message: failure.message,
+	})
+
+	hvScope.Logger.Info("Warning: the VM cannot start", "reason", failure.reason, "message", failure.message)
+
+	if !failure.terminal {
+		return ctrl.Result{RequeueAfter: requeueTimeShort}
+	}
+
+	machine := hvScope.Machine
+	if _, ok := machine.Annotations[clusterv1.RemediateMachineAnnotation]; ok {
+		return ctrl.Result{RequeueAfter: requeueTimeShort}
+	}
+
+	machineCopy := machine.DeepCopy()
+	if machineCopy.Annotations == nil {
+		machineCopy.Annotations = make(map[string]string)
+	}
+
+	machineCopy.Annotations[clusterv1.RemediateMachineAnnotation] = ""
+
+	err := r.Client.Patch(hvScope.Ctx, machineCopy, client.MergeFrom(machine))
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to mark the Machine for remediation", "error", err)
+	} else {
+		hvScope.Logger.Info("Marked the Machine for remediation", "machine", machine.Name, "reason", failure.reason)
+	}
+
+	return ctrl.Result{RequeueAfter: requeueTimeShort}
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stesting "k8s.io/client-go/testing"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for the diagnosis of VMs that cannot start
+// =============================================================================
+
+var vmCreationTime = metav1.NewTime(time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC))
+
+func newFailingVM(status kubevirtv1.VirtualMachinePrintableStatus, vmConditions ...kubevirtv1.VirtualMachineCondition) *kubevirtv1.VirtualMachine {
+	vm := newVolumesVM("worker-0-disk-0-abcde")
+	vm.CreationTimestamp = vmCreationTime
+	vm.Status.PrintableStatus = status
+	vm.Status.Conditions = vmConditions
+
+	return vm
+}
+
+func newWarningEvent(name, kind, object, message string, lastSeen time.Duration) *corev1.Event {
+	return &corev1.Event{
+		ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Type:           corev1.EventTypeWarning,
+		InvolvedObject: corev1.ObjectReference{Kind: kind, Name: object, Namespace: "default"},
+		Message:        message,
+		LastTimestamp:  metav1.NewTime(vmCreationTime.Add(lastSeen)),
+	}
+}
+
+var _ = Describe("diagnoseVMFailure", func() {
+	It("should report an unschedulable VM with the scheduler message", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusUnschedulable, kubevirtv1.VirtualMachineCondition{
+			Type:    kubevirtv1.VirtualMachineConditionType(corev1.PodScheduled),
+			Status:  corev1.ConditionFalse,
+			Message: "0/3 nodes are available: 3 Insufficient memory.",
+		})
+
+		failure := diagnoseVMFailure(newVolumesScope(newVolumesMachine(), vm), vm)
+		Expect(failure).ToNot(BeNil())
+		Expect(failure.reason).To(Equal(infrav1.VMUnschedulableReason))
+		Expect(failure.message).To(ContainSubstring("3 Insufficient memory"))
+		Expect(failure.terminal).To(BeFalse())
+	})
+
+	It("should report a crashing virt-launcher pod as terminal", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusCrashLoopBackOff)
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "virt-launcher-worker-0-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{"kubevirt.io": "virt-launcher", "harvesterhci.io/vmName": "worker-0"},
+			},
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name: "compute",
+					State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{
+						Reason:  "CrashLoopBackOff",
+						Message: "back-off 5m0s restarting failed container",
+					}},
+				}},
+			},
+		}
+
+		failure := diagnoseVMFailure(newVolumesScope(newVolumesMachine(), vm, pod), vm)
+		Expect(failure).ToNot(BeNil())
+		Expect(failure.reason).To(Equal(infrav1.VMCrashLoopBackOffReason))
+		Expect(failure.message).To(ContainSubstring("back-off 5m0s restarting failed container"))
+		Expect(failure.terminal).To(BeTrue())
+	})
+
+	It("should report a missing NetworkAttachmentDefinition from the VMI events as terminal", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusStarting)
+		event := newWarningEvent("worker-0.1", "VirtualMachineInstance", "worker-0",
+			`failed to render launch manifest: network-attachment-definitions.k8s.cni.cncf.io "vlan10" not found`, time.Minute)
+
+		failure := diagnoseVMFailure(newVolumesScope(newVolumesMachine(), vm, event), vm)
+		Expect(failure).ToNot(BeNil())
+		Expect(failure.reason).To(Equal(infrav1.VMNetworkAttachmentNotFoundReason))
+		Expect(failure.terminal).To(BeTrue())
+	})
+
+	It("should report a failed DataVolume import as terminal", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusProvisioning)
+		event := newWarningEvent("worker-0-disk-0-abcde.1", "DataVolume", "worker-0-disk-0-abcde",
+			"Unable to process data: checksum mismatch", time.Minute)
+
+		failure := diagnoseVMFailure(newVolumesScope(newVolumesMachine(), vm, event), vm)
+		Expect(failure).ToNot(BeNil())
+		Expect(failure.reason).To(Equal(infrav1.VMVolumeFailedReason))
+		Expect(failure.message).To(ContainSubstring("worker-0-disk-0-abcde"))
+		Expect(failure.terminal).To(BeTrue())
+	})
+
+	It("should only list the warning events of the VMI and of the volumes", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusStarting)
+		event := newWarningEvent("worker-0-disk-0-abcde-other.1", "PersistentVolumeClaim", "worker-0-disk-0-abcde-other",
+			"waiting for a volume to be created", time.Minute)
+		scope := newVolumesScope(newVolumesMachine(), vm, event)
+
+		Expect(diagnoseVMFailure(scope, vm)).To(BeNil())
+
+		var selectors []string
+
+		for _, action := range scope.HarvesterClient.(*hvfake.Clientset).Actions() {
+			if list, ok := action.(k8stesting.ListAction); ok && action.GetResource().Resource == "events" {
+				selectors = append(selectors, list.GetListRestrictions().Fields.String())
+			}
+		}
+
+		Expect(selectors).To(ConsistOf(
+			"involvedObject.name=worker-0,type=Warning",
+			"involvedObject.name=worker-0-disk-0-abcde,type=Warning",
+		))
+	})
+
+	It("should ignore the events recorded before the VM was created", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusStarting)
+		event := newWarningEvent("worker-0.1", "VirtualMachineInstance", "worker-0",
+			`network-attachment-definitions.k8s.cni.cncf.io "vlan10" not found`, -time.Minute)
+
+		Expect(diagnoseVMFailure(newVolumesScope(newVolumesMachine(), vm, event), vm)).To(BeNil())
+	})
+
+	It("should ignore the events once the machine is provisioned", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusStarting)
+		event := newWarningEvent("worker-0.1", "VirtualMachineInstance", "worker-0",
+			`network-attachment-definitions.k8s.cni.cncf.io "vlan10" not found`, time.Minute)
+		machine := newVolumesMachine()
+		machine.Status.Initialization.Provisioned = true
+
+		Expect(diagnoseVMFailure(newVolumesScope(machine, vm, event), vm)).To(BeNil())
+	})
+
+	It("should report nothing for a VM that is starting normally", func() {
+		vm := newFailingVM(kubevirtv1.VirtualMachineStatusStarting)
+
+		Expect(diagnoseVMFailure(newVolumesScope(newVolumesMachine(), vm), vm)).To(BeNil())
+	})
+})
+
+var _ = Describe("reportVMFailure", func() {
+	newReporter := func(machine *clusterv1.Machine) *HarvesterMachineReconciler {
+		scheme := pausedTestScheme()
+		cl := fake.NewClientBuilder().WithScheme(scheme).WithObjects(machine).Build()
+
+		return &HarvesterMachineReconciler{Client: cl, Scheme: scheme}
+	}
+
+	newOwnerMachine := func() *clusterv1.Machine {
+		return &clusterv1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns"}}
+	}
+
+	It("should set the condition and keep the Machine of a recoverable failure", func(ctx SpecContext) {
+		reconciler := newReporter(newOwnerMachine())
+		scope := newVolumesScope(newVolumesMachine())
+		scope.Machine = newOwnerMachine()
+
+		res := reconciler.reportVMFailure(scope, &vmFailure{reason: infrav1.VMUnschedulableReason, message: "no node"})
+		Expect(res.RequeueAfter).To(Equal(requeueTimeShort))
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.VMProvisioningReadyCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrav1.VMUnschedulableReason))
+
+		machine := &clusterv1.Machine{}
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(scope.Machine), machine)).To(Succeed())
+		Expect(machine.Annotations).ToNot(HaveKey(clusterv1.RemediateMachineAnnotation))
+	})
+
+	It("should mark the Machine of a terminal failure for remediation", func(ctx SpecContext) {
+		reconciler := newReporter(newOwnerMachine())
+		scope := newVolumesScope(newVolumesMachine())
+		scope.Machine = newOwnerMachine()
+
+		reconciler.reportVMFailure(scope, &vmFailure{
+			reason: infrav1.VMNetworkAttachmentNotFoundReason, message: "no vlan10", terminal: true,
+		})
+
+		machine := &clusterv1.Machine{}
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(scope.Machine), machine)).To(Succeed())
+		Expect(machine.Annotations).To(HaveKey(clusterv1.RemediateMachineAnnotation))
+	})
+})
@@ -0,0 +1,233 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+const (
+	// imageImportPollInterval is how often the import of VM images is checked.
+	imageImportPollInterval = 30 * time.Second
+
+	// imageImportRetries is the number of times Harvester retries a failed download.
+	imageImportRetries = 3
+)
+
+// reconcileImageImports makes sure the images of the volumes with an
+// imageSource exist in Harvester, creating them from their URL when absent,
+// and reports their import in the VMImagesReady condition. It reports whether
+// every image is imported, and returns an error when an image cannot be booted
+// from: its checksum differs from the pinned one, or its import failed.
+func reconcileImageImports(hvScope *Scope) (bool, error) {
+	machine := hvScope.HarvesterMachine
+	targetNS := hvScope.HarvesterCluster.Spec.TargetNamespace
+
+	var pending []string
+
+	imported := 0
+
+	for i := range machine.Spec.Volumes {
+		vol := &machine.Spec.Volumes[i]
+		if vol.VolumeType != "image" || vol.ImageSource == nil {
+			continue
+		}
+
+		name, err := locutil.GetNamespacedName(vol.ImageName, targetNS)
+		if err != nil {
+			return false, errors.Wrapf(err, "imageName %q is malformed", vol.ImageName)
+		}
+
+		vmImage, err := findImage(name, hvScope)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to look VM image %s up", name)
+		}
+
+		if vmImage == nil {
+			err = createImage(hvScope, name.Namespace, name.Name, vol.ImageSource)
+			if err != nil {
+				return false, err
+			}
+
+			pending = append(pending, name.String()+" created")
+
+			continue
+		}
+
+		if !strings.EqualFold(vmImage.Spec.Checksum, vol.ImageSource.Checksum) {
+			setVMImagesNotReady(machine, infrav1.VMImageChecksumMismatchReason,
+				fmt.Sprintf("VM image %s does not have the checksum pinned by volume %d", name, i))
+
+			return false, errors.Errorf("VM image %s has checksum %q, volume %d pins %q",
+				name, vmImage.Spec.Checksum, i, vol.ImageSource.Checksum)
+		}
+
+		switch {
+		case imageCondition(vmImage, string(harvesterv1beta1.ImageImported)) == corev1.ConditionTrue:
+			imported++
+		case imageCondition(vmImage, string(harvesterv1beta1.ImageRetryLimitExceeded)) == corev1.ConditionTrue:
+			setVMImagesNotReady(machine, infrav1.VMImageImportFailedReason,
+				fmt.Sprintf("VM image %s failed to import from %s", name, vmImage.Spec.URL))
+
+			return false, errors.Errorf("VM image %s failed to import %d times", name, vmImage.Status.Failed)
+		default:
+			pending = append(pending, fmt.Sprintf("%s importing: %d%%", name, vmImage.Status.Progress))
+		}
+	}
+
+	if len(pending) > 0 {
+		setVMImagesNotReady(machine, infrav1.VMImageImportingReason, strings.Join(pending, ", "))
+
+		return false, nil
+	}
+
+	if imported > 0 {
+		conditions.Set(machine, metav1.Condition{
+			Type:   infrav1.VMImagesReadyCondition,
+			Status: metav1.ConditionTrue,
+			Reason: infrav1.VMImagesReadyReason,
+		})
+	}
+
+	return true, nil
+}
+
+// resolveVolumeImage resolves the VM image of an "image" volume: the image
+// named by imageName, or the newest imported image matching imageSelector.
+// It returns nil for other volumes.
+func resolveVolumeImage(vol *infrav1.Volume, defaultNamespace string, hvScope *Scope) (*harvesterv1beta1.VirtualMachineImage, error) {
+	if vol.VolumeType != "image" {
+		return nil, nil
+	}
+
+	if vol.ImageSelector == nil {
+		return getImageByName(vol.ImageName, defaultNamespace, hvScope)
+	}
+
+	return getNewestImage(vol.ImageSelector, defaultNamespace, hvScope)
+}
+
+// getNewestImage returns the newest imported VM image matching a selector.
+// Images still importing are skipped, so that a machine never waits for an
+// image published while it is created.
+func getNewestImage(
+	imageSelector *infrav1.ImageSelector, defaultNamespace string, hvScope *Scope,
+) (*harvesterv1beta1.VirtualMachineImage, error) {
+	namespace := imageSelector.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(&imageSelector.LabelSelector)
+	if err != nil {
+		return nil, errors.Wrap(err, "imageSelector is invalid")
+	}
+
+	foundImages, err := hvScope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineImages(namespace).List(
+		hvScope.Ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+
+	var newest *harvesterv1beta1.VirtualMachineImage
+
+	for i := range foundImages.Items {
+		vmImage := &foundImages.Items[i]
+		if vmImage.DeletionTimestamp != nil ||
+			imageCondition(vmImage, string(harvesterv1beta1.ImageImported)) != corev1.ConditionTrue {
+			continue
+		}
+
+		// Images created in the same second are ordered by name, for a stable choice
+		if newest == nil || newest.CreationTimestamp.Before(&vmImage.CreationTimestamp) ||
+			(newest.CreationTimestamp.Equal(&vmImage.CreationTimestamp) && vmImage.Name > newest.Name) {
+			newest = vmImage
+		}
+	}
+
+	if newest == nil {
+		return nil, errors.Errorf("no imported VM image in namespace %s matches %s", namespace, selector.String())
+	}
+
+	return newest, nil
+}
+
+// createImage creates a VM image downloaded by Harvester from the URL of the
+// image source. Harvester verifies the download against the checksum.
+func createImage(hvScope *Scope, namespace, name string, source *infrav1.ImageSource) error {
+	vmImage := &harvesterv1beta1.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: harvesterv1beta1.VirtualMachineImageSpec{
+			DisplayName: name,
+			Description: fmt.Sprintf("Imported for HarvesterMachine %s/%s",
+				hvScope.HarvesterMachine.Namespace, hvScope.HarvesterMachine.Name),
+			SourceType: harvesterv1beta1.VirtualMachineImageSourceTypeDownload,
+			URL:        source.URL,
+			Checksum:   strings.ToLower(source.Checksum),
+			Retry:      imageImportRetries,
+		},
+	}
+
+	_, err := hvScope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineImages(namespace).Create(
+		hvScope.Ctx, vmImage, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "unable to create VM image %s/%s", namespace, name)
+	}
+
+	hvScope.Logger.Info("Importing VM image", "image", namespace+"/"+name, "url", source.URL)
+
+	return nil
+}
+
+// imageCondition returns the status of a condition of a VM image.
+func imageCondition(vmImage *harvesterv1beta1.VirtualMachineImage, conditionType string) corev1.ConditionStatus {
+	for _, condition := range vmImage.Status.Conditions {
+		if string(condition.Type) == conditionType {
+			return condition.Status
+		}
+	}
+
+	return corev1.ConditionUnknown
+}
+
+// setVMImagesNotReady reports in the VMImagesReady condition that the images of
+// the volumes cannot be booted from yet.
+func setVMImagesNotReady(machine *infrav1.HarvesterMachine, reason, message string) {
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VMImagesReadyCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+}
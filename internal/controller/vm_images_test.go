@@ -0,0 +1,186 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// =============================================================================
+// Tests for the import of VM images from URL
+// =============================================================================
+
+var testImageChecksum = strings.Repeat("ab", 64)
+
+func newImportMachine() *infrav1.HarvesterMachine {
+	machine := newVolumesMachine()
+	machine.Spec.Volumes = []infrav1.Volume{{
+		VolumeType: "image",
+		ImageName:  "sles-15-sp7",
+		ImageSource: &infrav1.ImageSource{
+			URL:      "https://download.example.com/sles-15-sp7.qcow2",
+			Checksum: testImageChecksum,
+		},
+	}}
+
+	return machine
+}
+
+func newImportedImage(checksum string, conds ...harvesterv1beta1.Condition) *harvesterv1beta1.VirtualMachineImage {
+	return &harvesterv1beta1.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{Name: "sles-15-sp7", Namespace: "default"},
+		Spec: harvesterv1beta1.VirtualMachineImageSpec{
+			DisplayName: "sles-15-sp7",
+			SourceType:  harvesterv1beta1.VirtualMachineImageSourceTypeDownload,
+			URL:         "https://download.example.com/sles-15-sp7.qcow2",
+			Checksum:    checksum,
+		},
+		Status: harvesterv1beta1.VirtualMachineImageStatus{Progress: 42, Conditions: conds},
+	}
+}
+
+var _ = Describe("reconcileImageImports", func() {
+	It("should do nothing without image sources", func() {
+		machine := newVolumesMachine("40Gi")
+
+		Expect(reconcileImageImports(newVolumesScope(machine))).To(BeTrue())
+		Expect(conditions.Get(machine, infrav1.VMImagesReadyCondition)).To(BeNil())
+	})
+
+	It("should create an absent image from its URL and checksum", func() {
+		machine := newImportMachine()
+		scope := newVolumesScope(machine)
+
+		Expect(reconcileImageImports(scope)).To(BeFalse())
+
+		vmImage, err := scope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineImages("default").Get(
+			context.TODO(), "sles-15-sp7", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vmImage.Spec.SourceType).To(Equal(harvesterv1beta1.VirtualMachineImageSourceTypeDownload))
+		Expect(vmImage.Spec.URL).To(Equal("https://download.example.com/sles-15-sp7.qcow2"))
+		Expect(vmImage.Spec.Checksum).To(Equal(testImageChecksum))
+		Expect(conditions.Get(machine, infrav1.VMImagesReadyCondition).Reason).To(Equal(infrav1.VMImageImportingReason))
+	})
+
+	It("should report the import progress", func() {
+		machine := newImportMachine()
+
+		Expect(reconcileImageImports(newVolumesScope(machine, newImportedImage(testImageChecksum)))).To(BeFalse())
+		Expect(conditions.Get(machine, infrav1.VMImagesReadyCondition).Message).To(Equal("default/sles-15-sp7 importing: 42%"))
+	})
+
+	It("should report the images ready once imported with the pinned checksum", func() {
+		machine := newImportMachine()
+		vmImage := newImportedImage(strings.ToUpper(testImageChecksum),
+			harvesterv1beta1.Condition{Type: harvesterv1beta1.ImageImported, Status: corev1.ConditionTrue})
+
+		Expect(reconcileImageImports(newVolumesScope(machine, vmImage))).To(BeTrue())
+		Expect(conditions.IsTrue(machine, infrav1.VMImagesReadyCondition)).To(BeTrue())
+	})
+
+	It("should refuse an image with another checksum", func() {
+		machine := newImportMachine()
+		vmImage := newImportedImage(strings.Repeat("cd", 64),
+			harvesterv1beta1.Condition{Type: harvesterv1beta1.ImageImported, Status: corev1.ConditionTrue})
+
+		ready, err := reconcileImageImports(newVolumesScope(machine, vmImage))
+		Expect(err).To(HaveOccurred())
+		Expect(ready).To(BeFalse())
+		Expect(conditions.Get(machine, infrav1.VMImagesReadyCondition).Reason).To(Equal(infrav1.VMImageChecksumMismatchReason))
+	})
+
+	It("should fail once the import exceeded its retries", func() {
+		machine := newImportMachine()
+		vmImage := newImportedImage(testImageChecksum,
+			harvesterv1beta1.Condition{Type: harvesterv1beta1.ImageRetryLimitExceeded, Status: corev1.ConditionTrue})
+
+		_, err := reconcileImageImports(newVolumesScope(machine, vmImage))
+		Expect(err).To(HaveOccurred())
+		Expect(conditions.Get(machine, infrav1.VMImagesReadyCondition).Reason).To(Equal(infrav1.VMImageImportFailedReason))
+	})
+})
+
+var _ = Describe("resolveVolumeImage", func() {
+	newLabeledImage := func(name string, created time.Time, imported bool) *harvesterv1beta1.VirtualMachineImage {
+		vmImage := &harvesterv1beta1.VirtualMachineImage{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "images",
+				Labels:            map[string]string{"os": "sles", "channel": "stable"},
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Spec: harvesterv1beta1.VirtualMachineImageSpec{DisplayName: name},
+		}
+
+		if imported {
+			vmImage.Status.Conditions = []harvesterv1beta1.Condition{
+				{Type: harvesterv1beta1.ImageImported, Status: corev1.ConditionTrue},
+			}
+		}
+
+		return vmImage
+	}
+
+	newSelectorVolume := func() *infrav1.Volume {
+		return &infrav1.Volume{
+			VolumeType: "image",
+			ImageSelector: &infrav1.ImageSelector{
+				Namespace:     "images",
+				LabelSelector: metav1.LabelSelector{MatchLabels: map[string]string{"os": "sles", "channel": "stable"}},
+			},
+		}
+	}
+
+	now := time.Now()
+
+	It("should ignore volumes other than images", func() {
+		vmImage, err := resolveVolumeImage(&infrav1.Volume{VolumeType: "storageClass"}, "default", newVolumesScope(newVolumesMachine()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vmImage).To(BeNil())
+	})
+
+	It("should resolve the newest imported image matching the selector", func() {
+		scope := newVolumesScope(newVolumesMachine(),
+			newLabeledImage("sles-15-sp5", now.Add(-2*time.Hour), true),
+			newLabeledImage("sles-15-sp6", now.Add(-time.Hour), true),
+			newLabeledImage("sles-15-sp7", now, false))
+
+		vmImage, err := resolveVolumeImage(newSelectorVolume(), "default", scope)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(vmImage.Name).To(Equal("sles-15-sp6"))
+	})
+
+	It("should report when no imported image matches", func() {
+		scope := newVolumesScope(newVolumesMachine(), newLabeledImage("sles-15-sp7", now, false))
+
+		_, err := resolveVolumeImage(newSelectorVolume(), "default", scope)
+		Expect(err).To(MatchError(ContainSubstring("no imported VM image in namespace images")))
+	})
+})
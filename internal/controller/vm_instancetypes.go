@@ -0,0 +1,74 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	harvclient "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned"
+)
+
+const (
+	// clusterInstancetypeKind is the kind of the instancetypes machines refer to.
+	clusterInstancetypeKind = "VirtualMachineClusterInstancetype"
+	// clusterPreferenceKind is the kind of the preferences machines refer to.
+	clusterPreferenceKind = "VirtualMachineClusterPreference"
+)
+
+// applyInstancetypeAndPreference points the VM at the cluster-wide KubeVirt
+// instancetype and preference of the HarvesterMachine, if any. KubeVirt then
+// fills the CPUs and memory of the domain from the instancetype, and the
+// defaults of its devices from the preference.
+func applyInstancetypeAndPreference(machine *infrav1.HarvesterMachine, vmSpec *kubevirtv1.VirtualMachineSpec) {
+	if machine.Spec.Instancetype != "" {
+		vmSpec.Instancetype = &kubevirtv1.InstancetypeMatcher{
+			Kind: clusterInstancetypeKind,
+			Name: machine.Spec.Instancetype,
+		}
+	}
+
+	if machine.Spec.Preference != "" {
+		vmSpec.Preference = &kubevirtv1.PreferenceMatcher{
+			Kind: clusterPreferenceKind,
+			Name: machine.Spec.Preference,
+		}
+	}
+}
+
+// getInstancetypeCapacity returns the CPUs and memory of a cluster-wide KubeVirt
+// instancetype as a capacity.
+func getInstancetypeCapacity(
+	ctx context.Context, hvClient harvclient.Interface, name string,
+) (corev1.ResourceList, error) {
+	instancetype, err := hvClient.InstancetypeV1beta1().VirtualMachineClusterInstancetypes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get instancetype %s", name)
+	}
+
+	return corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewQuantity(int64(instancetype.Spec.CPU.Guest), resource.DecimalSI),
+		corev1.ResourceMemory: instancetype.Spec.Memory.Guest,
+	}, nil
+}
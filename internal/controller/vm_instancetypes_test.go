@@ -0,0 +1,170 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+	instancetypev1beta1 "kubevirt.io/api/instancetype/v1beta1"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for KubeVirt instancetypes and preferences
+// =============================================================================
+
+var _ = Describe("applyInstancetypeAndPreference", func() {
+	It("should point the VM at the cluster instancetype and preference", func() {
+		machine := newVolumesMachine()
+		machine.Spec.Instancetype = "u1.medium"
+		machine.Spec.Preference = "rhel.9"
+
+		vmSpec := &kubevirtv1.VirtualMachineSpec{}
+		applyInstancetypeAndPreference(machine, vmSpec)
+
+		Expect(vmSpec.Instancetype).To(Equal(&kubevirtv1.InstancetypeMatcher{
+			Kind: "VirtualMachineClusterInstancetype", Name: "u1.medium",
+		}))
+		Expect(vmSpec.Preference).To(Equal(&kubevirtv1.PreferenceMatcher{
+			Kind: "VirtualMachineClusterPreference", Name: "rhel.9",
+		}))
+	})
+
+	It("should leave the VM alone without an instancetype or preference", func() {
+		vmSpec := &kubevirtv1.VirtualMachineSpec{}
+		applyInstancetypeAndPreference(newVolumesMachine(), vmSpec)
+
+		Expect(vmSpec.Instancetype).To(BeNil())
+		Expect(vmSpec.Preference).To(BeNil())
+	})
+})
+
+var _ = Describe("buildVMTemplate with an instancetype", func() {
+	It("should leave the CPUs and memory of the domain to the instancetype", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{"value": []byte("runcmd:\n  - echo hello\n")}, nil)
+		scope.HarvesterMachine.Spec.CPU = 0
+		scope.HarvesterMachine.Spec.Memory = ""
+		scope.HarvesterMachine.Spec.Instancetype = "u1.medium"
+
+		tmpl, err := buildVMTemplate(scope, nil, map[string]string{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(tmpl.Spec.Domain.CPU).To(BeNil())
+		Expect(tmpl.Spec.Domain.Memory).To(BeNil())
+		Expect(tmpl.Spec.Domain.Resources.Limits).To(BeEmpty())
+	})
+
+	It("should reject hotplug, which needs the CPUs and memory of the machine", func() {
+		scope := newBootstrapFormatTestScope(map[string][]byte{"value": []byte("runcmd:\n  - echo hello\n")}, nil)
+		scope.HarvesterMachine.Spec.CPU = 0
+		scope.HarvesterMachine.Spec.Memory = ""
+		scope.HarvesterMachine.Spec.Instancetype = "u1.medium"
+		scope.HarvesterMachine.Spec.Hotplug = &infrav1.HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"}
+
+		_, err := buildVMTemplate(scope, nil, map[string]string{})
+		Expect(err).To(MatchError(ContainSubstring("spec.hotplug cannot be set with spec.instancetype")))
+	})
+})
+
+var _ = Describe("getInstancetypeCapacity", func() {
+	It("should return the CPUs and memory of the instancetype", func() {
+		hvClient := hvfake.NewSimpleClientset(&instancetypev1beta1.VirtualMachineClusterInstancetype{
+			ObjectMeta: metav1.ObjectMeta{Name: "u1.medium"},
+			Spec: instancetypev1beta1.VirtualMachineInstancetypeSpec{
+				CPU:    instancetypev1beta1.CPUInstancetype{Guest: 2},
+				Memory: instancetypev1beta1.MemoryInstancetype{Guest: resource.MustParse("8Gi")},
+			},
+		})
+
+		capacity, err := getInstancetypeCapacity(context.TODO(), hvClient, "u1.medium")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(capacity.Cpu().String()).To(Equal("2"))
+		Expect(capacity.Memory().String()).To(Equal("8Gi"))
+	})
+
+	It("should report a missing instancetype", func() {
+		_, err := getInstancetypeCapacity(context.TODO(), hvfake.NewSimpleClientset(), "u1.medium")
+		Expect(err).To(MatchError(ContainSubstring("unable to get instancetype u1.medium")))
+	})
+})
+
+var _ = Describe("HarvesterMachineTemplateReconciler", func() {
+	request := ctrl.Request{NamespacedName: types.NamespacedName{Name: "workers", Namespace: "default"}}
+
+	newReconciler := func(spec infrav1.HarvesterMachineSpec) *HarvesterMachineTemplateReconciler {
+		scheme := pausedTestScheme()
+		template := &infrav1.HarvesterMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "workers", Namespace: "default"},
+			Spec: infrav1.HarvesterMachineTemplateSpec{
+				Template: infrav1.HarvesterMachineTemplateResource{Spec: spec},
+			},
+		}
+		cl := fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(template).
+			WithStatusSubresource(&infrav1.HarvesterMachineTemplate{}).
+			Build()
+
+		return &HarvesterMachineTemplateReconciler{Client: cl, Scheme: scheme}
+	}
+
+	It("should publish the capacity given by the cpu and memory of the template", func(ctx SpecContext) {
+		reconciler := newReconciler(infrav1.HarvesterMachineSpec{CPU: 4, Memory: "16Gi"})
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).ToNot(HaveOccurred())
+
+		template := &infrav1.HarvesterMachineTemplate{}
+		Expect(reconciler.Get(ctx, request.NamespacedName, template)).To(Succeed())
+		Expect(template.Status.Capacity.Cpu().String()).To(Equal("4"))
+		Expect(template.Status.Capacity.Memory().String()).To(Equal("16Gi"))
+	})
+
+	It("should not publish a capacity for VM template versions", func(ctx SpecContext) {
+		reconciler := newReconciler(infrav1.HarvesterMachineSpec{VMTemplateVersion: "templates/sles-worker-v3"})
+
+		_, err := reconciler.Reconcile(ctx, request)
+		Expect(err).ToNot(HaveOccurred())
+
+		template := &infrav1.HarvesterMachineTemplate{}
+		Expect(reconciler.Get(ctx, request.NamespacedName, template)).To(Succeed())
+		Expect(template.Status.Capacity).To(BeEmpty())
+	})
+
+	It("should wait for an instancetype template to be associated with a cluster", func(ctx SpecContext) {
+		reconciler := newReconciler(infrav1.HarvesterMachineSpec{Instancetype: "u1.medium"})
+
+		res, err := reconciler.Reconcile(ctx, request)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.RequeueAfter).To(Equal(instancetypeResyncPeriod))
+
+		template := &infrav1.HarvesterMachineTemplate{}
+		Expect(reconciler.Get(ctx, request.NamespacedName, template)).To(Succeed())
+		Expect(template.Status.Capacity).To(BeEmpty())
+	})
+})
@@ -0,0 +1,256 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/core/v1beta2"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+const (
+	// consoleLogContainer is the virt-launcher container streaming the serial
+	// console of the guest.
+	consoleLogContainer = "guest-console-log"
+	// consoleLogTailLines is the number of lines of the console log captured.
+	consoleLogTailLines int64 = 200
+	// consoleLogSecretKey is the key of the console log in its Secret.
+	consoleLogSecretKey = "console.log"
+)
+
+// effectiveProvisioningTimeout returns the provisioning timeout of the machine,
+// falling back to the one of its cluster.
+func effectiveProvisioningTimeout(hvScope *Scope) *infrav1.ProvisioningTimeout {
+	if hvScope.HarvesterMachine.Spec.ProvisioningTimeout != nil {
+		return hvScope.HarvesterMachine.Spec.ProvisioningTimeout
+	}
+
+	return hvScope.HarvesterCluster.Spec.ProvisioningTimeout
+}
+
+// reconcileProvisioningTimeout checks whether the VM exceeded the provisioning
+// timeout without reporting an IP address. Once it did, the tail of its guest
+// console log is captured and the ProvisioningTimedOut condition is set, and
+// the VM is deleted to be recreated while recreations remain. It returns true
+// when the VM is being recreated.
+func (r *HarvesterMachineReconciler) reconcileProvisioningTimeout(hvScope *Scope, vm *kubevirtv1.VirtualMachine) bool {
+	machine := hvScope.HarvesterMachine
+
+	// The providerID is only set once the VM reported an IP address, and is
+	// kept afterwards: later restarts of the VM are not provisioning.
+	if machine.Spec.ProviderID != "" || machine.Status.Initialization.Provisioned {
+		if conditions.IsTrue(machine, infrav1.ProvisioningTimedOutCondition) {
+			conditions.Set(machine, metav1.Condition{
+				Type:    infrav1.ProvisioningTimedOutCondition,
+				Status:  metav1.ConditionFalse,
+				Reason:  infrav1.ProvisionedReason,
+				Message: "The VM reported an IP address after exceeding the provisioning timeout",
+			})
+		}
+
+		return false
+	}
+
+	timeout := effectiveProvisioningTimeout(hvScope)
+	if timeout == nil || conditions.IsTrue(machine, infrav1.ProvisioningTimedOutCondition) {
+		return false
+	}
+
+	if time.Since(vm.CreationTimestamp.Time) < timeout.Timeout.Duration {
+		return false
+	}
+
+	message := fmt.Sprintf("The VM did not report an IP address within %s", timeout.Timeout.Duration)
+
+	secretName, err := captureConsoleLog(hvScope, vm)
+	switch {
+	case err != nil:
+		hvScope.Logger.Info("Warning: unable to capture the console log of the VM", "error", err)
+	case secretName != "":
+		machine.Status.ConsoleLogSecretRef = secretName
+		message += ", its console log is in Secret " + secretName
+	}
+
+	hvScope.Logger.Info("Warning: the VM exceeded the provisioning timeout", "timeout", timeout.Timeout.Duration)
+
+	if machine.Status.VMRecreations >= timeout.MaxRecreations {
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.ProvisioningTimedOutCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.ProvisioningTimedOutReason,
+			Message: message,
+		})
+
+		return false
+	}
+
+	err = r.recreateVM(hvScope, vm)
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to recreate the VM", "error", err)
+
+		return false
+	}
+
+	machine.Status.VMRecreations++
+
+	// The recreated VM has not timed out yet: the condition stays False until
+	// it does, and the message keeps the history.
+	conditions.Set(machine, metav1.Condition{
+		Type:   infrav1.ProvisioningTimedOutCondition,
+		Status: metav1.ConditionFalse,
+		Reason: infrav1.VMRecreatedReason,
+		Message: fmt.Sprintf("%s, recreated the VM (%d of %d)",
+			message, machine.Status.VMRecreations, timeout.MaxRecreations),
+	})
+
+	return true
+}
+
+// captureConsoleLog stores the tail of the guest console log of the VM, as
+// streamed by its virt-launcher pod, in a Secret next to the HarvesterMachine
+// and owned by it. It returns the name of the Secret, or an empty name when
+// the VM has no console log to capture.
+func captureConsoleLog(hvScope *Scope, vm *kubevirtv1.VirtualMachine) (string, error) {
+	if reason := consoleLogDisabled(vm); reason != "" {
+		hvScope.Logger.Info("Skipping the capture of the console log of the VM", "reason", reason)
+
+		return "", nil
+	}
+
+	pods, err := listLauncherPods(hvScope, vm)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to list the virt-launcher pods of the VM")
+	}
+
+	if len(pods) == 0 {
+		return "", errors.New("the VM has no virt-launcher pod")
+	}
+
+	// A VM that restarted keeps the pods of its earlier runs for a while
+	latest := &pods[0]
+	for i := range pods {
+		if pods[i].CreationTimestamp.After(latest.CreationTimestamp.Time) {
+			latest = &pods[i]
+		}
+	}
+
+	if !slices.ContainsFunc(latest.Spec.Containers, func(container corev1.Container) bool {
+		return container.Name == consoleLogContainer
+	}) {
+		hvScope.Logger.Info("Skipping the capture of the console log of the VM",
+			"reason", "pod "+latest.Name+" has no "+consoleLogContainer+" container")
+
+		return "", nil
+	}
+
+	tailLines := consoleLogTailLines
+
+	consoleLog, err := hvScope.HarvesterClient.CoreV1().Pods(latest.Namespace).GetLogs(latest.Name, &corev1.PodLogOptions{
+		Container: consoleLogContainer,
+		TailLines: &tailLines,
+	}).DoRaw(hvScope.Ctx)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get the logs of container %s of pod %s", consoleLogContainer, latest.Name)
+	}
+
+	machine := hvScope.HarvesterMachine
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      machine.Name + "-console-log",
+			Namespace: machine.Namespace,
+		},
+	}
+
+	_, err = controllerutil.CreateOrUpdate(hvScope.Ctx, hvScope.ReconcilerClient, secret, func() error {
+		if clusterName, ok := machine.Labels[clusterv1.ClusterNameLabel]; ok {
+			secret.Labels = map[string]string{clusterv1.ClusterNameLabel: clusterName}
+		}
+
+		secret.Data = map[string][]byte{consoleLogSecretKey: consoleLog}
+
+		return controllerutil.SetOwnerReference(machine, secret, hvScope.ReconcilerClient.Scheme())
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to store the console log in Secret %s", secret.Name)
+	}
+
+	return secret.Name, nil
+}
+
+// consoleLogDisabled returns why the spec of the VM keeps its virt-launcher
+// pod from streaming the guest console log, or an empty string.
+func consoleLogDisabled(vm *kubevirtv1.VirtualMachine) string {
+	if vm.Spec.Template == nil {
+		return ""
+	}
+
+	devices := vm.Spec.Template.Spec.Domain.Devices
+
+	switch {
+	case devices.AutoattachSerialConsole != nil && !*devices.AutoattachSerialConsole:
+		return "the serial console of the VM is disabled"
+	case devices.LogSerialConsole != nil && !*devices.LogSerialConsole:
+		return "the console logging of the VM is disabled"
+	}
+
+	return ""
+}
+
+// recreateVM deletes the VM and its volumes, so that the next reconciles
+// create it again once it is gone.
+func (r *HarvesterMachineReconciler) recreateVM(hvScope *Scope, vm *kubevirtv1.VirtualMachine) error {
+	err := hvScope.HarvesterClient.KubevirtV1().VirtualMachines(vm.Namespace).Delete(hvScope.Ctx, vm.Name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return errors.Wrapf(err, "unable to delete VM %s", vm.Name)
+	}
+
+	// The new VM gets new PVCs: the disks of the timed out one may hold a
+	// half-initialized system
+	if len(hvScope.HarvesterMachine.Status.Volumes) > 0 {
+		deleteVolumePVCs(hvScope, vm.Namespace)
+	} else {
+		r.deletePVCsByPrefix(hvScope.Ctx, hvScope, vm.Namespace, vm.Name+"-disk-")
+	}
+
+	hvScope.HarvesterMachine.Status.Volumes = nil
+	hvScope.HarvesterMachine.Status.Ready = false
+	hvScope.HarvesterMachine.Status.Initialization = machineInitializationNotProvisioned
+
+	conditions.Set(hvScope.HarvesterMachine, metav1.Condition{
+		Type:    infrav1.MachineCreatedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  infrav1.VMRecreatedReason,
+		Message: "The VM is recreated after exceeding the provisioning timeout",
+	})
+
+	hvScope.Logger.Info("Deleted the VM to recreate it", "vm", vm.Name)
+
+	return nil
+}
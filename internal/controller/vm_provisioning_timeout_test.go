@@ -0,0 +1,184 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// =============================================================================
+// Tests for the provisioning timeout of VMs
+// =============================================================================
+
+var _ = Describe("reconcileProvisioningTimeout", func() {
+	reconciler := &HarvesterMachineReconciler{}
+
+	newTimeoutVM := func(age time.Duration) *kubevirtv1.VirtualMachine {
+		vm := newVolumesVM()
+		vm.CreationTimestamp = metav1.NewTime(time.Now().Add(-age))
+
+		return vm
+	}
+
+	newLauncherPod := func() *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "virt-launcher-worker-0-abcde",
+				Namespace: "default",
+				Labels:    map[string]string{"kubevirt.io": "virt-launcher", "harvesterhci.io/vmName": "worker-0"},
+			},
+			Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "compute"}, {Name: consoleLogContainer}},
+			},
+		}
+	}
+
+	newTimeoutScope := func(timeout *infrav1.ProvisioningTimeout, objs ...client.Object) *Scope {
+		machine := newVolumesMachine()
+		machine.Spec.ProvisioningTimeout = timeout
+		machine.Status.Volumes = []infrav1.VolumeStatus{{Name: "disk-0", PVCName: "worker-0-disk-0-abcde"}}
+
+		pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "worker-0-disk-0-abcde", Namespace: "default"}}
+		scope := newVolumesScope(machine, newTimeoutVM(time.Hour), newLauncherPod(), pvc)
+		scope.ReconcilerClient = fake.NewClientBuilder().WithScheme(pausedTestScheme()).WithObjects(objs...).Build()
+
+		return scope
+	}
+
+	timeout := func(maxRecreations int32) *infrav1.ProvisioningTimeout {
+		return &infrav1.ProvisioningTimeout{Timeout: metav1.Duration{Duration: 15 * time.Minute}, MaxRecreations: maxRecreations}
+	}
+
+	It("should wait for a VM within the timeout", func() {
+		scope := newTimeoutScope(timeout(0))
+
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Minute))).To(BeFalse())
+		Expect(conditions.Get(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)).To(BeNil())
+	})
+
+	It("should fall back to the timeout of the cluster", func() {
+		scope := newTimeoutScope(nil)
+		scope.HarvesterCluster.Spec.ProvisioningTimeout = timeout(0)
+
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Hour))).To(BeFalse())
+		Expect(conditions.IsTrue(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)).To(BeTrue())
+	})
+
+	It("should capture the console log of a timed out VM and leave it in place", func(ctx SpecContext) {
+		scope := newTimeoutScope(timeout(0))
+
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Hour))).To(BeFalse())
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(infrav1.ProvisioningTimedOutReason))
+		Expect(condition.Message).To(ContainSubstring("within 15m0s, its console log is in Secret worker-0-console-log"))
+		Expect(scope.HarvesterMachine.Status.ConsoleLogSecretRef).To(Equal("worker-0-console-log"))
+
+		secret := &corev1.Secret{}
+		Expect(scope.ReconcilerClient.Get(ctx, client.ObjectKey{Name: "worker-0-console-log", Namespace: "test-ns"}, secret)).To(Succeed())
+		Expect(string(secret.Data[consoleLogSecretKey])).To(Equal("fake logs"))
+		Expect(secret.OwnerReferences).To(HaveLen(1))
+		Expect(secret.OwnerReferences[0].Kind).To(Equal("HarvesterMachine"))
+
+		_, err := scope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(ctx, "worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should skip the console log of a VM without serial console", func(ctx SpecContext) {
+		scope := newTimeoutScope(timeout(0))
+		vm := newTimeoutVM(time.Hour)
+		vm.Spec.Template.Spec.Domain.Devices.AutoattachSerialConsole = ptr.To(false)
+
+		Expect(reconciler.reconcileProvisioningTimeout(scope, vm)).To(BeFalse())
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Message).To(Equal("The VM did not report an IP address within 15m0s"))
+		Expect(scope.HarvesterMachine.Status.ConsoleLogSecretRef).To(BeEmpty())
+
+		err := scope.ReconcilerClient.Get(ctx, client.ObjectKey{Name: "worker-0-console-log", Namespace: "test-ns"}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should skip the console log of a virt-launcher pod that does not stream it", func(ctx SpecContext) {
+		scope := newTimeoutScope(timeout(0))
+		pod := newLauncherPod()
+		pod.Spec.Containers = pod.Spec.Containers[:1]
+		_, err := scope.HarvesterClient.CoreV1().Pods("default").Update(ctx, pod, metav1.UpdateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Hour))).To(BeFalse())
+		Expect(conditions.IsTrue(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)).To(BeTrue())
+		Expect(scope.HarvesterMachine.Status.ConsoleLogSecretRef).To(BeEmpty())
+
+		err = scope.ReconcilerClient.Get(ctx, client.ObjectKey{Name: "worker-0-console-log", Namespace: "test-ns"}, &corev1.Secret{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+
+	It("should recreate a timed out VM while recreations remain", func(ctx SpecContext) {
+		scope := newTimeoutScope(timeout(1))
+
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Hour))).To(BeTrue())
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrav1.VMRecreatedReason))
+		Expect(condition.Message).To(ContainSubstring("recreated the VM (1 of 1)"))
+		Expect(scope.HarvesterMachine.Status.VMRecreations).To(Equal(int32(1)))
+		Expect(scope.HarvesterMachine.Status.Volumes).To(BeEmpty())
+		Expect(conditions.IsFalse(scope.HarvesterMachine, infrav1.MachineCreatedCondition)).To(BeTrue())
+
+		_, err := scope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(ctx, "worker-0", metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		_, err = scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(ctx, "worker-0-disk-0-abcde", metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		// The recreated VM times out as well, with no recreation left
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Hour))).To(BeFalse())
+		Expect(conditions.IsTrue(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)).To(BeTrue())
+	})
+
+	It("should clear the condition once the machine is provisioned", func() {
+		scope := newTimeoutScope(timeout(0))
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Hour))).To(BeFalse())
+
+		scope.HarvesterMachine.Spec.ProviderID = "harvester://worker-0"
+
+		Expect(reconciler.reconcileProvisioningTimeout(scope, newTimeoutVM(time.Hour))).To(BeFalse())
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.ProvisioningTimedOutCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		Expect(condition.Reason).To(Equal(infrav1.ProvisionedReason))
+	})
+})
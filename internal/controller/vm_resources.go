@@ -0,0 +1,242 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// vmResourcesPollInterval is the requeue period while CPU or memory is being hotplugged.
+const vmResourcesPollInterval = 15 * time.Second
+
+// applyCPUOptions spreads the CPUs of the machine over the sockets and threads
+// of its cpuOptions, and sets the CPU model and dedicated placement.
+func applyCPUOptions(machine *infrav1.HarvesterMachine, domain *kubevirtv1.DomainSpec) {
+	opts := machine.Spec.CPUOptions
+	if opts == nil {
+		return
+	}
+
+	sockets := max(opts.Sockets, 1)
+	threads := max(opts.Threads, 1)
+
+	domain.CPU.Sockets = sockets
+	domain.CPU.Threads = threads
+	domain.CPU.Cores = machine.Spec.CPU / (sockets * threads)
+	domain.CPU.Model = opts.Model
+	domain.CPU.DedicatedCPUPlacement = opts.DedicatedCPUPlacement
+	domain.CPU.IsolateEmulatorThread = opts.IsolateEmulatorThread
+}
+
+// applyMemoryOptions backs the memory of the domain with hugepages, and lowers
+// the memory requested from the Harvester node by the overcommit ratio of the
+// machine. The limit stays at the guest memory.
+func applyMemoryOptions(machine *infrav1.HarvesterMachine, domain *kubevirtv1.DomainSpec) {
+	opts := machine.Spec.MemoryOptions
+	if opts == nil {
+		return
+	}
+
+	if opts.HugepagesPageSize != "" {
+		domain.Memory.Hugepages = &kubevirtv1.Hugepages{PageSize: opts.HugepagesPageSize}
+	}
+
+	if opts.OvercommitPercent > 100 {
+		request := domain.Memory.Guest.Value() * 100 / int64(opts.OvercommitPercent)
+		domain.Resources.Requests[corev1.ResourceMemory] = *resource.NewQuantity(request, resource.BinarySI)
+	}
+}
+
+// applyHotplug lays the CPUs of the domain out as sockets, the unit KubeVirt
+// hotplugs, and sets the hotplug maximums of the machine. The resource
+// requirements are dropped so that KubeVirt derives them from the guest CPUs
+// and memory, and updates them on hotplug.
+func applyHotplug(machine *infrav1.HarvesterMachine, domain *kubevirtv1.DomainSpec) error {
+	hotplug := machine.Spec.Hotplug
+	if hotplug == nil {
+		return nil
+	}
+
+	maxMemory, err := resource.ParseQuantity(hotplug.MaxMemory)
+	if err != nil {
+		return errors.Wrapf(err, "hotplug maxMemory %q is invalid", hotplug.MaxMemory)
+	}
+
+	domain.CPU.Sockets = machine.Spec.CPU
+	domain.CPU.Cores = 1
+	domain.CPU.Threads = 1
+	domain.CPU.MaxSockets = hotplug.MaxCPU
+	domain.Memory.MaxGuest = &maxMemory
+	domain.Resources = kubevirtv1.ResourceRequirements{}
+
+	return nil
+}
+
+// reconcileVMResources brings the CPUs and memory of the running VM of a
+// machine with hotplug to those of the spec, and reports the progress in the
+// VMResourcesUpToDate condition. It reports whether a change is in progress.
+func reconcileVMResources(hvScope *Scope, vm *kubevirtv1.VirtualMachine) bool {
+	machine := hvScope.HarvesterMachine
+	if machine.Spec.Hotplug == nil {
+		return false
+	}
+
+	memory, err := resource.ParseQuantity(machine.Spec.Memory)
+	if err != nil {
+		setVMResourcesUpdateFailed(machine, fmt.Sprintf("Invalid memory %q: %v", machine.Spec.Memory, err))
+
+		return false
+	}
+
+	domain := &vm.Spec.Template.Spec.Domain
+	if domain.CPU == nil || domain.CPU.MaxSockets == 0 || domain.Memory == nil || domain.Memory.MaxGuest == nil {
+		setVMResourcesUpdateFailed(machine, "The VM was created without CPU and memory hotplug")
+
+		return false
+	}
+
+	if machine.Spec.CPU > domain.CPU.MaxSockets || memory.Cmp(*domain.Memory.MaxGuest) > 0 {
+		setVMResourcesUpdateFailed(machine, fmt.Sprintf("%d CPUs and %s of memory exceed the hotplug maximums of the VM (%d CPUs, %s)",
+			machine.Spec.CPU, machine.Spec.Memory, domain.CPU.MaxSockets, domain.Memory.MaxGuest.String()))
+
+		return false
+	}
+
+	if domain.CPU.Sockets != machine.Spec.CPU || domain.Memory.Guest == nil || domain.Memory.Guest.Cmp(memory) != 0 {
+		return updateVMResources(hvScope, vm, memory)
+	}
+
+	for _, condition := range vm.Status.Conditions {
+		if condition.Type == kubevirtv1.VirtualMachineRestartRequired && condition.Status == corev1.ConditionTrue {
+			setVMResourcesUpdateFailed(machine, "The change cannot be applied without restarting the VM: "+condition.Message)
+
+			return false
+		}
+	}
+
+	vmi, err := hvScope.HarvesterClient.KubevirtV1().VirtualMachineInstances(vm.Namespace).Get(
+		hvScope.Ctx, vm.Name, metav1.GetOptions{})
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to get the VMI to check the hotplugged resources", "error", err)
+
+		return true
+	}
+
+	if !vmiResourcesApplied(vmi, machine.Spec.CPU, memory) {
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.VMResourcesUpToDateCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.VMResourcesUpdatingReason,
+			Message: fmt.Sprintf("Waiting for the VM to run with %d CPUs and %s of memory", machine.Spec.CPU, machine.Spec.Memory),
+		})
+
+		return true
+	}
+
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VMResourcesUpToDateCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  infrav1.VMResourcesUpToDateReason,
+		Message: fmt.Sprintf("The VM runs with %d CPUs and %s of memory", machine.Spec.CPU, machine.Spec.Memory),
+	})
+
+	return false
+}
+
+// updateVMResources sets the CPUs and memory of the spec on the VM, which
+// KubeVirt propagates to the running VMI. It reports whether a change is in
+// progress, which is also the case when the update failed and is retried.
+func updateVMResources(hvScope *Scope, vm *kubevirtv1.VirtualMachine, memory resource.Quantity) bool {
+	machine := hvScope.HarvesterMachine
+
+	vmCopy := vm.DeepCopy()
+	vmCopy.Spec.Template.Spec.Domain.CPU.Sockets = machine.Spec.CPU
+	vmCopy.Spec.Template.Spec.Domain.Memory.Guest = &memory
+
+	_, err := hvScope.HarvesterClient.KubevirtV1().VirtualMachines(vm.Namespace).Update(hvScope.Ctx, vmCopy, metav1.UpdateOptions{})
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to update the CPU and memory of the VM", "error", err)
+
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.VMResourcesUpToDateCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.VMResourcesUpdatingReason,
+			Message: fmt.Sprintf("Failed to update the CPU and memory of the VM, retrying: %v", err),
+		})
+
+		return true
+	}
+
+	hvScope.Logger.Info("Hotplugging VM resources", "cpu", machine.Spec.CPU, "memory", machine.Spec.Memory)
+
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VMResourcesUpToDateCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  infrav1.VMResourcesUpdatingReason,
+		Message: fmt.Sprintf("Hotplugging %d CPUs and %s of memory", machine.Spec.CPU, machine.Spec.Memory),
+	})
+
+	return true
+}
+
+// vmiResourcesApplied reports whether the running VMI has the given CPUs and
+// memory, with no hotplug left in progress.
+func vmiResourcesApplied(vmi *kubevirtv1.VirtualMachineInstance, cpu uint32, memory resource.Quantity) bool {
+	if vmi.Spec.Domain.CPU == nil || vmi.Spec.Domain.CPU.Sockets != cpu {
+		return false
+	}
+
+	if topology := vmi.Status.CurrentCPUTopology; topology != nil && topology.Sockets != cpu {
+		return false
+	}
+
+	if status := vmi.Status.Memory; status != nil && status.GuestCurrent != nil && status.GuestCurrent.Cmp(memory) < 0 {
+		return false
+	}
+
+	for _, condition := range vmi.Status.Conditions {
+		if (condition.Type == kubevirtv1.VirtualMachineInstanceVCPUChange ||
+			condition.Type == kubevirtv1.VirtualMachineInstanceMemoryChange) && condition.Status == corev1.ConditionTrue {
+			return false
+		}
+	}
+
+	return true
+}
+
+// setVMResourcesUpdateFailed reports in the VMResourcesUpToDate condition that
+// the CPUs and memory of the spec cannot be applied to the running VM.
+func setVMResourcesUpdateFailed(machine *infrav1.HarvesterMachine, message string) {
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VMResourcesUpToDateCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  infrav1.VMResourcesUpdateFailedReason,
+		Message: message,
+	})
+}
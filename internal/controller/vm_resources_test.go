@@ -0,0 +1,282 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for the in-place CPU and memory updates through hotplug
+// =============================================================================
+
+func newHotplugMachine(cpu uint32, memory string) *infrav1.HarvesterMachine {
+	return &infrav1.HarvesterMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns", Generation: 2},
+		Spec: infrav1.HarvesterMachineSpec{
+			CPU:     cpu,
+			Memory:  memory,
+			Hotplug: &infrav1.HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"},
+		},
+	}
+}
+
+func newHotplugVM(sockets uint32, guest string) *kubevirtv1.VirtualMachine {
+	return &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "default"},
+		Spec: kubevirtv1.VirtualMachineSpec{
+			Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kubevirtv1.VirtualMachineInstanceSpec{
+					Domain: kubevirtv1.DomainSpec{
+						CPU:    &kubevirtv1.CPU{Sockets: sockets, Cores: 1, Threads: 1, MaxSockets: 8},
+						Memory: &kubevirtv1.Memory{Guest: new(resource.MustParse(guest)), MaxGuest: new(resource.MustParse("16Gi"))},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newHotplugVMI(sockets uint32, guest string) *kubevirtv1.VirtualMachineInstance {
+	return &kubevirtv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "default"},
+		Spec: kubevirtv1.VirtualMachineInstanceSpec{
+			Domain: kubevirtv1.DomainSpec{CPU: &kubevirtv1.CPU{Sockets: sockets}},
+		},
+		Status: kubevirtv1.VirtualMachineInstanceStatus{
+			CurrentCPUTopology: &kubevirtv1.CPUTopology{Sockets: sockets, Cores: 1, Threads: 1},
+			Memory:             &kubevirtv1.MemoryStatus{GuestCurrent: new(resource.MustParse(guest))},
+		},
+	}
+}
+
+func newHotplugScope(machine *infrav1.HarvesterMachine, vm *kubevirtv1.VirtualMachine, vmi *kubevirtv1.VirtualMachineInstance) *Scope {
+	logger := log.FromContext(context.TODO())
+
+	client := hvfake.NewSimpleClientset(vm)
+	if vmi != nil {
+		client = hvfake.NewSimpleClientset(vm, vmi)
+	}
+
+	return &Scope{
+		Ctx:              context.TODO(),
+		HarvesterMachine: machine,
+		HarvesterClient:  client,
+		Logger:           &logger,
+	}
+}
+
+var _ = Describe("applyHotplug", func() {
+	It("should leave the domain untouched without hotplug", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{CPU: 2, Memory: "4Gi"}}
+		domain := &kubevirtv1.DomainSpec{
+			CPU:    &kubevirtv1.CPU{Cores: 2, Sockets: 1, Threads: 1},
+			Memory: &kubevirtv1.Memory{},
+		}
+
+		Expect(applyHotplug(machine, domain)).To(Succeed())
+
+		Expect(domain.CPU.Cores).To(Equal(uint32(2)))
+		Expect(domain.CPU.MaxSockets).To(BeZero())
+		Expect(domain.Memory.MaxGuest).To(BeNil())
+	})
+
+	It("should lay the CPUs out as sockets and set the maximums", func() {
+		machine := newHotplugMachine(2, "4Gi")
+		domain := &kubevirtv1.DomainSpec{
+			CPU:    &kubevirtv1.CPU{Cores: 2, Sockets: 1, Threads: 1},
+			Memory: &kubevirtv1.Memory{Guest: new(resource.MustParse("4Gi"))},
+			Resources: kubevirtv1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")},
+			},
+		}
+
+		Expect(applyHotplug(machine, domain)).To(Succeed())
+
+		Expect(domain.CPU.Sockets).To(Equal(uint32(2)))
+		Expect(domain.CPU.Cores).To(Equal(uint32(1)))
+		Expect(domain.CPU.MaxSockets).To(Equal(uint32(8)))
+		Expect(domain.Memory.MaxGuest.String()).To(Equal("16Gi"))
+		Expect(domain.Resources.Limits).To(BeEmpty())
+	})
+
+	It("should reject an invalid maximum memory", func() {
+		machine := newHotplugMachine(2, "4Gi")
+		machine.Spec.Hotplug.MaxMemory = ""
+		domain := &kubevirtv1.DomainSpec{
+			CPU:    &kubevirtv1.CPU{Cores: 2, Sockets: 1, Threads: 1},
+			Memory: &kubevirtv1.Memory{Guest: new(resource.MustParse("4Gi"))},
+		}
+
+		Expect(applyHotplug(machine, domain)).To(MatchError(ContainSubstring("hotplug maxMemory")))
+		Expect(domain.CPU.MaxSockets).To(BeZero())
+	})
+})
+
+var _ = Describe("applyCPUOptions", func() {
+	It("should spread the CPUs over the sockets and threads with the model and placement", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{
+			CPU: 8, Memory: "16Gi",
+			CPUOptions: &infrav1.CPUOptions{
+				Sockets: 2, Threads: 2, Model: "host-passthrough",
+				DedicatedCPUPlacement: true, IsolateEmulatorThread: true,
+			},
+		}}
+		domain := &kubevirtv1.DomainSpec{}
+
+		applyCPUAndMemory(machine, domain)
+		applyCPUOptions(machine, domain)
+
+		Expect(domain.CPU.Sockets).To(Equal(uint32(2)))
+		Expect(domain.CPU.Cores).To(Equal(uint32(2)))
+		Expect(domain.CPU.Threads).To(Equal(uint32(2)))
+		Expect(domain.CPU.Model).To(Equal("host-passthrough"))
+		Expect(domain.CPU.DedicatedCPUPlacement).To(BeTrue())
+		Expect(domain.CPU.IsolateEmulatorThread).To(BeTrue())
+	})
+
+	It("should keep one socket and thread by default", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{
+			CPU: 4, Memory: "8Gi", CPUOptions: &infrav1.CPUOptions{Model: "host-model"},
+		}}
+		domain := &kubevirtv1.DomainSpec{}
+
+		applyCPUAndMemory(machine, domain)
+		applyCPUOptions(machine, domain)
+
+		Expect(domain.CPU.Sockets).To(Equal(uint32(1)))
+		Expect(domain.CPU.Cores).To(Equal(uint32(4)))
+		Expect(domain.CPU.Threads).To(Equal(uint32(1)))
+		Expect(domain.CPU.Model).To(Equal("host-model"))
+	})
+})
+
+var _ = Describe("applyMemoryOptions", func() {
+	It("should back the memory with hugepages", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{
+			CPU: 2, Memory: "4Gi", MemoryOptions: &infrav1.MemoryOptions{HugepagesPageSize: "1Gi"},
+		}}
+		domain := &kubevirtv1.DomainSpec{}
+
+		applyCPUAndMemory(machine, domain)
+		applyMemoryOptions(machine, domain)
+
+		Expect(domain.Memory.Hugepages.PageSize).To(Equal("1Gi"))
+		Expect(domain.Resources.Requests.Memory().String()).To(Equal("4Gi"))
+	})
+
+	It("should lower the memory request by the overcommit ratio", func() {
+		machine := &infrav1.HarvesterMachine{Spec: infrav1.HarvesterMachineSpec{
+			CPU: 2, Memory: "12Gi", MemoryOptions: &infrav1.MemoryOptions{OvercommitPercent: 150},
+		}}
+		domain := &kubevirtv1.DomainSpec{}
+
+		applyCPUAndMemory(machine, domain)
+		applyMemoryOptions(machine, domain)
+
+		Expect(domain.Memory.Guest.String()).To(Equal("12Gi"))
+		Expect(domain.Resources.Requests.Memory().String()).To(Equal("8Gi"))
+		Expect(domain.Resources.Limits.Memory().String()).To(Equal("12Gi"))
+	})
+})
+
+var _ = Describe("reconcileVMResources", func() {
+	It("should do nothing without hotplug", func() {
+		machine := newHotplugMachine(4, "8Gi")
+		machine.Spec.Hotplug = nil
+		scope := newHotplugScope(machine, newHotplugVM(2, "4Gi"), nil)
+
+		Expect(reconcileVMResources(scope, newHotplugVM(2, "4Gi"))).To(BeFalse())
+		Expect(conditions.Get(machine, infrav1.VMResourcesUpToDateCondition)).To(BeNil())
+	})
+
+	It("should update the VM when the spec changed", func() {
+		machine := newHotplugMachine(4, "8Gi")
+		vm := newHotplugVM(2, "4Gi")
+		scope := newHotplugScope(machine, vm, newHotplugVMI(2, "4Gi"))
+
+		Expect(reconcileVMResources(scope, vm)).To(BeTrue())
+
+		updated, err := scope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(context.TODO(), "worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(updated.Spec.Template.Spec.Domain.CPU.Sockets).To(Equal(uint32(4)))
+		Expect(updated.Spec.Template.Spec.Domain.Memory.Guest.String()).To(Equal("8Gi"))
+
+		condition := conditions.Get(machine, infrav1.VMResourcesUpToDateCondition)
+		Expect(condition.Reason).To(Equal(infrav1.VMResourcesUpdatingReason))
+		Expect(condition.ObservedGeneration).To(Equal(int64(2)))
+	})
+
+	It("should wait for the VMI to run with the new resources", func() {
+		machine := newHotplugMachine(4, "8Gi")
+		vm := newHotplugVM(4, "8Gi")
+		vmi := newHotplugVMI(4, "4Gi")
+		vmi.Status.Conditions = []kubevirtv1.VirtualMachineInstanceCondition{
+			{Type: kubevirtv1.VirtualMachineInstanceMemoryChange, Status: corev1.ConditionTrue},
+		}
+		scope := newHotplugScope(machine, vm, vmi)
+
+		Expect(reconcileVMResources(scope, vm)).To(BeTrue())
+		Expect(conditions.Get(machine, infrav1.VMResourcesUpToDateCondition).Reason).To(Equal(infrav1.VMResourcesUpdatingReason))
+	})
+
+	It("should report the resources up to date once hotplugged", func() {
+		machine := newHotplugMachine(4, "8Gi")
+		vm := newHotplugVM(4, "8Gi")
+		scope := newHotplugScope(machine, vm, newHotplugVMI(4, "8Gi"))
+
+		Expect(reconcileVMResources(scope, vm)).To(BeFalse())
+		Expect(conditions.IsTrue(machine, infrav1.VMResourcesUpToDateCondition)).To(BeTrue())
+	})
+
+	It("should fail when the VM requires a restart", func() {
+		machine := newHotplugMachine(4, "8Gi")
+		vm := newHotplugVM(4, "8Gi")
+		vm.Status.Conditions = []kubevirtv1.VirtualMachineCondition{
+			{Type: kubevirtv1.VirtualMachineRestartRequired, Status: corev1.ConditionTrue, Message: "live update disabled"},
+		}
+		scope := newHotplugScope(machine, vm, newHotplugVMI(2, "4Gi"))
+
+		Expect(reconcileVMResources(scope, vm)).To(BeFalse())
+		Expect(conditions.Get(machine, infrav1.VMResourcesUpToDateCondition).Reason).To(Equal(infrav1.VMResourcesUpdateFailedReason))
+	})
+
+	It("should fail when the VM was created without hotplug", func() {
+		machine := newHotplugMachine(4, "8Gi")
+		vm := newHotplugVM(2, "4Gi")
+		vm.Spec.Template.Spec.Domain.CPU.MaxSockets = 0
+		scope := newHotplugScope(machine, vm, nil)
+
+		Expect(reconcileVMResources(scope, vm)).To(BeFalse())
+		Expect(conditions.Get(machine, infrav1.VMResourcesUpToDateCondition).Reason).To(Equal(infrav1.VMResourcesUpdateFailedReason))
+	})
+})
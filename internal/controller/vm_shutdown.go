@@ -0,0 +1,136 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// effectiveShutdownGracePeriod returns the shutdown grace period of the
+// machine, falling back to the one of its cluster. It returns 0 when the VM is
+// deleted at once.
+func effectiveShutdownGracePeriod(hvScope *Scope) time.Duration {
+	gracePeriod := hvScope.HarvesterMachine.Spec.ShutdownGracePeriod
+	if gracePeriod == nil {
+		gracePeriod = hvScope.HarvesterCluster.Spec.ShutdownGracePeriod
+	}
+
+	if gracePeriod == nil {
+		return 0
+	}
+
+	return gracePeriod.Duration
+}
+
+// applyShutdownGracePeriod lets the guest of the VMI take the shutdown grace
+// period to shut down: KubeVirt powers the VMI off once its termination grace
+// period is over, 30 seconds by default.
+func applyShutdownGracePeriod(hvScope *Scope, vmiSpec *kubevirtv1.VirtualMachineInstanceSpec) {
+	gracePeriod := effectiveShutdownGracePeriod(hvScope)
+	if gracePeriod <= 0 {
+		return
+	}
+
+	seconds := int64(gracePeriod.Seconds())
+	vmiSpec.TerminationGracePeriodSeconds = &seconds
+}
+
+// shutDownVM halts the VM, which sends an ACPI shutdown to its guest, and
+// waits up to the shutdown grace period for its VMI to be gone, reporting the
+// progress in the Deleting condition. It returns true once the VM can be
+// deleted.
+func shutDownVM(hvScope *Scope, vm *kubevirtv1.VirtualMachine) bool {
+	gracePeriod := effectiveShutdownGracePeriod(hvScope)
+	if gracePeriod <= 0 || !vm.DeletionTimestamp.IsZero() {
+		return true
+	}
+
+	machine := hvScope.HarvesterMachine
+
+	// The condition turns True when the shutdown starts, its transition time
+	// is the start of the grace period
+	if !conditions.IsTrue(machine, infrav1.DeletingCondition) {
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.DeletingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.VMShuttingDownReason,
+			Message: "Shutting down the guest of the VM",
+		})
+	}
+
+	elapsed := time.Since(conditions.Get(machine, infrav1.DeletingCondition).LastTransitionTime.Time).Round(time.Second)
+
+	_, err := hvScope.HarvesterClient.KubevirtV1().VirtualMachineInstances(vm.Namespace).Get(hvScope.Ctx, vm.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.DeletingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.VMShutDownReason,
+			Message: fmt.Sprintf("The guest shut down after %s, deleting the VM", elapsed),
+		})
+
+		return true
+	}
+
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to get the VMI of the VM", "error", err)
+	}
+
+	if elapsed >= gracePeriod {
+		hvScope.Logger.Info("Warning: the guest did not shut down in time, deleting the VM", "gracePeriod", gracePeriod)
+
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.DeletingCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  infrav1.VMShutdownTimedOutReason,
+			Message: fmt.Sprintf("The guest did not shut down within %s, deleting the VM", gracePeriod),
+		})
+
+		return true
+	}
+
+	if strategy, _ := vm.RunStrategy(); strategy != kubevirtv1.RunStrategyHalted {
+		vmCopy := vm.DeepCopy()
+		vmCopy.Spec.Running = nil
+		vmCopy.Spec.RunStrategy = new(kubevirtv1.RunStrategyHalted)
+
+		_, err = hvScope.HarvesterClient.KubevirtV1().VirtualMachines(vm.Namespace).Update(hvScope.Ctx, vmCopy, metav1.UpdateOptions{})
+		if err != nil {
+			hvScope.Logger.Info("Warning: unable to halt the VM, retrying", "error", err)
+		} else {
+			hvScope.Logger.Info("Shutting down the guest before deleting the VM", "gracePeriod", gracePeriod)
+		}
+	}
+
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.DeletingCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  infrav1.VMShuttingDownReason,
+		Message: fmt.Sprintf("Waiting for the guest to shut down, %s of %s elapsed", elapsed, gracePeriod),
+	})
+
+	return false
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// =============================================================================
+// Tests for the graceful shutdown of VMs before their deletion
+// =============================================================================
+
+var _ = Describe("shutDownVM", func() {
+	newShutdownVM := func() *kubevirtv1.VirtualMachine {
+		vm := newVolumesVM()
+		vm.Spec.RunStrategy = new(kubevirtv1.RunStrategyAlways)
+
+		return vm
+	}
+
+	newVMI := func() *kubevirtv1.VirtualMachineInstance {
+		return &kubevirtv1.VirtualMachineInstance{ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "default"}}
+	}
+
+	newShutdownMachine := func(gracePeriod time.Duration) *infrav1.HarvesterMachine {
+		machine := newVolumesMachine()
+		machine.Spec.ShutdownGracePeriod = &metav1.Duration{Duration: gracePeriod}
+
+		return machine
+	}
+
+	It("should let a VM without grace period be deleted at once", func(ctx SpecContext) {
+		vm := newShutdownVM()
+		scope := newVolumesScope(newVolumesMachine(), vm, newVMI())
+
+		Expect(shutDownVM(scope, vm)).To(BeTrue())
+		Expect(conditions.Get(scope.HarvesterMachine, infrav1.DeletingCondition)).To(BeNil())
+
+		current, err := scope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(ctx, "worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*current.Spec.RunStrategy).To(Equal(kubevirtv1.RunStrategyAlways))
+	})
+
+	It("should halt the VM and wait for its guest to shut down", func(ctx SpecContext) {
+		vm := newShutdownVM()
+		scope := newVolumesScope(newShutdownMachine(5*time.Minute), vm, newVMI())
+
+		Expect(shutDownVM(scope, vm)).To(BeFalse())
+
+		current, err := scope.HarvesterClient.KubevirtV1().VirtualMachines("default").Get(ctx, "worker-0", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*current.Spec.RunStrategy).To(Equal(kubevirtv1.RunStrategyHalted))
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.DeletingCondition)
+		Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+		Expect(condition.Reason).To(Equal(infrav1.VMShuttingDownReason))
+		Expect(condition.Message).To(ContainSubstring("of 5m0s elapsed"))
+	})
+
+	It("should let the VM be deleted once its guest shut down", func() {
+		vm := newShutdownVM()
+		scope := newVolumesScope(newShutdownMachine(5*time.Minute), vm)
+
+		Expect(shutDownVM(scope, vm)).To(BeTrue())
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.DeletingCondition)
+		Expect(condition.Reason).To(Equal(infrav1.VMShutDownReason))
+	})
+
+	It("should let the VM be deleted once the grace period is over", func() {
+		vm := newShutdownVM()
+		machine := newShutdownMachine(5 * time.Minute)
+		conditions.Set(machine, metav1.Condition{
+			Type:               infrav1.DeletingCondition,
+			Status:             metav1.ConditionTrue,
+			Reason:             infrav1.VMShuttingDownReason,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-10 * time.Minute)),
+		})
+		scope := newVolumesScope(machine, vm, newVMI())
+
+		Expect(shutDownVM(scope, vm)).To(BeTrue())
+
+		condition := conditions.Get(scope.HarvesterMachine, infrav1.DeletingCondition)
+		Expect(condition.Reason).To(Equal(infrav1.VMShutdownTimedOutReason))
+		Expect(condition.Message).To(ContainSubstring("within 5m0s"))
+	})
+})
+
+var _ = Describe("applyShutdownGracePeriod", func() {
+	It("should give the VMI the grace period of the cluster", func() {
+		scope := newVolumesScope(newVolumesMachine())
+		scope.HarvesterCluster.Spec.ShutdownGracePeriod = &metav1.Duration{Duration: 3 * time.Minute}
+
+		vmiSpec := &kubevirtv1.VirtualMachineInstanceSpec{}
+		applyShutdownGracePeriod(scope, vmiSpec)
+
+		Expect(*vmiSpec.TerminationGracePeriodSeconds).To(Equal(int64(180)))
+	})
+
+	It("should keep the default of KubeVirt without grace period", func() {
+		scope := newVolumesScope(newVolumesMachine())
+		scope.HarvesterCluster.Spec.ShutdownGracePeriod = &metav1.Duration{Duration: 3 * time.Minute}
+		scope.HarvesterMachine.Spec.ShutdownGracePeriod = &metav1.Duration{}
+
+		vmiSpec := &kubevirtv1.VirtualMachineInstanceSpec{}
+		applyShutdownGracePeriod(scope, vmiSpec)
+
+		Expect(vmiSpec.TerminationGracePeriodSeconds).To(BeNil())
+	})
+})
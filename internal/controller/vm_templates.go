@@ -0,0 +1,188 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	locutil "github.com/rancher-sandbox/cluster-api-provider-harvester/util"
+)
+
+// getVMTemplateVersion gets the Harvester VirtualMachineTemplateVersion a
+// machine is built from. vmTemplateVersion can be "namespace/name" or just
+// "name" (defaults to the target namespace).
+func getVMTemplateVersion(hvScope *Scope) (*harvesterv1beta1.VirtualMachineTemplateVersion, error) {
+	ref := hvScope.HarvesterMachine.Spec.VMTemplateVersion
+
+	name, err := locutil.GetNamespacedName(ref, hvScope.HarvesterCluster.Spec.TargetNamespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "vmTemplateVersion %q is malformed, expecting <NAMESPACE>/<NAME> format", ref)
+	}
+
+	version, err := hvScope.HarvesterClient.HarvesterhciV1beta1().VirtualMachineTemplateVersions(name.Namespace).Get(
+		hvScope.Ctx, name.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get VM template version %s", name)
+	}
+
+	if version.Spec.VM.Spec.Template == nil {
+		return nil, errors.Errorf("VM template version %s has no VM template", name)
+	}
+
+	return version, nil
+}
+
+// buildTemplateVersionPVCs builds the PVCs of a VM from the volumeClaimTemplates
+// of a VM template version, named after the VM so that each machine gets its
+// own disks, and deletes them with its other PVCs. It returns them with the
+// claim names of the template version mapped to theirs.
+func buildTemplateVersionPVCs(
+	version *harvesterv1beta1.VirtualMachineTemplateVersion, vmName, namespace string,
+) ([]*corev1.PersistentVolumeClaim, map[string]string, error) {
+	var templates []*corev1.PersistentVolumeClaim
+
+	if annotation := version.Spec.VM.ObjectMeta.Annotations[vmAnnotationPVC]; annotation != "" {
+		err := json.Unmarshal([]byte(annotation), &templates)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "unable to parse the volumeClaimTemplates of VM template version %s", version.Name)
+		}
+	}
+
+	templatesByName := make(map[string]*corev1.PersistentVolumeClaim, len(templates))
+	for _, pvc := range templates {
+		templatesByName[pvc.Name] = pvc
+	}
+
+	pvcs := make([]*corev1.PersistentVolumeClaim, 0, len(templates))
+	claimNames := make(map[string]string, len(templates))
+
+	for _, volume := range version.Spec.VM.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		template, ok := templatesByName[volume.PersistentVolumeClaim.ClaimName]
+		if !ok {
+			continue
+		}
+
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        fmt.Sprintf("%s-disk-%s-%s", vmName, volume.Name, locutil.RandomID()),
+				Namespace:   namespace,
+				Annotations: maps.Clone(template.Annotations),
+			},
+			Spec: *template.Spec.DeepCopy(),
+		}
+		pvc.Spec.VolumeName = ""
+
+		claimNames[template.Name] = pvc.Name
+		pvcs = append(pvcs, pvc)
+	}
+
+	return pvcs, claimNames, nil
+}
+
+// overlayVMTemplateVersion builds the VMI template of a machine from a VM
+// template version: the domain, disks and scheduling come from the version,
+// and the parts owned by CAPI from the template built for the machine, that
+// is the cloud-init disk, networks, labels, annotations, hostname, failure
+// domain affinity and shutdown grace period. claimNames maps the PVCs of the
+// version to those of the VM.
+func overlayVMTemplateVersion(
+	version *harvesterv1beta1.VirtualMachineTemplateVersion,
+	machineTemplate *kubevirtv1.VirtualMachineInstanceTemplateSpec,
+	claimNames map[string]string,
+) (*kubevirtv1.VirtualMachineInstanceTemplateSpec, error) {
+	vmTemplate := version.Spec.VM.Spec.Template.DeepCopy()
+
+	if vmTemplate.ObjectMeta.Labels == nil {
+		vmTemplate.ObjectMeta.Labels = make(map[string]string)
+	}
+
+	if vmTemplate.ObjectMeta.Annotations == nil {
+		vmTemplate.ObjectMeta.Annotations = make(map[string]string)
+	}
+
+	maps.Copy(vmTemplate.ObjectMeta.Labels, machineTemplate.ObjectMeta.Labels)
+	maps.Copy(vmTemplate.ObjectMeta.Annotations, machineTemplate.ObjectMeta.Annotations)
+
+	spec := &vmTemplate.Spec
+	spec.Hostname = machineTemplate.Spec.Hostname
+	spec.Networks = machineTemplate.Spec.Networks
+	spec.Domain.Devices.Interfaces = machineTemplate.Spec.Domain.Devices.Interfaces
+
+	if machineTemplate.Spec.Affinity != nil {
+		spec.Affinity = machineTemplate.Spec.Affinity
+	}
+
+	if machineTemplate.Spec.TerminationGracePeriodSeconds != nil {
+		spec.TerminationGracePeriodSeconds = machineTemplate.Spec.TerminationGracePeriodSeconds
+	}
+
+	// The cloud-init of the version is replaced by the bootstrap data of the machine
+	dropped := make(map[string]bool)
+	volumes := make([]kubevirtv1.Volume, 0, len(spec.Volumes)+len(machineTemplate.Spec.Volumes))
+	diskNames := make([]string, 0, len(spec.Volumes))
+
+	for _, volume := range spec.Volumes {
+		if volume.CloudInitNoCloud != nil || volume.CloudInitConfigDrive != nil || volume.Name == "cloudinitdisk" {
+			dropped[volume.Name] = true
+
+			continue
+		}
+
+		if claim := volume.PersistentVolumeClaim; claim != nil {
+			if name, ok := claimNames[claim.ClaimName]; ok {
+				claim.ClaimName = name
+			}
+
+			diskNames = append(diskNames, claim.ClaimName)
+		}
+
+		volumes = append(volumes, volume)
+	}
+
+	disks := make([]kubevirtv1.Disk, 0, len(spec.Domain.Devices.Disks)+len(machineTemplate.Spec.Domain.Devices.Disks))
+
+	for _, disk := range spec.Domain.Devices.Disks {
+		if !dropped[disk.Name] {
+			disks = append(disks, disk)
+		}
+	}
+
+	spec.Volumes = append(volumes, machineTemplate.Spec.Volumes...)
+	spec.Domain.Devices.Disks = append(disks, machineTemplate.Spec.Domain.Devices.Disks...)
+
+	diskNamesJSON, err := json.Marshal(diskNames)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal disk names to JSON")
+	}
+
+	vmTemplate.ObjectMeta.Annotations[hvAnnotationDiskNames] = string(diskNamesJSON)
+
+	return vmTemplate, nil
+}
@@ -0,0 +1,201 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+
+	harvesterv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+)
+
+// =============================================================================
+// Tests for the VMs built from Harvester VM template versions
+// =============================================================================
+
+func newTemplateVersion() *harvesterv1beta1.VirtualMachineTemplateVersion {
+	rootDisk := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "tmpl-rootdisk-x1y2z",
+			Annotations: map[string]string{hvAnnotationImageID: "default/image-sles"},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("60Gi")},
+			},
+		},
+	}
+
+	pvcTemplates, err := json.Marshal([]*corev1.PersistentVolumeClaim{rootDisk})
+	Expect(err).ToNot(HaveOccurred())
+
+	return &harvesterv1beta1.VirtualMachineTemplateVersion{
+		ObjectMeta: metav1.ObjectMeta{Name: "sles-worker-v3", Namespace: "templates"},
+		Spec: harvesterv1beta1.VirtualMachineTemplateVersionSpec{
+			TemplateID: "templates/sles-worker",
+			VM: harvesterv1beta1.VirtualMachineSourceSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{vmAnnotationPVC: string(pvcTemplates)},
+				},
+				Spec: kubevirtv1.VirtualMachineSpec{
+					Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"team": "virtualization"}},
+						Spec: kubevirtv1.VirtualMachineInstanceSpec{
+							Hostname: "template",
+							Networks: []kubevirtv1.Network{*kubevirtv1.DefaultPodNetwork()},
+							Domain: kubevirtv1.DomainSpec{
+								CPU: &kubevirtv1.CPU{Cores: 8, Model: "host-passthrough"},
+								Devices: kubevirtv1.Devices{
+									Disks: []kubevirtv1.Disk{{Name: "rootdisk"}, {Name: "cloudinitdisk"}},
+									Interfaces: []kubevirtv1.Interface{
+										*kubevirtv1.DefaultMasqueradeNetworkInterface(),
+									},
+								},
+							},
+							Volumes: []kubevirtv1.Volume{
+								{
+									Name: "rootdisk",
+									VolumeSource: kubevirtv1.VolumeSource{
+										PersistentVolumeClaim: &kubevirtv1.PersistentVolumeClaimVolumeSource{
+											PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
+												ClaimName: "tmpl-rootdisk-x1y2z",
+											},
+										},
+									},
+								},
+								{
+									Name: "cloudinitdisk",
+									VolumeSource: kubevirtv1.VolumeSource{
+										CloudInitNoCloud: &kubevirtv1.CloudInitNoCloudSource{UserData: "#cloud-config"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newMachineVMTemplate() *kubevirtv1.VirtualMachineInstanceTemplateSpec {
+	return &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels:      map[string]string{"harvesterhci.io/vmName": "worker-0"},
+			Annotations: map[string]string{hvAnnotationDiskNames: "[]"},
+		},
+		Spec: kubevirtv1.VirtualMachineInstanceSpec{
+			Hostname: "worker-0",
+			Networks: []kubevirtv1.Network{{
+				Name:          "nic-1",
+				NetworkSource: kubevirtv1.NetworkSource{Multus: &kubevirtv1.MultusNetwork{NetworkName: "default/vlan10"}},
+			}},
+			Domain: kubevirtv1.DomainSpec{
+				Devices: kubevirtv1.Devices{
+					Disks:      []kubevirtv1.Disk{{Name: "cloudinitdisk"}},
+					Interfaces: []kubevirtv1.Interface{{Name: "nic-1"}},
+				},
+			},
+			Volumes: []kubevirtv1.Volume{{
+				Name: "cloudinitdisk",
+				VolumeSource: kubevirtv1.VolumeSource{
+					CloudInitNoCloud: &kubevirtv1.CloudInitNoCloudSource{
+						UserDataSecretRef: &corev1.LocalObjectReference{Name: "worker-0-cloud-init"},
+					},
+				},
+			}},
+			Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{}},
+		},
+	}
+}
+
+var _ = Describe("getVMTemplateVersion", func() {
+	It("should get the template version in its namespace", func() {
+		machine := newVolumesMachine()
+		machine.Spec.VMTemplateVersion = "templates/sles-worker-v3"
+
+		version, err := getVMTemplateVersion(newVolumesScope(machine, newTemplateVersion()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(version.Spec.TemplateID).To(Equal("templates/sles-worker"))
+	})
+
+	It("should report a missing template version", func() {
+		machine := newVolumesMachine()
+		machine.Spec.VMTemplateVersion = "sles-worker-v3"
+
+		_, err := getVMTemplateVersion(newVolumesScope(machine, newTemplateVersion()))
+		Expect(err).To(MatchError(ContainSubstring("unable to get VM template version default/sles-worker-v3")))
+	})
+})
+
+var _ = Describe("buildTemplateVersionPVCs", func() {
+	It("should name the PVCs of the template version after the VM", func() {
+		pvcs, claimNames, err := buildTemplateVersionPVCs(newTemplateVersion(), "worker-0", "default")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvcs).To(HaveLen(1))
+		Expect(strings.HasPrefix(pvcs[0].Name, "worker-0-disk-rootdisk-")).To(BeTrue())
+		Expect(pvcs[0].Namespace).To(Equal("default"))
+		Expect(pvcs[0].Annotations[hvAnnotationImageID]).To(Equal("default/image-sles"))
+		Expect(pvcs[0].Spec.Resources.Requests.Storage().String()).To(Equal("60Gi"))
+		Expect(claimNames).To(HaveKeyWithValue("tmpl-rootdisk-x1y2z", pvcs[0].Name))
+	})
+
+	It("should reject malformed volumeClaimTemplates", func() {
+		version := newTemplateVersion()
+		version.Spec.VM.ObjectMeta.Annotations[vmAnnotationPVC] = "{"
+
+		_, _, err := buildTemplateVersionPVCs(version, "worker-0", "default")
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("overlayVMTemplateVersion", func() {
+	It("should keep the shape of the version and overlay the parts owned by CAPI", func() {
+		claimNames := map[string]string{"tmpl-rootdisk-x1y2z": "worker-0-disk-rootdisk-abcde"}
+
+		tmpl, err := overlayVMTemplateVersion(newTemplateVersion(), newMachineVMTemplate(), claimNames)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(tmpl.Spec.Domain.CPU.Model).To(Equal("host-passthrough"))
+		Expect(tmpl.Spec.Hostname).To(Equal("worker-0"))
+		Expect(tmpl.Spec.Networks[0].Multus.NetworkName).To(Equal("default/vlan10"))
+		Expect(tmpl.Spec.Domain.Devices.Interfaces[0].Name).To(Equal("nic-1"))
+		Expect(tmpl.Spec.Affinity.NodeAffinity).ToNot(BeNil())
+		Expect(tmpl.ObjectMeta.Labels).To(HaveKeyWithValue("team", "virtualization"))
+		Expect(tmpl.ObjectMeta.Labels).To(HaveKeyWithValue("harvesterhci.io/vmName", "worker-0"))
+		Expect(tmpl.ObjectMeta.Annotations[hvAnnotationDiskNames]).To(Equal(`["worker-0-disk-rootdisk-abcde"]`))
+	})
+
+	It("should replace the cloud-init of the version with the one of the machine", func() {
+		tmpl, err := overlayVMTemplateVersion(newTemplateVersion(), newMachineVMTemplate(), nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(tmpl.Spec.Volumes).To(HaveLen(2))
+		Expect(tmpl.Spec.Volumes[0].Name).To(Equal("rootdisk"))
+		Expect(tmpl.Spec.Volumes[1].CloudInitNoCloud.UserDataSecretRef.Name).To(Equal("worker-0-cloud-init"))
+		Expect(tmpl.Spec.Domain.Devices.Disks).To(HaveLen(2))
+		Expect(tmpl.Spec.Domain.Devices.Disks[1].Name).To(Equal("cloudinitdisk"))
+	})
+})
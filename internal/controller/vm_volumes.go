@@ -0,0 +1,404 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// volumeDiskPrefix prefixes the index of a volume of the spec in the name of its VM disk.
+const volumeDiskPrefix = "disk-"
+
+// volumeDiskName returns the name of the VM disk of the volume at the given index of the spec.
+func volumeDiskName(index int) string {
+	return volumeDiskPrefix + strconv.Itoa(index)
+}
+
+// buildVolumeDisk builds the VM disk of a volume. The bus of the volume
+// overrides the given default, and CD-ROMs default to the SATA bus since
+// KubeVirt has no virtio CD-ROM.
+func buildVolumeDisk(name string, vol *infrav1.Volume, defaultBus kubevirtv1.DiskBus) kubevirtv1.Disk {
+	disk := kubevirtv1.Disk{
+		Name:  name,
+		Cache: kubevirtv1.DriverCache(vol.Cache),
+		IO:    kubevirtv1.DriverIO(vol.IO),
+	}
+
+	if vol.DeviceType == infrav1.VolumeDeviceTypeCDRom {
+		bus := kubevirtv1.DiskBusSATA
+		if vol.Bus != "" {
+			bus = kubevirtv1.DiskBus(vol.Bus)
+		}
+
+		disk.CDRom = &kubevirtv1.CDRomTarget{Bus: bus}
+	} else {
+		bus := defaultBus
+		if vol.Bus != "" {
+			bus = kubevirtv1.DiskBus(vol.Bus)
+		}
+
+		disk.Disk = &kubevirtv1.DiskTarget{Bus: bus}
+	}
+
+	if vol.BootOrder > 0 {
+		disk.BootOrder = new(uint(vol.BootOrder))
+	}
+
+	return disk
+}
+
+// recordVolumeStatuses records the PVCs of the disks a VM was created with,
+// and the images they were created from.
+func recordVolumeStatuses(machine *infrav1.HarvesterMachine, disks []diskInfo) {
+	volumes := make([]infrav1.VolumeStatus, 0, len(disks))
+
+	for _, d := range disks {
+		volumes = append(volumes, infrav1.VolumeStatus{
+			Index:     d.index,
+			Name:      volumeDiskName(d.index),
+			PVCName:   d.pvcName,
+			Size:      copyQuantity(machine.Spec.Volumes[d.index].VolumeSize),
+			ImageName: d.imageName,
+			ImageUID:  d.imageUID,
+		})
+	}
+
+	machine.Status.Volumes = volumes
+}
+
+// restoreVolumeStatuses rebuilds the volume status of a machine from the PVC
+// disks of its VM. It covers machines created before the status was recorded,
+// and machines whose status was lost in a move or restore.
+func restoreVolumeStatuses(hvScope *Scope, vm *kubevirtv1.VirtualMachine) {
+	if len(hvScope.HarvesterMachine.Status.Volumes) > 0 || vm.Spec.Template == nil {
+		return
+	}
+
+	for _, volume := range vm.Spec.Template.Spec.Volumes {
+		if !strings.HasPrefix(volume.Name, volumeDiskPrefix) || volume.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		index, err := strconv.Atoi(strings.TrimPrefix(volume.Name, volumeDiskPrefix))
+		if err != nil {
+			continue
+		}
+
+		status := infrav1.VolumeStatus{
+			Index:      index,
+			Name:       volume.Name,
+			PVCName:    volume.PersistentVolumeClaim.ClaimName,
+			Hotplugged: volume.PersistentVolumeClaim.Hotpluggable,
+		}
+
+		pvc, err := hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(vm.Namespace).Get(
+			hvScope.Ctx, status.PVCName, metav1.GetOptions{})
+		if err == nil {
+			if request, ok := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; ok {
+				status.Size = &request
+			}
+
+			status.ImageName = pvc.Annotations[hvAnnotationImageID]
+		}
+
+		hvScope.HarvesterMachine.Status.Volumes = append(hvScope.HarvesterMachine.Status.Volumes, status)
+	}
+}
+
+// copyQuantity returns a copy of an optional quantity.
+func copyQuantity(quantity *resource.Quantity) *resource.Quantity {
+	if quantity == nil {
+		return nil
+	}
+
+	return new(quantity.DeepCopy())
+}
+
+// findVolumeStatus returns the status of the volume at the given index of the spec.
+func findVolumeStatus(machine *infrav1.HarvesterMachine, index int) *infrav1.VolumeStatus {
+	for i := range machine.Status.Volumes {
+		if machine.Status.Volumes[i].Index == index {
+			return &machine.Status.Volumes[i]
+		}
+	}
+
+	return nil
+}
+
+// reconcileVolumes applies the volume changes of the spec to the running VM
+// of a machine opted in to inPlaceVolumeUpdates: PVCs are expanded when
+// volumeSize grows, and appended volumes are created and hotplugged. The
+// progress is reported in the VolumesUpToDate condition. It reports whether a
+// change is in progress.
+func reconcileVolumes(hvScope *Scope, vm *kubevirtv1.VirtualMachine) bool {
+	machine := hvScope.HarvesterMachine
+	// The disks of machines built from a VM template version belong to the version
+	if machine.Spec.VMTemplateVersion != "" {
+		return false
+	}
+
+	restoreVolumeStatuses(hvScope, vm)
+
+	// The volume changes of other machines are rolled out by CAPI replacing them
+	if !machine.Spec.InPlaceVolumeUpdates {
+		conditions.Delete(machine, infrav1.VolumesUpToDateCondition)
+
+		return false
+	}
+
+	var pending []string
+
+	for i := range machine.Spec.Volumes {
+		vol := &machine.Spec.Volumes[i]
+
+		status := findVolumeStatus(machine, i)
+		if status == nil {
+			if vol.VolumeType != "storageClass" || vol.VolumeSize == nil {
+				setVolumesUpdateFailed(machine, fmt.Sprintf("Volume %d cannot be hotplugged, only sized storageClass volumes can", i))
+
+				return false
+			}
+
+			// The PVC name is recorded before the volume is attached, so that it
+			// is reused, and deleted with the machine, should the attachment fail.
+			err := createHotplugPVC(hvScope, vm.Namespace, i)
+			if err != nil {
+				hvScope.Logger.Info("Warning: unable to create the PVC of a hotplugged volume", "volume", i, "error", err)
+
+				pending = append(pending, volumeDiskName(i)+" creating")
+
+				continue
+			}
+
+			pending = append(pending, volumeDiskName(i)+" created")
+
+			continue
+		}
+
+		if !vmHasVolume(vm, status.Name) {
+			err := hotplugVolume(hvScope, vm, vol, status)
+			if err != nil {
+				hvScope.Logger.Info("Warning: unable to hotplug volume", "volume", status.Name, "error", err)
+			}
+
+			pending = append(pending, status.Name+" attaching")
+
+			continue
+		}
+
+		expanding, err := reconcileVolumeSize(hvScope, vm.Namespace, vol, status)
+		if err != nil {
+			setVolumesUpdateFailed(machine, fmt.Sprintf("Volume %s cannot be expanded: %v", status.Name, err))
+
+			return false
+		}
+
+		if expanding {
+			pending = append(pending, status.Name+" expanding")
+		}
+	}
+
+	if len(pending) > 0 {
+		conditions.Set(machine, metav1.Condition{
+			Type:    infrav1.VolumesUpToDateCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  infrav1.VolumesUpdatingReason,
+			Message: "Updating volumes: " + strings.Join(pending, ", "),
+		})
+
+		return true
+	}
+
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VolumesUpToDateCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  infrav1.VolumesUpToDateReason,
+		Message: fmt.Sprintf("The VM has the %d volumes of the spec", len(machine.Spec.Volumes)),
+	})
+
+	return false
+}
+
+// createHotplugPVC creates the PVC of the volume appended at the given index
+// of the spec, and records it in the status. Only storageClass volumes are
+// hotplugged, so the PVC never references an image. The PVC name is derived
+// from the machine and the index, so that a PVC created by a reconcile whose
+// status update was lost is found again instead of duplicated.
+func createHotplugPVC(hvScope *Scope, namespace string, index int) error {
+	machine := hvScope.HarvesterMachine
+	vol := &machine.Spec.Volumes[index]
+	pvcName := hotplugPVCName(machine.Name, index)
+
+	pvc, err := buildPVCForVolume(vol, nil, pvcName, namespace, hvScope)
+	if err != nil {
+		return err
+	}
+
+	_, err = hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(namespace).Create(hvScope.Ctx, pvc, metav1.CreateOptions{})
+	if err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+
+	machine.Status.Volumes = append(machine.Status.Volumes, infrav1.VolumeStatus{
+		Index:      index,
+		Name:       volumeDiskName(index),
+		PVCName:    pvcName,
+		Size:       copyQuantity(vol.VolumeSize),
+		Hotplugged: true,
+	})
+
+	return nil
+}
+
+// hotplugPVCName returns the name of the PVC of the volume hotplugged at the
+// given index of the volumes of a machine.
+func hotplugPVCName(machineName string, index int) string {
+	return fmt.Sprintf("%s-disk-%d-hotplug", machineName, index)
+}
+
+// vmHasVolume reports whether a VM has, or is adding, the volume with the given name.
+func vmHasVolume(vm *kubevirtv1.VirtualMachine, name string) bool {
+	for _, volume := range vm.Spec.Template.Spec.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+
+	for _, request := range vm.Status.VolumeRequests {
+		if request.AddVolumeOptions != nil && request.AddVolumeOptions.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hotplugVolume attaches the PVC of a volume to the running VM through the
+// addvolume subresource of KubeVirt. Hotplugged disks use the SCSI bus, the
+// only one KubeVirt hotplugs.
+func hotplugVolume(hvScope *Scope, vm *kubevirtv1.VirtualMachine, vol *infrav1.Volume, status *infrav1.VolumeStatus) error {
+	disk := buildVolumeDisk(status.Name, vol, kubevirtv1.DiskBusSCSI)
+
+	options := &kubevirtv1.AddVolumeOptions{
+		Name: status.Name,
+		Disk: &disk,
+		VolumeSource: &kubevirtv1.HotplugVolumeSource{
+			PersistentVolumeClaim: &kubevirtv1.PersistentVolumeClaimVolumeSource{
+				PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{ClaimName: status.PVCName},
+				Hotpluggable:                      true,
+			},
+		},
+	}
+
+	body, err := json.Marshal(options)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal the volume options")
+	}
+
+	hvScope.Logger.Info("Hotplugging volume", "volume", status.Name, "pvc", status.PVCName)
+
+	return hvScope.HarvesterClient.KubevirtV1().RESTClient().Put().
+		AbsPath("/apis/subresources.kubevirt.io/v1/namespaces", vm.Namespace, "virtualmachines", vm.Name, "addvolume").
+		Body(body).
+		Do(hvScope.Ctx).
+		Error()
+}
+
+// reconcileVolumeSize expands the PVC of a volume to the volumeSize of the
+// spec, and records its size and capacity. It reports whether the expansion
+// is still in progress, and returns an error when the PVC cannot be expanded.
+func reconcileVolumeSize(hvScope *Scope, namespace string, vol *infrav1.Volume, status *infrav1.VolumeStatus) (bool, error) {
+	pvcs := hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(namespace)
+
+	pvc, err := pvcs.Get(hvScope.Ctx, status.PVCName, metav1.GetOptions{})
+	if err != nil {
+		hvScope.Logger.Info("Warning: unable to get the PVC of a volume", "pvc", status.PVCName, "error", err)
+
+		return true, nil
+	}
+
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		status.Capacity = &capacity
+	}
+
+	if vol.VolumeSize == nil {
+		return false, nil
+	}
+
+	request := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if request.Cmp(*vol.VolumeSize) < 0 {
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = *vol.VolumeSize
+
+		_, err = pvcs.Update(hvScope.Ctx, pvc, metav1.UpdateOptions{})
+		if apierrors.IsForbidden(err) || apierrors.IsInvalid(err) {
+			return false, err
+		}
+
+		if err != nil {
+			hvScope.Logger.Info("Warning: unable to expand the PVC of a volume", "pvc", status.PVCName, "error", err)
+
+			return true, nil
+		}
+
+		hvScope.Logger.Info("Expanding volume", "pvc", status.PVCName, "size", vol.VolumeSize.String())
+	}
+
+	status.Size = copyQuantity(vol.VolumeSize)
+
+	return status.Capacity == nil || status.Capacity.Cmp(*vol.VolumeSize) < 0, nil
+}
+
+// setVolumesUpdateFailed reports in the VolumesUpToDate condition that the
+// volumes of the spec cannot be applied to the running VM.
+func setVolumesUpdateFailed(machine *infrav1.HarvesterMachine, message string) {
+	conditions.Set(machine, metav1.Condition{
+		Type:    infrav1.VolumesUpToDateCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  infrav1.VolumesUpdateFailedReason,
+		Message: message,
+	})
+}
+
+// deleteVolumePVCs deletes the PVCs recorded in the volume status of a machine.
+func deleteVolumePVCs(hvScope *Scope, namespace string) {
+	for _, volume := range hvScope.HarvesterMachine.Status.Volumes {
+		err := hvScope.HarvesterClient.CoreV1().PersistentVolumeClaims(namespace).Delete(
+			hvScope.Ctx, volume.PVCName, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			hvScope.Logger.Info("Warning: failed to delete PVC", "pvc", volume.PVCName, "error", err)
+
+			continue
+		}
+
+		hvScope.Logger.Info("Deleted PVC", "pvc", volume.PVCName)
+	}
+}
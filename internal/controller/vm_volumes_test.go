@@ -0,0 +1,289 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	kubevirtv1 "kubevirt.io/api/core/v1"
+
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+	hvfake "github.com/rancher-sandbox/cluster-api-provider-harvester/pkg/clientset/versioned/fake"
+)
+
+// =============================================================================
+// Tests for the expansion and hotplug of volumes on running machines
+// =============================================================================
+
+func newVolumesMachine(sizes ...string) *infrav1.HarvesterMachine {
+	machine := &infrav1.HarvesterMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns"},
+	}
+
+	for _, size := range sizes {
+		machine.Spec.Volumes = append(machine.Spec.Volumes, infrav1.Volume{
+			VolumeType: "storageClass", StorageClass: "longhorn", VolumeSize: new(resource.MustParse(size)),
+		})
+	}
+
+	return machine
+}
+
+func newVolumesVM(pvcNames ...string) *kubevirtv1.VirtualMachine {
+	vm := &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "default"},
+		Spec: kubevirtv1.VirtualMachineSpec{
+			Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{},
+		},
+	}
+
+	for i, pvcName := range pvcNames {
+		vm.Spec.Template.Spec.Volumes = append(vm.Spec.Template.Spec.Volumes, kubevirtv1.Volume{
+			Name: volumeDiskName(i),
+			VolumeSource: kubevirtv1.VolumeSource{
+				PersistentVolumeClaim: &kubevirtv1.PersistentVolumeClaimVolumeSource{
+					PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+				},
+			},
+		})
+	}
+
+	return vm
+}
+
+func newVolumesPVC(name, request, capacity string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(request)},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)},
+		},
+	}
+}
+
+func newVolumesScope(machine *infrav1.HarvesterMachine, objs ...runtime.Object) *Scope {
+	logger := log.FromContext(context.TODO())
+
+	return &Scope{
+		Ctx:              context.TODO(),
+		HarvesterMachine: machine,
+		HarvesterCluster: &infrav1.HarvesterCluster{Spec: infrav1.HarvesterClusterSpec{TargetNamespace: "default"}},
+		HarvesterClient:  hvfake.NewSimpleClientset(objs...),
+		Logger:           &logger,
+	}
+}
+
+var _ = Describe("buildVolumeDisk", func() {
+	It("should attach a volume as a disk on the default bus", func() {
+		disk := buildVolumeDisk("disk-0", &infrav1.Volume{BootOrder: 1}, kubevirtv1.DiskBusVirtio)
+
+		Expect(disk.Disk.Bus).To(Equal(kubevirtv1.DiskBusVirtio))
+		Expect(disk.CDRom).To(BeNil())
+		Expect(*disk.BootOrder).To(Equal(uint(1)))
+		Expect(disk.Cache).To(BeEmpty())
+	})
+
+	It("should use the bus, cache and IO of the volume", func() {
+		vol := &infrav1.Volume{Bus: "sata", Cache: "none", IO: "native"}
+
+		disk := buildVolumeDisk("disk-0", vol, kubevirtv1.DiskBusVirtio)
+
+		Expect(disk.Disk.Bus).To(Equal(kubevirtv1.DiskBusSATA))
+		Expect(disk.Cache).To(Equal(kubevirtv1.CacheNone))
+		Expect(disk.IO).To(Equal(kubevirtv1.IONative))
+		Expect(disk.BootOrder).To(BeNil())
+	})
+
+	It("should attach a CD-ROM on the SATA bus by default", func() {
+		vol := &infrav1.Volume{VolumeType: "image", DeviceType: infrav1.VolumeDeviceTypeCDRom}
+
+		disk := buildVolumeDisk("disk-1", vol, kubevirtv1.DiskBusVirtio)
+
+		Expect(disk.Disk).To(BeNil())
+		Expect(disk.CDRom.Bus).To(Equal(kubevirtv1.DiskBusSATA))
+	})
+})
+
+var _ = Describe("volume status", func() {
+	It("should record the PVCs a VM is created with", func() {
+		machine := newVolumesMachine("40Gi", "10Gi")
+
+		recordVolumeStatuses(machine, []diskInfo{{pvcName: "worker-0-disk-0-abcde", index: 0}, {pvcName: "worker-0-disk-1-fghij", index: 1}})
+
+		Expect(machine.Status.Volumes).To(HaveLen(2))
+		Expect(machine.Status.Volumes[1].Name).To(Equal("disk-1"))
+		Expect(machine.Status.Volumes[1].PVCName).To(Equal("worker-0-disk-1-fghij"))
+		Expect(machine.Status.Volumes[1].Size.String()).To(Equal("10Gi"))
+	})
+
+	It("should record the image a disk is created from", func() {
+		machine := newVolumesMachine("40Gi")
+
+		recordVolumeStatuses(machine, []diskInfo{
+			{pvcName: "worker-0-disk-0-abcde", index: 0, imageName: "images/sles-15-sp6", imageUID: "1234-abcd"},
+		})
+
+		Expect(machine.Status.Volumes[0].ImageName).To(Equal("images/sles-15-sp6"))
+		Expect(machine.Status.Volumes[0].ImageUID).To(BeEquivalentTo("1234-abcd"))
+	})
+
+	It("should restore the volume status from the VM", func() {
+		machine := newVolumesMachine("40Gi")
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+
+		restoreVolumeStatuses(scope, newVolumesVM("worker-0-disk-0-abcde"))
+
+		Expect(machine.Status.Volumes).To(HaveLen(1))
+		Expect(machine.Status.Volumes[0].Index).To(Equal(0))
+		Expect(machine.Status.Volumes[0].PVCName).To(Equal("worker-0-disk-0-abcde"))
+		Expect(machine.Status.Volumes[0].Size.String()).To(Equal("40Gi"))
+	})
+
+	It("should delete the recorded PVCs", func() {
+		machine := newVolumesMachine("40Gi")
+		machine.Status.Volumes = []infrav1.VolumeStatus{{Index: 0, Name: "disk-0", PVCName: "worker-0-disk-0-abcde"}}
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+
+		deleteVolumePVCs(scope, "default")
+
+		_, err := scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(
+			context.TODO(), "worker-0-disk-0-abcde", metav1.GetOptions{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+	})
+})
+
+var _ = Describe("reconcileVolumes", func() {
+	newInPlaceMachine := func(sizes ...string) *infrav1.HarvesterMachine {
+		machine := newVolumesMachine(sizes...)
+		machine.Spec.InPlaceVolumeUpdates = true
+
+		return machine
+	}
+
+	It("should leave the volumes of machines without in-place volume updates", func() {
+		machine := newVolumesMachine("60Gi", "10Gi")
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+
+		Expect(reconcileVolumes(scope, newVolumesVM("worker-0-disk-0-abcde"))).To(BeFalse())
+		Expect(conditions.Has(machine, infrav1.VolumesUpToDateCondition)).To(BeFalse())
+
+		// The status is still recorded for the deletion of the PVCs
+		Expect(machine.Status.Volumes).To(HaveLen(1))
+
+		pvc, err := scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(
+			context.TODO(), "worker-0-disk-0-abcde", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("40Gi"))
+	})
+
+	It("should report the volumes up to date", func() {
+		machine := newInPlaceMachine("40Gi")
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+
+		Expect(reconcileVolumes(scope, newVolumesVM("worker-0-disk-0-abcde"))).To(BeFalse())
+		Expect(conditions.IsTrue(machine, infrav1.VolumesUpToDateCondition)).To(BeTrue())
+		Expect(machine.Status.Volumes[0].Capacity.String()).To(Equal("40Gi"))
+	})
+
+	It("should expand the PVC of a grown volume", func() {
+		machine := newInPlaceMachine("60Gi")
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+
+		Expect(reconcileVolumes(scope, newVolumesVM("worker-0-disk-0-abcde"))).To(BeTrue())
+
+		pvc, err := scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(
+			context.TODO(), "worker-0-disk-0-abcde", metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvc.Spec.Resources.Requests.Storage().String()).To(Equal("60Gi"))
+		Expect(machine.Status.Volumes[0].Size.String()).To(Equal("60Gi"))
+		Expect(conditions.Get(machine, infrav1.VolumesUpToDateCondition).Reason).To(Equal(infrav1.VolumesUpdatingReason))
+	})
+
+	It("should create and record the PVC of an appended volume", func() {
+		machine := newInPlaceMachine("40Gi", "10Gi")
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+
+		Expect(reconcileVolumes(scope, newVolumesVM("worker-0-disk-0-abcde"))).To(BeTrue())
+
+		Expect(machine.Status.Volumes).To(HaveLen(2))
+		added := machine.Status.Volumes[1]
+		Expect(added.Name).To(Equal("disk-1"))
+		Expect(added.Hotplugged).To(BeTrue())
+		Expect(added.PVCName).To(Equal("worker-0-disk-1-hotplug"))
+
+		pvc, err := scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").Get(
+			context.TODO(), added.PVCName, metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(*pvc.Spec.StorageClassName).To(Equal("longhorn"))
+	})
+
+	It("should reuse the PVC of an appended volume whose status was lost", func() {
+		machine := newInPlaceMachine("40Gi", "10Gi")
+		scope := newVolumesScope(machine,
+			newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"), newVolumesPVC("worker-0-disk-1-hotplug", "10Gi", "10Gi"))
+
+		Expect(reconcileVolumes(scope, newVolumesVM("worker-0-disk-0-abcde"))).To(BeTrue())
+
+		Expect(machine.Status.Volumes).To(HaveLen(2))
+		Expect(machine.Status.Volumes[1].PVCName).To(Equal("worker-0-disk-1-hotplug"))
+
+		pvcs, err := scope.HarvesterClient.CoreV1().PersistentVolumeClaims("default").List(context.TODO(), metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(pvcs.Items).To(HaveLen(2))
+	})
+
+	It("should wait for a volume being hotplugged", func() {
+		machine := newInPlaceMachine("40Gi", "10Gi")
+		machine.Status.Volumes = []infrav1.VolumeStatus{
+			{Index: 0, Name: "disk-0", PVCName: "worker-0-disk-0-abcde"},
+			{Index: 1, Name: "disk-1", PVCName: "worker-0-disk-1-fghij", Hotplugged: true},
+		}
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+		vm := newVolumesVM("worker-0-disk-0-abcde")
+		vm.Status.VolumeRequests = []kubevirtv1.VirtualMachineVolumeRequest{
+			{AddVolumeOptions: &kubevirtv1.AddVolumeOptions{Name: "disk-1"}},
+		}
+
+		Expect(reconcileVolumes(scope, vm)).To(BeTrue())
+		Expect(conditions.Get(machine, infrav1.VolumesUpToDateCondition).Message).To(ContainSubstring("disk-1"))
+	})
+
+	It("should fail on an appended image volume", func() {
+		machine := newInPlaceMachine("40Gi")
+		machine.Spec.Volumes = append(machine.Spec.Volumes, infrav1.Volume{VolumeType: "image", ImageName: "default/leap"})
+		scope := newVolumesScope(machine, newVolumesPVC("worker-0-disk-0-abcde", "40Gi", "40Gi"))
+
+		Expect(reconcileVolumes(scope, newVolumesVM("worker-0-disk-0-abcde"))).To(BeFalse())
+		Expect(conditions.Get(machine, infrav1.VolumesUpToDateCondition).Reason).To(Equal(infrav1.VolumesUpdateFailedReason))
+	})
+})
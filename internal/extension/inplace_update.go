@@ -0,0 +1,433 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package extension implements the Cluster API runtime extension handlers of
+// the provider.
+package extension
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	runtimehooksv1 "sigs.k8s.io/cluster-api/api/runtime/hooks/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1alpha1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1alpha1"
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+// updateRetryAfterSeconds is the delay before CAPI checks again an in-place update in progress.
+const updateRetryAfterSeconds = 15
+
+// InPlaceUpdateHandlers implements the in-place update hooks, which apply CPU,
+// memory and volume changes to running machines through KubeVirt hotplug.
+type InPlaceUpdateHandlers struct {
+	Client client.Client
+}
+
+type jsonPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// DoCanUpdateMachine tells CAPI which changes of a HarvesterMachine can be
+// applied in place. CAPI replaces the machine when other fields change.
+func (h *InPlaceUpdateHandlers) DoCanUpdateMachine(ctx context.Context,
+	request *runtimehooksv1.CanUpdateMachineRequest, response *runtimehooksv1.CanUpdateMachineResponse,
+) {
+	logger := log.FromContext(ctx)
+
+	current, desired := &infrav1.HarvesterMachine{}, &infrav1.HarvesterMachine{}
+
+	received, supported, err := decodeObjects(request.Current.InfrastructureMachine, current,
+		request.Desired.InfrastructureMachine, desired,
+		func() conversion.Convertible { return &infrav1alpha1.HarvesterMachine{} })
+	if err != nil {
+		response.SetStatus(runtimehooksv1.ResponseStatusFailure)
+		response.SetMessage(err.Error())
+
+		return
+	}
+
+	response.SetStatus(runtimehooksv1.ResponseStatusSuccess)
+
+	if !supported {
+		return
+	}
+
+	patch, err := inPlacePatch(&current.Spec, &desired.Spec, "/spec")
+	if err != nil {
+		logger.Info("Change cannot be applied in place", "reason", err.Error())
+
+		return
+	}
+
+	patch, err = receivedVersionPatch(patch, received)
+	if err != nil {
+		response.SetStatus(runtimehooksv1.ResponseStatusFailure)
+		response.SetMessage(err.Error())
+
+		return
+	}
+
+	response.InfrastructureMachinePatch = patch
+}
+
+// DoCanUpdateMachineSet tells CAPI which changes of a HarvesterMachineTemplate
+// can be applied in place to the machines of a MachineSet.
+func (h *InPlaceUpdateHandlers) DoCanUpdateMachineSet(ctx context.Context,
+	request *runtimehooksv1.CanUpdateMachineSetRequest, response *runtimehooksv1.CanUpdateMachineSetResponse,
+) {
+	logger := log.FromContext(ctx)
+
+	current, desired := &infrav1.HarvesterMachineTemplate{}, &infrav1.HarvesterMachineTemplate{}
+
+	received, supported, err := decodeObjects(request.Current.InfrastructureMachineTemplate, current,
+		request.Desired.InfrastructureMachineTemplate, desired,
+		func() conversion.Convertible { return &infrav1alpha1.HarvesterMachineTemplate{} })
+	if err != nil {
+		response.SetStatus(runtimehooksv1.ResponseStatusFailure)
+		response.SetMessage(err.Error())
+
+		return
+	}
+
+	response.SetStatus(runtimehooksv1.ResponseStatusSuccess)
+
+	if !supported {
+		return
+	}
+
+	patch, err := inPlacePatch(&current.Spec.Template.Spec, &desired.Spec.Template.Spec, "/spec/template/spec")
+	if err != nil {
+		logger.Info("Change cannot be applied in place", "reason", err.Error())
+
+		return
+	}
+
+	patch, err = receivedVersionPatch(patch, received)
+	if err != nil {
+		response.SetStatus(runtimehooksv1.ResponseStatusFailure)
+		response.SetMessage(err.Error())
+
+		return
+	}
+
+	response.InfrastructureMachineTemplatePatch = patch
+}
+
+// DoUpdateMachine reports the progress of an in-place update. CAPI sets the
+// new CPU and memory on the HarvesterMachine before calling the hook, and the
+// HarvesterMachine controller hotplugs them into the VM.
+func (h *InPlaceUpdateHandlers) DoUpdateMachine(ctx context.Context,
+	request *runtimehooksv1.UpdateMachineRequest, response *runtimehooksv1.UpdateMachineResponse,
+) {
+	desired := &infrav1.HarvesterMachine{}
+
+	err := json.Unmarshal(request.Desired.InfrastructureMachine.Raw, desired)
+	if err != nil {
+		response.SetStatus(runtimehooksv1.ResponseStatusFailure)
+		response.SetMessage(fmt.Sprintf("failed to decode the HarvesterMachine: %v", err))
+
+		return
+	}
+
+	hvMachine := &infrav1.HarvesterMachine{}
+
+	err = h.Client.Get(ctx, client.ObjectKey{Namespace: desired.Namespace, Name: desired.Name}, hvMachine)
+	if err != nil {
+		response.SetStatus(runtimehooksv1.ResponseStatusFailure)
+		response.SetMessage(fmt.Sprintf("failed to get HarvesterMachine %s/%s: %v", desired.Namespace, desired.Name, err))
+
+		return
+	}
+
+	status, retryAfterSeconds, message := updateStatus(hvMachine)
+	response.SetStatus(status)
+	response.SetRetryAfterSeconds(retryAfterSeconds)
+	response.SetMessage(message)
+}
+
+// updateStatus maps the VMResourcesUpToDate and VolumesUpToDate conditions
+// of a machine to the status of the UpdateMachine hook. An update in progress
+// is reported as a success with a retry delay, and a completed one without.
+func updateStatus(hvMachine *infrav1.HarvesterMachine) (runtimehooksv1.ResponseStatus, int32, string) {
+	var conditionTypes []string
+	if hvMachine.Spec.InPlaceVolumeUpdates {
+		conditionTypes = append(conditionTypes, infrav1.VolumesUpToDateCondition)
+	}
+
+	if hvMachine.Spec.Hotplug != nil {
+		conditionTypes = append(conditionTypes, infrav1.VMResourcesUpToDateCondition)
+	}
+
+	var messages []string
+
+	for _, conditionType := range conditionTypes {
+		condition := conditions.Get(hvMachine, conditionType)
+		if condition == nil || condition.ObservedGeneration < hvMachine.Generation {
+			return runtimehooksv1.ResponseStatusSuccess, updateRetryAfterSeconds,
+				"Waiting for the HarvesterMachine controller to apply the update"
+		}
+
+		if condition.Reason == infrav1.VMResourcesUpdateFailedReason || condition.Reason == infrav1.VolumesUpdateFailedReason {
+			return runtimehooksv1.ResponseStatusFailure, 0, condition.Message
+		}
+
+		if condition.Status != metav1.ConditionTrue {
+			messages = append(messages, condition.Message)
+		}
+	}
+
+	if len(messages) > 0 {
+		return runtimehooksv1.ResponseStatusSuccess, updateRetryAfterSeconds, strings.Join(messages, "; ")
+	}
+
+	return runtimehooksv1.ResponseStatusSuccess, 0, ""
+}
+
+// decodeObjects decodes the current and desired objects of a request into
+// hub objects. The CRDs map the CAPI contracts to v1alpha1, so CAPI usually
+// sends v1alpha1 objects: these are converted with newSpoke, and the desired
+// one is returned as received, to build the patch against it. It reports false
+// when the objects are of another API version.
+func decodeObjects(currentRaw runtime.RawExtension, current conversion.Hub,
+	desiredRaw runtime.RawExtension, desired conversion.Hub, newSpoke func() conversion.Convertible,
+) (conversion.Convertible, bool, error) {
+	apiVersions := make([]string, 0, 2)
+
+	for _, raw := range []runtime.RawExtension{currentRaw, desiredRaw} {
+		typeMeta := metav1.TypeMeta{}
+
+		err := json.Unmarshal(raw.Raw, &typeMeta)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to decode the object type")
+		}
+
+		apiVersions = append(apiVersions, typeMeta.APIVersion)
+	}
+
+	switch {
+	case apiVersions[0] != apiVersions[1]:
+		return nil, false, nil
+	case apiVersions[0] == infrav1.GroupVersion.String():
+		err := json.Unmarshal(currentRaw.Raw, current)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to decode the current object")
+		}
+
+		err = json.Unmarshal(desiredRaw.Raw, desired)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to decode the desired object")
+		}
+
+		return nil, true, nil
+	case apiVersions[0] == infrav1alpha1.GroupVersion.String():
+		err := convertObject(currentRaw, newSpoke(), current)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to decode the current object")
+		}
+
+		received := newSpoke()
+
+		err = convertObject(desiredRaw, received, desired)
+		if err != nil {
+			return nil, false, errors.Wrap(err, "failed to decode the desired object")
+		}
+
+		return received, true, nil
+	default:
+		return nil, false, nil
+	}
+}
+
+// convertObject decodes raw into spoke and converts it to hub.
+func convertObject(raw runtime.RawExtension, spoke conversion.Convertible, hub conversion.Hub) error {
+	err := json.Unmarshal(raw.Raw, spoke)
+	if err != nil {
+		return err
+	}
+
+	return spoke.ConvertTo(hub)
+}
+
+// receivedVersionPatch rewrites the values of the patch computed on the hub
+// objects with those of the desired object as received, so that the patch
+// applies to its API version. The patch is returned as is for the hub version.
+func receivedVersionPatch(patch runtimehooksv1.Patch, received conversion.Convertible) (runtimehooksv1.Patch, error) {
+	if received == nil || !patch.IsDefined() {
+		return patch, nil
+	}
+
+	object, err := runtime.DefaultUnstructuredConverter.ToUnstructured(received)
+	if err != nil {
+		return runtimehooksv1.Patch{}, errors.Wrap(err, "failed to convert the desired object")
+	}
+
+	var operations []jsonPatchOperation
+
+	err = json.Unmarshal(patch.Patch, &operations)
+	if err != nil {
+		return runtimehooksv1.Patch{}, errors.Wrap(err, "failed to decode the patch")
+	}
+
+	for i := range operations {
+		fields := strings.Split(strings.TrimPrefix(operations[i].Path, "/"), "/")
+
+		value, found, fieldErr := unstructured.NestedFieldNoCopy(object, fields...)
+		if fieldErr != nil || !found {
+			return runtimehooksv1.Patch{}, errors.Errorf("the desired object has no field %s", operations[i].Path)
+		}
+
+		operations[i].Value = value
+	}
+
+	patch.Patch, err = json.Marshal(operations)
+	if err != nil {
+		return runtimehooksv1.Patch{}, errors.Wrap(err, "failed to marshal the patch")
+	}
+
+	return patch, nil
+}
+
+// inPlacePatch returns the JSON patch bringing the current spec to the
+// desired one, under the given path. It returns an error when the change
+// cannot be applied to the running VM, and an empty patch when none of the
+// fields updated in place changed.
+func inPlacePatch(current, desired *infrav1.HarvesterMachineSpec, path string) (runtimehooksv1.Patch, error) {
+	operations, err := resourcesOperations(current, desired, path)
+	if err != nil {
+		return runtimehooksv1.Patch{}, err
+	}
+
+	volumeOperations, err := volumesOperations(current, desired, path)
+	if err != nil {
+		return runtimehooksv1.Patch{}, err
+	}
+
+	operations = append(operations, volumeOperations...)
+	if len(operations) == 0 {
+		return runtimehooksv1.Patch{}, nil
+	}
+
+	patch, err := json.Marshal(operations)
+	if err != nil {
+		return runtimehooksv1.Patch{}, errors.Wrap(err, "failed to marshal the patch")
+	}
+
+	return runtimehooksv1.Patch{PatchType: runtimehooksv1.JSONPatchType, Patch: patch}, nil
+}
+
+// resourcesOperations returns the operations bringing the CPU and memory of
+// the current spec to those of the desired one. It returns an error when the
+// change cannot be hotplugged.
+func resourcesOperations(current, desired *infrav1.HarvesterMachineSpec, path string) ([]jsonPatchOperation, error) {
+	if current.CPU == desired.CPU && current.Memory == desired.Memory {
+		return nil, nil
+	}
+
+	if desired.Hotplug == nil {
+		return nil, errors.New("hotplug is not enabled")
+	}
+
+	if !equality.Semantic.DeepEqual(current.Hotplug, desired.Hotplug) {
+		return nil, errors.New("the hotplug maximums changed")
+	}
+
+	currentMemory, err := resource.ParseQuantity(current.Memory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid current memory %q", current.Memory)
+	}
+
+	desiredMemory, err := resource.ParseQuantity(desired.Memory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid desired memory %q", desired.Memory)
+	}
+
+	maxMemory, err := resource.ParseQuantity(desired.Hotplug.MaxMemory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid hotplug maximum memory %q", desired.Hotplug.MaxMemory)
+	}
+
+	if desired.CPU < current.CPU || desiredMemory.Cmp(currentMemory) < 0 {
+		return nil, errors.New("CPU and memory cannot be removed from a running VM")
+	}
+
+	if desired.CPU > desired.Hotplug.MaxCPU || desiredMemory.Cmp(maxMemory) > 0 {
+		return nil, errors.New("the CPU or memory exceeds the hotplug maximums")
+	}
+
+	return []jsonPatchOperation{
+		{Op: "replace", Path: path + "/cpu", Value: desired.CPU},
+		{Op: "replace", Path: path + "/memory", Value: desired.Memory},
+	}, nil
+}
+
+// volumesOperations returns the operation bringing the volumes of the current
+// spec to those of the desired one, for machines opting in with
+// inPlaceVolumeUpdates. Volumes can be expanded, and blank storageClass
+// volumes appended; any other change returns an error.
+func volumesOperations(current, desired *infrav1.HarvesterMachineSpec, path string) ([]jsonPatchOperation, error) {
+	if equality.Semantic.DeepEqual(current.Volumes, desired.Volumes) {
+		return nil, nil
+	}
+
+	if !current.InPlaceVolumeUpdates || !desired.InPlaceVolumeUpdates {
+		return nil, errors.New("in-place volume updates are not enabled")
+	}
+
+	if len(desired.Volumes) < len(current.Volumes) {
+		return nil, errors.New("volumes cannot be removed from a running VM")
+	}
+
+	for i, vol := range desired.Volumes {
+		if i >= len(current.Volumes) {
+			if vol.VolumeType != "storageClass" || vol.VolumeSize == nil || (vol.Bus != "" && vol.Bus != "scsi") {
+				return nil, errors.Errorf("volume %d cannot be hotplugged, only sized storageClass volumes on the scsi bus can", i)
+			}
+
+			continue
+		}
+
+		currentVol, desiredVol := current.Volumes[i], vol
+		currentVol.VolumeSize, desiredVol.VolumeSize = nil, nil
+
+		if !equality.Semantic.DeepEqual(desiredVol, currentVol) {
+			return nil, errors.Errorf("only the size of volume %d can change in place", i)
+		}
+
+		currentSize := current.Volumes[i].VolumeSize
+		if currentSize != nil && (vol.VolumeSize == nil || vol.VolumeSize.Cmp(*currentSize) < 0) {
+			return nil, errors.Errorf("volume %d cannot shrink", i)
+		}
+	}
+
+	return []jsonPatchOperation{{Op: "replace", Path: path + "/volumes", Value: desired.Volumes}}, nil
+}
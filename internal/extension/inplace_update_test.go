@@ -0,0 +1,414 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package extension
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	runtimehooksv1 "sigs.k8s.io/cluster-api/api/runtime/hooks/v1alpha1"
+
+	infrav1alpha1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1alpha1"
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
+)
+
+func newMachineSpec(cpu uint32, memory string, hotplug *infrav1.HotplugConfig) infrav1.HarvesterMachineSpec {
+	return infrav1.HarvesterMachineSpec{CPU: cpu, Memory: memory, Hotplug: hotplug}
+}
+
+func withVolumes(spec infrav1.HarvesterMachineSpec, inPlace bool, sizes ...string) infrav1.HarvesterMachineSpec {
+	spec.InPlaceVolumeUpdates = inPlace
+
+	for _, size := range sizes {
+		spec.Volumes = append(spec.Volumes, infrav1.Volume{
+			VolumeType: "storageClass", StorageClass: "longhorn", VolumeSize: new(resource.MustParse(size)),
+		})
+	}
+
+	return spec
+}
+
+func withVolumeBus(spec infrav1.HarvesterMachineSpec, index int, bus infrav1.DiskBus) infrav1.HarvesterMachineSpec {
+	spec.Volumes[index].Bus = bus
+
+	return spec
+}
+
+func rawMachine(t *testing.T, spec infrav1.HarvesterMachineSpec) runtime.RawExtension {
+	t.Helper()
+
+	machine := &infrav1.HarvesterMachine{
+		TypeMeta:   metav1.TypeMeta{APIVersion: infrav1.GroupVersion.String(), Kind: "HarvesterMachine"},
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns"},
+		Spec:       spec,
+	}
+
+	raw, err := json.Marshal(machine)
+	if err != nil {
+		t.Fatalf("failed to marshal the machine: %v", err)
+	}
+
+	return runtime.RawExtension{Raw: raw}
+}
+
+func rawV1alpha1Machine(t *testing.T, spec infrav1.HarvesterMachineSpec) runtime.RawExtension {
+	t.Helper()
+
+	machine := &infrav1alpha1.HarvesterMachine{}
+	if err := machine.ConvertFrom(&infrav1.HarvesterMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns"},
+		Spec:       spec,
+	}); err != nil {
+		t.Fatalf("failed to convert the machine: %v", err)
+	}
+
+	machine.TypeMeta = metav1.TypeMeta{APIVersion: infrav1alpha1.GroupVersion.String(), Kind: "HarvesterMachine"}
+
+	raw, err := json.Marshal(machine)
+	if err != nil {
+		t.Fatalf("failed to marshal the machine: %v", err)
+	}
+
+	return runtime.RawExtension{Raw: raw}
+}
+
+func TestInPlacePatch(t *testing.T) {
+	hotplug := &infrav1.HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"}
+
+	cases := []struct {
+		name      string
+		current   infrav1.HarvesterMachineSpec
+		desired   infrav1.HarvesterMachineSpec
+		wantPatch string
+		wantErr   bool
+	}{
+		{
+			"unchanged resources need no patch",
+			newMachineSpec(2, "4Gi", hotplug), newMachineSpec(2, "4Gi", hotplug),
+			"", false,
+		},
+		{
+			"increased resources within the maximums are patched",
+			newMachineSpec(2, "4Gi", hotplug), newMachineSpec(4, "8Gi", hotplug),
+			`[{"op":"replace","path":"/spec/cpu","value":4},{"op":"replace","path":"/spec/memory","value":"8Gi"}]`, false,
+		},
+		{
+			"resources cannot change without hotplug",
+			newMachineSpec(2, "4Gi", nil), newMachineSpec(4, "4Gi", nil),
+			"", true,
+		},
+		{
+			"resources cannot change with the maximums",
+			newMachineSpec(2, "4Gi", hotplug), newMachineSpec(4, "4Gi", &infrav1.HotplugConfig{MaxCPU: 16, MaxMemory: "16Gi"}),
+			"", true,
+		},
+		{
+			"cpu cannot decrease",
+			newMachineSpec(4, "4Gi", hotplug), newMachineSpec(2, "4Gi", hotplug),
+			"", true,
+		},
+		{
+			"memory cannot decrease",
+			newMachineSpec(2, "8Gi", hotplug), newMachineSpec(2, "4096Mi", hotplug),
+			"", true,
+		},
+		{
+			"memory cannot exceed the maximum",
+			newMachineSpec(2, "4Gi", hotplug), newMachineSpec(2, "32Gi", hotplug),
+			"", true,
+		},
+		{
+			"volume changes are patched with in-place volume updates",
+			withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi"), withVolumes(newMachineSpec(2, "4Gi", nil), true, "60Gi", "10Gi"),
+			`[{"op":"replace","path":"/spec/volumes","value":[` +
+				`{"volumeType":"storageClass","storageClass":"longhorn","volumeSize":"60Gi"},` +
+				`{"volumeType":"storageClass","storageClass":"longhorn","volumeSize":"10Gi"}]}]`, false,
+		},
+		{
+			"volumes cannot change without in-place volume updates",
+			withVolumes(newMachineSpec(2, "4Gi", nil), false, "40Gi"), withVolumes(newMachineSpec(2, "4Gi", nil), false, "60Gi"),
+			"", true,
+		},
+		{
+			"volumes cannot shrink",
+			withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi"), withVolumes(newMachineSpec(2, "4Gi", nil), true, "20Gi"),
+			"", true,
+		},
+		{
+			"volumes cannot be removed",
+			withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi", "10Gi"), withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi"),
+			"", true,
+		},
+		{
+			"the bus of a volume cannot change",
+			withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi"),
+			withVolumeBus(withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi"), 0, "sata"),
+			"", true,
+		},
+		{
+			"volumes on another bus than scsi cannot be hotplugged",
+			withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi"),
+			withVolumeBus(withVolumes(newMachineSpec(2, "4Gi", nil), true, "40Gi", "10Gi"), 1, "virtio"),
+			"", true,
+		},
+	}
+	for _, tc := range cases {
+		patch, err := inPlacePatch(&tc.current, &tc.desired, "/spec")
+
+		if tc.wantErr != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+		}
+
+		if string(patch.Patch) != tc.wantPatch {
+			t.Errorf("%s: expected patch %s, got %s", tc.name, tc.wantPatch, patch.Patch)
+		}
+	}
+}
+
+func TestDoCanUpdateMachine(t *testing.T) {
+	hotplug := &infrav1.HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"}
+	handlers := &InPlaceUpdateHandlers{}
+
+	request := &runtimehooksv1.CanUpdateMachineRequest{}
+	request.Current.InfrastructureMachine = rawMachine(t, newMachineSpec(2, "4Gi", hotplug))
+	request.Desired.InfrastructureMachine = rawMachine(t, newMachineSpec(4, "4Gi", hotplug))
+	response := &runtimehooksv1.CanUpdateMachineResponse{}
+
+	handlers.DoCanUpdateMachine(context.TODO(), request, response)
+
+	if response.Status != runtimehooksv1.ResponseStatusSuccess {
+		t.Fatalf("expected a success, got %s: %s", response.Status, response.Message)
+	}
+
+	if response.InfrastructureMachinePatch.PatchType != runtimehooksv1.JSONPatchType {
+		t.Errorf("expected a JSON patch of the machine, got %q", response.InfrastructureMachinePatch.PatchType)
+	}
+
+	request.Desired.InfrastructureMachine = rawMachine(t, newMachineSpec(4, "4Gi", nil))
+	response = &runtimehooksv1.CanUpdateMachineResponse{}
+
+	handlers.DoCanUpdateMachine(context.TODO(), request, response)
+
+	if response.Status != runtimehooksv1.ResponseStatusSuccess || response.InfrastructureMachinePatch.IsDefined() {
+		t.Errorf("expected a success without patch when hotplug is disabled, got %s with %s",
+			response.Status, response.InfrastructureMachinePatch.Patch)
+	}
+}
+
+func TestDoCanUpdateMachineV1alpha1(t *testing.T) {
+	hotplug := &infrav1.HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"}
+
+	request := &runtimehooksv1.CanUpdateMachineRequest{}
+	request.Current.InfrastructureMachine = rawV1alpha1Machine(t,
+		withVolumes(newMachineSpec(2, "4Gi", hotplug), true, "40Gi"))
+	request.Desired.InfrastructureMachine = rawV1alpha1Machine(t,
+		withVolumes(newMachineSpec(4, "4Gi", hotplug), true, "60Gi"))
+	response := &runtimehooksv1.CanUpdateMachineResponse{}
+
+	(&InPlaceUpdateHandlers{}).DoCanUpdateMachine(context.TODO(), request, response)
+
+	if response.Status != runtimehooksv1.ResponseStatusSuccess {
+		t.Fatalf("expected a success, got %s: %s", response.Status, response.Message)
+	}
+
+	want := `[{"op":"replace","path":"/spec/cpu","value":4},{"op":"replace","path":"/spec/memory","value":"4Gi"},` +
+		`{"op":"replace","path":"/spec/volumes","value":[` +
+		`{"storageClass":"longhorn","volumeSize":"60Gi","volumeType":"storageClass"}]}]`
+	if string(response.InfrastructureMachinePatch.Patch) != want {
+		t.Errorf("expected patch %s, got %s", want, response.InfrastructureMachinePatch.Patch)
+	}
+
+	request.Desired.InfrastructureMachine = rawMachine(t, withVolumes(newMachineSpec(4, "4Gi", hotplug), true, "60Gi"))
+	response = &runtimehooksv1.CanUpdateMachineResponse{}
+
+	(&InPlaceUpdateHandlers{}).DoCanUpdateMachine(context.TODO(), request, response)
+
+	if response.Status != runtimehooksv1.ResponseStatusSuccess || response.InfrastructureMachinePatch.IsDefined() {
+		t.Errorf("expected a success without patch across API versions, got %s with %s",
+			response.Status, response.InfrastructureMachinePatch.Patch)
+	}
+}
+
+func TestDoCanUpdateMachineSet(t *testing.T) {
+	hotplug := &infrav1.HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"}
+
+	rawTemplate := func(spec infrav1.HarvesterMachineSpec) runtime.RawExtension {
+		template := &infrav1.HarvesterMachineTemplate{
+			TypeMeta: metav1.TypeMeta{APIVersion: infrav1.GroupVersion.String(), Kind: "HarvesterMachineTemplate"},
+		}
+		template.Spec.Template.Spec = spec
+
+		raw, err := json.Marshal(template)
+		if err != nil {
+			t.Fatalf("failed to marshal the template: %v", err)
+		}
+
+		return runtime.RawExtension{Raw: raw}
+	}
+
+	request := &runtimehooksv1.CanUpdateMachineSetRequest{}
+	request.Current.InfrastructureMachineTemplate = rawTemplate(newMachineSpec(2, "4Gi", hotplug))
+	request.Desired.InfrastructureMachineTemplate = rawTemplate(newMachineSpec(2, "8Gi", hotplug))
+	response := &runtimehooksv1.CanUpdateMachineSetResponse{}
+
+	(&InPlaceUpdateHandlers{}).DoCanUpdateMachineSet(context.TODO(), request, response)
+
+	want := `[{"op":"replace","path":"/spec/template/spec/cpu","value":2},` +
+		`{"op":"replace","path":"/spec/template/spec/memory","value":"8Gi"}]`
+	if string(response.InfrastructureMachineTemplatePatch.Patch) != want {
+		t.Errorf("expected patch %s, got %s", want, response.InfrastructureMachineTemplatePatch.Patch)
+	}
+
+	rawV1alpha1Template := func(spec infrav1.HarvesterMachineSpec) runtime.RawExtension {
+		hub := &infrav1.HarvesterMachineTemplate{}
+		hub.Spec.Template.Spec = spec
+
+		template := &infrav1alpha1.HarvesterMachineTemplate{}
+		if err := template.ConvertFrom(hub); err != nil {
+			t.Fatalf("failed to convert the template: %v", err)
+		}
+
+		template.TypeMeta = metav1.TypeMeta{APIVersion: infrav1alpha1.GroupVersion.String(), Kind: "HarvesterMachineTemplate"}
+
+		raw, err := json.Marshal(template)
+		if err != nil {
+			t.Fatalf("failed to marshal the template: %v", err)
+		}
+
+		return runtime.RawExtension{Raw: raw}
+	}
+
+	request.Current.InfrastructureMachineTemplate = rawV1alpha1Template(newMachineSpec(2, "4Gi", hotplug))
+	request.Desired.InfrastructureMachineTemplate = rawV1alpha1Template(newMachineSpec(2, "8Gi", hotplug))
+	response = &runtimehooksv1.CanUpdateMachineSetResponse{}
+
+	(&InPlaceUpdateHandlers{}).DoCanUpdateMachineSet(context.TODO(), request, response)
+
+	if string(response.InfrastructureMachineTemplatePatch.Patch) != want {
+		t.Errorf("expected patch %s of the v1alpha1 template, got %s", want, response.InfrastructureMachineTemplatePatch.Patch)
+	}
+}
+
+func TestDoUpdateMachine(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := infrav1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build the scheme: %v", err)
+	}
+
+	volumesUpToDate := metav1.Condition{
+		Type: infrav1.VolumesUpToDateCondition, Status: metav1.ConditionTrue,
+		Reason: infrav1.VolumesUpToDateReason, ObservedGeneration: 2,
+	}
+
+	cases := []struct {
+		name      string
+		condition *metav1.Condition
+		wantFail  bool
+		wantRetry bool
+	}{
+		{"missing condition is in progress", nil, false, true},
+		{
+			"condition of an older generation is in progress",
+			&metav1.Condition{Status: metav1.ConditionTrue, Reason: infrav1.VMResourcesUpToDateReason, ObservedGeneration: 1},
+			false, true,
+		},
+		{
+			"updating condition is in progress",
+			&metav1.Condition{Status: metav1.ConditionFalse, Reason: infrav1.VMResourcesUpdatingReason, ObservedGeneration: 2},
+			false, true,
+		},
+		{
+			"up-to-date condition is done",
+			&metav1.Condition{Status: metav1.ConditionTrue, Reason: infrav1.VMResourcesUpToDateReason, ObservedGeneration: 2},
+			false, false,
+		},
+		{
+			"failed condition fails the update",
+			&metav1.Condition{Status: metav1.ConditionFalse, Reason: infrav1.VMResourcesUpdateFailedReason, ObservedGeneration: 2},
+			true, false,
+		},
+	}
+	for _, tc := range cases {
+		hvMachine := &infrav1.HarvesterMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker-0", Namespace: "test-ns", Generation: 2},
+			Spec:       newMachineSpec(4, "8Gi", &infrav1.HotplugConfig{MaxCPU: 8, MaxMemory: "16Gi"}),
+			Status:     infrav1.HarvesterMachineStatus{Conditions: []metav1.Condition{volumesUpToDate}},
+		}
+		if tc.condition != nil {
+			tc.condition.Type = infrav1.VMResourcesUpToDateCondition
+			hvMachine.Status.Conditions = append(hvMachine.Status.Conditions, *tc.condition)
+		}
+
+		handlers := &InPlaceUpdateHandlers{
+			Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(hvMachine).Build(),
+		}
+
+		for _, raw := range []runtime.RawExtension{
+			rawMachine(t, newMachineSpec(4, "8Gi", nil)), rawV1alpha1Machine(t, newMachineSpec(4, "8Gi", nil)),
+		} {
+			request := &runtimehooksv1.UpdateMachineRequest{}
+			request.Desired.InfrastructureMachine = raw
+			response := &runtimehooksv1.UpdateMachineResponse{}
+
+			handlers.DoUpdateMachine(context.TODO(), request, response)
+
+			if tc.wantFail != (response.Status == runtimehooksv1.ResponseStatusFailure) {
+				t.Errorf("%s: unexpected status %s: %s", tc.name, response.Status, response.Message)
+			}
+
+			if tc.wantRetry != (response.RetryAfterSeconds > 0) {
+				t.Errorf("%s: unexpected retry after %d seconds", tc.name, response.RetryAfterSeconds)
+			}
+		}
+	}
+}
+
+func TestUpdateStatusVolumes(t *testing.T) {
+	hvMachine := &infrav1.HarvesterMachine{ObjectMeta: metav1.ObjectMeta{Generation: 3}}
+	hvMachine.Spec.InPlaceVolumeUpdates = true
+	hvMachine.Status.Conditions = []metav1.Condition{{
+		Type: infrav1.VolumesUpToDateCondition, Status: metav1.ConditionFalse,
+		Reason: infrav1.VolumesUpdatingReason, Message: "Updating volumes: disk-1 attaching", ObservedGeneration: 3,
+	}}
+
+	status, retryAfterSeconds, message := updateStatus(hvMachine)
+	if status != runtimehooksv1.ResponseStatusSuccess || retryAfterSeconds == 0 || message != "Updating volumes: disk-1 attaching" {
+		t.Errorf("expected an update in progress, got %s after %d seconds: %s", status, retryAfterSeconds, message)
+	}
+
+	hvMachine.Status.Conditions[0].Reason = infrav1.VolumesUpdateFailedReason
+
+	status, _, _ = updateStatus(hvMachine)
+	if status != runtimehooksv1.ResponseStatusFailure {
+		t.Errorf("expected a failure, got %s", status)
+	}
+
+	hvMachine.Spec.InPlaceVolumeUpdates = false
+
+	status, retryAfterSeconds, _ = updateStatus(hvMachine)
+	if status != runtimehooksv1.ResponseStatusSuccess || retryAfterSeconds != 0 {
+		t.Errorf("expected the volumes to be ignored without in-place volume updates, got %s after %d seconds",
+			status, retryAfterSeconds)
+	}
+}
@@ -95,6 +95,62 @@ var (
 		Help:      "Total number of VM IP pool releases.",
 	})
 
+	// IPPoolAllocationConflictsTotal counts IP pool writes lost to concurrent writers.
+	IPPoolAllocationConflictsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ippool_allocation_conflicts_total",
+		Help:      "Total number of IP pool updates retried after a conflict with a concurrent writer.",
+	})
+
+	// IPPoolLeakedAllocations reports the allocations without owner found by the IP pool audit.
+	IPPoolLeakedAllocations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ippool_leaked_allocations",
+		Help:      "Number of IP pool allocations whose owner no longer exists, per cluster and pool.",
+	}, []string{"cluster", "pool"})
+
+	// IPPoolLeakedReleasesTotal counts the leaked allocations released by the IP pool audit.
+	IPPoolLeakedReleasesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ippool_leaked_releases_total",
+		Help:      "Total number of leaked IP pool allocations released by the IP pool audit.",
+	})
+
+	// IPPoolTotalAddresses reports the number of addresses of the IP pools used by clusters.
+	IPPoolTotalAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ippool_total_addresses",
+		Help:      "Number of addresses of the IP pool.",
+	}, []string{"pool"})
+
+	// IPPoolAllocatedAddresses reports the number of allocated addresses of the IP pools.
+	IPPoolAllocatedAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ippool_allocated_addresses",
+		Help:      "Number of allocated addresses of the IP pool, whatever their owner.",
+	}, []string{"pool"})
+
+	// IPPoolAvailableAddresses reports the number of free addresses of the IP pools.
+	IPPoolAvailableAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ippool_available_addresses",
+		Help:      "Number of free addresses of the IP pool.",
+	}, []string{"pool"})
+
+	// IPPoolCAPHVAllocatedAddresses reports the number of addresses of the IP pools allocated by CAPHV.
+	IPPoolCAPHVAllocatedAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ippool_caphv_allocated_addresses",
+		Help:      "Number of addresses of the IP pool allocated to CAPHV machines, claims and load balancers.",
+	}, []string{"pool"})
+
+	// IPPoolClusterAllocatedAddresses reports the number of addresses of the IP pools allocated per cluster.
+	IPPoolClusterAllocatedAddresses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ippool_cluster_allocated_addresses",
+		Help:      "Number of addresses of the IP pool allocated to the machines, claims and load balancer of a cluster.",
+	}, []string{"cluster", "pool"})
+
 	// Cluster lifecycle metrics.
 
 	// ClusterReconcileDuration tracks cluster reconciliation duration.
@@ -175,6 +231,14 @@ func init() {
 		IPPoolAllocationsTotal,
 		IPPoolAllocationErrorsTotal,
 		IPPoolReleasesTotal,
+		IPPoolAllocationConflictsTotal,
+		IPPoolLeakedAllocations,
+		IPPoolLeakedReleasesTotal,
+		IPPoolTotalAddresses,
+		IPPoolAllocatedAddresses,
+		IPPoolAvailableAddresses,
+		IPPoolCAPHVAllocatedAddresses,
+		IPPoolClusterAllocatedAddresses,
 		// Cluster
 		ClusterReconcileDuration,
 		ClusterReady,
@@ -29,6 +29,7 @@ import (
 	managementv3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	upgradev1 "github.com/rancher/system-upgrade-controller/pkg/apis/upgrade.cattle.io/v1"
 	kubevirtv1 "kubevirt.io/api/core/v1"
+	instancetypev1beta1 "kubevirt.io/api/instancetype/v1beta1"
 
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
@@ -50,6 +51,7 @@ var localSchemeBuilder = runtime.SchemeBuilder{
 	clusterv1beta1.AddToScheme,
 	corev1.AddToScheme,
 	harvesterhciv1beta1.AddToScheme,
+	instancetypev1beta1.AddToScheme,
 	k8scnicncfiov1.AddToScheme,
 	kubevirtv1.AddToScheme,
 	lbv1beta1.AddToScheme,
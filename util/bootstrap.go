@@ -0,0 +1,125 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// BootstrapFormat is the format of the bootstrap data produced by a CAPI bootstrap provider.
+type BootstrapFormat string
+
+const (
+	// BootstrapFormatCloudConfig is cloud-init cloud-config user data.
+	BootstrapFormatCloudConfig BootstrapFormat = "cloud-config"
+	// BootstrapFormatIgnition is an Ignition JSON config (Flatcar, Fedora CoreOS).
+	BootstrapFormatIgnition BootstrapFormat = "ignition"
+	// BootstrapFormatTalos is a Talos machine configuration. The Talos bootstrap provider
+	// labels it cloud-config, so it is recognized from its content.
+	BootstrapFormatTalos BootstrapFormat = "talos"
+)
+
+// DetectBootstrapFormat returns the format of the bootstrap data given the value of the
+// "format" key of the CAPI bootstrap data secret, which may be empty.
+func DetectBootstrapFormat(format string, data string) (BootstrapFormat, error) {
+	switch BootstrapFormat(format) {
+	case BootstrapFormatIgnition:
+		return BootstrapFormatIgnition, nil
+	case "", BootstrapFormatCloudConfig:
+		if isTalosMachineConfig(data) {
+			return BootstrapFormatTalos, nil
+		}
+
+		return BootstrapFormatCloudConfig, nil
+	default:
+		return "", fmt.Errorf("unsupported bootstrap data format %q", format)
+	}
+}
+
+// isTalosMachineConfig reports whether the first YAML document of data is a Talos
+// v1alpha1 machine configuration.
+func isTalosMachineConfig(data string) bool {
+	if strings.HasPrefix(strings.TrimSpace(data), "#cloud-config") {
+		return false
+	}
+
+	doc := make(map[string]any)
+
+	err := yaml.NewDecoder(strings.NewReader(data)).Decode(&doc)
+	if err != nil {
+		return false
+	}
+
+	_, hasMachine := doc["machine"]
+
+	return doc["version"] == "v1alpha1" && hasMachine
+}
+
+// InjectIgnitionSSHKey adds publicKey to the authorized SSH keys of user in the Ignition
+// config data, creating the user entry when it does not exist.
+func InjectIgnitionSSHKey(data []byte, user string, publicKey string) ([]byte, error) {
+	config := make(map[string]any)
+
+	err := json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to unmarshall ignition config, input ignition is malformed"), err)
+	}
+
+	passwd, ok := config["passwd"].(map[string]any)
+	if !ok {
+		passwd = make(map[string]any)
+	}
+
+	users, _ := passwd["users"].([]any)
+
+	userIndex := slices.IndexFunc(users, func(u any) bool {
+		entry, isMap := u.(map[string]any)
+
+		return isMap && entry["name"] == user
+	})
+	if userIndex < 0 {
+		users = append(users, map[string]any{"name": user})
+		userIndex = len(users) - 1
+	}
+
+	entry, ok := users[userIndex].(map[string]any)
+	if !ok {
+		return nil, errors.New("unable to cast ignition user entry to map[string]any")
+	}
+
+	keys, _ := entry["sshAuthorizedKeys"].([]any)
+	if !slices.Contains(keys, any(publicKey)) {
+		keys = append(keys, publicKey)
+	}
+
+	entry["sshAuthorizedKeys"] = keys
+	passwd["users"] = users
+	config["passwd"] = passwd
+
+	result, err := json.Marshal(config)
+	if err != nil {
+		return nil, errors.Join(errors.New("unable to marshall ignition config"), err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2025 SUSE.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const talosMachineConfig = `version: v1alpha1
+debug: false
+machine:
+  type: worker
+  token: abcdef.0123456789abcdef
+cluster:
+  controlPlane:
+    endpoint: https://172.16.3.10:6443
+---
+apiVersion: v1alpha1
+kind: HostnameConfig
+auto: stable
+`
+
+var _ = Describe("DetectBootstrapFormat", func() {
+	It("should detect cloud-config data with or without the format key", func() {
+		format, err := DetectBootstrapFormat("", "#cloud-config\nruncmd:\n  - echo hello\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal(BootstrapFormatCloudConfig))
+
+		format, err = DetectBootstrapFormat("cloud-config", "runcmd:\n  - echo hello\n")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal(BootstrapFormatCloudConfig))
+	})
+
+	It("should detect a Talos machine config labeled cloud-config", func() {
+		format, err := DetectBootstrapFormat("cloud-config", talosMachineConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal(BootstrapFormatTalos))
+	})
+
+	It("should honour the ignition format key", func() {
+		format, err := DetectBootstrapFormat("ignition", `{"ignition":{"version":"3.4.0"}}`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(format).To(Equal(BootstrapFormatIgnition))
+	})
+
+	It("should reject an unknown format", func() {
+		_, err := DetectBootstrapFormat("shell", "echo hello")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("unsupported bootstrap data format"))
+	})
+})
+
+var _ = Describe("InjectIgnitionSSHKey", func() {
+	ignitionUsers := func(data []byte) []any {
+		config := map[string]any{}
+		Expect(json.Unmarshal(data, &config)).To(Succeed())
+
+		passwd, ok := config["passwd"].(map[string]any)
+		Expect(ok).To(BeTrue())
+
+		users, ok := passwd["users"].([]any)
+		Expect(ok).To(BeTrue())
+
+		return users
+	}
+
+	It("should create the user entry when missing", func() {
+		result, err := InjectIgnitionSSHKey([]byte(`{"ignition":{"version":"3.4.0"}}`), "core", "ssh-rsa AAAA test")
+		Expect(err).ToNot(HaveOccurred())
+
+		users := ignitionUsers(result)
+		Expect(users).To(HaveLen(1))
+		Expect(users[0]).To(HaveKeyWithValue("name", "core"))
+		Expect(users[0]).To(HaveKeyWithValue("sshAuthorizedKeys", []any{"ssh-rsa AAAA test"}))
+	})
+
+	It("should append to the keys of an existing user without duplicates", func() {
+		input := `{"ignition":{"version":"3.4.0"},"passwd":{"users":[` +
+			`{"name":"admin"},{"name":"core","sshAuthorizedKeys":["ssh-ed25519 BBBB other"]}]}}`
+
+		result, err := InjectIgnitionSSHKey([]byte(input), "core", "ssh-rsa AAAA test")
+		Expect(err).ToNot(HaveOccurred())
+
+		result, err = InjectIgnitionSSHKey(result, "core", "ssh-rsa AAAA test")
+		Expect(err).ToNot(HaveOccurred())
+
+		users := ignitionUsers(result)
+		Expect(users).To(HaveLen(2))
+		Expect(users[1]).To(HaveKeyWithValue("sshAuthorizedKeys", []any{"ssh-ed25519 BBBB other", "ssh-rsa AAAA test"}))
+	})
+
+	It("should return an error for malformed JSON", func() {
+		_, err := InjectIgnitionSSHKey([]byte("not json"), "core", "ssh-rsa AAAA test")
+		Expect(err).To(HaveOccurred())
+	})
+})
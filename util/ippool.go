@@ -17,10 +17,10 @@ limitations under the License.
 package util
 
 import (
+	"math"
 	"math/big"
 	"net"
 	"net/netip"
-	"strings"
 
 	"github.com/containernetworking/cni/pkg/types"
 	current "github.com/containernetworking/cni/pkg/types/100"
@@ -28,11 +28,14 @@ import (
 	"github.com/containernetworking/plugins/plugins/ipam/host-local/backend/allocator"
 	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
 	"github.com/pkg/errors"
+
+	corev1 "k8s.io/api/core/v1"
+
+	infrav1 "github.com/rancher-sandbox/cluster-api-provider-harvester/api/v1beta1"
 )
 
 const (
 	initialCapacity = 10
-	p2pMaskStr      = "ffffffff"
 )
 
 // Store implements the backend.Store interface.
@@ -164,9 +167,8 @@ func MakeRange(r *lbv1beta1.Range) (*allocator.Range, error) {
 
 	var defaultStart, defaultEnd, defaultGateway, start, end, gateway net.IP
 
-	mask := ipNet.Mask.String()
-	// If the subnet is a point to point IP
-	if mask == p2pMaskStr {
+	// If the subnet is a point to point IP (/32 for IPv4, /128 for IPv6)
+	if ones, bits := ipNet.Mask.Size(); ones == bits {
 		defaultStart = ip.To16()
 		defaultEnd = ip.To16()
 		defaultGateway = nil
@@ -277,8 +279,14 @@ func lastIP(subnet net.IPNet) net.IP {
 }
 
 // CountIP counts the number of IP addresses in the given range.
+// IPv6 ranges larger than math.MaxInt64 addresses are capped to it.
 func CountIP(r *allocator.Range) int64 {
-	count := big.NewInt(0).Add(big.NewInt(0).Sub(ipToInt(r.RangeEnd), ipToInt(r.RangeStart)), big.NewInt(1)).Int64()
+	size := big.NewInt(0).Add(big.NewInt(0).Sub(ipToInt(r.RangeEnd), ipToInt(r.RangeStart)), big.NewInt(1))
+	if !size.IsInt64() {
+		return math.MaxInt64
+	}
+
+	count := size.Int64()
 
 	if r.Gateway != nil && r.Contains(r.Gateway) {
 		count--
@@ -308,7 +316,7 @@ func PoolMatchesNetworks(pool *lbv1beta1.IPPool, machineNetworks []string) bool
 	}
 
 	for _, network := range machineNetworks {
-		if networkRefsEqual(selector, network) {
+		if infrav1.NetworkRefsEqual(selector, network) {
 			return true
 		}
 	}
@@ -316,29 +324,32 @@ func PoolMatchesNetworks(pool *lbv1beta1.IPPool, machineNetworks []string) bool
 	return false
 }
 
-func networkRefsEqual(a, b string) bool {
-	if a == b {
-		return true
-	}
-
-	aNS, aName := splitNetworkRef(a)
-	bNS, bName := splitNetworkRef(b)
-
-	// An unqualified reference matches on the name alone; two qualified
-	// references must agree on the namespace too.
-	if aNS == "" || bNS == "" {
-		return aName == bName
+// PoolIPFamily returns the IP family of the pool, derived from the subnet of its
+// first range. Pools without a parsable range are reported as IPv4, the only
+// family supported before dual-stack.
+func PoolIPFamily(pool *lbv1beta1.IPPool) corev1.IPFamily {
+	if len(pool.Spec.Ranges) > 0 {
+		if _, ipNet, err := net.ParseCIDR(pool.Spec.Ranges[0].Subnet); err == nil && ipNet.IP.To4() == nil {
+			return corev1.IPv6Protocol
+		}
 	}
 
-	return false
+	return corev1.IPv4Protocol
 }
 
-func splitNetworkRef(ref string) (namespace, name string) {
-	if idx := strings.LastIndex(ref, "/"); idx >= 0 {
-		return ref[:idx], ref[idx+1:]
-	}
+// IPFamilyOf returns the IP family of address, or an empty family when it is not
+// a valid IP address.
+func IPFamilyOf(address string) corev1.IPFamily {
+	ip := net.ParseIP(address)
 
-	return "", ref
+	switch {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return corev1.IPv4Protocol
+	default:
+		return corev1.IPv6Protocol
+	}
 }
 
 // AllocateVMIPFromPool allocates an IP address from the given IPPool for the given machineID.
@@ -388,3 +399,35 @@ func AllocateVMIPFromPool(pool *lbv1beta1.IPPool, machineID string) (string, err
 
 	return ipObj.Address.IP.String(), nil
 }
+
+// AddressPrefixAndGateway returns the prefix length of the subnet and the
+// gateway of the range of pool that contains address. The gateway is empty for
+// point to point ranges, which have none.
+func AddressPrefixAndGateway(pool *lbv1beta1.IPPool, address string) (int32, string, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return 0, "", errors.Errorf("invalid IP %s", address)
+	}
+
+	for i := range pool.Spec.Ranges {
+		r, err := MakeRange(&pool.Spec.Ranges[i])
+		if err != nil {
+			return 0, "", errors.Wrapf(err, "failed to parse range %d", i)
+		}
+
+		if !r.Contains(ip) {
+			continue
+		}
+
+		ones, _ := r.Subnet.Mask.Size()
+
+		gateway := ""
+		if r.Gateway != nil {
+			gateway = r.Gateway.String()
+		}
+
+		return int32(ones), gateway, nil //nolint:gosec // a mask size is at most 128
+	}
+
+	return 0, "", errors.Errorf("IP %s is not in any range of pool %s", address, pool.Name)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package util
 
 import (
+	"math"
 	"math/big"
 	"net"
 
@@ -24,6 +25,8 @@ import (
 	. "github.com/onsi/gomega"
 
 	lbv1beta1 "github.com/harvester/harvester-load-balancer/pkg/apis/loadbalancer.harvesterhci.io/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 var _ = Describe("IP Pool Store", func() {
@@ -243,6 +246,24 @@ var _ = Describe("MakeRange", func() {
 	})
 })
 
+var _ = Describe("MakeRange IPv6", func() {
+	It("should use defaults inside an IPv6 subnet", func() {
+		result, err := MakeRange(&lbv1beta1.Range{Subnet: "2001:db8:1::/120"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RangeStart.String()).To(Equal("2001:db8:1::1"))
+		Expect(result.RangeEnd.String()).To(Equal("2001:db8:1::ff"))
+		Expect(result.Gateway.String()).To(Equal("2001:db8:1::1"))
+	})
+
+	It("should treat a /128 subnet as a single address", func() {
+		result, err := MakeRange(&lbv1beta1.Range{Subnet: "2001:db8:1::40/128"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.RangeStart.String()).To(Equal("2001:db8:1::40"))
+		Expect(result.RangeEnd.String()).To(Equal("2001:db8:1::40"))
+		Expect(result.Gateway).To(BeNil())
+	})
+})
+
 var _ = Describe("CountIP", func() {
 	It("should count IPs in a range excluding gateway", func() {
 		r := &lbv1beta1.Range{
@@ -276,6 +297,12 @@ var _ = Describe("CountIP", func() {
 		Expect(count).To(Equal(int64(9)))
 	})
 
+	It("should cap the count of a large IPv6 range", func() {
+		result, err := MakeRange(&lbv1beta1.Range{Subnet: "2001:db8:1::/56"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(CountIP(result)).To(Equal(int64(math.MaxInt64)))
+	})
+
 	It("should return 1 for a single-IP range", func() {
 		r := &lbv1beta1.Range{
 			Subnet:     "172.16.0.0/16",
@@ -386,6 +413,47 @@ var _ = Describe("AllocateVMIPFromPool", func() {
 	})
 })
 
+var _ = Describe("IPv6 pools", func() {
+	newIPv6Pool := func() *lbv1beta1.IPPool {
+		return &lbv1beta1.IPPool{
+			Spec: lbv1beta1.IPPoolSpec{
+				Ranges: []lbv1beta1.Range{
+					{
+						Subnet:     "2001:db8:1::/64",
+						RangeStart: "2001:db8:1::40",
+						RangeEnd:   "2001:db8:1::49",
+						Gateway:    "2001:db8:1::1",
+					},
+				},
+			},
+			Status: lbv1beta1.IPPoolStatus{Available: 10},
+		}
+	}
+
+	It("should allocate an IPv6 address", func() {
+		pool := newIPv6Pool()
+
+		ip, err := AllocateVMIPFromPool(pool, "machine-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ip).To(HavePrefix("2001:db8:1::4"))
+		Expect(pool.Status.Allocated).To(HaveKeyWithValue(ip, "machine-1"))
+	})
+
+	It("should report the IP family of a pool", func() {
+		Expect(PoolIPFamily(newIPv6Pool())).To(Equal(corev1.IPv6Protocol))
+		Expect(PoolIPFamily(&lbv1beta1.IPPool{
+			Spec: lbv1beta1.IPPoolSpec{Ranges: []lbv1beta1.Range{{Subnet: "172.16.0.0/16"}}},
+		})).To(Equal(corev1.IPv4Protocol))
+		Expect(PoolIPFamily(&lbv1beta1.IPPool{})).To(Equal(corev1.IPv4Protocol))
+	})
+
+	It("should report the IP family of an address", func() {
+		Expect(IPFamilyOf("172.16.3.40")).To(Equal(corev1.IPv4Protocol))
+		Expect(IPFamilyOf("2001:db8:1::40")).To(Equal(corev1.IPv6Protocol))
+		Expect(IPFamilyOf("not-an-ip")).To(BeEmpty())
+	})
+})
+
 var _ = Describe("ipToInt", func() {
 	It("should convert IPv4 address to integer", func() {
 		ip := net.ParseIP("192.168.1.1")
@@ -521,3 +589,43 @@ var _ = Describe("AllocateVMIPFromPool with history", func() {
 		Expect(ip).To(Equal("192.168.1.15"))
 	})
 })
+
+var _ = Describe("AddressPrefixAndGateway", func() {
+	pool := &lbv1beta1.IPPool{
+		Spec: lbv1beta1.IPPoolSpec{
+			Ranges: []lbv1beta1.Range{
+				{Subnet: "192.168.1.0/24", RangeStart: "192.168.1.10", RangeEnd: "192.168.1.20"},
+				{Subnet: "10.0.0.0/16", Gateway: "10.0.0.254"},
+				{Subnet: "2001:db8:1::/64"},
+				{Subnet: "172.16.3.40/32"},
+			},
+		},
+	}
+
+	It("should return the prefix and gateway of the range containing the address", func() {
+		prefix, gateway, err := AddressPrefixAndGateway(pool, "10.0.3.7")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prefix).To(Equal(int32(16)))
+		Expect(gateway).To(Equal("10.0.0.254"))
+	})
+
+	It("should default the gateway to the first address of the subnet", func() {
+		prefix, gateway, err := AddressPrefixAndGateway(pool, "2001:db8:1::40")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prefix).To(Equal(int32(64)))
+		Expect(gateway).To(Equal("2001:db8:1::1"))
+	})
+
+	It("should return no gateway for a point to point range", func() {
+		prefix, gateway, err := AddressPrefixAndGateway(pool, "172.16.3.40")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prefix).To(Equal(int32(32)))
+		Expect(gateway).To(BeEmpty())
+	})
+
+	It("should reject an address outside of the ranges", func() {
+		_, _, err := AddressPrefixAndGateway(pool, "192.168.1.30")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("not in any range"))
+	})
+})